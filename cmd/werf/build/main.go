@@ -9,7 +9,6 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
@@ -66,11 +65,15 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupConfigPath(&commonCmdData, cmd)
 	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
 
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupStagesLocalCache(&commonCmdData, cmd)
+	common.SetupCacheRepos(&commonCmdData, cmd)
+	common.SetupContainerRuntime(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified stages storage, to pull base images")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
@@ -90,6 +93,11 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 
 	common.SetupReportPath(&commonCmdData, cmd)
 	common.SetupReportFormat(&commonCmdData, cmd)
+	common.SetupEventsFile(&commonCmdData, cmd)
+	common.SetupEventsFormat(&commonCmdData, cmd)
+	common.SetupTraceFile(&commonCmdData, cmd)
+	common.SetupTraceFormat(&commonCmdData, cmd)
+	common.SetupExplain(&commonCmdData, cmd)
 
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
@@ -132,6 +140,12 @@ func run(commonCmdData *common.CmdData, imagesToProcess []string) error {
 	}
 	ctx = ctxWithDockerCli
 
+	ctx, writeTrace, err := common.InitTrace(ctx, commonCmdData)
+	if err != nil {
+		return err
+	}
+	defer writeTrace()
+
 	projectDir, err := common.GetProjectDir(commonCmdData)
 	if err != nil {
 		return fmt.Errorf("getting project dir failed: %s", err)
@@ -158,7 +172,10 @@ func run(commonCmdData *common.CmdData, imagesToProcess []string) error {
 	}
 	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
-	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	containerRuntime, err := common.GetContainerRuntime(commonCmdData)
+	if err != nil {
+		return err
+	}
 
 	stagesStorageAddress := common.GetOptionalStagesStorageAddress(commonCmdData)
 	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, commonCmdData)
@@ -184,6 +201,12 @@ func run(commonCmdData *common.CmdData, imagesToProcess []string) error {
 		return err
 	}
 
+	cacheStagesStorageList, err := common.GetCacheStagesStorageList(stagesStorage, containerRuntime, commonCmdData)
+	if err != nil {
+		return err
+	}
+	storageManager.UseCacheStagesStorageList(cacheStagesStorageList, containerRuntime)
+
 	if err := ssh_agent.Init(ctx, *commonCmdData.SSHKeys); err != nil {
 		return fmt.Errorf("cannot initialize ssh agent: %s", err)
 	}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
 
 	"github.com/werf/logboek"
@@ -25,6 +26,7 @@ import (
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/logging"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/trace"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -34,6 +36,7 @@ type CmdData struct {
 	Dir                *string
 	ConfigPath         *string
 	ConfigTemplatesDir *string
+	ConfigVars         *[]string
 	TmpDir             *string
 	HomeDir            *string
 	SSHKeys            *[]string
@@ -61,15 +64,28 @@ type CmdData struct {
 	CommonRepoData *RepoData
 	StagesStorage  *string
 
+	CacheRepos *[]string
+
+	ContainerRuntime *string
+	BuildahIsolation *string
+
+	StagesLocalCache     *bool
+	StagesLocalCacheDir  *string
+	StagesLocalCacheSize *string
+
 	SkipBuild *bool
 	StubTags  *bool
 
-	Synchronization           *string
-	GitHistorySynchronization *bool
-	GitUnshallow              *bool
-	AllowGitShallowClone      *bool
-	Parallel                  *bool
-	ParallelTasksLimit        *int64
+	Synchronization              *string
+	SynchronizationToken         *string
+	SynchronizationTLSCACert     *string
+	SynchronizationTLSClientCert *string
+	SynchronizationTLSClientKey  *string
+	GitHistorySynchronization    *bool
+	GitUnshallow                 *bool
+	AllowGitShallowClone         *bool
+	Parallel                     *bool
+	ParallelTasksLimit           *int64
 
 	DockerConfig          *string
 	InsecureRegistry      *bool
@@ -93,6 +109,14 @@ type CmdData struct {
 	ReportPath   *string
 	ReportFormat *string
 
+	EventsFile   *string
+	EventsFormat *string
+
+	Explain *bool
+
+	TraceFile   *string
+	TraceFormat *string
+
 	VirtualMerge           *bool
 	VirtualMergeFromCommit *string
 	VirtualMergeIntoCommit *string
@@ -132,6 +156,15 @@ func SetupConfigTemplatesDir(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().StringVarP(cmdData.ConfigTemplatesDir, "config-templates-dir", "", os.Getenv("WERF_CONFIG_TEMPLATES_DIR"), `Change to the custom configuration templates directory (default $WERF_CONFIG_TEMPLATES_DIR or .werf in working directory)`)
 }
 
+func SetupConfigVars(cmdData *CmdData, cmd *cobra.Command) {
+	configVars := predefinedValuesByEnvNamePrefix("WERF_CONFIG_VAR")
+
+	cmdData.ConfigVars = &configVars
+	cmd.Flags().StringArrayVarP(cmdData.ConfigVars, "config-var", "", configVars, `Override var defined in the vars directive of werf.yaml meta config section (can specify multiple).
+Format: varName=varValue.
+Also, can be specified with $WERF_CONFIG_VAR* (e.g. $WERF_CONFIG_VAR_1=varName1=varValue1, $WERF_CONFIG_VAR_2=varName2=varValue2)`)
+}
+
 func SetupTmpDir(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.TmpDir = new(string)
 	cmd.Flags().StringVarP(cmdData.TmpDir, "tmp-dir", "", "", "Use specified dir to store tmp files and dirs (default $WERF_TMP_DIR or system tmp dir)")
@@ -170,6 +203,74 @@ func GetReportFormat(cmdData *CmdData) (build.ReportFormat, error) {
 	}
 }
 
+func SetupEventsFile(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.EventsFile = new(string)
+	cmd.Flags().StringVarP(cmdData.EventsFile, "events-file", "", os.Getenv("WERF_EVENTS_FILE"), "Write machine-readable build events: image started, stage digest calculated, stage cache hit or miss, stage build started, finished or failed, stage pushed, error and build finished or failed ($WERF_EVENTS_FILE by default)")
+}
+
+func SetupEventsFormat(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.EventsFormat = new(string)
+
+	defaultValue := os.Getenv("WERF_EVENTS_FORMAT")
+	if defaultValue == "" {
+		defaultValue = string(build.EventsJSONL)
+	}
+	cmd.Flags().StringVarP(cmdData.EventsFormat, "events-format", "", defaultValue, "Events file format (only jsonl available for now, $WERF_EVENTS_FORMAT by default)")
+}
+
+func GetEventsWriter(cmdData *CmdData) (*build.BuildEventsWriter, error) {
+	if cmdData.EventsFile == nil || *cmdData.EventsFile == "" {
+		return nil, nil
+	}
+
+	switch format := build.EventsFormat(*cmdData.EventsFormat); format {
+	case build.EventsJSONL:
+		return build.NewBuildEventsWriter(*cmdData.EventsFile, format)
+	default:
+		return nil, fmt.Errorf("bad --events-format given %q, expected: \"jsonl\"", format)
+	}
+}
+
+func SetupExplain(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.Explain = new(bool)
+	cmd.Flags().BoolVarP(cmdData.Explain, "explain", "", GetBoolEnvironmentDefaultFalse("WERF_EXPLAIN"), `Record stages digest components and print which components have been changed since the previous build of the image on this host: base image, instructions, git commits and stage dependencies checksums, import sources and so on (default $WERF_EXPLAIN)`)
+}
+
+func SetupTraceFile(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.TraceFile = new(string)
+	cmd.Flags().StringVarP(cmdData.TraceFile, "trace-file", "", os.Getenv("WERF_TRACE_FILE"), "Write build timeline with spans for each image, stage, digest calculation, stages storage lookup, lock wait, fetch, build and push ($WERF_TRACE_FILE by default)")
+}
+
+func SetupTraceFormat(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.TraceFormat = new(string)
+
+	defaultValue := os.Getenv("WERF_TRACE_FORMAT")
+	if defaultValue == "" {
+		defaultValue = "chrome"
+	}
+	cmd.Flags().StringVarP(cmdData.TraceFormat, "trace-format", "", defaultValue, "Trace file format (only chrome available for now: Chrome trace event JSON, which can be opened with chrome://tracing or https://ui.perfetto.dev, $WERF_TRACE_FORMAT by default)")
+}
+
+// InitTrace returns context with the tracer when --trace-file is specified and the function which writes collected spans into the trace file
+func InitTrace(ctx context.Context, cmdData *CmdData) (context.Context, func(), error) {
+	if cmdData.TraceFile == nil || *cmdData.TraceFile == "" {
+		return ctx, func() {}, nil
+	}
+
+	if *cmdData.TraceFormat != "chrome" {
+		return nil, nil, fmt.Errorf("bad --trace-format given %q, expected: \"chrome\"", *cmdData.TraceFormat)
+	}
+
+	tracer := trace.NewTracer()
+	writeTrace := func() {
+		if err := tracer.WriteChromeTraceFile(*cmdData.TraceFile); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to write trace file: %s\n", err)
+		}
+	}
+
+	return trace.NewContext(ctx, tracer), writeTrace, nil
+}
+
 func SetupWithoutKube(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.WithoutKube = new(bool)
 	cmd.Flags().BoolVarP(cmdData.WithoutKube, "without-kube", "", GetBoolEnvironmentDefaultFalse("WERF_WITHOUT_KUBE"), "Do not skip deployed Kubernetes images (default $WERF_WITHOUT_KUBE)")
@@ -269,6 +370,8 @@ func SetupCommonRepoData(cmdData *CmdData, cmd *cobra.Command) {
 	SetupHarborUsernameForRepoData(cmdData.CommonRepoData, cmd, "repo-harbor-username", []string{"WERF_REPO_HARBOR_USERNAME"})
 	SetupHarborPasswordForRepoData(cmdData.CommonRepoData, cmd, "repo-harbor-password", []string{"WERF_REPO_HARBOR_PASSWORD"})
 	SetupQuayTokenForRepoData(cmdData.CommonRepoData, cmd, "repo-quay-token", []string{"WERF_REPO_QUAY_TOKEN"})
+	SetupS3EndpointForRepoData(cmdData.CommonRepoData, cmd, "repo-s3-endpoint", []string{"WERF_REPO_S3_ENDPOINT"})
+	SetupS3RegionForRepoData(cmdData.CommonRepoData, cmd, "repo-s3-region", []string{"WERF_REPO_S3_REGION"})
 }
 
 func SetupStagesStorageOptions(cmdData *CmdData, cmd *cobra.Command) {
@@ -280,7 +383,44 @@ func SetupStagesStorageOptions(cmdData *CmdData, cmd *cobra.Command) {
 
 func setupStagesStorage(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.StagesStorage = new(string)
-	cmd.Flags().StringVarP(cmdData.StagesStorage, "repo", "", os.Getenv("WERF_REPO"), fmt.Sprintf("Docker Repo to store stages (default $WERF_REPO)"))
+	cmd.Flags().StringVarP(cmdData.StagesStorage, "repo", "", os.Getenv("WERF_REPO"), fmt.Sprintf("Docker Repo or s3://BUCKET[/PREFIX] address of S3-compatible bucket to store stages (default $WERF_REPO)"))
+}
+
+func SetupCacheRepos(cmdData *CmdData, cmd *cobra.Command) {
+	cacheRepos := predefinedValuesByEnvNamePrefix("WERF_CACHE_REPO")
+
+	cmdData.CacheRepos = &cacheRepos
+	cmd.Flags().StringArrayVarP(cmdData.CacheRepos, "cache-repo", "", cacheRepos, `Docker Repo or s3://BUCKET[/PREFIX] address of the read-only stages storage to look up stages which are not found in the --repo (can specify multiple).
+Found stages are copied into the --repo, cache repos are never modified.
+Also, can be specified with $WERF_CACHE_REPO* (e.g. $WERF_CACHE_REPO_1=registry.company.io/shared-cache, $WERF_CACHE_REPO_2=registry.company.io/project/main)`)
+}
+
+func SetupContainerRuntime(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.ContainerRuntime = new(string)
+	cmdData.BuildahIsolation = new(string)
+
+	defaultContainerRuntime := os.Getenv("WERF_CONTAINER_RUNTIME")
+	if defaultContainerRuntime == "" {
+		defaultContainerRuntime = "docker"
+	}
+	cmd.Flags().StringVarP(cmdData.ContainerRuntime, "container-runtime", "", defaultContainerRuntime, `Container runtime to build stapel stages: docker or buildah (default $WERF_CONTAINER_RUNTIME or docker).
+Buildah runtime does not require docker server, it supports stapel images with shell instructions and docker registry --repo only`)
+	cmd.Flags().StringVarP(cmdData.BuildahIsolation, "buildah-isolation", "", os.Getenv("WERF_BUILDAH_ISOLATION"), "Isolation type of buildah stage containers: oci, rootless or chroot (default $WERF_BUILDAH_ISOLATION or buildah default)")
+}
+
+func SetupStagesLocalCache(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.StagesLocalCache = new(bool)
+	cmdData.StagesLocalCacheDir = new(string)
+	cmdData.StagesLocalCacheSize = new(string)
+
+	cmd.Flags().BoolVarP(cmdData.StagesLocalCache, "stages-local-cache", "", GetBoolEnvironmentDefaultFalse("WERF_STAGES_LOCAL_CACHE"), "Keep archives of fetched and stored stages in the local cache directory to skip pulls from the stages storage in the next runs (default $WERF_STAGES_LOCAL_CACHE)")
+	cmd.Flags().StringVarP(cmdData.StagesLocalCacheDir, "stages-local-cache-dir", "", os.Getenv("WERF_STAGES_LOCAL_CACHE_DIR"), "Use specified dir for the local stages cache (default $WERF_STAGES_LOCAL_CACHE_DIR or ~/.werf/local_cache/stages/1)")
+
+	defaultSize := os.Getenv("WERF_STAGES_LOCAL_CACHE_SIZE")
+	if defaultSize == "" {
+		defaultSize = "10GiB"
+	}
+	cmd.Flags().StringVarP(cmdData.StagesLocalCacheSize, "stages-local-cache-size", "", defaultSize, "Local stages cache size limit, least recently used stages are evicted when exceeded (default $WERF_STAGES_LOCAL_CACHE_SIZE or 10GiB)")
 }
 
 func SetupStatusProgressPeriod(cmdData *CmdData, cmd *cobra.Command) {
@@ -770,7 +910,7 @@ func GetStagesStorage(stagesStorageAddress string, containerRuntime container_ru
 		return nil, err
 	}
 
-	return storage.NewStagesStorage(
+	stagesStorage, err := storage.NewStagesStorage(
 		stagesStorageAddress,
 		containerRuntime,
 		storage.StagesStorageOptions{
@@ -788,8 +928,71 @@ func GetStagesStorage(stagesStorageAddress string, containerRuntime container_ru
 					QuayToken:             *cmdData.CommonRepoData.QuayToken,
 				},
 			},
+			S3StagesStorageOptions: storage.S3StagesStorageOptions{
+				Endpoint: *cmdData.CommonRepoData.S3Endpoint,
+				Region:   *cmdData.CommonRepoData.S3Region,
+			},
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmdData.StagesLocalCache != nil && *cmdData.StagesLocalCache && stagesStorageAddress != storage.LocalStorageAddress {
+		if _, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime); !ok {
+			return nil, fmt.Errorf("--stages-local-cache is not supported by %s container runtime", containerRuntime.String())
+		}
+
+		maxSize, err := units.RAMInBytes(*cmdData.StagesLocalCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("bad --stages-local-cache-size value %q: %s", *cmdData.StagesLocalCacheSize, err)
+		}
+
+		return storage.NewLocalCacheStagesStorage(stagesStorage, containerRuntime, storage.LocalCacheStagesStorageOptions{
+			Dir:     *cmdData.StagesLocalCacheDir,
+			MaxSize: maxSize,
+		}), nil
+	}
+
+	return stagesStorage, nil
+}
+
+func GetContainerRuntime(cmdData *CmdData) (container_runtime.ContainerRuntime, error) {
+	if cmdData.ContainerRuntime == nil {
+		return &container_runtime.LocalDockerServerRuntime{}, nil
+	}
+
+	switch *cmdData.ContainerRuntime {
+	case "docker":
+		return &container_runtime.LocalDockerServerRuntime{}, nil
+	case "buildah":
+		return container_runtime.NewBuildahRuntime(*cmdData.BuildahIsolation)
+	default:
+		return nil, fmt.Errorf("bad --container-runtime value %q: docker or buildah expected", *cmdData.ContainerRuntime)
+	}
+}
+
+// GetCacheStagesStorageList returns read-only stages storages specified by --cache-repo params
+func GetCacheStagesStorageList(stagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime, cmdData *CmdData) ([]storage.StagesStorage, error) {
+	if cmdData.CacheRepos == nil {
+		return nil, nil
+	}
+
+	var res []storage.StagesStorage
+	for _, address := range *cmdData.CacheRepos {
+		if address == stagesStorage.Address() {
+			return nil, fmt.Errorf("cache repo %s should differ from the --repo", address)
+		}
+
+		cacheStagesStorage, err := GetStagesStorage(address, containerRuntime, cmdData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to init cache repo %s: %s", address, err)
+		}
+
+		res = append(res, cacheStagesStorage)
+	}
+
+	return res, nil
 }
 
 func GetOptionalWerfConfig(ctx context.Context, projectDir string, cmdData *CmdData, logRenderedFilePath bool) (*config.WerfConfig, error) {
@@ -800,7 +1003,7 @@ func GetOptionalWerfConfig(ctx context.Context, projectDir string, cmdData *CmdD
 
 	if werfConfigPath != "" {
 		werfConfigTemplatesDir := GetWerfConfigTemplatesDir(projectDir, cmdData)
-		return config.GetWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, logRenderedFilePath)
+		return config.GetWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, GetWerfConfigOptions(cmdData, logRenderedFilePath))
 	}
 
 	return nil, nil
@@ -814,7 +1017,25 @@ func GetRequiredWerfConfig(ctx context.Context, projectDir string, cmdData *CmdD
 
 	werfConfigTemplatesDir := GetWerfConfigTemplatesDir(projectDir, cmdData)
 
-	return config.GetWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, logRenderedFilePath)
+	return config.GetWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, GetWerfConfigOptions(cmdData, logRenderedFilePath))
+}
+
+// GetWerfConfigOptions returns werf.yaml reading options: $WERF_ENV is used for commands without --env option,
+// so vars of werf.yaml are resolved the same way by all commands
+func GetWerfConfigOptions(cmdData *CmdData, logRenderedFilePath bool) config.WerfConfigOptions {
+	opts := config.WerfConfigOptions{LogRenderedFilePath: logRenderedFilePath}
+
+	if cmdData.Environment != nil {
+		opts.Env = *cmdData.Environment
+	} else {
+		opts.Env = os.Getenv("WERF_ENV")
+	}
+
+	if cmdData.ConfigVars != nil {
+		opts.ConfigVars = *cmdData.ConfigVars
+	}
+
+	return opts
 }
 
 func GetWerfConfigPath(projectDir string, cmdData *CmdData, required bool) (string, error) {
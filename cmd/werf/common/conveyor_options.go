@@ -46,6 +46,11 @@ func GetBuildOptions(commonCmdData *CmdData, werfConfig *config.WerfConfig) (bui
 		return buildOptions, err
 	}
 
+	eventsWriter, err := GetEventsWriter(commonCmdData)
+	if err != nil {
+		return buildOptions, err
+	}
+
 	buildOptions = build.BuildOptions{
 		ImageBuildOptions: container_runtime.BuildOptions{
 			IntrospectAfterError:  *commonCmdData.IntrospectAfterError,
@@ -54,6 +59,11 @@ func GetBuildOptions(commonCmdData *CmdData, werfConfig *config.WerfConfig) (bui
 		IntrospectOptions: introspectOptions,
 		ReportPath:        *commonCmdData.ReportPath,
 		ReportFormat:      reportFormat,
+		EventsWriter:      eventsWriter,
+	}
+
+	if commonCmdData.Explain != nil {
+		buildOptions.Explain = *commonCmdData.Explain
 	}
 
 	return buildOptions, nil
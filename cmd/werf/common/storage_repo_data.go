@@ -22,6 +22,8 @@ type RepoData struct {
 	HarborUsername    *string
 	HarborPassword    *string
 	QuayToken         *string
+	S3Endpoint        *string
+	S3Region          *string
 }
 
 func MergeRepoData(repoDataArr ...*RepoData) *RepoData {
@@ -52,6 +54,12 @@ func MergeRepoData(repoDataArr ...*RepoData) *RepoData {
 		if res.QuayToken == nil || *res.QuayToken == "" {
 			res.QuayToken = repoData.QuayToken
 		}
+		if res.S3Endpoint == nil || *res.S3Endpoint == "" {
+			res.S3Endpoint = repoData.S3Endpoint
+		}
+		if res.S3Region == nil || *res.S3Region == "" {
+			res.S3Region = repoData.S3Region
+		}
 	}
 
 	return res
@@ -207,6 +215,42 @@ func SetupQuayTokenForRepoData(repoData *RepoData, cmd *cobra.Command, paramName
 	)
 }
 
+func SetupS3EndpointForRepoData(repoData *RepoData, cmd *cobra.Command, paramName string, paramEnvNames []string) {
+	var usage string
+	if repoData.IsCommon {
+		usage = fmt.Sprintf("Endpoint of S3-compatible service for s3://BUCKET[/PREFIX] repo, e.g. http://localhost:9000 for MinIO (default AWS S3 or %s)", strings.Join(getParamEnvNamesForUsageDescription(paramEnvNames), ", "))
+	} else {
+		usage = fmt.Sprintf("Endpoint of S3-compatible service for %s (default AWS S3 or %s)", repoData.DesignationStorageName, strings.Join(getParamEnvNamesForUsageDescription(paramEnvNames), ", "))
+	}
+
+	repoData.S3Endpoint = new(string)
+	cmd.Flags().StringVarP(
+		repoData.S3Endpoint,
+		paramName,
+		"",
+		getDefaultValueByParamEnvNames(paramEnvNames),
+		usage,
+	)
+}
+
+func SetupS3RegionForRepoData(repoData *RepoData, cmd *cobra.Command, paramName string, paramEnvNames []string) {
+	var usage string
+	if repoData.IsCommon {
+		usage = fmt.Sprintf("Region of S3 bucket for s3://BUCKET[/PREFIX] repo (default $AWS_REGION or %s)", strings.Join(getParamEnvNamesForUsageDescription(paramEnvNames), ", "))
+	} else {
+		usage = fmt.Sprintf("Region of S3 bucket for %s (default $AWS_REGION or %s)", repoData.DesignationStorageName, strings.Join(getParamEnvNamesForUsageDescription(paramEnvNames), ", "))
+	}
+
+	repoData.S3Region = new(string)
+	cmd.Flags().StringVarP(
+		repoData.S3Region,
+		paramName,
+		"",
+		getDefaultValueByParamEnvNames(paramEnvNames),
+		usage,
+	)
+}
+
 func getDefaultValueByParamEnvNames(paramEnvNames []string) string {
 	var defaultValue string
 	for _, paramEnvName := range paramEnvNames {
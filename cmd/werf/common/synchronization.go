@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
@@ -31,6 +32,16 @@ func SetupSynchronization(cmdData *CmdData, cmd *cobra.Command) {
 	defaultValue := os.Getenv("WERF_SYNCHRONIZATION")
 
 	cmd.Flags().StringVarP(cmdData.Synchronization, "synchronization", "S", defaultValue, fmt.Sprintf("Address of synchronizer for multiple werf processes to work with a single stages storage (default :local if --stages-storage=:local or %s if non-local stages-storage specified or $WERF_SYNCHRONIZATION if set). The same address should be specified for all werf processes that work with a single stages storage. :local address allows execution of werf processes from a single host only.", storage.DefaultKubernetesStorageAddress))
+
+	cmdData.SynchronizationToken = new(string)
+	cmdData.SynchronizationTLSCACert = new(string)
+	cmdData.SynchronizationTLSClientCert = new(string)
+	cmdData.SynchronizationTLSClientKey = new(string)
+
+	cmd.Flags().StringVarP(cmdData.SynchronizationToken, "synchronization-token", "", os.Getenv("WERF_SYNCHRONIZATION_TOKEN"), "Bearer token to authenticate in the http synchronization server (default $WERF_SYNCHRONIZATION_TOKEN)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationTLSCACert, "synchronization-tls-ca-cert", "", os.Getenv("WERF_SYNCHRONIZATION_TLS_CA_CERT"), "Path to the CA certificate to verify the https synchronization server certificate instead of system CA certificates (default $WERF_SYNCHRONIZATION_TLS_CA_CERT)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationTLSClientCert, "synchronization-tls-client-cert", "", os.Getenv("WERF_SYNCHRONIZATION_TLS_CLIENT_CERT"), "Path to the client certificate to authenticate in the https synchronization server with mutual TLS (default $WERF_SYNCHRONIZATION_TLS_CLIENT_CERT)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationTLSClientKey, "synchronization-tls-client-key", "", os.Getenv("WERF_SYNCHRONIZATION_TLS_CLIENT_KEY"), "Path to the client certificate key to authenticate in the https synchronization server with mutual TLS (default $WERF_SYNCHRONIZATION_TLS_CLIENT_KEY)")
 }
 
 type SynchronizationType string
@@ -45,6 +56,7 @@ type SynchronizationParams struct {
 	Address             string
 	SynchronizationType SynchronizationType
 	KubeParams          *storage.KubernetesSynchronizationParams
+	HttpClient          *http.Client
 }
 
 func checkSynchronizationKubernetesParamsForWarnings(cmdData *CmdData) {
@@ -128,10 +140,20 @@ func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName strin
 	}
 
 	getHttpParamsFunc := func(synchronization string, stagesStorage storage.StagesStorage) (*SynchronizationParams, error) {
+		httpClient, err := synchronization_server.NewHttpClient(synchronization_server.HttpClientOptions{
+			Token:             *cmdData.SynchronizationToken,
+			TLSCACertFile:     *cmdData.SynchronizationTLSCACert,
+			TLSClientCertFile: *cmdData.SynchronizationTLSClientCert,
+			TLSClientKeyFile:  *cmdData.SynchronizationTLSClientKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create http client for synchronization server %s: %s", synchronization, err)
+		}
+
 		var address string
 		if err := logboek.Default().LogProcess(fmt.Sprintf("Getting client id for the http syncrhonization server")).
 			DoError(func() error {
-				if clientID, err := synchronization_server.GetOrCreateClientID(ctx, projectName, synchronization_server.NewSynchronizationClient(synchronization), stagesStorage); err != nil {
+				if clientID, err := synchronization_server.GetOrCreateClientID(ctx, projectName, synchronization_server.NewSynchronizationClient(synchronization, httpClient), stagesStorage); err != nil {
 					return fmt.Errorf("unable to get synchronization client id: %s", err)
 				} else {
 					address = fmt.Sprintf("%s/%s", synchronization, clientID)
@@ -142,7 +164,7 @@ func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName strin
 			return nil, err
 		}
 
-		return &SynchronizationParams{Address: address, SynchronizationType: HttpSynchronization}, nil
+		return &SynchronizationParams{Address: address, SynchronizationType: HttpSynchronization, HttpClient: httpClient}, nil
 	}
 
 	if *cmdData.Synchronization == "" {
@@ -182,7 +204,7 @@ func GetStagesStorageCache(synchronization *SynchronizationParams) (storage.Stag
 			}), nil
 		}
 	case HttpSynchronization:
-		return synchronization_server.NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/stages-storage-cache", synchronization.Address)), nil
+		return synchronization_server.NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/stages-storage-cache", synchronization.Address), synchronization.HttpClient), nil
 	default:
 		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
 	}
@@ -209,7 +231,9 @@ func GetStorageLockManager(ctx context.Context, synchronization *Synchronization
 			}), nil
 		}
 	case HttpSynchronization:
-		locker := distributed_locker.NewHttpLocker(fmt.Sprintf("%s/locker", synchronization.Address))
+		backend := distributed_locker.NewHttpBackend(fmt.Sprintf("%s/locker", synchronization.Address))
+		backend.HttpClient = synchronization.HttpClient
+		locker := distributed_locker.NewDistributedLocker(backend)
 		lockerWithRetry := locker_with_retry.NewLockerWithRetry(ctx, locker, locker_with_retry.LockerWithRetryOptions{MaxAcquireAttempts: 10, MaxReleaseAttempts: 10})
 		return storage.NewGenericLockManager(lockerWithRetry), nil
 	default:
@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+var cmdData struct {
+	OutputFormat string
+}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "graph",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print dependency graph of images, artifacts and git mappings defined in werf.yaml",
+		Long: common.GetLongCommandDescription(`Print dependency graph of images, artifacts and git mappings defined in werf.yaml.
+
+An edge from A to B means that B uses A as fromImage, fromArtifact or imports files from A, so B is built only after A. Images which do not depend on each other are built concurrently.
+
+Import edges are labeled with imported paths and the user stage of the import. Git mappings are shown as separate nodes with their stageDependencies.`),
+		Example: `  # Render the graph with Graphviz
+  $ werf config graph | dot -Tsvg > graph.svg
+
+  # Print the graph as a Mermaid flowchart
+  $ werf config graph --output-format=mermaid
+
+  # Print the graph as JSON
+  $ werf config graph --output-format=json | jq '.edges[] | select(.type == "import")'`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	defaultOutputFormat := os.Getenv("WERF_OUTPUT_FORMAT")
+	if defaultOutputFormat == "" {
+		defaultOutputFormat = "dot"
+	}
+	cmd.Flags().StringVarP(&cmdData.OutputFormat, "output-format", "", defaultOutputFormat, "Output format: dot, mermaid or json (default $WERF_OUTPUT_FORMAT or dot)")
+
+	return cmd
+}
+
+func run() error {
+	switch cmdData.OutputFormat {
+	case "dot", "mermaid", "json":
+	default:
+		return fmt.Errorf("bad --output-format value %q: dot, mermaid or json expected", cmdData.OutputFormat)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	projectDir, err := common.GetProjectDir(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	werfConfig, err := common.GetRequiredWerfConfig(common.BackgroundContext(), projectDir, &commonCmdData, false)
+	if err != nil {
+		return err
+	}
+
+	graph := werfConfig.GetGraph()
+
+	switch cmdData.OutputFormat {
+	case "mermaid":
+		fmt.Print(graph.Mermaid())
+	case "json":
+		data, err := graph.JSON()
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(data)
+	default:
+		fmt.Print(graph.DOT())
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "schema",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print JSON Schema of werf.yaml",
+		Long: common.GetLongCommandDescription(`Print JSON Schema of werf.yaml documents.
+
+The schema can be used by editors for werf.yaml autocompletion and validation, e.g. with yaml-language-server:
+
+  werf config schema > werf.schema.json
+  # yaml-language-server: $schema=werf.schema.json`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := config.GetWerfConfigJsonSchemaJson()
+			if err != nil {
+				return fmt.Errorf("unable to generate JSON Schema: %s", err)
+			}
+
+			fmt.Println(string(data))
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,59 @@
+package update_includes_lock
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "update-includes-lock",
+		DisableFlagsInUseLine: true,
+		Short:                 "Update werf-includes.lock",
+		Long: common.GetLongCommandDescription(`Update werf-includes.lock.
+
+Resolve the latest commits of git includes specified by branch or tag in the meta config section of werf.yaml and write them into the werf-includes.lock file next to werf.yaml. The lock file is removed when there are no such includes.
+
+Other werf commands only read the lock file and fail when it does not pin commits of all git includes specified by branch or tag or has records of unused includes.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := common.BackgroundContext()
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+				return fmt.Errorf("initialization error: %s", err)
+			}
+
+			projectDir, err := common.GetProjectDir(&commonCmdData)
+			if err != nil {
+				return fmt.Errorf("getting project dir failed: %s", err)
+			}
+
+			werfConfigPath, err := common.GetWerfConfigPath(projectDir, &commonCmdData, true)
+			if err != nil {
+				return err
+			}
+
+			return config.UpdateIncludesLock(ctx, werfConfigPath)
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
@@ -0,0 +1,78 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "validate",
+		DisableFlagsInUseLine: true,
+		Short:                 "Validate werf.yaml",
+		Long: common.GetLongCommandDescription(`Validate werf.yaml.
+
+Rendered werf.yaml documents are validated by JSON Schema (see werf config schema) and werf config parser. All found problems are reported at once with the line of the rendered config.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := common.BackgroundContext()
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+				return fmt.Errorf("initialization error: %s", err)
+			}
+
+			projectDir, err := common.GetProjectDir(&commonCmdData)
+			if err != nil {
+				return fmt.Errorf("getting project dir failed: %s", err)
+			}
+
+			werfConfigPath, err := common.GetWerfConfigPath(projectDir, &commonCmdData, true)
+			if err != nil {
+				return err
+			}
+
+			werfConfigTemplatesDir := common.GetWerfConfigTemplatesDir(projectDir, &commonCmdData)
+
+			validationErrors, err := config.ValidateWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, common.GetWerfConfigOptions(&commonCmdData, true))
+			if err != nil {
+				return err
+			}
+
+			if len(validationErrors) == 0 {
+				logboek.Context(ctx).LogLn("werf.yaml is valid")
+				return nil
+			}
+
+			for _, validationErr := range validationErrors {
+				fmt.Println(validationErr.Error())
+			}
+
+			return fmt.Errorf("werf.yaml is not valid: %d problem(s) found", len(validationErrors))
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
@@ -25,7 +25,6 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/ssh_agent"
@@ -80,6 +79,7 @@ werf converge --stages-storage registry.mydomain.com/web/back/stages --images-re
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupConfigPath(&commonCmdData, cmd)
 	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
@@ -89,6 +89,9 @@ werf converge --stages-storage registry.mydomain.com/web/back/stages --images-re
 	common.SetupIntrospectStage(&commonCmdData, cmd)
 
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupStagesLocalCache(&commonCmdData, cmd)
+	common.SetupCacheRepos(&commonCmdData, cmd)
+	common.SetupContainerRuntime(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified stages storage, to push images into the specified images repo, to pull base images")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
@@ -122,6 +125,10 @@ werf converge --stages-storage registry.mydomain.com/web/back/stages --images-re
 
 	common.SetupReportPath(&commonCmdData, cmd)
 	common.SetupReportFormat(&commonCmdData, cmd)
+	common.SetupEventsFile(&commonCmdData, cmd)
+	common.SetupEventsFormat(&commonCmdData, cmd)
+	common.SetupTraceFile(&commonCmdData, cmd)
+	common.SetupTraceFormat(&commonCmdData, cmd)
 
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
@@ -170,6 +177,12 @@ func runConverge() error {
 	}
 	ctx = ctxWithDockerCli
 
+	ctx, writeTrace, err := common.InitTrace(ctx, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	defer writeTrace()
+
 	projectDir, err := common.GetProjectDir(&commonCmdData)
 	if err != nil {
 		return fmt.Errorf("getting project dir failed: %s", err)
@@ -252,7 +265,10 @@ func runConverge() error {
 		if err != nil {
 			return err
 		}
-		containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+		containerRuntime, err := common.GetContainerRuntime(&commonCmdData)
+		if err != nil {
+			return err
+		}
 		stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
 		if err != nil {
 			return err
@@ -275,6 +291,12 @@ func runConverge() error {
 			return err
 		}
 
+		cacheStagesStorageList, err := common.GetCacheStagesStorageList(stagesStorage, containerRuntime, &commonCmdData)
+		if err != nil {
+			return err
+		}
+		storageManager.UseCacheStagesStorageList(cacheStagesStorageList, containerRuntime)
+
 		imagesRepository = storageManager.StagesStorage.String()
 
 		conveyorOptions, err := common.GetConveyorOptionsWithParallel(&commonCmdData, buildOptions)
@@ -296,7 +318,11 @@ func runConverge() error {
 				}
 			}
 
-			imagesInfoGetters = c.GetImageInfoGetters()
+			if getters, err := c.GetImageInfoGetters(); err != nil {
+				return err
+			} else {
+				imagesInfoGetters = getters
+			}
 
 			return nil
 		}); err != nil {
@@ -60,6 +60,7 @@ werf converge --stages-storage registry.mydomain.com/web/back/stages --images-re
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupConfigPath(&commonCmdData, cmd)
 	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
@@ -220,7 +221,11 @@ func runDiff() error {
 			return err
 		}
 
-		imagesInfoGetters = c.GetImageInfoGetters()
+		if getters, err := c.GetImageInfoGetters(); err != nil {
+			return err
+		} else {
+			imagesInfoGetters = getters
+		}
 
 		return nil
 	}); err != nil {
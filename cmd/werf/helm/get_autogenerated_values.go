@@ -49,6 +49,7 @@ These values includes project name, docker images ids and other`),
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupConfigPath(&commonCmdData, cmd)
 	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
@@ -188,7 +189,11 @@ func runGetServiceValues() error {
 			}
 
 			imagesRepository = storageManager.StagesStorage.String()
-			imagesInfoGetters = c.GetImageInfoGetters()
+			if getters, err := c.GetImageInfoGetters(); err != nil {
+				return err
+			} else {
+				imagesInfoGetters = getters
+			}
 
 			return nil
 		}); err != nil {
@@ -29,6 +29,7 @@ func NewGetNamespaceCmd() *cobra.Command {
 	common.SetupDir(&getNamespaceCmdData, cmd)
 	common.SetupConfigPath(&getNamespaceCmdData, cmd)
 	common.SetupConfigTemplatesDir(&getNamespaceCmdData, cmd)
+	common.SetupConfigVars(&getNamespaceCmdData, cmd)
 	common.SetupTmpDir(&getNamespaceCmdData, cmd)
 	common.SetupHomeDir(&getNamespaceCmdData, cmd)
 	common.SetupEnvironment(&getNamespaceCmdData, cmd)
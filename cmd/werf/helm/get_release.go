@@ -29,6 +29,7 @@ func NewGetReleaseCmd() *cobra.Command {
 	common.SetupDir(&getReleaseCmdData, cmd)
 	common.SetupConfigPath(&getReleaseCmdData, cmd)
 	common.SetupConfigTemplatesDir(&getReleaseCmdData, cmd)
+	common.SetupConfigVars(&getReleaseCmdData, cmd)
 	common.SetupTmpDir(&getReleaseCmdData, cmd)
 	common.SetupHomeDir(&getReleaseCmdData, cmd)
 	common.SetupEnvironment(&getReleaseCmdData, cmd)
@@ -32,13 +32,24 @@ import (
 	managed_images_ls "github.com/werf/werf/cmd/werf/managed_images/ls"
 	managed_images_rm "github.com/werf/werf/cmd/werf/managed_images/rm"
 
+	stages_export "github.com/werf/werf/cmd/werf/stages/export"
+	stages_fsck "github.com/werf/werf/cmd/werf/stages/fsck"
+	stages_import "github.com/werf/werf/cmd/werf/stages/import"
+	stages_inspect "github.com/werf/werf/cmd/werf/stages/inspect"
+	stages_ls "github.com/werf/werf/cmd/werf/stages/ls"
+	stages_sync "github.com/werf/werf/cmd/werf/stages/sync"
+
 	host_cleanup "github.com/werf/werf/cmd/werf/host/cleanup"
 	host_project_list "github.com/werf/werf/cmd/werf/host/project/list"
 	host_project_purge "github.com/werf/werf/cmd/werf/host/project/purge"
 	host_purge "github.com/werf/werf/cmd/werf/host/purge"
 
+	config_graph "github.com/werf/werf/cmd/werf/config/graph"
 	config_list "github.com/werf/werf/cmd/werf/config/list"
 	config_render "github.com/werf/werf/cmd/werf/config/render"
+	config_schema "github.com/werf/werf/cmd/werf/config/schema"
+	config_update_includes_lock "github.com/werf/werf/cmd/werf/config/update_includes_lock"
+	config_validate "github.com/werf/werf/cmd/werf/config/validate"
 
 	"github.com/werf/werf/cmd/werf/completion"
 	"github.com/werf/werf/cmd/werf/docs"
@@ -110,6 +121,7 @@ Find more information at https://werf.io`),
 			Commands: []*cobra.Command{
 				configCmd(),
 				managedImagesCmd(),
+				stagesCmd(),
 				hostCmd(),
 				helm.NewCmd(),
 			},
@@ -139,6 +151,10 @@ func configCmd() *cobra.Command {
 	cmd.AddCommand(
 		config_render.NewCmd(),
 		config_list.NewCmd(),
+		config_graph.NewCmd(),
+		config_validate.NewCmd(),
+		config_schema.NewCmd(),
+		config_update_includes_lock.NewCmd(),
 	)
 
 	return cmd
@@ -158,6 +174,23 @@ func managedImagesCmd() *cobra.Command {
 	return cmd
 }
 
+func stagesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stages",
+		Short: "Work with stages, which are stored in the stages storage",
+	}
+	cmd.AddCommand(
+		stages_ls.NewCmd(),
+		stages_inspect.NewCmd(),
+		stages_sync.NewCmd(),
+		stages_export.NewCmd(),
+		stages_import.NewCmd(),
+		stages_fsck.NewCmd(),
+	)
+
+	return cmd
+}
+
 func hostCmd() *cobra.Command {
 	hostCmd := &cobra.Command{
 		Use:   "host",
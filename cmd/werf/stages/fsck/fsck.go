@@ -0,0 +1,228 @@
+package fsck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	OutputFormat string
+	Repair       bool
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "fsck",
+		DisableFlagsInUseLine: true,
+		Short:                 "Check stages storage consistency",
+		Long: common.GetLongCommandDescription(`Check consistency of the project stages storage.
+
+werf walks all project stages, images metadata and client ID records and reports:
+* images metadata which points to missing stages;
+* stages with broken parent chain: stages with werf git or import labels whose parent stage or import source stage is missing;
+* stages with unreadable labels or labels which do not match the project and the stage digest;
+* duplicate client ID records.
+
+Broken parent chain detection is a heuristic: the parent of the first stage of an image is a base image which is not stored in the stages storage, so stages built on top of images published by werf may also be reported. Stages with broken parent chain are reported only and never repaired, delete them manually after checking.
+
+With --repair option werf removes images metadata which points to missing stages, deletes stages with inconsistent labels along with images metadata which points to them (stages will be rebuilt by the next build) and removes all client ID records except the oldest one. Stages which description cannot be read are reported only.
+
+Command exits with an error when found problems are not repaired`),
+		Example: `  # Check the stages storage
+  $ werf stages fsck --repo=registry.company.io/project
+
+  # Show what will be repaired without changing the stages storage
+  $ werf stages fsck --repo=registry.company.io/project --repair --dry-run
+
+  # Repair found problems
+  $ werf stages fsck --repo=registry.company.io/project --repair`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupProjectName(&commonCmdData, cmd)
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and delete images from the specified stages storage")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupParallelTasksLimit(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+	common.SetupDryRun(&commonCmdData, cmd)
+
+	defaultOutputFormat := os.Getenv("WERF_OUTPUT_FORMAT")
+	if defaultOutputFormat == "" {
+		defaultOutputFormat = "table"
+	}
+	cmd.Flags().StringVarP(&cmdData.OutputFormat, "output-format", "", defaultOutputFormat, "Output format: table or json (default $WERF_OUTPUT_FORMAT or table)")
+
+	cmd.Flags().BoolVarP(&cmdData.Repair, "repair", "", common.GetBoolEnvironmentDefaultFalse("WERF_REPAIR"), "Repair found problems (default $WERF_REPAIR)")
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if cmdData.OutputFormat != "table" && cmdData.OutputFormat != "json" {
+		return fmt.Errorf("bad --output-format value %q: table or json expected", cmdData.OutputFormat)
+	}
+
+	parallelTasksLimit, err := common.GetParallelTasksLimit(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting parallel tasks limit failed: %s", err)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	projectDir, err := common.GetProjectDir(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	werfConfig, err := common.GetOptionalWerfConfig(ctx, projectDir, &commonCmdData, false)
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	var projectName string
+	if werfConfig != nil {
+		projectName = werfConfig.Meta.Project
+	} else if *commonCmdData.ProjectName != "" {
+		projectName = *commonCmdData.ProjectName
+	} else {
+		return fmt.Errorf("run command in the project directory with werf.yaml or specify --project-name=PROJECT_NAME param")
+	}
+
+	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
+	if err != nil {
+		return err
+	}
+	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	if err != nil {
+		return err
+	}
+	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return err
+	}
+
+	var report *manager.StagesFsckReport
+	if err := logboek.Context(ctx).LogProcess("Checking %s", stagesStorage.String()).DoError(func() error {
+		report, err = manager.FsckStages(ctx, projectName, stagesStorage, stagesStorageCache, storageLockManager, manager.FsckStagesOptions{
+			Repair:             cmdData.Repair,
+			DryRun:             *commonCmdData.DryRun,
+			MaxNumberOfWorkers: int(parallelTasksLimit),
+		})
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if cmdData.OutputFormat == "json" {
+		if report.Problems == nil {
+			report.Problems = []*manager.StagesFsckProblem{}
+		}
+
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		t := uitable.New()
+		t.MaxColWidth = uint(logboek.Streams().ContentWidth())
+		t.Wrap = true
+		t.AddRow("PROBLEM", "SUBJECT", "DESCRIPTION", "STATUS")
+		for _, problem := range report.Problems {
+			status := "found"
+			switch {
+			case problem.Repaired:
+				status = "repaired"
+			case !problem.Repairable:
+				status = "not repairable"
+			}
+			t.AddRow(problem.Type, problem.Subject, problem.Description, status)
+		}
+		fmt.Println(t.String())
+		fmt.Println()
+		fmt.Printf("Stages: %d, problems: %d\n", report.StagesCount, len(report.Problems))
+	}
+
+	if count := report.UnrepairedProblemsCount(); count > 0 {
+		return fmt.Errorf("%d problems are not repaired", count)
+	}
+
+	return nil
+}
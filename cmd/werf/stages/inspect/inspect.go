@@ -0,0 +1,236 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	OutputFormat string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "inspect DIGEST-UNIQUEID",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print stage labels, stages chain and git commits info",
+		Long:                  common.GetLongCommandDescription(`Print labels of the stage from the stages storage, the chain of parent stages back to the base image and git commits info recorded into the stage labels for each git mapping`),
+		Example: `  # Inspect the stage in the registry
+  $ werf stages inspect --repo=registry.company.io/project 4a3a2eb0d7c8c2e6ef0a8bfcc3a1f5e9c2e9a3b6b5d3a8c0f1d4e3e2-1601234567890`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if len(args) != 1 {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("DIGEST-UNIQUEID param required")
+			}
+
+			return run(args[0])
+		},
+	}
+
+	common.SetupProjectName(&commonCmdData, cmd)
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read images from the specified stages storage")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupParallelTasksLimit(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+
+	defaultOutputFormat := os.Getenv("WERF_OUTPUT_FORMAT")
+	if defaultOutputFormat == "" {
+		defaultOutputFormat = "text"
+	}
+	cmd.Flags().StringVarP(&cmdData.OutputFormat, "output-format", "", defaultOutputFormat, "Output format: text or json (default $WERF_OUTPUT_FORMAT or text)")
+
+	return cmd
+}
+
+func run(stageIDParam string) error {
+	ctx := common.BackgroundContext()
+
+	if cmdData.OutputFormat != "text" && cmdData.OutputFormat != "json" {
+		return fmt.Errorf("bad --output-format value %q: text or json expected", cmdData.OutputFormat)
+	}
+
+	stageID, err := manager.ParseStageID(stageIDParam)
+	if err != nil {
+		return err
+	}
+
+	parallelTasksLimit, err := common.GetParallelTasksLimit(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting parallel tasks limit failed: %s", err)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	projectDir, err := common.GetProjectDir(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	werfConfig, err := common.GetOptionalWerfConfig(ctx, projectDir, &commonCmdData, false)
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	var projectName string
+	if werfConfig != nil {
+		projectName = werfConfig.Meta.Project
+	} else if *commonCmdData.ProjectName != "" {
+		projectName = *commonCmdData.ProjectName
+	} else {
+		return fmt.Errorf("run command in the project directory with werf.yaml or specify --project-name=PROJECT_NAME param")
+	}
+
+	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	var info *manager.StageInspectInfo
+	if err := logboek.Context(ctx).Info().LogProcess("Getting stages from %s", stagesStorage.String()).DoError(func() error {
+		info, err = manager.InspectStage(ctx, projectName, stagesStorage, stageID, int(parallelTasksLimit))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if cmdData.OutputFormat == "json" {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+
+		return nil
+	}
+
+	printStageInspectInfo(info)
+
+	return nil
+}
+
+func printStageInspectInfo(info *manager.StageInspectInfo) {
+	fmt.Printf("Stage ID:   %s\n", info.StageID)
+	fmt.Printf("Image:      %s\n", info.ImageName)
+	fmt.Printf("Image ID:   %s\n", info.ImageID)
+	fmt.Printf("Created:    %s (%s ago)\n", info.CreatedAt.Format(time.RFC3339), units.HumanDuration(time.Since(info.CreatedAt)))
+	fmt.Printf("Size:       %s\n", units.HumanSize(float64(info.Size)))
+
+	fmt.Println()
+	fmt.Println("Used by:")
+	if len(info.UsedBy) == 0 {
+		fmt.Println("  -")
+	}
+	for _, usage := range info.UsedBy {
+		imageName := usage.ImageName
+		if imageName == "" {
+			imageName = "~"
+		}
+		fmt.Printf("  %s: %s\n", imageName, strings.Join(usage.Commits, ", "))
+	}
+
+	fmt.Println()
+	fmt.Println("Stages chain:")
+	for _, item := range info.Chain {
+		if item.StageID == "" {
+			fmt.Printf("  %s (base image)\n", item.ImageID)
+		} else {
+			fmt.Printf("  %s %s\n", item.StageID, item.ImageName)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Git commits:")
+	if len(info.GitCommits) == 0 {
+		fmt.Println("  -")
+	}
+	for _, commitInfo := range info.GitCommits {
+		fmt.Printf("  git mapping %s: commit %s", commitInfo.ParamsHash, commitInfo.Commit)
+		if commitInfo.VirtualMerge {
+			fmt.Printf(" (virtual merge of %s into %s)", commitInfo.VirtualMergeFromCommit, commitInfo.VirtualMergeIntoCommit)
+		}
+		fmt.Println()
+	}
+
+	var labels []string
+	for label := range info.Labels {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	fmt.Println()
+	fmt.Println("Labels:")
+	for _, label := range labels {
+		fmt.Printf("  %s=%s\n", label, info.Labels[label])
+	}
+}
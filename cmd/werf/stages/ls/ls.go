@@ -0,0 +1,285 @@
+package ls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	OutputFormat    string
+	Duplicates      bool
+	MergeDuplicates bool
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "ls",
+		DisableFlagsInUseLine: true,
+		Short:                 "List stages from the stages storage",
+		Long: common.GetLongCommandDescription(`List project stages from the stages storage.
+
+For each stage werf prints stage ID, digest, creation time, size, parent stage and werf images with commits which use the stage according to the images metadata.
+
+With --duplicates option werf compares layers of stages through the docker registry API and prints groups of stages with the same content: stages with equal content digest or stages which add identical layers. The report shows how much registry space is used by layers of duplicate stages.
+
+With --merge-duplicates option werf moves images metadata of duplicate stages to the oldest stage of the group and deletes duplicates, which are not used by other stages as parent or import source. Only duplicates with identical layers and the same stage digest as the oldest stage are merged, other duplicates are kept`),
+		Example: `  # List stages of the project in the registry
+  $ werf stages ls --repo=registry.company.io/project
+
+  # List stages in JSON
+  $ werf stages ls --repo=registry.company.io/project --output-format=json
+
+  # Show duplicate stages report
+  $ werf stages ls --repo=registry.company.io/project --duplicates
+
+  # Show which duplicate stages will be merged without merging
+  $ werf stages ls --repo=registry.company.io/project --merge-duplicates --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupProjectName(&commonCmdData, cmd)
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read images from the specified stages storage")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupParallelTasksLimit(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+	common.SetupDryRun(&commonCmdData, cmd)
+
+	defaultOutputFormat := os.Getenv("WERF_OUTPUT_FORMAT")
+	if defaultOutputFormat == "" {
+		defaultOutputFormat = "table"
+	}
+	cmd.Flags().StringVarP(&cmdData.OutputFormat, "output-format", "", defaultOutputFormat, "Output format: table or json (default $WERF_OUTPUT_FORMAT or table)")
+
+	cmd.Flags().BoolVarP(&cmdData.Duplicates, "duplicates", "", common.GetBoolEnvironmentDefaultFalse("WERF_DUPLICATES"), "Print report about stages with duplicate content instead of stages list (default $WERF_DUPLICATES)")
+	cmd.Flags().BoolVarP(&cmdData.MergeDuplicates, "merge-duplicates", "", common.GetBoolEnvironmentDefaultFalse("WERF_MERGE_DUPLICATES"), "Merge stages with duplicate content and print report (default $WERF_MERGE_DUPLICATES)")
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if cmdData.OutputFormat != "table" && cmdData.OutputFormat != "json" {
+		return fmt.Errorf("bad --output-format value %q: table or json expected", cmdData.OutputFormat)
+	}
+
+	parallelTasksLimit, err := common.GetParallelTasksLimit(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting parallel tasks limit failed: %s", err)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	projectDir, err := common.GetProjectDir(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	werfConfig, err := common.GetOptionalWerfConfig(ctx, projectDir, &commonCmdData, false)
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	var projectName string
+	if werfConfig != nil {
+		projectName = werfConfig.Meta.Project
+	} else if *commonCmdData.ProjectName != "" {
+		projectName = *commonCmdData.ProjectName
+	} else {
+		return fmt.Errorf("run command in the project directory with werf.yaml or specify --project-name=PROJECT_NAME param")
+	}
+
+	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if cmdData.Duplicates || cmdData.MergeDuplicates {
+		return runDuplicates(ctx, projectName, stagesStorage, int(parallelTasksLimit))
+	}
+
+	var stages []*manager.StageListItem
+	if err := logboek.Context(ctx).Info().LogProcess("Getting stages from %s", stagesStorage.String()).DoError(func() error {
+		stages, err = manager.ListStages(ctx, projectName, stagesStorage, int(parallelTasksLimit))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if cmdData.OutputFormat == "json" {
+		if stages == nil {
+			stages = []*manager.StageListItem{}
+		}
+		return printJson(stages)
+	}
+
+	t := uitable.New()
+	t.MaxColWidth = uint(logboek.Streams().ContentWidth())
+	t.AddRow("STAGE ID", "CREATED", "SIZE", "PARENT", "USED BY")
+	for _, stage := range stages {
+		parent := stage.ParentStageID
+		if parent == "" {
+			parent = "-"
+		}
+
+		var usedBy []string
+		for _, usage := range stage.UsedBy {
+			imageName := usage.ImageName
+			if imageName == "" {
+				imageName = "~"
+			}
+			usedBy = append(usedBy, fmt.Sprintf("%s@%s", imageName, strings.Join(usage.Commits, ",")))
+		}
+		if len(usedBy) == 0 {
+			usedBy = []string{"-"}
+		}
+
+		created := units.HumanDuration(time.Since(stage.CreatedAt)) + " ago"
+		t.AddRow(stage.StageID, created, units.HumanSize(float64(stage.Size)), parent, strings.Join(usedBy, " "))
+	}
+	fmt.Println(t.String())
+
+	return nil
+}
+
+func runDuplicates(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, parallelTasksLimit int) error {
+	var report *manager.StagesDedupReport
+
+	if cmdData.MergeDuplicates {
+		synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
+		if err != nil {
+			return err
+		}
+		stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+		if err != nil {
+			return err
+		}
+		storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+		if err != nil {
+			return err
+		}
+
+		if err := logboek.Context(ctx).LogProcess("Merging duplicate stages in %s", stagesStorage.String()).DoError(func() error {
+			report, err = manager.MergeDuplicateStages(ctx, projectName, stagesStorage, stagesStorageCache, storageLockManager, manager.MergeDuplicateStagesOptions{
+				DryRun:             *commonCmdData.DryRun,
+				MaxNumberOfWorkers: parallelTasksLimit,
+			})
+			return err
+		}); err != nil {
+			return err
+		}
+	} else {
+		if err := logboek.Context(ctx).Info().LogProcess("Comparing stages layers in %s", stagesStorage.String()).DoError(func() error {
+			var err error
+			report, err = manager.GetStagesDedupReport(ctx, projectName, stagesStorage, parallelTasksLimit)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if cmdData.OutputFormat == "json" {
+		if report.Groups == nil {
+			report.Groups = []*manager.DuplicateStagesGroup{}
+		}
+		return printJson(report)
+	}
+
+	t := uitable.New()
+	t.MaxColWidth = uint(logboek.Streams().ContentWidth())
+	t.AddRow("CANONICAL STAGE", "DUPLICATE STAGES", "IDENTICAL LAYERS", "WASTED SIZE")
+	for _, group := range report.Groups {
+		t.AddRow(group.CanonicalStage, strings.Join(group.DuplicateStages, " "), group.IdenticalLayers, units.HumanSize(float64(group.WastedSize)))
+	}
+	fmt.Println(t.String())
+	fmt.Println()
+	fmt.Printf("Stages: %d, layers: %d\n", report.StagesCount, report.LayersCount)
+	fmt.Printf("Referenced size: %s, stored size: %s, wasted by duplicates: %s\n", units.HumanSize(float64(report.ReferencedSize)), units.HumanSize(float64(report.StoredSize)), units.HumanSize(float64(report.WastedSize)))
+
+	return nil
+}
+
+func printJson(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+
+	return nil
+}
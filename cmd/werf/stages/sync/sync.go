@@ -0,0 +1,192 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	From   string
+	To     string
+	MaxAge string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync [IMAGE_NAME...]",
+		Short: "Copy stages, managed images and images metadata between stages storages",
+		Example: `  # Copy all project stages from the local stages storage into the registry
+  $ werf stages sync --from=:local --to=registry.company.io/project
+
+  # Copy stages of images 'backend' and 'frontend' created during the last week between registries
+  $ werf stages sync --from=registry-a.company.io/project --to=registry-b.company.io/project --max-age=168h backend frontend
+
+  # Show which stages will be copied without copying
+  $ werf stages sync --from=registry.company.io/project --to=s3://bucket/project --dry-run`,
+		Long: common.GetLongCommandDescription(`Copy stages, managed images and images metadata of the project from one stages storage to another.
+
+Only stages which do not exist in the destination stages storage are copied, so sync can be repeated to copy newly built stages.
+
+If one or more IMAGE_NAME parameters specified, werf will copy only stages of these images (including parent and imported stages) referenced by images metadata of the source stages storage`),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer werf.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return runSync(args)
+			})
+		},
+	}
+
+	common.SetupProjectName(&commonCmdData, cmd)
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigVars(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupCommonRepoData(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and pull images from the source stages storage and to push images into the destination stages storage")
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupParallelTasksLimit(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+	common.SetupDryRun(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.From, "from", "", os.Getenv("WERF_FROM"), "Source stages storage: :local, docker repo or s3://BUCKET[/PREFIX] address (default $WERF_FROM)")
+	cmd.Flags().StringVarP(&cmdData.To, "to", "", os.Getenv("WERF_TO"), "Destination stages storage: :local, docker repo or s3://BUCKET[/PREFIX] address (default $WERF_TO)")
+	cmd.Flags().StringVarP(&cmdData.MaxAge, "max-age", "", os.Getenv("WERF_MAX_AGE"), "Copy only stages created not earlier than specified duration ago along with their parent and imported stages regardless of their age, for example 72h (default $WERF_MAX_AGE)")
+
+	return cmd
+}
+
+func runSync(imageNameList []string) error {
+	ctx := common.BackgroundContext()
+
+	if cmdData.From == "" || cmdData.To == "" {
+		return fmt.Errorf("--from=ADDRESS and --to=ADDRESS params required")
+	}
+
+	if cmdData.From == cmdData.To {
+		return fmt.Errorf("source and destination stages storages should differ, got %q", cmdData.From)
+	}
+
+	var maxAge time.Duration
+	if cmdData.MaxAge != "" {
+		if d, err := time.ParseDuration(cmdData.MaxAge); err != nil {
+			return fmt.Errorf("bad --max-age value %q: %s", cmdData.MaxAge, err)
+		} else {
+			maxAge = d
+		}
+	}
+
+	parallelTasksLimit, err := common.GetParallelTasksLimit(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting parallel tasks limit failed: %s", err)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	projectDir, err := common.GetProjectDir(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	werfConfig, err := common.GetOptionalWerfConfig(ctx, projectDir, &commonCmdData, false)
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	var projectName string
+	if werfConfig != nil {
+		projectName = werfConfig.Meta.Project
+	} else if *commonCmdData.ProjectName != "" {
+		projectName = *commonCmdData.ProjectName
+	} else {
+		return fmt.Errorf("run command in the project directory with werf.yaml or specify --project-name=PROJECT_NAME param")
+	}
+
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	fromStagesStorage, err := common.GetStagesStorage(cmdData.From, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	toStagesStorage, err := common.GetStagesStorage(cmdData.To, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, toStagesStorage)
+	if err != nil {
+		return err
+	}
+	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return err
+	}
+
+	return manager.SyncStages(ctx, projectName, fromStagesStorage, toStagesStorage, storageLockManager, containerRuntime, manager.SyncStagesOptions{
+		CleanupLocalCache:  true,
+		DryRun:             *commonCmdData.DryRun,
+		ImageNameList:      imageNameList,
+		MaxAge:             maxAge,
+		MaxNumberOfWorkers: int(parallelTasksLimit),
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+
+	"github.com/go-redis/redis"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -31,9 +34,20 @@ var cmdData struct {
 	LocalLockManagerBaseDir        string
 	LocalStagesStorageCacheBaseDir string
 
+	Redis          bool
+	RedisAddress   string
+	RedisPassword  string
+	RedisDB        int
+	RedisKeyPrefix string
+
 	TTL  string
 	Host string
 	Port string
+
+	Token           string
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
 }
 
 var commonCmdData common.CmdData
@@ -75,10 +89,21 @@ func NewCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&cmdData.Kubernetes, "kubernetes", "", common.GetBoolEnvironmentDefaultFalse("WERF_KUBERNETES"), "Use kubernetes lock-manager stages-storage-cache (default $WERF_KUBERNETES)")
 	cmd.Flags().StringVarP(&cmdData.KubernetesNamespacePrefix, "kubernetes-namespace-prefix", "", os.Getenv("WERF_KUBERNETES_NAMESPACE_PREFIX"), "Use specified prefix for namespaces created for lock-manager and stages-storage-cache (defaults to 'werf-synchronization-' when --kubernetes option is used or $WERF_KUBERNETES_NAMESPACE_PREFIX)")
 
+	cmd.Flags().BoolVarP(&cmdData.Redis, "redis", "", common.GetBoolEnvironmentDefaultFalse("WERF_REDIS"), "Use redis lock-manager and stages-storage-cache, which allows running multiple synchronization server replicas, cannot be used with --kubernetes (default $WERF_REDIS)")
+	cmd.Flags().StringVarP(&cmdData.RedisAddress, "redis-address", "", os.Getenv("WERF_REDIS_ADDRESS"), "Use specified redis server address HOST:PORT (default localhost:6379 or $WERF_REDIS_ADDRESS)")
+	cmd.Flags().StringVarP(&cmdData.RedisPassword, "redis-password", "", os.Getenv("WERF_REDIS_PASSWORD"), "Use specified password to connect to the redis server (default $WERF_REDIS_PASSWORD)")
+	cmd.Flags().IntVarP(&cmdData.RedisDB, "redis-db", "", getIntEnvironment("WERF_REDIS_DB"), "Use specified redis database number (default 0 or $WERF_REDIS_DB)")
+	cmd.Flags().StringVarP(&cmdData.RedisKeyPrefix, "redis-key-prefix", "", os.Getenv("WERF_REDIS_KEY_PREFIX"), "Use specified prefix for all redis keys of lock-manager and stages-storage-cache (default werf-synchronization or $WERF_REDIS_KEY_PREFIX)")
+
 	cmd.Flags().StringVarP(&cmdData.TTL, "ttl", "", os.Getenv("WERF_TTL"), "Time to live for lock-manager locks and stages-storage-cache records (default $WERF_TTL)")
 	cmd.Flags().StringVarP(&cmdData.Host, "host", "", os.Getenv("WERF_HOST"), "Bind synchronization server to the specified host (default localhost or $WERF_HOST)")
 	cmd.Flags().StringVarP(&cmdData.Port, "port", "", os.Getenv("WERF_PORT"), "Bind synchronization server to the specified port (default 55581 or $WERF_PORT)")
 
+	cmd.Flags().StringVarP(&cmdData.Token, "token", "", os.Getenv("WERF_TOKEN"), "Require clients to authenticate with the specified bearer token, health check requests are not authenticated (default $WERF_TOKEN)")
+	cmd.Flags().StringVarP(&cmdData.TLSCertFile, "tls-cert", "", os.Getenv("WERF_TLS_CERT"), "Serve https using specified certificate file, requires --tls-key (default $WERF_TLS_CERT)")
+	cmd.Flags().StringVarP(&cmdData.TLSKeyFile, "tls-key", "", os.Getenv("WERF_TLS_KEY"), "Serve https using specified certificate key file, requires --tls-cert (default $WERF_TLS_KEY)")
+	cmd.Flags().StringVarP(&cmdData.TLSClientCAFile, "tls-client-ca-cert", "", os.Getenv("WERF_TLS_CLIENT_CA_CERT"), "Require clients to authenticate with certificates signed by the specified CA certificate (mutual TLS), requires --tls-cert and --tls-key (default $WERF_TLS_CLIENT_CA_CERT)")
+
 	return cmd
 }
 
@@ -97,10 +122,41 @@ func runSynchronization() error {
 		port = "55581"
 	}
 
+	if cmdData.Redis && cmdData.Kubernetes {
+		return fmt.Errorf("--redis option cannot be used with --kubernetes option")
+	}
+
 	var distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error)
 	var stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error)
 
-	if cmdData.Kubernetes {
+	if cmdData.Redis {
+		address := cmdData.RedisAddress
+		if address == "" {
+			address = "localhost:6379"
+		}
+
+		keyPrefix := cmdData.RedisKeyPrefix
+		if keyPrefix == "" {
+			keyPrefix = "werf-synchronization"
+		}
+
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     address,
+			Password: cmdData.RedisPassword,
+			DB:       cmdData.RedisDB,
+		})
+		if err := redisClient.Ping().Err(); err != nil {
+			return fmt.Errorf("unable to connect to redis server %s: %s", address, err)
+		}
+
+		distributedLockerBackendFactoryFunc = func(clientID string) (distributed_locker.DistributedLockerBackend, error) {
+			return storage.NewRedisDistributedLockerBackend(redisClient, fmt.Sprintf("%s:%s", keyPrefix, clientID)), nil
+		}
+
+		stagesStorageCacheFactoryFunc = func(clientID string) (storage.StagesStorageCache, error) {
+			return storage.NewRedisStagesStorageCache(redisClient, fmt.Sprintf("%s:%s", keyPrefix, clientID)), nil
+		}
+	} else if cmdData.Kubernetes {
 		if err := kube.Init(kube.InitOptions{kube.KubeConfigOptions{
 			Context:          *commonCmdData.KubeContext,
 			ConfigPath:       *commonCmdData.KubeConfig,
@@ -152,5 +208,21 @@ func runSynchronization() error {
 		}
 	}
 
-	return synchronization_server.RunSynchronizationServer(ctx, host, port, distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc)
+	return synchronization_server.RunSynchronizationServer(ctx, host, port, distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc, synchronization_server.SynchronizationServerOptions{
+		Token:           cmdData.Token,
+		TLSCertFile:     cmdData.TLSCertFile,
+		TLSKeyFile:      cmdData.TLSKeyFile,
+		TLSClientCAFile: cmdData.TLSClientCAFile,
+	})
+}
+
+func getIntEnvironment(environmentName string) int {
+	if envValue := os.Getenv(environmentName); envValue != "" {
+		v, err := strconv.Atoi(envValue)
+		if err != nil {
+			common.TerminateWithError(fmt.Sprintf("bad %s value: %s", environmentName, err), 1)
+		}
+		return v
+	}
+	return 0
 }
@@ -0,0 +1,110 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	EventsJSONL EventsFormat = "jsonl"
+)
+
+type EventsFormat string
+
+type BuildEventType string
+
+const (
+	ImageStartedEvent          BuildEventType = "image-started"
+	StageDigestCalculatedEvent BuildEventType = "stage-digest-calculated"
+	StageCacheHitEvent         BuildEventType = "stage-cache-hit"
+	StageCacheMissEvent        BuildEventType = "stage-cache-miss"
+	StageBuildStartedEvent     BuildEventType = "stage-build-started"
+	StageBuildFinishedEvent    BuildEventType = "stage-build-finished"
+	StageBuildFailedEvent      BuildEventType = "stage-build-failed"
+	StagePushedEvent           BuildEventType = "stage-pushed"
+	ErrorEvent                 BuildEventType = "error"
+	BuildFinishedEvent         BuildEventType = "build-finished"
+	BuildFailedEvent           BuildEventType = "build-failed"
+)
+
+type BuildEvent struct {
+	Type            BuildEventType `json:"type"`
+	Time            time.Time      `json:"time"`
+	ImageName       string         `json:"imageName,omitempty"`
+	StageName       string         `json:"stageName,omitempty"`
+	Digest          string         `json:"digest,omitempty"`
+	StageID         string         `json:"stageID,omitempty"`
+	DurationSeconds float64        `json:"durationSeconds,omitempty"`
+	Size            int64          `json:"size,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// NewBuildResultEvent creates the terminal event of the build: build-finished or build-failed with the error
+func NewBuildResultEvent(startedAt time.Time, err error) BuildEvent {
+	event := BuildEvent{
+		Type:            BuildFinishedEvent,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+	}
+
+	if err != nil {
+		event.Type = BuildFailedEvent
+		event.Error = err.Error()
+	}
+
+	return event
+}
+
+// BuildEventsWriter appends build events to the events file, one JSON object per line.
+// Nil writer discards events.
+type BuildEventsWriter struct {
+	mux    sync.Mutex
+	Path   string
+	Format EventsFormat
+}
+
+// NewBuildEventsWriter creates or truncates the events file
+func NewBuildEventsWriter(path string, format EventsFormat) (*BuildEventsWriter, error) {
+	if format != EventsJSONL {
+		return nil, fmt.Errorf("unsupported events format %q", format)
+	}
+
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		return nil, fmt.Errorf("unable to create events file %s: %s", path, err)
+	}
+
+	return &BuildEventsWriter{Path: path, Format: format}, nil
+}
+
+func (writer *BuildEventsWriter) Write(event BuildEvent) error {
+	if writer == nil {
+		return nil
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	writer.mux.Lock()
+	defer writer.mux.Unlock()
+
+	f, err := os.OpenFile(writer.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
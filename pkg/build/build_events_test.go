@@ -0,0 +1,125 @@
+package build
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readBuildEvents(t *testing.T, path string) []BuildEvent {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []BuildEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event BuildEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("unable to unmarshal event %q: %s", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+func TestBuildEventsWriter(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-build-events-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "events.jsonl")
+	if err := ioutil.WriteFile(path, []byte("stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewBuildEventsWriter(path, EventsJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buildStartedAt := time.Now()
+	buildErr := errors.New("failed to build image for stage install")
+	for _, event := range []BuildEvent{
+		{Type: ImageStartedEvent, ImageName: "app"},
+		{Type: StageBuildStartedEvent, ImageName: "app", StageName: "install", Digest: "digest"},
+		{Type: StageBuildFailedEvent, ImageName: "app", StageName: "install", Digest: "digest", Error: buildErr.Error()},
+		{Type: ErrorEvent, ImageName: "app", StageName: "install", Digest: "digest", Error: buildErr.Error()},
+		NewBuildResultEvent(buildStartedAt, buildErr),
+	} {
+		if err := writer.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := readBuildEvents(t, path)
+
+	expectedTypes := []BuildEventType{ImageStartedEvent, StageBuildStartedEvent, StageBuildFailedEvent, ErrorEvent, BuildFailedEvent}
+	if len(events) != len(expectedTypes) {
+		t.Fatalf("expected %d events, got %d: %#v", len(expectedTypes), len(events), events)
+	}
+	for i, event := range events {
+		if event.Type != expectedTypes[i] {
+			t.Errorf("expected event %d type %q, got %q", i, expectedTypes[i], event.Type)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("expected event %d time to be set", i)
+		}
+	}
+
+	if last := events[len(events)-1]; last.Error != buildErr.Error() {
+		t.Errorf("expected terminal event error %q, got %q", buildErr.Error(), last.Error)
+	}
+}
+
+func TestNilBuildEventsWriter(t *testing.T) {
+	var writer *BuildEventsWriter
+	if err := writer.Write(BuildEvent{Type: ImageStartedEvent}); err != nil {
+		t.Fatalf("expected nil writer to discard events, got error: %s", err)
+	}
+}
+
+func TestNewBuildEventsWriterUnsupportedFormat(t *testing.T) {
+	if _, err := NewBuildEventsWriter(filepath.Join(os.TempDir(), "werf-build-events-test.json"), "json"); err == nil {
+		t.Fatal("expected unsupported format error")
+	}
+}
+
+func TestNewBuildResultEvent(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		err           error
+		expectedType  BuildEventType
+		expectedError string
+	}{
+		{name: "finished", err: nil, expectedType: BuildFinishedEvent},
+		{name: "failed", err: errors.New("stages required"), expectedType: BuildFailedEvent, expectedError: "stages required"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			event := NewBuildResultEvent(time.Now().Add(-time.Second), test.err)
+
+			if event.Type != test.expectedType {
+				t.Errorf("expected type %q, got %q", test.expectedType, event.Type)
+			}
+			if event.Error != test.expectedError {
+				t.Errorf("expected error %q, got %q", test.expectedError, event.Error)
+			}
+			if event.DurationSeconds < 1 {
+				t.Errorf("expected duration of at least 1 second, got %f", event.DurationSeconds)
+			}
+		})
+	}
+}
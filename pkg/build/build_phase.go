@@ -20,10 +20,13 @@ import (
 	"github.com/werf/logboek/pkg/types"
 
 	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
 	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/logging"
 	"github.com/werf/werf/pkg/stapel"
+	"github.com/werf/werf/pkg/trace"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -40,6 +43,12 @@ type BuildOptions struct {
 	ReportPath   string
 	ReportFormat ReportFormat
 
+	// EventsWriter receives structured build events, events are not written when nil
+	EventsWriter *BuildEventsWriter
+
+	// Explain enables recording of stages digest components and printing of changes since the previous build of the image
+	Explain bool
+
 	DryRun bool
 }
 
@@ -80,6 +89,8 @@ type BuildPhase struct {
 	ImagesReport *ImagesReport
 	ReportPath   string
 	ReportFormat ReportFormat
+
+	digestExplanations *digestExplanations
 }
 
 const (
@@ -117,11 +128,60 @@ func (phase *BuildPhase) Name() string {
 }
 
 func (phase *BuildPhase) BeforeImages(_ context.Context) error {
+	if phase.Explain {
+		explanations, err := loadDigestExplanations(phase.Conveyor.projectName())
+		if err != nil {
+			return fmt.Errorf("unable to load stages digest explanations: %s", err)
+		}
+		phase.digestExplanations = explanations
+	}
+
 	return nil
 }
 
 func (phase *BuildPhase) AfterImages(ctx context.Context) error {
-	return phase.createReport(ctx)
+	if !phase.ShouldBeBuiltMode {
+		if err := phase.publishManifestLists(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := phase.createReport(ctx); err != nil {
+		return err
+	}
+
+	if phase.digestExplanations != nil {
+		if err := phase.digestExplanations.Save(); err != nil {
+			return fmt.Errorf("unable to save stages digest explanations: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// publishManifestLists publishes the manifest list for each image built for multiple platforms
+func (phase *BuildPhase) publishManifestLists(ctx context.Context) error {
+	processedImages := map[string]bool{}
+	for _, img := range phase.Conveyor.images {
+		if img.isArtifact || processedImages[img.GetName()] {
+			continue
+		}
+		processedImages[img.GetName()] = true
+
+		platformImages := phase.Conveyor.getManifestListPlatformImages(img.GetName())
+		if len(platformImages) == 0 {
+			continue
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Publishing manifest list for %s", logging.ImageLogProcessName(img.GetName(), false)).
+			DoError(func() error {
+				return phase.Conveyor.StorageManager.StagesStorage.StoreManifestList(ctx, phase.Conveyor.projectName(), platformImages)
+			}); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (phase *BuildPhase) createReport(ctx context.Context) error {
@@ -130,6 +190,21 @@ func (phase *BuildPhase) createReport(ctx context.Context) error {
 			continue
 		}
 
+		if platformImages := phase.Conveyor.getManifestListPlatformImages(img.GetName()); len(platformImages) != 0 {
+			manifestListName, err := phase.Conveyor.StorageManager.StagesStorage.ConstructManifestListImageName(phase.Conveyor.projectName(), platformImages)
+			if err != nil {
+				return err
+			}
+
+			repository, tag := image.ParseRepositoryAndTag(manifestListName)
+			phase.ImagesReport.SetImageRecord(img.GetName(), ReportImageRecord{
+				WerfImageName: manifestListName,
+				DockerRepo:    repository,
+				DockerTag:     tag,
+			})
+			continue
+		}
+
 		desc := img.GetLastNonEmptyStage().GetImage().GetStageDescription()
 		phase.ImagesReport.SetImageRecord(img.GetName(), ReportImageRecord{
 			WerfImageName: desc.Info.Name,
@@ -158,14 +233,31 @@ func (phase *BuildPhase) ImageProcessingShouldBeStopped(_ context.Context, img *
 	return false
 }
 
-func (phase *BuildPhase) BeforeImageStages(_ context.Context, img *Image) error {
+func (phase *BuildPhase) BeforeImageStages(ctx context.Context, img *Image) error {
 	phase.StagesIterator = NewStagesIterator(phase.Conveyor)
 
 	img.SetupBaseImage(phase.Conveyor)
 
+	phase.writeEvent(ctx, BuildEvent{Type: ImageStartedEvent, ImageName: img.GetName()})
+
 	return nil
 }
 
+func (phase *BuildPhase) writeEvent(ctx context.Context, event BuildEvent) {
+	if err := phase.EventsWriter.Write(event); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Unable to write build event %s to %s: %s\n", event.Type, phase.EventsWriter.Path, err)
+	}
+}
+
+func (phase *BuildPhase) newStageEvent(eventType BuildEventType, img *Image, stg stage.Interface) BuildEvent {
+	return BuildEvent{
+		Type:      eventType,
+		ImageName: img.GetName(),
+		StageName: string(stg.Name()),
+		Digest:    stg.GetDigest(),
+	}
+}
+
 func (phase *BuildPhase) AfterImageStages(ctx context.Context, img *Image) error {
 	img.SetLastNonEmptyStage(phase.StagesIterator.PrevNonEmptyStage)
 	img.SetContentDigest(phase.StagesIterator.PrevNonEmptyStage.GetContentDigest())
@@ -234,16 +326,30 @@ func (phase *BuildPhase) getPrevNonEmptyStageImageSize() int64 {
 
 func (phase *BuildPhase) OnImageStage(ctx context.Context, img *Image, stg stage.Interface) error {
 	return phase.StagesIterator.OnImageStage(ctx, img, stg, func(img *Image, stg stage.Interface, isEmpty bool) error {
-		return phase.onImageStage(ctx, img, stg, isEmpty)
+		if err := phase.onImageStage(ctx, img, stg, isEmpty); err != nil {
+			event := phase.newStageEvent(ErrorEvent, img, stg)
+			event.Error = err.Error()
+			phase.writeEvent(ctx, event)
+
+			return err
+		}
+
+		return nil
 	})
 }
 
-func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage.Interface, isEmpty bool) error {
+func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage.Interface, isEmpty bool) (err error) {
 	if isEmpty {
 		return nil
 	}
 
-	if err := stg.FetchDependencies(ctx, phase.Conveyor, phase.Conveyor.ContainerRuntime); err != nil {
+	span := trace.StartSpan(ctx, trace.StageCategory, stg.LogDetailedName())
+	defer func() {
+		span.SetArg("digest", stg.GetDigest())
+		span.EndWithError(err)
+	}()
+
+	if err := stg.FetchDependencies(ctx, phase.Conveyor.forImage(img), phase.Conveyor.ContainerRuntime); err != nil {
 		return fmt.Errorf("unable to fetch dependencies for stage %s: %s", stg.LogDetailedName(), err)
 	}
 
@@ -313,7 +419,10 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 	}
 }
 
-func (phase *BuildPhase) fetchBaseImageForStage(ctx context.Context, img *Image, stg stage.Interface) error {
+func (phase *BuildPhase) fetchBaseImageForStage(ctx context.Context, img *Image, stg stage.Interface) (err error) {
+	span := trace.StartSpan(ctx, trace.FetchCategory, fmt.Sprintf("fetch base image for %s", stg.LogDetailedName()))
+	defer func() { span.EndWithError(err) }()
+
 	if stg.Name() == "from" {
 		if err := img.FetchBaseImage(ctx, phase.Conveyor); err != nil {
 			return fmt.Errorf("unable to fetch base image %s for stage %s: %s", img.GetBaseImage().Name(), stg.LogDetailedName(), err)
@@ -335,17 +444,34 @@ func castToStageImage(img container_runtime.ImageInterface) *container_runtime.S
 }
 
 func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg stage.Interface, shouldBeBuiltMode bool) error {
-	stageDependencies, err := stg.GetDependencies(ctx, phase.Conveyor, phase.StagesIterator.GetPrevImage(img, stg), phase.StagesIterator.GetPrevBuiltImage(img, stg))
+	digestCtx := ctx
+	var digestComponents *stage.DigestComponents
+	if phase.digestExplanations != nil {
+		digestCtx, digestComponents = stage.ContextWithDigestComponents(ctx)
+	}
+
+	digestSpan := trace.StartSpan(ctx, trace.DigestCategory, fmt.Sprintf("calculate %s digest", stg.LogDetailedName()))
+	stageDependencies, err := stg.GetDependencies(digestCtx, phase.Conveyor.forImage(img), phase.StagesIterator.GetPrevImage(img, stg), phase.StagesIterator.GetPrevBuiltImage(img, stg))
 	if err != nil {
+		digestSpan.EndWithError(err)
 		return err
 	}
 
-	stageSig, err := calculateDigest(ctx, string(stg.Name()), stageDependencies, phase.StagesIterator.PrevNonEmptyStage, phase.Conveyor)
+	stageSig, err := calculateDigest(digestCtx, string(stg.Name()), stageDependencies, phase.StagesIterator.PrevNonEmptyStage, phase.Conveyor.forImage(img))
+	digestSpan.SetArg("digest", stageSig)
+	digestSpan.EndWithError(err)
 	if err != nil {
 		return err
 	}
 	stg.SetDigest(stageSig)
 
+	if digestComponents != nil {
+		phase.explainStageDigest(ctx, img, stg, digestComponents.Components)
+	}
+
+	phase.writeEvent(ctx, phase.newStageEvent(StageDigestCalculatedEvent, img, stg))
+
+	lockSpan := trace.StartSpan(ctx, trace.LockCategory, fmt.Sprintf("wait for %s handling lock", stg.LogDetailedName()))
 	logboek.Context(ctx).Info().LogProcessInline("Locking stage %s handling", stg.LogDetailedName()).
 		Options(func(options types.LogProcessInlineOptionsInterface) {
 			if !phase.Conveyor.Parallel {
@@ -353,6 +479,7 @@ func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg sta
 			}
 		}).
 		Do(phase.Conveyor.GetStageDigestMutex(stg.GetDigest()).Lock)
+	lockSpan.End()
 
 	if stages, err := phase.Conveyor.StorageManager.GetStagesByDigest(ctx, stg.LogDetailedName(), stageSig); err != nil {
 		return err
@@ -363,7 +490,14 @@ func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg sta
 			i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), stageDesc.Info.Name)
 			i.SetStageDescription(stageDesc)
 			stg.SetImage(i)
+
+			event := phase.newStageEvent(StageCacheHitEvent, img, stg)
+			event.StageID = stageDesc.StageID.String()
+			event.Size = stageDesc.Info.Size
+			phase.writeEvent(ctx, event)
 		} else {
+			phase.writeEvent(ctx, phase.newStageEvent(StageCacheMissEvent, img, stg))
+
 			if shouldBeBuiltMode {
 				phase.printShouldBeBuiltError(ctx, img, stg)
 				return fmt.Errorf("stages required")
@@ -375,7 +509,7 @@ func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg sta
 		}
 	}
 
-	stageContentSig, err := calculateDigest(ctx, fmt.Sprintf("%s-content", stg.Name()), "", stg, phase.Conveyor)
+	stageContentSig, err := calculateDigest(ctx, fmt.Sprintf("%s-content", stg.Name()), "", stg, phase.Conveyor.forImage(img))
 	if err != nil {
 		return fmt.Errorf("unable to calculate stage %s content digest: %s", stg.Name(), err)
 	}
@@ -432,7 +566,7 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 		}
 	}
 
-	err := stg.PrepareImage(ctx, phase.Conveyor, phase.StagesIterator.GetPrevBuiltImage(img, stg), stageImage)
+	err := stg.PrepareImage(ctx, phase.Conveyor.forImage(img), phase.StagesIterator.GetPrevBuiltImage(img, stg), stageImage)
 	if err != nil {
 		return fmt.Errorf("error preparing stage %s: %s", stg.Name(), err)
 	}
@@ -441,9 +575,11 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 }
 
 func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.Interface) error {
-	_, err := stapel.GetOrCreateContainer(ctx)
-	if err != nil {
-		return fmt.Errorf("get or create stapel container failed: %s", err)
+	if _, ok := phase.Conveyor.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime); ok {
+		_, err := stapel.GetOrCreateContainer(ctx)
+		if err != nil {
+			return fmt.Errorf("get or create stapel container failed: %s", err)
+		}
 	}
 
 	infoSectionFunc := func(err error) {
@@ -462,11 +598,11 @@ func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.I
 			options.Style(style.Highlight())
 		}).
 		DoError(func() (err error) {
-			if err := stg.PreRunHook(ctx, phase.Conveyor); err != nil {
+			if err := stg.PreRunHook(ctx, phase.Conveyor.forImage(img)); err != nil {
 				return fmt.Errorf("%s preRunHook failed: %s", stg.LogDetailedName(), err)
 			}
 
-			return phase.atomicBuildStageImage(ctx, img, stg)
+			return phase.atomicBuildStageImageWithRetry(ctx, img, stg)
 		}); err != nil {
 		return err
 	}
@@ -480,9 +616,55 @@ func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.I
 	return nil
 }
 
-func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image, stg stage.Interface) error {
+// atomicBuildStageImageWithRetry rebuilds the stage image when the stage container run fails according to the retry policy of the user stage
+func (phase *BuildPhase) atomicBuildStageImageWithRetry(ctx context.Context, img *Image, stg stage.Interface) error {
+	var retryPolicy *config.RetryPolicy
+	if userStage, ok := stg.(interface{ GetRetryPolicy() *config.RetryPolicy }); ok {
+		retryPolicy = userStage.GetRetryPolicy()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := phase.atomicBuildStageImage(ctx, img, stg)
+		if err == nil || retryPolicy == nil || attempt > retryPolicy.Count || ctx.Err() != nil {
+			return err
+		}
+
+		failure := stg.GetImage().Container().LastRunFailure()
+		if failure == nil || !retryPolicy.ShouldRetry(failure.ExitCode, failure.Output) {
+			return err
+		}
+
+		logboek.Context(ctx).Warn().LogF("WARNING: %s\n", err)
+		logboek.Context(ctx).Warn().LogF("Retrying stage %s build (%d/%d) in %s ...\n", stg.LogDetailedName(), attempt, retryPolicy.Count, retryPolicy.Delay)
+
+		select {
+		case <-time.After(retryPolicy.Delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image, stg stage.Interface) (err error) {
 	stageImage := stg.GetImage()
 
+	buildStartedAt := time.Now()
+	phase.writeEvent(ctx, phase.newStageEvent(StageBuildStartedEvent, img, stg))
+	defer func() {
+		if err != nil {
+			event := phase.newStageEvent(StageBuildFailedEvent, img, stg)
+			event.Error = err.Error()
+			event.DurationSeconds = time.Since(buildStartedAt).Seconds()
+			phase.writeEvent(ctx, event)
+		} else if desc := stg.GetImage().GetStageDescription(); desc != nil {
+			event := phase.newStageEvent(StageBuildFinishedEvent, img, stg)
+			event.StageID = desc.StageID.String()
+			event.Size = desc.Info.Size
+			event.DurationSeconds = time.Since(buildStartedAt).Seconds()
+			phase.writeEvent(ctx, event)
+		}
+	}()
+
 	if v := os.Getenv("WERF_TEST_ATOMIC_STAGE_BUILD__SLEEP_SECONDS_BEFORE_STAGE_BUILD"); v != "" {
 		seconds := 0
 		fmt.Sscanf(v, "%d", &seconds)
@@ -490,9 +672,23 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 		time.Sleep(time.Duration(seconds) * time.Second)
 	}
 
-	if err := logboek.Context(ctx).Streams().DoErrorWithTag(fmt.Sprintf("%s/%s", img.LogName(), stg.Name()), img.LogTagStyle(), func() error {
-		return stageImage.Build(ctx, phase.ImageBuildOptions)
-	}); err != nil {
+	buildSpan := trace.StartSpan(ctx, trace.BuildCategory, fmt.Sprintf("build %s", stg.LogDetailedName()))
+	buildCtx := ctx
+	if timeout := stg.GetTimeout(); timeout != 0 {
+		var cancel context.CancelFunc
+		buildCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err = logboek.Context(ctx).Streams().DoErrorWithTag(fmt.Sprintf("%s/%s", img.LogName(), stg.Name()), img.LogTagStyle(), func() error {
+		return stageImage.Build(buildCtx, phase.ImageBuildOptions)
+	})
+	buildSpan.EndWithError(err)
+	if err != nil {
+		if buildCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return fmt.Errorf("failed to build image for stage %s with digest %s: stage timeout %s exceeded", stg.Name(), stg.GetDigest(), stg.GetTimeout())
+		}
+
 		return fmt.Errorf("failed to build image for stage %s with digest %s: %s", stg.Name(), stg.GetDigest(), err)
 	}
 
@@ -503,7 +699,10 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 		time.Sleep(time.Duration(seconds) * time.Second)
 	}
 
-	if lock, err := phase.Conveyor.StorageLockManager.LockStage(ctx, phase.Conveyor.projectName(), stg.GetDigest()); err != nil {
+	lockSpan := trace.StartSpan(ctx, trace.LockCategory, fmt.Sprintf("lock %s", stg.LogDetailedName()), "digest", stg.GetDigest())
+	lock, err := phase.Conveyor.StorageLockManager.LockStage(ctx, phase.Conveyor.projectName(), stg.GetDigest())
+	lockSpan.EndWithError(err)
+	if err != nil {
 		return fmt.Errorf("unable to lock project %s digest %s: %s", phase.Conveyor.projectName(), stg.GetDigest(), err)
 	} else {
 		defer phase.Conveyor.StorageLockManager.Unlock(ctx, lock)
@@ -540,6 +739,8 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			stageImageObj.SetName(newStageImageName)
 			phase.Conveyor.SetStageImage(stageImageObj)
 
+			storeStartedAt := time.Now()
+			pushSpan := trace.StartSpan(ctx, trace.PushCategory, fmt.Sprintf("store %s", stg.LogDetailedName()), "image", newStageImageName)
 			if err := logboek.Context(ctx).Info().LogProcess("Store into stages storage").DoError(func() error {
 				if err := phase.Conveyor.StorageManager.StagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: stageImage}); err != nil {
 					return fmt.Errorf("unable to store stage %s digest %s image %s into stages storage %s: %s", stg.LogDetailedName(), stg.GetDigest(), stageImage.Name(), phase.Conveyor.StorageManager.StagesStorage.String(), err)
@@ -551,8 +752,16 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 				}
 				return nil
 			}); err != nil {
+				pushSpan.EndWithError(err)
 				return err
 			}
+			pushSpan.End()
+
+			event := phase.newStageEvent(StagePushedEvent, img, stg)
+			event.StageID = stageImage.GetStageDescription().StageID.String()
+			event.Size = stageImage.GetStageDescription().Info.Size
+			event.DurationSeconds = time.Since(storeStartedAt).Seconds()
+			phase.writeEvent(ctx, event)
 
 			var stageIDs []image.StageID
 			for _, stageDesc := range stages {
@@ -565,6 +774,43 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 	}
 }
 
+// explainStageDigest records the stage digest components and prints components changed since the previous build of the image
+func (phase *BuildPhase) explainStageDigest(ctx context.Context, img *Image, stg stage.Interface, components []stage.DigestComponent) {
+	prev := phase.digestExplanations.Set(img.getNameWithPlatform(), string(stg.Name()), &StageDigestExplanation{
+		Digest:     stg.GetDigest(),
+		Components: components,
+	})
+
+	if prev == nil {
+		logboek.Context(ctx).Default().LogF("Stage %s digest %s: no previous build of the image recorded\n", stg.LogDetailedName(), stg.GetDigest())
+		return
+	}
+
+	if prev.Digest == stg.GetDigest() {
+		logboek.Context(ctx).Info().LogF("Stage %s digest %s: not changed\n", stg.LogDetailedName(), stg.GetDigest())
+		return
+	}
+
+	logboek.Context(ctx).Default().LogBlock("Stage %s digest changed %s -> %s", stg.LogDetailedName(), prev.Digest, stg.GetDigest()).Do(func() {
+		changes := DiffDigestComponents(prev.Components, components)
+		if len(changes) == 0 {
+			logboek.Context(ctx).Default().LogLn("Digest components are not changed: werf version or stage dependencies format has been changed")
+			return
+		}
+
+		for _, change := range changes {
+			switch {
+			case change.Added:
+				logboek.Context(ctx).Default().LogF("%s added: %q\n", change.Name, change.NewValue)
+			case change.Removed:
+				logboek.Context(ctx).Default().LogF("%s removed: %q\n", change.Name, change.OldValue)
+			default:
+				logboek.Context(ctx).Default().LogF("%s changed: %q -> %q\n", change.Name, change.OldValue, change.NewValue)
+			}
+		}
+	})
+}
+
 func introspectStage(ctx context.Context, s stage.Interface) error {
 	return logboek.Context(ctx).Info().LogProcess("Introspecting stage %s", s.Name()).
 		Options(func(options types.LogProcessOptionsInterface) {
@@ -632,9 +878,13 @@ func byteCountBinary(b int64) string {
 	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
-func calculateDigest(ctx context.Context, stageName, stageDependencies string, prevNonEmptyStage stage.Interface, conveyor *Conveyor) (string, error) {
+func calculateDigest(ctx context.Context, stageName, stageDependencies string, prevNonEmptyStage stage.Interface, conveyor stage.Conveyor) (string, error) {
 	checksumArgs := []string{image.BuildCacheVersion, stageName, stageDependencies}
+	stage.AddDigestComponent(ctx, "werf build cache version", image.BuildCacheVersion)
+
 	if prevNonEmptyStage != nil {
+		stage.AddDigestComponent(ctx, "previous stage digest", fmt.Sprintf("%s %s", prevNonEmptyStage.Name(), prevNonEmptyStage.GetDigest()))
+
 		prevStageDependencies, err := prevNonEmptyStage.GetNextStageDependencies(ctx, conveyor)
 		if err != nil {
 			return "", fmt.Errorf("unable to get prev stage %s dependencies for the stage %s: %s", prevNonEmptyStage.Name(), stageName, err)
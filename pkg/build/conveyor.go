@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/cli/cli/command/image/build"
 	"github.com/docker/docker/pkg/fileutils"
@@ -34,6 +35,7 @@ import (
 	"github.com/werf/werf/pkg/path_matcher"
 	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/trace"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/util/parallel"
 )
@@ -53,8 +55,8 @@ type Conveyor struct {
 
 	gitReposCaches map[string]*stage.GitRepoCache
 
-	images    []*Image
-	imageSets [][]*Image
+	images            []*Image
+	imageDependencies map[*Image][]*Image
 
 	stageImages    map[string]*container_runtime.StageImage
 	localGitRepo   *git_repo.Local
@@ -101,7 +103,7 @@ func NewConveyor(werfConfig *config.WerfConfig, imageNamesToProcess []string, pr
 		baseImagesRepoIdsCache: make(map[string]string),
 		baseImagesRepoErrCache: make(map[string]error),
 		images:                 []*Image{},
-		imageSets:              [][]*Image{},
+		imageDependencies:      make(map[*Image][]*Image),
 		remoteGitRepos:         make(map[string]*git_repo.Remote),
 		tmpDir:                 filepath.Join(baseTmpDir, util.GenerateConsistentRandomString(10)),
 		importServers:          make(map[string]import_server.ImportServer),
@@ -194,9 +196,15 @@ func (c *Conveyor) GetLocalGitRepoVirtualMergeOptions() stage.VirtualMergeOption
 }
 
 func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error) {
+	return c.getImportServer(ctx, c.GetImage(imageName), stageName)
+}
+
+func (c *Conveyor) getImportServer(ctx context.Context, img *Image, stageName string) (import_server.ImportServer, error) {
 	c.getServiceRWMutex("ImportServer").Lock()
 	defer c.getServiceRWMutex("ImportServer").Unlock()
 
+	imageName := img.getNameWithPlatform()
+
 	importServerName := imageName
 	if stageName != "" {
 		importServerName += "/" + stageName
@@ -207,7 +215,7 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 
 	var srv *import_server.RsyncServer
 
-	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Firing up import rsync server for image %s", imageName)).
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Firing up import rsync server for %s", img.LogDetailedName())).
 		DoError(func() error {
 			var tmpDir string
 			if stageName == "" {
@@ -220,12 +228,7 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 				return fmt.Errorf("unable to create dir %s: %s", tmpDir, err)
 			}
 
-			var dockerImageName string
-			if stageName == "" {
-				dockerImageName = c.GetImageNameForLastImageStage(imageName)
-			} else {
-				dockerImageName = c.GetImageNameForImageStage(imageName, stageName)
-			}
+			dockerImageName := c.getImageStage(img, stageName).GetImage().Name()
 
 			var err error
 			srv, err = import_server.RunRsyncServer(ctx, dockerImageName, tmpDir)
@@ -367,18 +370,64 @@ func (c *Conveyor) FetchLastImageStage(ctx context.Context, imageName string) er
 	return c.StorageManager.FetchStage(ctx, lastImageStage)
 }
 
-func (c *Conveyor) GetImageInfoGetters() (images []*image.InfoGetter) {
+func (c *Conveyor) GetImageInfoGetters() ([]*image.InfoGetter, error) {
+	var images []*image.InfoGetter
+	processedImages := map[string]bool{}
 	for _, img := range c.images {
-		if img.isArtifact {
+		if img.isArtifact || processedImages[img.GetName()] {
 			continue
 		}
-		images = append(images, img.GetImageInfoGetter())
+		processedImages[img.GetName()] = true
+
+		if platformImages := c.getManifestListPlatformImages(img.GetName()); len(platformImages) != 0 {
+			manifestListName, err := c.StorageManager.StagesStorage.ConstructManifestListImageName(c.projectName(), platformImages)
+			if err != nil {
+				return nil, err
+			}
+
+			_, tag := image.ParseRepositoryAndTag(manifestListName)
+			images = append(images, image.NewInfoGetter(img.GetName(), manifestListName, tag))
+		} else {
+			images = append(images, img.GetImageInfoGetter())
+		}
 	}
 
-	return images
+	return images, nil
+}
+
+// getManifestListPlatformImages returns built platform images which should be published by the manifest list,
+// there is no manifest list for the image built for the single platform
+func (c *Conveyor) getManifestListPlatformImages(name string) []*storage.ManifestListPlatformImage {
+	images := c.getPlatformImages(name)
+	if len(images) < 2 {
+		return nil
+	}
+
+	var platformImages []*storage.ManifestListPlatformImage
+	for _, img := range images {
+		platformImages = append(platformImages, &storage.ManifestListPlatformImage{
+			Platform:         img.platform,
+			StageDescription: img.GetLastNonEmptyStage().GetImage().GetStageDescription(),
+		})
+	}
+
+	return platformImages
 }
 
-func (c *Conveyor) Build(ctx context.Context, opts BuildOptions) error {
+func (c *Conveyor) Build(ctx context.Context, opts BuildOptions) (err error) {
+	buildStartedAt := time.Now()
+	defer func() {
+		// build is retried with the reset stages storage cache, so the result is not final yet
+		if opts.DryRun || manager.ShouldResetStagesStorageCache(err) {
+			return
+		}
+
+		event := NewBuildResultEvent(buildStartedAt, err)
+		if writeErr := opts.EventsWriter.Write(event); writeErr != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to write build event %s to %s: %s\n", event.Type, opts.EventsWriter.Path, writeErr)
+		}
+	}()
+
 	if err := c.determineStages(ctx); err != nil {
 		return err
 	}
@@ -411,11 +460,10 @@ func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 	imageConfigsToProcess := getImageConfigsToProcess(ctx, c)
 	configSets := c.werfConfig.ImagesWithDependenciesBySets(imageConfigsToProcess)
 
-	for _, iteration := range configSets {
-		var imageSet []*Image
+	imagesByConfig := map[config.ImageInterface][]*Image{}
 
+	for _, iteration := range configSets {
 		for _, imageInterfaceConfig := range iteration {
-			var img *Image
 			var imageLogName string
 			var style *style.Style
 
@@ -428,36 +476,93 @@ func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 				style = ImageLogProcessStyle(false)
 			}
 
-			err := logboek.Context(ctx).Info().LogProcess(imageLogName).
-				Options(func(options types.LogProcessOptionsInterface) {
-					options.Style(style)
-				}).
-				DoError(func() error {
-					var err error
+			if err := c.checkImageConfigSupportedByContainerRuntime(imageInterfaceConfig); err != nil {
+				return err
+			}
 
-					switch imageConfig := imageInterfaceConfig.(type) {
-					case config.StapelImageInterface:
-						img, err = prepareImageBasedOnStapelImageConfig(ctx, imageConfig, c)
-					case *config.ImageFromDockerfile:
-						img, err = prepareImageBasedOnImageFromDockerfile(ctx, imageConfig, c)
-					}
+			platforms := config.GetImagePlatforms(imageInterfaceConfig)
+			if len(platforms) > 1 && !storage.IsManifestListSupported(c.StorageManager.StagesStorage.Address()) {
+				return fmt.Errorf("%s: multi-platform images are not supported by %s stages storage: docker registry should be specified with --repo", imageLogName, c.StorageManager.StagesStorage.String())
+			}
 
-					if err != nil {
-						return err
-					}
+			// the image is built for the host platform if no platforms specified
+			if len(platforms) == 0 {
+				platforms = []string{""}
+			}
 
-					c.images = append(c.images, img)
-					imageSet = append(imageSet, img)
+			for _, platform := range platforms {
+				platformImageLogName := imageLogName
+				if platform != "" {
+					platformImageLogName = fmt.Sprintf("%s [%s]", imageLogName, platform)
+				}
 
-					return nil
-				})
+				err := logboek.Context(ctx).Info().LogProcess(platformImageLogName).
+					Options(func(options types.LogProcessOptionsInterface) {
+						options.Style(style)
+					}).
+					DoError(func() error {
+						var img *Image
+						var err error
+
+						switch imageConfig := imageInterfaceConfig.(type) {
+						case config.StapelImageInterface:
+							img, err = prepareImageBasedOnStapelImageConfig(ctx, imageConfig, platform, c)
+						case *config.ImageFromDockerfile:
+							img, err = prepareImageBasedOnImageFromDockerfile(ctx, imageConfig, platform, c)
+						}
 
-			if err != nil {
-				return err
+						if err != nil {
+							return err
+						}
+
+						c.images = append(c.images, img)
+						imagesByConfig[imageInterfaceConfig] = append(imagesByConfig[imageInterfaceConfig], img)
+
+						return nil
+					})
+
+				if err != nil {
+					return err
+				}
 			}
 		}
+	}
 
-		c.imageSets = append(c.imageSets, imageSet)
+	for imageConfig, images := range imagesByConfig {
+		for _, img := range images {
+			for _, depConfig := range c.werfConfig.GetImageDependencies(imageConfig) {
+				for _, dep := range imagesByConfig[depConfig] {
+					if dep.platform == "" || dep.platform == img.platform {
+						c.imageDependencies[img] = append(c.imageDependencies[img], dep)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkImageConfigSupportedByContainerRuntime returns error for features which require docker server when other container runtime is used
+func (c *Conveyor) checkImageConfigSupportedByContainerRuntime(imageInterfaceConfig config.ImageInterface) error {
+	if _, ok := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime); ok {
+		return nil
+	}
+
+	switch imageConfig := imageInterfaceConfig.(type) {
+	case *config.ImageFromDockerfile:
+		return fmt.Errorf("%s: dockerfile images are not supported by %s container runtime", logging.ImageLogProcessName(imageConfig.Name, false), c.ContainerRuntime.String())
+	case config.StapelImageInterface:
+		imageBaseConfig := imageConfig.ImageBaseConfig()
+		imageLogName := logging.ImageLogProcessName(imageBaseConfig.Name, imageConfig.IsArtifact())
+
+		if imageBaseConfig.Ansible != nil {
+			return fmt.Errorf("%s: ansible is not supported by %s container runtime, use shell instead", imageLogName, c.ContainerRuntime.String())
+		}
+
+		if len(imageBaseConfig.Import) != 0 {
+			return fmt.Errorf("%s: imports are not supported by %s container runtime", imageLogName, c.ContainerRuntime.String())
+		}
 	}
 
 	return nil
@@ -521,55 +626,70 @@ type goResult struct {
 	err  error
 }
 
+// doImagesInParallel starts each image as soon as all images it depends on (fromImage, fromArtifact and imports) are done
 func (c *Conveyor) doImagesInParallel(ctx context.Context, phases []Phase, logImages bool) error {
 	blockMsg := "Concurrent builds plan"
 	if c.ParallelTasksLimit > 0 {
 		blockMsg = fmt.Sprintf("%s (no more than %d images at the same time)", blockMsg, c.ParallelTasksLimit)
 	}
 
+	imageTaskIds := map[*Image]int{}
+	for taskId, img := range c.images {
+		imageTaskIds[img] = taskId
+	}
+
+	var dependencies [][]int
+	for _, img := range c.images {
+		var imageDependencies []int
+		for _, dep := range c.imageDependencies[img] {
+			imageDependencies = append(imageDependencies, imageTaskIds[dep])
+		}
+
+		dependencies = append(dependencies, imageDependencies)
+	}
+
 	logboek.Context(ctx).LogBlock(blockMsg).
 		Options(func(options types.LogBlockOptionsInterface) {
 			options.Style(style.Highlight())
 		}).
 		Do(func() {
-			for setId := range c.imageSets {
-				logboek.Context(ctx).LogFHighlight("Set #%d:\n", setId)
-				for _, img := range c.imageSets[setId] {
-					logboek.Context(ctx).LogLnHighlight("-", img.LogDetailedName())
+			for _, img := range c.images {
+				var depNames []string
+				for _, dep := range c.imageDependencies[img] {
+					depNames = append(depNames, dep.LogDetailedName())
 				}
-				logboek.Context(ctx).LogOptionalLn()
 
+				if len(depNames) == 0 {
+					logboek.Context(ctx).LogLnHighlight("-", img.LogDetailedName())
+				} else {
+					logboek.Context(ctx).LogFHighlight("- %s (after %s)\n", img.LogDetailedName(), strings.Join(depNames, ", "))
+				}
 			}
 		})
 
-	for setId := range c.imageSets {
-		logboek.Context(ctx).LogLn()
-
-		numberOfTasks := len(c.imageSets[setId])
-		numberOfWorkers := int(c.ParallelTasksLimit)
+	logboek.Context(ctx).LogLn()
 
-		if err := parallel.DoTasks(ctx, numberOfTasks, parallel.DoTasksOptions{
-			InitDockerCLIForEachWorker: true,
-			MaxNumberOfWorkers:         numberOfWorkers,
-			IsLiveOutputOn:             true,
-		}, func(ctx context.Context, taskId int) error {
-			taskImage := c.imageSets[setId][taskId]
+	return parallel.DoTasksWithDependencies(ctx, len(c.images), dependencies, parallel.DoTasksOptions{
+		InitDockerCLIForEachWorker: true,
+		MaxNumberOfWorkers:         int(c.ParallelTasksLimit),
+		IsLiveOutputOn:             true,
+	}, func(ctx context.Context, taskId int) error {
+		taskImage := c.images[taskId]
 
-			var taskPhases []Phase
-			for _, phase := range phases {
-				taskPhases = append(taskPhases, phase.Clone())
-			}
-
-			return c.doImage(ctx, taskImage, taskPhases, logImages)
-		}); err != nil {
-			return err
+		var taskPhases []Phase
+		for _, phase := range phases {
+			taskPhases = append(taskPhases, phase.Clone())
 		}
-	}
 
-	return nil
+		return c.doImage(ctx, taskImage, taskPhases, logImages)
+	})
 }
 
-func (c *Conveyor) doImage(ctx context.Context, img *Image, phases []Phase, logImages bool) error {
+func (c *Conveyor) doImage(ctx context.Context, img *Image, phases []Phase, logImages bool) (err error) {
+	ctx = trace.WithNewTrack(ctx, img.LogDetailedName())
+	span := trace.StartSpan(ctx, trace.ImageCategory, img.LogDetailedName())
+	defer func() { span.EndWithError(err) }()
+
 	var imagesLogger types.ManagerInterface
 	if logImages {
 		imagesLogger = logboek.Context(ctx).Default()
@@ -653,14 +773,20 @@ func (c *Conveyor) GetOrCreateStageImage(fromImage *container_runtime.StageImage
 		return img
 	}
 
-	img := container_runtime.NewStageImage(fromImage, name, c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime))
+	img := container_runtime.NewStageImage(fromImage, name, c.ContainerRuntime.(container_runtime.StageImageRuntime))
 	c.SetStageImage(img)
 	return img
 }
 
 func (c *Conveyor) GetImage(name string) *Image {
+	return c.getImage(name, "")
+}
+
+// getImage returns the image built for the platform, the image built for the host platform is suitable for any platform.
+// The first found image is returned if the platform is not specified.
+func (c *Conveyor) getImage(name, platform string) *Image {
 	for _, img := range c.images {
-		if img.GetName() == name {
+		if img.GetName() == name && (platform == "" || img.platform == "" || img.platform == platform) {
 			return img
 		}
 	}
@@ -668,20 +794,32 @@ func (c *Conveyor) GetImage(name string) *Image {
 	panic(fmt.Sprintf("Image '%s' not found!", name))
 }
 
+// getPlatformImages returns all images built by the image config, the image is built once for each platform
+func (c *Conveyor) getPlatformImages(name string) []*Image {
+	var images []*Image
+	for _, img := range c.images {
+		if img.GetName() == name {
+			images = append(images, img)
+		}
+	}
+
+	return images
+}
+
 func (c *Conveyor) GetImageStageContentDigest(imageName, stageName string) string {
-	return c.getImageStage(imageName, stageName).GetContentDigest()
+	return c.getImageStage(c.GetImage(imageName), stageName).GetContentDigest()
 }
 
 func (c *Conveyor) GetImageContentDigest(imageName string) string {
 	return c.GetImage(imageName).GetContentDigest()
 }
 
-func (c *Conveyor) getImageStage(imageName, stageName string) stage.Interface {
-	if stg := c.GetImage(imageName).GetStage(stage.StageName(stageName)); stg != nil {
+func (c *Conveyor) getImageStage(img *Image, stageName string) stage.Interface {
+	if stg := img.GetStage(stage.StageName(stageName)); stg != nil {
 		return stg
 	} else {
 		// FIXME: find first existing stage after specified unexisting
-		return c.GetImage(imageName).GetLastNonEmptyStage()
+		return img.GetLastNonEmptyStage()
 	}
 }
 
@@ -690,7 +828,7 @@ func (c *Conveyor) GetImageNameForLastImageStage(imageName string) string {
 }
 
 func (c *Conveyor) GetImageNameForImageStage(imageName, stageName string) string {
-	return c.getImageStage(imageName, stageName).GetImage().Name()
+	return c.getImageStage(c.GetImage(imageName), stageName).GetImage().Name()
 }
 
 func (c *Conveyor) GetStageID(imageName string) string {
@@ -702,7 +840,50 @@ func (c *Conveyor) GetImageIDForLastImageStage(imageName string) string {
 }
 
 func (c *Conveyor) GetImageIDForImageStage(imageName, stageName string) string {
-	return c.getImageStage(imageName, stageName).GetImage().GetStageDescription().Info.ID
+	return c.getImageStage(c.GetImage(imageName), stageName).GetImage().GetStageDescription().Info.ID
+}
+
+// forImage returns the conveyor for stages of the image.
+// Images referenced by stages of the image built for the platform (fromImage, fromArtifact and imports) are resolved to the images built for the same platform.
+func (c *Conveyor) forImage(img *Image) stage.Conveyor {
+	if img.platform == "" {
+		return c
+	}
+
+	return &platformConveyor{Conveyor: c, platform: img.platform}
+}
+
+type platformConveyor struct {
+	*Conveyor
+	platform string
+}
+
+func (c *platformConveyor) GetImageStageContentDigest(imageName, stageName string) string {
+	return c.getImageStage(c.getImage(imageName, c.platform), stageName).GetContentDigest()
+}
+
+func (c *platformConveyor) GetImageContentDigest(imageName string) string {
+	return c.getImage(imageName, c.platform).GetContentDigest()
+}
+
+func (c *platformConveyor) GetImageNameForLastImageStage(imageName string) string {
+	return c.getImage(imageName, c.platform).GetLastNonEmptyStage().GetImage().Name()
+}
+
+func (c *platformConveyor) GetImageNameForImageStage(imageName, stageName string) string {
+	return c.getImageStage(c.getImage(imageName, c.platform), stageName).GetImage().Name()
+}
+
+func (c *platformConveyor) GetImageIDForLastImageStage(imageName string) string {
+	return c.getImage(imageName, c.platform).GetLastNonEmptyStage().GetImage().GetStageDescription().Info.ID
+}
+
+func (c *platformConveyor) GetImageIDForImageStage(imageName, stageName string) string {
+	return c.getImageStage(c.getImage(imageName, c.platform), stageName).GetImage().GetStageDescription().Info.ID
+}
+
+func (c *platformConveyor) GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error) {
+	return c.getImportServer(ctx, c.getImage(imageName, c.platform), stageName)
 }
 
 func (c *Conveyor) GetImageTmpDir(imageName string) string {
@@ -718,8 +899,9 @@ func (c *Conveyor) GetProjectRepoCommit(ctx context.Context) (string, error) {
 	}
 }
 
-func prepareImageBasedOnStapelImageConfig(ctx context.Context, imageInterfaceConfig config.StapelImageInterface, c *Conveyor) (*Image, error) {
+func prepareImageBasedOnStapelImageConfig(ctx context.Context, imageInterfaceConfig config.StapelImageInterface, platform string, c *Conveyor) (*Image, error) {
 	image := &Image{}
+	image.platform = platform
 
 	imageBaseConfig := imageInterfaceConfig.ImageBaseConfig()
 	imageName := imageBaseConfig.Name
@@ -750,6 +932,14 @@ func prepareImageBasedOnStapelImageConfig(ctx context.Context, imageInterfaceCon
 func handleImageFromName(ctx context.Context, from string, fromLatest bool, image *Image, c *Conveyor) error {
 	image.baseImageName = from
 
+	// the same base image name refers to different images for different platforms,
+	// so the base image of the stapel image is pulled by the digest of the platform image
+	if image.platform != "" && !image.isDockerfileImage {
+		if err := image.pinBaseImageForPlatform(ctx); err != nil {
+			return err
+		}
+	}
+
 	if fromLatest {
 		if _, err := image.getFromBaseImageIdFromRegistry(ctx, c, image.baseImageName); err != nil {
 			return err
@@ -804,26 +994,30 @@ func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.S
 	imageBaseConfig := imageInterfaceConfig.ImageBaseConfig()
 	imageName := imageBaseConfig.Name
 	imageArtifact := imageInterfaceConfig.IsArtifact()
+	imageTmpName := image.getNameWithPlatform()
 
 	baseStageOptions := &stage.NewBaseStageOptions{
 		ImageName:        imageName,
 		ConfigMounts:     imageBaseConfig.Mount,
-		ImageTmpDir:      c.GetImageTmpDir(imageBaseConfig.Name),
+		ConfigSecrets:    imageBaseConfig.Secrets,
+		ConfigLimits:     imageBaseConfig.Limits,
+		ImageTmpDir:      c.GetImageTmpDir(imageTmpName),
 		ContainerWerfDir: c.containerWerfDir,
 		ProjectName:      c.werfConfig.Meta.Project,
+		Platform:         image.platform,
 	}
 
 	gitArchiveStageOptions := &stage.NewGitArchiveStageOptions{
-		ArchivesDir:          getImageArchivesDir(imageName, c),
-		ScriptsDir:           getImageScriptsDir(imageName, c),
+		ArchivesDir:          getImageArchivesDir(imageTmpName, c),
+		ScriptsDir:           getImageScriptsDir(imageTmpName, c),
 		ContainerArchivesDir: getImageArchivesContainerDir(c),
 		ContainerScriptsDir:  getImageScriptsContainerDir(c),
 	}
 
 	gitPatchStageOptions := &stage.NewGitPatchStageOptions{
-		PatchesDir:           getImagePatchesDir(imageName, c),
-		ArchivesDir:          getImageArchivesDir(imageName, c),
-		ScriptsDir:           getImageScriptsDir(imageName, c),
+		PatchesDir:           getImagePatchesDir(imageTmpName, c),
+		ArchivesDir:          getImageArchivesDir(imageTmpName, c),
+		ScriptsDir:           getImageScriptsDir(imageTmpName, c),
 		ContainerPatchesDir:  getImagePatchesContainerDir(c),
 		ContainerArchivesDir: getImageArchivesContainerDir(c),
 		ContainerScriptsDir:  getImageScriptsContainerDir(c),
@@ -1133,9 +1327,10 @@ func appendIfExist(ctx context.Context, stages []stage.Interface, stage stage.In
 	return stages
 }
 
-func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDockerfileConfig *config.ImageFromDockerfile, c *Conveyor) (*Image, error) {
+func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDockerfileConfig *config.ImageFromDockerfile, platform string, c *Conveyor) (*Image, error) {
 	img := &Image{}
 	img.name = imageFromDockerfileConfig.Name
+	img.platform = platform
 	img.isDockerfileImage = true
 
 	contextDir := filepath.Join(c.projectDir, imageFromDockerfileConfig.Context)
@@ -1248,6 +1443,7 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 	baseStageOptions := &stage.NewBaseStageOptions{
 		ImageName:   imageFromDockerfileConfig.Name,
 		ProjectName: c.werfConfig.Meta.Project,
+		Platform:    platform,
 	}
 
 	dockerfileStage := stage.GenerateDockerfileStage(
@@ -0,0 +1,147 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const DigestExplanationsCacheVersion = "1"
+
+// StageDigestExplanation is the stage digest along with the components which have been hashed into the digest
+type StageDigestExplanation struct {
+	Digest     string                  `json:"digest"`
+	Components []stage.DigestComponent `json:"components"`
+}
+
+type DigestComponentChange struct {
+	Name     string
+	OldValue string
+	NewValue string
+	Added    bool
+	Removed  bool
+}
+
+// digestExplanations keeps stages digest explanations of the last build of each image of the project in the werf local cache
+type digestExplanations struct {
+	mux      sync.Mutex
+	path     string
+	Previous map[string]map[string]*StageDigestExplanation
+	Current  map[string]map[string]*StageDigestExplanation
+}
+
+func GetDigestExplanationsDir() string {
+	return filepath.Join(werf.GetLocalCacheDir(), "digest_explanations", DigestExplanationsCacheVersion)
+}
+
+func loadDigestExplanations(projectName string) (*digestExplanations, error) {
+	explanations := &digestExplanations{
+		path:     filepath.Join(GetDigestExplanationsDir(), fmt.Sprintf("%s.json", projectName)),
+		Previous: make(map[string]map[string]*StageDigestExplanation),
+		Current:  make(map[string]map[string]*StageDigestExplanation),
+	}
+
+	data, err := ioutil.ReadFile(explanations.path)
+	if os.IsNotExist(err) {
+		return explanations, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", explanations.path, err)
+	}
+
+	if err := json.Unmarshal(data, &explanations.Previous); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", explanations.path, err)
+	}
+
+	return explanations, nil
+}
+
+// Set records the stage digest explanation of the current build and returns the explanation of the previous build of the same image stage
+func (explanations *digestExplanations) Set(imageName, stageName string, explanation *StageDigestExplanation) *StageDigestExplanation {
+	explanations.mux.Lock()
+	defer explanations.mux.Unlock()
+
+	if _, hasKey := explanations.Current[imageName]; !hasKey {
+		explanations.Current[imageName] = make(map[string]*StageDigestExplanation)
+	}
+	explanations.Current[imageName][stageName] = explanation
+
+	return explanations.Previous[imageName][stageName]
+}
+
+// Save writes explanations of the current build, explanations of images which have not been built are kept
+func (explanations *digestExplanations) Save() error {
+	explanations.mux.Lock()
+	defer explanations.mux.Unlock()
+
+	res := make(map[string]map[string]*StageDigestExplanation)
+	for imageName, stages := range explanations.Previous {
+		res[imageName] = stages
+	}
+	for imageName, stages := range explanations.Current {
+		res[imageName] = stages
+	}
+
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(explanations.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmpPath := explanations.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, explanations.path)
+}
+
+// DiffDigestComponents returns changed, added and removed components in the order of the new components.
+// Components with the same name are distinguished by the occurrence number.
+func DiffDigestComponents(oldComponents, newComponents []stage.DigestComponent) []DigestComponentChange {
+	oldNames, oldValues := indexDigestComponents(oldComponents)
+	newNames, newValues := indexDigestComponents(newComponents)
+
+	var changes []DigestComponentChange
+	for _, name := range newNames {
+		if oldValue, hasKey := oldValues[name]; !hasKey {
+			changes = append(changes, DigestComponentChange{Name: name, NewValue: newValues[name], Added: true})
+		} else if oldValue != newValues[name] {
+			changes = append(changes, DigestComponentChange{Name: name, OldValue: oldValue, NewValue: newValues[name]})
+		}
+	}
+
+	for _, name := range oldNames {
+		if _, hasKey := newValues[name]; !hasKey {
+			changes = append(changes, DigestComponentChange{Name: name, OldValue: oldValues[name], Removed: true})
+		}
+	}
+
+	return changes
+}
+
+func indexDigestComponents(components []stage.DigestComponent) ([]string, map[string]string) {
+	var names []string
+	values := make(map[string]string)
+	occurrences := make(map[string]int)
+
+	for _, component := range components {
+		name := component.Name
+		if occurrences[component.Name]++; occurrences[component.Name] > 1 {
+			name = fmt.Sprintf("%s #%d", component.Name, occurrences[component.Name])
+		}
+
+		names = append(names, name)
+		values[name] = component.Value
+	}
+
+	return names, values
+}
@@ -0,0 +1,201 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestDiffDigestComponents(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		oldComponents   []stage.DigestComponent
+		newComponents   []stage.DigestComponent
+		expectedChanges []DigestComponentChange
+	}{
+		{
+			name:          "unchanged",
+			oldComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "shell", Value: "apk add curl"}},
+			newComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "shell", Value: "apk add curl"}},
+		},
+		{
+			name:          "changed",
+			oldComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "shell", Value: "apk add curl"}},
+			newComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "shell", Value: "apk add wget"}},
+			expectedChanges: []DigestComponentChange{
+				{Name: "shell", OldValue: "apk add curl", NewValue: "apk add wget"},
+			},
+		},
+		{
+			name:          "added",
+			oldComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}},
+			newComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "env", Value: "A=1"}},
+			expectedChanges: []DigestComponentChange{
+				{Name: "env", NewValue: "A=1", Added: true},
+			},
+		},
+		{
+			name:          "removed",
+			oldComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "env", Value: "A=1"}},
+			newComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}},
+			expectedChanges: []DigestComponentChange{
+				{Name: "env", OldValue: "A=1", Removed: true},
+			},
+		},
+		{
+			name:          "changed, added and removed in the order of the new components followed by removed",
+			oldComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}, {Name: "label", Value: "a"}, {Name: "shell", Value: "apk add curl"}},
+			newComponents: []stage.DigestComponent{{Name: "env", Value: "A=1"}, {Name: "from", Value: "ubuntu"}, {Name: "shell", Value: "apk add curl"}},
+			expectedChanges: []DigestComponentChange{
+				{Name: "env", NewValue: "A=1", Added: true},
+				{Name: "from", OldValue: "alpine", NewValue: "ubuntu"},
+				{Name: "label", OldValue: "a", Removed: true},
+			},
+		},
+		{
+			name:          "components with the same name are distinguished by the occurrence number",
+			oldComponents: []stage.DigestComponent{{Name: "git", Value: "commit-1"}, {Name: "git", Value: "commit-2"}},
+			newComponents: []stage.DigestComponent{{Name: "git", Value: "commit-1"}, {Name: "git", Value: "commit-3"}, {Name: "git", Value: "commit-4"}},
+			expectedChanges: []DigestComponentChange{
+				{Name: "git #2", OldValue: "commit-2", NewValue: "commit-3"},
+				{Name: "git #3", NewValue: "commit-4", Added: true},
+			},
+		},
+		{
+			name:          "no previous components",
+			newComponents: []stage.DigestComponent{{Name: "from", Value: "alpine"}},
+			expectedChanges: []DigestComponentChange{
+				{Name: "from", NewValue: "alpine", Added: true},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			changes := DiffDigestComponents(test.oldComponents, test.newComponents)
+			if !reflect.DeepEqual(changes, test.expectedChanges) {
+				t.Errorf("expected changes %#v, got %#v", test.expectedChanges, changes)
+			}
+		})
+	}
+}
+
+func TestLoadDigestExplanations(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-digest-explanations-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	explanation := &StageDigestExplanation{
+		Digest:     "digest",
+		Components: []stage.DigestComponent{{Name: "from", Value: "alpine"}},
+	}
+
+	for _, test := range []struct {
+		name             string
+		data             string
+		expectedPrevious map[string]map[string]*StageDigestExplanation
+		expectedErr      bool
+	}{
+		{
+			name:             "no explanations file",
+			expectedPrevious: map[string]map[string]*StageDigestExplanation{},
+		},
+		{
+			name:             "explanations file",
+			data:             `{"app": {"from": {"digest": "digest", "components": [{"name": "from", "value": "alpine"}]}}}`,
+			expectedPrevious: map[string]map[string]*StageDigestExplanation{"app": {"from": explanation}},
+		},
+		{
+			name:        "invalid explanations file",
+			data:        `{"app": [`,
+			expectedErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			projectName := filepath.Base(t.Name())
+			if test.data != "" {
+				if err := os.MkdirAll(GetDigestExplanationsDir(), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(GetDigestExplanationsDir(), projectName+".json"), []byte(test.data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			explanations, err := loadDigestExplanations(projectName)
+			if test.expectedErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(explanations.Previous, test.expectedPrevious) {
+				t.Errorf("expected previous explanations %#v, got %#v", test.expectedPrevious, explanations.Previous)
+			}
+			if len(explanations.Current) != 0 {
+				t.Errorf("expected no current explanations, got %#v", explanations.Current)
+			}
+		})
+	}
+}
+
+func TestDigestExplanationsSave(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-digest-explanations-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	oldExplanation := &StageDigestExplanation{Digest: "digest-1", Components: []stage.DigestComponent{{Name: "from", Value: "alpine"}}}
+	newExplanation := &StageDigestExplanation{Digest: "digest-2", Components: []stage.DigestComponent{{Name: "from", Value: "ubuntu"}}}
+	otherExplanation := &StageDigestExplanation{Digest: "digest-3", Components: []stage.DigestComponent{{Name: "from", Value: "debian"}}}
+
+	explanations, err := loadDigestExplanations("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	explanations.Set("app", "from", oldExplanation)
+	explanations.Set("other", "from", otherExplanation)
+	if err := explanations.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	explanations, err = loadDigestExplanations("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous := explanations.Set("app", "from", newExplanation); !reflect.DeepEqual(previous, oldExplanation) {
+		t.Errorf("expected previous explanation %#v, got %#v", oldExplanation, previous)
+	}
+	if err := explanations.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	explanations, err = loadDigestExplanations("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPrevious := map[string]map[string]*StageDigestExplanation{
+		"app":   {"from": newExplanation},
+		"other": {"from": otherExplanation},
+	}
+	if !reflect.DeepEqual(explanations.Previous, expectedPrevious) {
+		t.Errorf("expected explanations of images which have not been built to be kept, got %#v", explanations.Previous)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
+	"github.com/werf/werf/pkg/slug"
 )
 
 type BaseImageType string
@@ -25,7 +26,8 @@ const (
 )
 
 type Image struct {
-	name string
+	name     string
+	platform string
 
 	baseImageName      string
 	baseImageImageName string
@@ -43,11 +45,19 @@ type Image struct {
 }
 
 func (i *Image) LogName() string {
-	return logging.ImageLogName(i.name, i.isArtifact)
+	return i.withPlatformLogSuffix(logging.ImageLogName(i.name, i.isArtifact))
 }
 
 func (i *Image) LogDetailedName() string {
-	return logging.ImageLogProcessName(i.name, i.isArtifact)
+	return i.withPlatformLogSuffix(logging.ImageLogProcessName(i.name, i.isArtifact))
+}
+
+func (i *Image) withPlatformLogSuffix(logName string) string {
+	if i.platform == "" {
+		return logName
+	}
+
+	return fmt.Sprintf("%s [%s]", logName, i.platform)
 }
 
 func (i *Image) LogProcessStyle() *style.Style {
@@ -128,6 +138,20 @@ func (i *Image) GetName() string {
 	return i.name
 }
 
+// GetPlatform returns the platform the image is built for, the empty platform means the host platform
+func (i *Image) GetPlatform() string {
+	return i.platform
+}
+
+// getNameWithPlatform returns the name which is unique among images built for different platforms from the same image config
+func (i *Image) getNameWithPlatform() string {
+	if i.platform == "" {
+		return i.name
+	}
+
+	return fmt.Sprintf("%s-%s", i.name, slug.Slug(i.platform))
+}
+
 func (i *Image) GetLogName() string {
 	return i.LogName()
 }
@@ -135,7 +159,7 @@ func (i *Image) GetLogName() string {
 func (i *Image) SetupBaseImage(c *Conveyor) {
 	if i.baseImageImageName != "" {
 		i.baseImageType = StageAsBaseImage
-		i.stageAsBaseImage = c.GetImage(i.baseImageImageName).GetLastNonEmptyStage()
+		i.stageAsBaseImage = c.getImage(i.baseImageImageName, i.platform).GetLastNonEmptyStage()
 		i.baseImage = c.GetOrCreateStageImage(nil, i.stageAsBaseImage.GetImage().Name())
 	} else {
 		i.baseImageType = ImageFromRegistryAsBaseImage
@@ -150,7 +174,7 @@ func (i *Image) GetBaseImage() *container_runtime.StageImage {
 func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 	switch i.baseImageType {
 	case ImageFromRegistryAsBaseImage:
-		containerRuntime := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime)
+		containerRuntime := c.ContainerRuntime.(container_runtime.StageImageRuntime)
 
 		if inspect, err := containerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
 			return fmt.Errorf("unable to inspect local image %s: %s", i.baseImage.Name(), err)
@@ -207,6 +231,21 @@ func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 	return nil
 }
 
+// pinBaseImageForPlatform replaces the base image name with the digest reference of the image built for the platform
+func (i *Image) pinBaseImageForPlatform(ctx context.Context) error {
+	processMsg := fmt.Sprintf("Getting base image %s for platform %s from registry", i.baseImageName, i.platform)
+	return logboek.Context(ctx).Info().LogProcessInline(processMsg).DoError(func() error {
+		info, err := docker_registry.API().GetRepoImageForPlatform(ctx, i.baseImageName, i.platform)
+		if err != nil {
+			return fmt.Errorf("can not get base image %s for platform %s from registry: %s", i.baseImageName, i.platform, err)
+		}
+
+		i.baseImageName = fmt.Sprintf("%s@%s", info.Repository, info.RepoDigest)
+
+		return nil
+	})
+}
+
 func (i *Image) getFromBaseImageIdFromRegistry(ctx context.Context, c *Conveyor, baseImageName string) (string, error) {
 	c.getServiceRWMutex("baseImagesRepoIdsCache" + baseImageName).Lock()
 	defer c.getServiceRWMutex("baseImagesRepoIdsCache" + baseImageName).Unlock()
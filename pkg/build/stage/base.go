@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/werf/logboek"
 
@@ -63,9 +64,12 @@ var (
 type NewBaseStageOptions struct {
 	ImageName        string
 	ConfigMounts     []*config.Mount
+	ConfigSecrets    []*config.Secret
+	ConfigLimits     *config.Limits
 	ImageTmpDir      string
 	ContainerWerfDir string
 	ProjectName      string
+	Platform         string
 }
 
 func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
@@ -73,9 +77,11 @@ func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
 	s.name = name
 	s.imageName = options.ImageName
 	s.configMounts = options.ConfigMounts
+	s.limits = options.ConfigLimits.GetStageLimits(string(name))
 	s.imageTmpDir = options.ImageTmpDir
 	s.containerWerfDir = options.ContainerWerfDir
 	s.projectName = options.ProjectName
+	s.platform = options.Platform
 	return s
 }
 
@@ -89,7 +95,9 @@ type BaseStage struct {
 	imageTmpDir      string
 	containerWerfDir string
 	configMounts     []*config.Mount
+	limits           config.StageLimits
 	projectName      string
+	platform         string
 }
 
 func (s *BaseStage) LogDetailedName() string {
@@ -98,6 +106,10 @@ func (s *BaseStage) LogDetailedName() string {
 		imageName = "~"
 	}
 
+	if s.platform != "" {
+		return fmt.Sprintf("%s/%s [%s]", imageName, s.Name(), s.platform)
+	}
+
 	return fmt.Sprintf("%s/%s", imageName, s.Name())
 }
 
@@ -137,6 +149,8 @@ func (s *BaseStage) getNextStageGitDependencies(ctx context.Context, c Conveyor)
 			}
 			args = append(args, latestCommitInfo.Commit)
 		}
+
+		AddDigestComponent(ctx, fmt.Sprintf("%s commit", gitMapping.digestComponentName()), args[len(args)-1])
 	}
 
 	logboek.Context(ctx).Debug().LogF("Stage %q next stage dependencies: %#v\n", s.Name(), args)
@@ -247,9 +261,22 @@ func (s *BaseStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage
 		return fmt.Errorf("error adding mounts volumes: %s", err)
 	}
 
+	if s.limits.Memory != "" {
+		image.Container().RunOptions().AddMemory(s.limits.Memory)
+	}
+
+	if s.limits.Cpus != "" {
+		image.Container().RunOptions().AddCpus(s.limits.Cpus)
+	}
+
 	return nil
 }
 
+// GetTimeout returns the maximum duration of the stage build, zero means no limit
+func (s *BaseStage) GetTimeout() time.Duration {
+	return s.limits.Timeout
+}
+
 func (s *BaseStage) addProjectRepoCommitToLabels(ctx context.Context, c Conveyor, image container_runtime.ImageInterface) error {
 	if commit, err := c.GetProjectRepoCommit(ctx); err != nil {
 		return fmt.Errorf("unable to get project repo commit: %s", err)
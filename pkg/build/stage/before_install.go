@@ -11,7 +11,9 @@ import (
 func GenerateBeforeInstallStage(ctx context.Context, imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) *BeforeInstallStage {
 	b := getBuilder(imageBaseConfig, baseStageOptions)
 	if b != nil && !b.IsBeforeInstallEmpty(ctx) {
-		return newBeforeInstallStage(b, baseStageOptions)
+		s := newBeforeInstallStage(b, baseStageOptions)
+		s.retryPolicy = imageBaseConfig.GetUserStageRetryPolicy(string(BeforeInstall))
+		return s
 	}
 
 	return nil
@@ -28,11 +30,11 @@ type BeforeInstallStage struct {
 }
 
 func (s *BeforeInstallStage) GetDependencies(ctx context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
-	return s.builder.BeforeInstallChecksum(ctx), nil
+	return s.addInstructionsDigestComponent(ctx, s.builder.BeforeInstallChecksum(ctx)), nil
 }
 
 func (s *BeforeInstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
-	if err := s.BaseStage.PrepareImage(ctx, c, prevBuiltImage, image); err != nil {
+	if err := s.UserStage.PrepareImage(ctx, c, prevBuiltImage, image); err != nil {
 		return err
 	}
 
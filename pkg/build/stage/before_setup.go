@@ -12,7 +12,9 @@ import (
 func GenerateBeforeSetupStage(ctx context.Context, imageBaseConfig *config.StapelImageBase, gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *BeforeSetupStage {
 	b := getBuilder(imageBaseConfig, baseStageOptions)
 	if b != nil && !b.IsBeforeSetupEmpty(ctx) {
-		return newBeforeSetupStage(b, gitPatchStageOptions, baseStageOptions)
+		s := newBeforeSetupStage(b, gitPatchStageOptions, baseStageOptions)
+		s.retryPolicy = imageBaseConfig.GetUserStageRetryPolicy(string(BeforeSetup))
+		return s
 	}
 
 	return nil
@@ -34,7 +36,7 @@ func (s *BeforeSetupStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.BeforeSetupChecksum(ctx), stageDependenciesChecksum), nil
+	return util.Sha256Hash(s.addInstructionsDigestComponent(ctx, s.builder.BeforeSetupChecksum(ctx)), stageDependenciesChecksum), nil
 }
 
 func (s *BeforeSetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
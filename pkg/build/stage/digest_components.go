@@ -0,0 +1,49 @@
+package stage
+
+import (
+	"context"
+	"sync"
+)
+
+// DigestComponent is a named input of the stage digest
+type DigestComponent struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// DigestComponents collects inputs of the stage digest in the order they are hashed
+type DigestComponents struct {
+	mux        sync.Mutex
+	Components []DigestComponent
+}
+
+type digestComponentsContextKey struct{}
+
+// ContextWithDigestComponents returns context which records digest components added by GetDependencies and GetNextStageDependencies of stages
+func ContextWithDigestComponents(ctx context.Context) (context.Context, *DigestComponents) {
+	components := &DigestComponents{}
+	return context.WithValue(ctx, digestComponentsContextKey{}, components), components
+}
+
+func getDigestComponents(ctx context.Context) *DigestComponents {
+	if components, ok := ctx.Value(digestComponentsContextKey{}).(*DigestComponents); ok {
+		return components
+	}
+	return nil
+}
+
+func isDigestComponentsRecorded(ctx context.Context) bool {
+	return getDigestComponents(ctx) != nil
+}
+
+// AddDigestComponent records the digest component if the context has been created by ContextWithDigestComponents
+func AddDigestComponent(ctx context.Context, name, value string) {
+	components := getDigestComponents(ctx)
+	if components == nil {
+		return
+	}
+
+	components.mux.Lock()
+	defer components.mux.Unlock()
+	components.Components = append(components.Components, DigestComponent{Name: name, Value: value})
+}
@@ -2,7 +2,9 @@ package stage
 
 import (
 	"context"
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
@@ -30,9 +32,27 @@ type DockerInstructionsStage struct {
 	instructions *config.Docker
 }
 
-func (s *DockerInstructionsStage) GetDependencies(_ context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
+func (s *DockerInstructionsStage) GetDependencies(ctx context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
 
+	if isDigestComponentsRecorded(ctx) {
+		AddDigestComponent(ctx, "VOLUME", strings.Join(s.instructions.Volume, " "))
+		AddDigestComponent(ctx, "EXPOSE", strings.Join(s.instructions.Expose, " "))
+		envArgs := mapToSortedArgs(s.instructions.Env)
+		for i := 0; i < len(envArgs); i += 2 {
+			AddDigestComponent(ctx, fmt.Sprintf("ENV %s", envArgs[i]), envArgs[i+1])
+		}
+		labelArgs := mapToSortedArgs(s.instructions.Label)
+		for i := 0; i < len(labelArgs); i += 2 {
+			AddDigestComponent(ctx, fmt.Sprintf("LABEL %s", labelArgs[i]), labelArgs[i+1])
+		}
+		AddDigestComponent(ctx, "CMD", s.instructions.Cmd)
+		AddDigestComponent(ctx, "ENTRYPOINT", s.instructions.Entrypoint)
+		AddDigestComponent(ctx, "WORKDIR", s.instructions.Workdir)
+		AddDigestComponent(ctx, "USER", s.instructions.User)
+		AddDigestComponent(ctx, "HEALTHCHECK", s.instructions.HealthCheck)
+	}
+
 	args = append(args, s.instructions.Volume...)
 	args = append(args, s.instructions.Expose...)
 	args = append(args, mapToSortedArgs(s.instructions.Env)...)
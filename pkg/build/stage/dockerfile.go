@@ -276,6 +276,15 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, _ Conveyor, _, _
 		}
 	}
 
+	for ind, dependency := range stagesDependencies[s.dockerTargetStageIndex] {
+		AddDigestComponent(ctx, fmt.Sprintf("dockerfile dependency %d", ind), dependency)
+	}
+
+	if s.platform != "" {
+		AddDigestComponent(ctx, "platform", s.platform)
+		return util.Sha256Hash(append(stagesDependencies[s.dockerTargetStageIndex], s.platform)...), nil
+	}
+
 	return util.Sha256Hash(stagesDependencies[s.dockerTargetStageIndex]...), nil
 }
 
@@ -381,6 +390,10 @@ func (s *DockerfileStage) DockerBuildArgs() []string {
 		result = append(result, fmt.Sprintf("--ssh=%s", s.ssh))
 	}
 
+	if s.platform != "" {
+		result = append(result, fmt.Sprintf("--platform=%s", s.platform))
+	}
+
 	result = append(result, s.context)
 
 	return result
@@ -26,12 +26,13 @@ func GenerateFromStage(imageBaseConfig *config.StapelImageBase, baseImageRepoId
 		fromImageOrArtifactImageName = imageBaseConfig.FromArtifactName
 	}
 
-	return newFromStage(fromImageOrArtifactImageName, baseImageRepoIdOrNone, imageBaseConfig.FromCacheVersion, baseStageOptions)
+	return newFromStage(imageBaseConfig.From, fromImageOrArtifactImageName, baseImageRepoIdOrNone, imageBaseConfig.FromCacheVersion, baseStageOptions)
 }
 
-func newFromStage(fromImageOrArtifactImageName, baseImageRepoIdOrNone, cacheVersion string, baseStageOptions *NewBaseStageOptions) *FromStage {
+func newFromStage(baseImageName, fromImageOrArtifactImageName, baseImageRepoIdOrNone, cacheVersion string, baseStageOptions *NewBaseStageOptions) *FromStage {
 	s := &FromStage{}
 	s.cacheVersion = cacheVersion
+	s.baseImageName = baseImageName
 	s.fromImageOrArtifactImageName = fromImageOrArtifactImageName
 	s.baseImageRepoIdOrNone = baseImageRepoIdOrNone
 	s.BaseStage = newBaseStage(From, baseStageOptions)
@@ -41,30 +42,46 @@ func newFromStage(fromImageOrArtifactImageName, baseImageRepoIdOrNone, cacheVers
 type FromStage struct {
 	*BaseStage
 
+	baseImageName                string
 	fromImageOrArtifactImageName string
 	baseImageRepoIdOrNone        string
 	cacheVersion                 string
 }
 
-func (s *FromStage) GetDependencies(_ context.Context, c Conveyor, prevImage, _ container_runtime.ImageInterface) (string, error) {
+func (s *FromStage) GetDependencies(ctx context.Context, c Conveyor, prevImage, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
 
 	if s.cacheVersion != "" {
 		args = append(args, s.cacheVersion)
+		AddDigestComponent(ctx, "fromCacheVersion", s.cacheVersion)
 	}
 
 	if s.baseImageRepoIdOrNone != "" {
 		args = append(args, s.baseImageRepoIdOrNone)
+		AddDigestComponent(ctx, "base image ID", s.baseImageRepoIdOrNone)
 	}
 
 	for _, mount := range s.configMounts {
 		args = append(args, filepath.ToSlash(filepath.Clean(mount.From)), path.Clean(mount.To), mount.Type)
+		AddDigestComponent(ctx, fmt.Sprintf("mount %s", path.Clean(mount.To)), fmt.Sprintf("%s %s", mount.Type, filepath.ToSlash(filepath.Clean(mount.From))))
 	}
 
 	if s.fromImageOrArtifactImageName != "" {
-		args = append(args, c.GetImageContentDigest(s.fromImageOrArtifactImageName))
+		contentDigest := c.GetImageContentDigest(s.fromImageOrArtifactImageName)
+		args = append(args, contentDigest)
+		AddDigestComponent(ctx, fmt.Sprintf("fromImage %s content digest", s.fromImageOrArtifactImageName), contentDigest)
+	} else if s.platform != "" {
+		// the base image of the platform is pulled by the digest, so the configured base image name is used instead
+		args = append(args, s.baseImageName)
+		AddDigestComponent(ctx, "base image", s.baseImageName)
 	} else {
 		args = append(args, prevImage.Name())
+		AddDigestComponent(ctx, "base image", prevImage.Name())
+	}
+
+	if s.platform != "" {
+		args = append(args, s.platform)
+		AddDigestComponent(ctx, "platform", s.platform)
 	}
 
 	return util.Sha256Hash(args...), nil
@@ -45,10 +45,11 @@ func (s *GitArchiveStage) SelectSuitableStage(ctx context.Context, c Conveyor, s
 	return s.selectStageByOldestCreationTimestamp(ancestorsStages)
 }
 
-func (s *GitArchiveStage) GetDependencies(_ context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
+func (s *GitArchiveStage) GetDependencies(ctx context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
 	for _, gitMapping := range s.gitMappings {
 		args = append(args, gitMapping.GetParamshash())
+		AddDigestComponent(ctx, fmt.Sprintf("%s params", gitMapping.digestComponentName()), gitMapping.GetParamshash())
 	}
 
 	sort.Strings(args)
@@ -52,6 +52,8 @@ func (s *GitCacheStage) GetDependencies(ctx context.Context, c Conveyor, _, prev
 		return "", err
 	}
 
+	AddDigestComponent(ctx, "git patch size step", fmt.Sprintf("%d", patchSize/patchSizeStep))
+
 	return util.Sha256Hash(fmt.Sprintf("%d", patchSize/patchSizeStep)), nil
 }
 
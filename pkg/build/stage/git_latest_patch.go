@@ -60,6 +60,7 @@ func (s *GitLatestPatchStage) GetDependencies(ctx context.Context, c Conveyor, _
 		}
 
 		args = append(args, patchContent)
+		AddDigestComponent(ctx, fmt.Sprintf("%s patch checksum", gitMapping.digestComponentName()), util.Sha256Hash(patchContent))
 	}
 
 	return util.Sha256Hash(args...), nil
@@ -724,6 +724,39 @@ func (gm *GitMapping) StageDependenciesChecksum(ctx context.Context, c Conveyor,
 	return checksum.String(), nil
 }
 
+// StageDependenciesPathsChecksums returns checksum of each stage dependency path separately to explain which path changes the stage digest
+func (gm *GitMapping) StageDependenciesPathsChecksums(ctx context.Context, c Conveyor, stageName StageName) (map[string]string, error) {
+	depsPaths := gm.StagesDependencies[stageName]
+	if len(depsPaths) == 0 {
+		return nil, nil
+	}
+
+	commitInfo, err := gm.GetLatestCommitInfo(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get latest commit info: %s", err)
+	}
+
+	res := make(map[string]string)
+	for _, p := range depsPaths {
+		checksum, err := gm.getOrCreateChecksum(ctx, git_repo.ChecksumOptions{
+			FilterOptions: gm.getRepoFilterOptions(),
+			Paths:         []string{p},
+			Commit:        commitInfo.Commit,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		res[p] = checksum.String()
+	}
+
+	return res, nil
+}
+
+func (gm *GitMapping) digestComponentName() string {
+	return fmt.Sprintf("git %s add %s", gm.GetFullName(), gm.Add)
+}
+
 func (gm *GitMapping) PatchSize(ctx context.Context, c Conveyor, fromCommit string) (int64, error) {
 	toCommitInfo, err := gm.GetLatestCommitInfo(ctx, c)
 	if err != nil {
@@ -3,6 +3,7 @@ package stage
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
@@ -42,7 +43,7 @@ type ImportsStage struct {
 	imports []*config.Import
 }
 
-func (s *ImportsStage) GetDependencies(_ context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
+func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
 
 	for _, elm := range s.imports {
@@ -53,12 +54,17 @@ func (s *ImportsStage) GetDependencies(_ context.Context, c Conveyor, _, _ conta
 			imgName = elm.ArtifactName
 		}
 
+		importArgsStart := len(args)
+
 		if elm.Stage == "" {
 			args = append(args, c.GetImageContentDigest(imgName))
 		} else {
 			args = append(args, c.GetImageStageContentDigest(imgName, elm.Stage))
 		}
 
+		componentName := fmt.Sprintf("import %s add %s to %s", imgName, elm.Add, elm.To)
+		AddDigestComponent(ctx, fmt.Sprintf("%s source content digest", componentName), args[importArgsStart])
+
 		args = append(args, elm.Add, elm.To)
 		args = append(args, elm.Group, elm.Owner)
 		args = append(args, elm.IncludePaths...)
@@ -67,6 +73,8 @@ func (s *ImportsStage) GetDependencies(_ context.Context, c Conveyor, _, _ conta
 		if elm.Stage != "" {
 			args = append(args, elm.Stage)
 		}
+
+		AddDigestComponent(ctx, fmt.Sprintf("%s params", componentName), strings.Join(args[importArgsStart+1:], " "))
 	}
 
 	return util.Sha256Hash(args...), nil
@@ -12,7 +12,9 @@ import (
 func GenerateInstallStage(ctx context.Context, imageBaseConfig *config.StapelImageBase, gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *InstallStage {
 	b := getBuilder(imageBaseConfig, baseStageOptions)
 	if b != nil && !b.IsInstallEmpty(ctx) {
-		return newInstallStage(b, gitPatchStageOptions, baseStageOptions)
+		s := newInstallStage(b, gitPatchStageOptions, baseStageOptions)
+		s.retryPolicy = imageBaseConfig.GetUserStageRetryPolicy(string(Install))
+		return s
 	}
 
 	return nil
@@ -34,7 +36,7 @@ func (s *InstallStage) GetDependencies(ctx context.Context, c Conveyor, _, _ con
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.InstallChecksum(ctx), stageDependenciesChecksum), nil
+	return util.Sha256Hash(s.addInstructionsDigestComponent(ctx, s.builder.InstallChecksum(ctx)), stageDependenciesChecksum), nil
 }
 
 func (s *InstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -2,6 +2,7 @@ package stage
 
 import (
 	"context"
+	"time"
 
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
@@ -21,6 +22,8 @@ type Interface interface {
 
 	PreRunHook(context.Context, Conveyor) error
 
+	GetTimeout() time.Duration
+
 	SetDigest(digest string)
 	GetDigest() string
 
@@ -12,7 +12,9 @@ import (
 func GenerateSetupStage(ctx context.Context, imageBaseConfig *config.StapelImageBase, gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *SetupStage {
 	b := getBuilder(imageBaseConfig, baseStageOptions)
 	if b != nil && !b.IsSetupEmpty(ctx) {
-		return newSetupStage(b, gitPatchStageOptions, baseStageOptions)
+		s := newSetupStage(b, gitPatchStageOptions, baseStageOptions)
+		s.retryPolicy = imageBaseConfig.GetUserStageRetryPolicy(string(Setup))
+		return s
 	}
 
 	return nil
@@ -34,7 +36,7 @@ func (s *SetupStage) GetDependencies(ctx context.Context, c Conveyor, _, _ conta
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.SetupChecksum(ctx), stageDependenciesChecksum), nil
+	return util.Sha256Hash(s.addInstructionsDigestComponent(ctx, s.builder.SetupChecksum(ctx)), stageDependenciesChecksum), nil
 }
 
 func (s *SetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -2,12 +2,15 @@ package stage
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/build/builder"
 	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/util"
 )
 
@@ -26,6 +29,7 @@ func getBuilder(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBa
 func newUserStage(builder builder.Builder, name StageName, baseStageOptions *NewBaseStageOptions) *UserStage {
 	s := &UserStage{}
 	s.builder = builder
+	s.configSecrets = baseStageOptions.ConfigSecrets
 	s.BaseStage = newBaseStage(name, baseStageOptions)
 	return s
 }
@@ -33,7 +37,58 @@ func newUserStage(builder builder.Builder, name StageName, baseStageOptions *New
 type UserStage struct {
 	*BaseStage
 
-	builder builder.Builder
+	builder       builder.Builder
+	configSecrets []*config.Secret
+	retryPolicy   *config.RetryPolicy
+}
+
+// GetRetryPolicy returns the policy to retry the failed stage build, nil if the stage build should not be retried
+func (s *UserStage) GetRetryPolicy() *config.RetryPolicy {
+	return s.retryPolicy
+}
+
+func (s *UserStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
+	if err := s.BaseStage.PrepareImage(ctx, c, prevBuiltImage, image); err != nil {
+		return err
+	}
+
+	secrets, err := s.getSecrets()
+	if err != nil {
+		return err
+	}
+	image.Container().AddSecrets(secrets...)
+
+	return nil
+}
+
+// getSecrets reads secrets data from the local files and the environment,
+// secrets are not taken into account in the stage digest and are not saved into the image
+func (s *UserStage) getSecrets() ([]*container_runtime.Secret, error) {
+	var secrets []*container_runtime.Secret
+	for _, secretCfg := range s.configSecrets {
+		var data []byte
+		if secretCfg.FromPath != "" {
+			var err error
+			data, err = ioutil.ReadFile(util.ExpandPath(secretCfg.FromPath))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read secret file %s: %s", secretCfg.FromPath, err)
+			}
+		} else {
+			value, ok := os.LookupEnv(secretCfg.FromEnv)
+			if !ok {
+				return nil, fmt.Errorf("unable to get secret: environment variable %s is not set", secretCfg.FromEnv)
+			}
+			data = []byte(value)
+		}
+
+		secrets = append(secrets, &container_runtime.Secret{
+			Data: data,
+			Path: secretCfg.To,
+			Env:  secretCfg.Env,
+		})
+	}
+
+	return secrets, nil
 }
 
 func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor, name StageName) (string, error) {
@@ -52,11 +107,29 @@ func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor
 		}
 
 		args = append(args, checksum)
+
+		if isDigestComponentsRecorded(ctx) {
+			AddDigestComponent(ctx, fmt.Sprintf("%s stageDependencies.%s checksum", gitMapping.digestComponentName(), name), checksum)
+
+			pathsChecksums, err := gitMapping.StageDependenciesPathsChecksums(ctx, c, name)
+			if err != nil {
+				return "", err
+			}
+
+			for _, p := range gitMapping.StagesDependencies[name] {
+				AddDigestComponent(ctx, fmt.Sprintf("%s stageDependencies.%s path %s checksum", gitMapping.digestComponentName(), name, p), pathsChecksums[p])
+			}
+		}
 	}
 
 	return util.Sha256Hash(args...), nil
 }
 
+func (s *UserStage) addInstructionsDigestComponent(ctx context.Context, checksum string) string {
+	AddDigestComponent(ctx, fmt.Sprintf("%s instructions checksum", s.Name()), checksum)
+	return checksum
+}
+
 func debugUserStageChecksum() bool {
 	return os.Getenv("WERF_DEBUG_USER_STAGE_CHECKSUM") == "1"
 }
@@ -66,6 +66,8 @@ func newCleanupManager(projectName string, storageManager *manager.StorageManage
 type cleanupManager struct {
 	stages                     []*image.StageDescription
 	imageNameLinkListByStageID map[string][]string
+	manifestLists              []*storage.ManifestListDescription
+	keptManifestLists          map[string]bool
 
 	imageNameStageIDCommitList            map[string]map[string][]string
 	imageNameStageIDCommitListToCleanup   map[string]map[string][]string
@@ -113,6 +115,14 @@ func (m *cleanupManager) initStages(ctx context.Context) error {
 
 	m.stages = stages
 
+	manifestLists, err := m.StorageManager.StagesStorage.GetManifestLists(ctx, m.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	m.manifestLists = manifestLists
+	m.keptManifestLists = map[string]bool{}
+
 	return nil
 }
 
@@ -292,6 +302,14 @@ func (m *cleanupManager) run(ctx context.Context) error {
 		return err
 	}
 
+	if len(m.manifestLists) != 0 {
+		if err := logboek.Context(ctx).LogProcess("Cleanup unused manifest lists").DoError(func() error {
+			return m.cleanupUnusedManifestLists(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -301,23 +319,36 @@ func (m *cleanupManager) skipStageIDsThatAreUsedInKubernetes(ctx context.Context
 		return err
 	}
 
+	// platform images of the deployed manifest list are used in kubernetes as well
+	deployedPlatformImagesRepoDigests := map[string]bool{}
+	for _, manifestList := range m.manifestLists {
+		if !util.IsStringsContainValue(deployedDockerImagesNames, manifestList.Info.Name) {
+			continue
+		}
+
+		m.keptManifestLists[manifestList.Info.Name] = true
+		for _, repoDigest := range manifestList.PlatformImagesRepoDigests {
+			deployedPlatformImagesRepoDigests[repoDigest] = true
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", manifestList.Info.Tag)
+		logboek.Context(ctx).LogOptionalLn()
+	}
+
 	skippedDeployedImages := map[string]bool{}
 	for imageName, stageIDCommitList := range m.imageNameStageIDCommitListToCleanup {
-	Loop:
 		for stageID, _ := range stageIDCommitList {
 			dockerImageName := fmt.Sprintf("%s:%s", m.StorageManager.StagesStorage.String(), stageID)
-			for _, deployedDockerImageName := range deployedDockerImagesNames {
-				if deployedDockerImageName == dockerImageName {
-					m.keepStageID(imageName, stageID)
-
-					if !skippedDeployedImages[stageID] {
-						logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
-						logboek.Context(ctx).LogOptionalLn()
-						skippedDeployedImages[stageID] = true
-					}
+			if !util.IsStringsContainValue(deployedDockerImagesNames, dockerImageName) && !deployedPlatformImagesRepoDigests[m.mustGetStage(stageID).Info.RepoDigest] {
+				continue
+			}
 
-					continue Loop
-				}
+			m.keepStageID(imageName, stageID)
+
+			if !skippedDeployedImages[stageID] {
+				logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
+				logboek.Context(ctx).LogOptionalLn()
+				skippedDeployedImages[stageID] = true
 			}
 		}
 	}
@@ -676,6 +707,57 @@ func (m *cleanupManager) cleanupUnusedStages(ctx context.Context) error {
 	return nil
 }
 
+// cleanupUnusedManifestLists deletes manifest lists which refer to deleted stages, manifest lists used in kubernetes are kept
+func (m *cleanupManager) cleanupUnusedManifestLists(ctx context.Context) error {
+	stagesRepoDigests := map[string]bool{}
+	for _, stage := range m.stages {
+		stagesRepoDigests[stage.Info.RepoDigest] = true
+	}
+
+	var manifestListsToDelete []*storage.ManifestListDescription
+	for _, manifestList := range m.manifestLists {
+		if m.keptManifestLists[manifestList.Info.Name] {
+			continue
+		}
+
+		for _, repoDigest := range manifestList.PlatformImagesRepoDigests {
+			if !stagesRepoDigests[repoDigest] {
+				manifestListsToDelete = append(manifestListsToDelete, manifestList)
+				break
+			}
+		}
+	}
+
+	if len(manifestListsToDelete) == 0 {
+		return nil
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Deleting manifest lists tags").DoError(func() error {
+		return deleteManifestLists(ctx, m.ProjectName, m.StorageManager, m.DryRun, manifestListsToDelete)
+	})
+}
+
+func deleteManifestLists(ctx context.Context, projectName string, storageManager *manager.StorageManager, dryRun bool, manifestLists []*storage.ManifestListDescription) error {
+	for _, manifestList := range manifestLists {
+		if !dryRun {
+			if err := storageManager.StagesStorage.DeleteManifestList(ctx, projectName, manifestList); err != nil {
+				if err := handleDeletionError(err); err != nil {
+					return err
+				}
+
+				logboek.Context(ctx).Warn().LogF("WARNING: Manifest list %s deletion failed: %s\n", manifestList.Info.Name, err)
+
+				continue
+			}
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", manifestList.Info.Tag)
+		logboek.Context(ctx).LogOptionalLn()
+	}
+
+	return nil
+}
+
 func excludeStageAndRelativesByImageID(stages []*image.StageDescription, imageID string) []*image.StageDescription {
 	stage := findStageByImageID(stages, imageID)
 	if stage == nil {
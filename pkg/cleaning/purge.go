@@ -45,6 +45,17 @@ type purgeManager struct {
 }
 
 func (m *purgeManager) run(ctx context.Context) error {
+	if err := logboek.Context(ctx).Default().LogProcess("Deleting manifest lists").DoError(func() error {
+		manifestLists, err := m.StorageManager.StagesStorage.GetManifestLists(ctx, m.ProjectName)
+		if err != nil {
+			return err
+		}
+
+		return deleteManifestLists(ctx, m.ProjectName, m.StorageManager, m.DryRun, manifestLists)
+	}); err != nil {
+		return err
+	}
+
 	if err := logboek.Context(ctx).Default().LogProcess("Deleting stages").DoError(func() error {
 		stages, err := m.StorageManager.GetStageDescriptionList(ctx)
 		if err != nil {
@@ -10,10 +10,20 @@ type Ansible struct {
 	InstallCacheVersion       string
 	BeforeSetupCacheVersion   string
 	SetupCacheVersion         string
+	Retry                     *RetryPolicy
+	BeforeInstallRetry        *RetryPolicy
+	InstallRetry              *RetryPolicy
+	BeforeSetupRetry          *RetryPolicy
+	SetupRetry                *RetryPolicy
 
 	raw *rawAnsible
 }
 
+// GetRetryPolicy returns the retry policy of the user stage, nil means the stage build is not retried
+func (c *Ansible) GetRetryPolicy(userStageName string) *RetryPolicy {
+	return selectUserStageRetryPolicy(userStageName, c.Retry, c.BeforeInstallRetry, c.InstallRetry, c.BeforeSetupRetry, c.SetupRetry)
+}
+
 func (c *Ansible) GetDumpConfigSection() string {
 	return dumpConfigDoc(c.raw.rawImage.doc)
 }
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveStapelImagesExtends merges sections of the base stapel images into the images with the extends directive.
+// Merge rules:
+//   - shell and ansible: commands and tasks of the base are followed by commands and tasks of the image for each stage,
+//     cache versions and retry policies of the image override base ones;
+//   - git, import: base entries are followed by entries of the image;
+//   - mount: base mounts are followed by mounts of the image, the image mount overrides the base mount with the same `to`;
+//   - docker (images only): ENV and LABEL are merged, VOLUME and EXPOSE are appended, other instructions of the image override base ones.
+func resolveStapelImagesExtends(rawImages []*rawStapelImage) error {
+	rawImageByName := map[string]*rawStapelImage{}
+	for _, rawImage := range rawImages {
+		if rawImage.stapelImageType() == "images" {
+			for _, name := range rawImage.Images {
+				rawImageByName[name] = rawImage
+			}
+		} else {
+			rawImageByName[rawImage.Artifact] = rawImage
+		}
+	}
+
+	resolved := map[*rawStapelImage]bool{}
+	for _, rawImage := range rawImages {
+		if err := resolveStapelImageExtends(rawImage, rawImageByName, resolved, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resolveStapelImageExtends(rawImage *rawStapelImage, rawImageByName map[string]*rawStapelImage, resolved map[*rawStapelImage]bool, chain []string) error {
+	if resolved[rawImage] || rawImage.Extends == "" {
+		return nil
+	}
+
+	for _, name := range chain {
+		if name == rawImage.Extends {
+			return newDetailedConfigError(fmt.Sprintf("infinite extends loop detected: %s -> %s!", strings.Join(chain, " -> "), rawImage.Extends), nil, rawImage.doc)
+		}
+	}
+
+	base, ok := rawImageByName[rawImage.Extends]
+	if !ok {
+		return newDetailedConfigError(fmt.Sprintf("invalid `extends: %s`: no such stapel image or artifact!", rawImage.Extends), nil, rawImage.doc)
+	}
+
+	if base == rawImage {
+		return newDetailedConfigError(fmt.Sprintf("invalid `extends: %s`: image cannot extend itself!", rawImage.Extends), nil, rawImage.doc)
+	}
+
+	if err := resolveStapelImageExtends(base, rawImageByName, resolved, append(chain, rawImage.Extends)); err != nil {
+		return err
+	}
+
+	if err := rawImage.extend(base); err != nil {
+		return err
+	}
+
+	resolved[rawImage] = true
+
+	return nil
+}
+
+func (c *rawStapelImage) extend(base *rawStapelImage) error {
+	if (base.RawShell != nil && c.RawAnsible != nil) || (base.RawAnsible != nil && c.RawShell != nil) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `extends: %s`: cannot combine `shell` and `ansible` sections of the base and the image!", c.Extends), nil, c.doc)
+	}
+
+	if base.RawShell != nil {
+		if c.RawShell == nil {
+			c.RawShell = &rawShell{rawStapelImage: c}
+		}
+
+		if err := c.RawShell.extend(base.RawShell); err != nil {
+			return err
+		}
+	}
+
+	if base.RawAnsible != nil {
+		if c.RawAnsible == nil {
+			c.RawAnsible = &rawAnsible{rawImage: c}
+		}
+
+		c.RawAnsible.extend(base.RawAnsible)
+	}
+
+	c.RawGit = append(append([]*rawGit{}, base.RawGit...), c.RawGit...)
+	c.RawImport = append(append([]*rawImport{}, base.RawImport...), c.RawImport...)
+
+	var mounts []*rawMount
+baseMountsLoop:
+	for _, baseMount := range base.RawMount {
+		for _, mount := range c.RawMount {
+			if mount.To == baseMount.To {
+				continue baseMountsLoop
+			}
+		}
+		mounts = append(mounts, baseMount)
+	}
+	c.RawMount = append(mounts, c.RawMount...)
+
+	if base.RawDocker != nil && c.stapelImageType() == "images" {
+		if c.RawDocker == nil {
+			c.RawDocker = &rawDocker{rawStapelImage: c}
+		}
+
+		if err := c.RawDocker.extend(base.RawDocker); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *rawShell) extend(base *rawShell) error {
+	for _, commands := range []struct {
+		base  interface{}
+		value *interface{}
+	}{
+		{base.BeforeInstall, &c.BeforeInstall},
+		{base.Install, &c.Install},
+		{base.BeforeSetup, &c.BeforeSetup},
+		{base.Setup, &c.Setup},
+	} {
+		if merged, err := mergeStringOrStringArrays(commands.base, *commands.value, c, c.rawStapelImage.doc); err != nil {
+			return err
+		} else {
+			*commands.value = merged
+		}
+	}
+
+	c.CacheVersion = extendString(base.CacheVersion, c.CacheVersion)
+	c.BeforeInstallCacheVersion = extendString(base.BeforeInstallCacheVersion, c.BeforeInstallCacheVersion)
+	c.InstallCacheVersion = extendString(base.InstallCacheVersion, c.InstallCacheVersion)
+	c.BeforeSetupCacheVersion = extendString(base.BeforeSetupCacheVersion, c.BeforeSetupCacheVersion)
+	c.SetupCacheVersion = extendString(base.SetupCacheVersion, c.SetupCacheVersion)
+
+	c.Retry = extendRetry(base.Retry, c.Retry)
+	c.BeforeInstallRetry = extendRetry(base.BeforeInstallRetry, c.BeforeInstallRetry)
+	c.InstallRetry = extendRetry(base.InstallRetry, c.InstallRetry)
+	c.BeforeSetupRetry = extendRetry(base.BeforeSetupRetry, c.BeforeSetupRetry)
+	c.SetupRetry = extendRetry(base.SetupRetry, c.SetupRetry)
+
+	return nil
+}
+
+func (c *rawAnsible) extend(base *rawAnsible) {
+	c.BeforeInstall = append(append([]rawAnsibleTask{}, base.BeforeInstall...), c.BeforeInstall...)
+	c.Install = append(append([]rawAnsibleTask{}, base.Install...), c.Install...)
+	c.BeforeSetup = append(append([]rawAnsibleTask{}, base.BeforeSetup...), c.BeforeSetup...)
+	c.Setup = append(append([]rawAnsibleTask{}, base.Setup...), c.Setup...)
+
+	c.CacheVersion = extendString(base.CacheVersion, c.CacheVersion)
+	c.BeforeInstallCacheVersion = extendString(base.BeforeInstallCacheVersion, c.BeforeInstallCacheVersion)
+	c.InstallCacheVersion = extendString(base.InstallCacheVersion, c.InstallCacheVersion)
+	c.BeforeSetupCacheVersion = extendString(base.BeforeSetupCacheVersion, c.BeforeSetupCacheVersion)
+	c.SetupCacheVersion = extendString(base.SetupCacheVersion, c.SetupCacheVersion)
+
+	c.Retry = extendRetry(base.Retry, c.Retry)
+	c.BeforeInstallRetry = extendRetry(base.BeforeInstallRetry, c.BeforeInstallRetry)
+	c.InstallRetry = extendRetry(base.InstallRetry, c.InstallRetry)
+	c.BeforeSetupRetry = extendRetry(base.BeforeSetupRetry, c.BeforeSetupRetry)
+	c.SetupRetry = extendRetry(base.SetupRetry, c.SetupRetry)
+}
+
+func (c *rawDocker) extend(base *rawDocker) error {
+	if volume, err := mergeStringOrStringArrays(base.Volume, c.Volume, c, c.rawStapelImage.doc); err != nil {
+		return err
+	} else {
+		c.Volume = volume
+	}
+
+	if expose, err := mergeStringOrStringArrays(base.Expose, c.Expose, c, c.rawStapelImage.doc); err != nil {
+		return err
+	} else {
+		c.Expose = expose
+	}
+
+	c.Env = extendStringMap(base.Env, c.Env)
+	c.Label = extendStringMap(base.Label, c.Label)
+
+	if c.Cmd == nil {
+		c.Cmd = base.Cmd
+	}
+
+	if c.Entrypoint == nil {
+		c.Entrypoint = base.Entrypoint
+	}
+
+	c.Workdir = extendString(base.Workdir, c.Workdir)
+	c.User = extendString(base.User, c.User)
+	c.HealthCheck = extendString(base.HealthCheck, c.HealthCheck)
+
+	return nil
+}
+
+func mergeStringOrStringArrays(base, value interface{}, configSection interface{}, doc *doc) (interface{}, error) {
+	if base == nil {
+		return value, nil
+	} else if value == nil {
+		return base, nil
+	}
+
+	var merged []interface{}
+	for _, stringOrStringArray := range []interface{}{base, value} {
+		stringArray, err := InterfaceToStringArray(stringOrStringArray, configSection, doc)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range stringArray {
+			merged = append(merged, s)
+		}
+	}
+
+	return merged, nil
+}
+
+func extendString(base, value string) string {
+	if value == "" {
+		return base
+	}
+
+	return value
+}
+
+func extendRetry(base, value *rawRetry) *rawRetry {
+	if value == nil {
+		return base
+	}
+
+	return value
+}
+
+func extendStringMap(base, value map[string]string) map[string]string {
+	if len(base) == 0 {
+		return value
+	}
+
+	res := map[string]string{}
+	for k, v := range base {
+		res[k] = v
+	}
+
+	for k, v := range value {
+		res[k] = v
+	}
+
+	return res
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("extends", func() {
+	prepareConfig := func(content string) (*WerfConfig, error) {
+		docs, err := splitByDocs(content, "werf.yaml")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		meta, rawStapelImages, rawImagesFromDockerfile, err := splitByMetaAndRawImages(docs)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return prepareWerfConfig(rawStapelImages, rawImagesFromDockerfile, meta)
+	}
+
+	It("merges sections of the base image", func() {
+		werfConfig, err := prepareConfig(`configVersion: 1
+project: app
+---
+image: base
+from: alpine
+shell:
+  install: apk add curl
+  installCacheVersion: "1"
+mount:
+- from: tmp_dir
+  to: /var/cache/apk
+- from: build_dir
+  to: /root/.cache
+docker:
+  ENV:
+    A: base
+    B: base
+  EXPOSE: "80"
+  WORKDIR: /app
+---
+image: app
+from: alpine
+extends: base
+shell:
+  install:
+  - make
+  installCacheVersion: "2"
+mount:
+- fromPath: /cache
+  to: /root/.cache
+docker:
+  ENV:
+    B: app
+  EXPOSE: "443"
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		app := werfConfig.GetStapelImage("app")
+		Ω(app.Shell.Install).Should(Equal([]string{"apk add curl", "make"}))
+		Ω(app.Shell.InstallCacheVersion).Should(Equal("2"))
+
+		var mountsFrom []string
+		for _, mount := range app.Mount {
+			mountsFrom = append(mountsFrom, mount.To+":"+mount.Type)
+		}
+		Ω(mountsFrom).Should(Equal([]string{"/var/cache/apk:tmp_dir", "/root/.cache:custom_dir"}))
+
+		Ω(app.Docker.Env).Should(Equal(map[string]string{"A": "base", "B": "app"}))
+		Ω(app.Docker.Expose).Should(Equal([]string{"80", "443"}))
+		Ω(app.Docker.Workdir).Should(Equal("/app"))
+
+		base := werfConfig.GetStapelImage("base")
+		Ω(base.Shell.Install).Should(Equal([]string{"apk add curl"}))
+	})
+
+	It("rejects invalid extends", func() {
+		for _, content := range []string{
+			"image: app\nfrom: alpine\nextends: base\n",
+			"image: app\nfrom: alpine\nextends: app\n",
+			"image: a\nfrom: alpine\nextends: b\n---\nimage: b\nfrom: alpine\nextends: a\n",
+			"image: base\nfrom: alpine\nshell:\n  install: make\n---\nimage: app\nfrom: alpine\nextends: base\nansible:\n  install:\n  - command: make\n",
+		} {
+			_, err := prepareConfig("configVersion: 1\nproject: app\n---\n" + content)
+			Ω(err).Should(HaveOccurred(), content)
+		}
+	})
+})
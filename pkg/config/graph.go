@@ -0,0 +1,325 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type GraphNodeType string
+
+const (
+	ImageGraphNode           GraphNodeType = "image"
+	ArtifactGraphNode        GraphNodeType = "artifact"
+	DockerfileImageGraphNode GraphNodeType = "dockerfile"
+	GitGraphNode             GraphNodeType = "git"
+)
+
+type GraphEdgeType string
+
+const (
+	FromImageGraphEdge    GraphEdgeType = "fromImage"
+	FromArtifactGraphEdge GraphEdgeType = "fromArtifact"
+	ImportGraphEdge       GraphEdgeType = "import"
+	GitGraphEdge          GraphEdgeType = "git"
+)
+
+type GraphNode struct {
+	ID   string           `json:"id"`
+	Name string           `json:"name"`
+	Type GraphNodeType    `json:"type"`
+	Git  *GraphGitMapping `json:"git,omitempty"`
+}
+
+// GraphGitMapping describes the git mapping of the git node
+type GraphGitMapping struct {
+	Url               string              `json:"url,omitempty"` // empty for the local repository
+	Branch            string              `json:"branch,omitempty"`
+	Tag               string              `json:"tag,omitempty"`
+	Commit            string              `json:"commit,omitempty"`
+	Add               string              `json:"add"`
+	To                string              `json:"to"`
+	StageDependencies map[string][]string `json:"stageDependencies,omitempty"`
+}
+
+// GraphEdge means that the node To depends on the node From: the image To is built only after the image From or uses files of the git mapping From
+type GraphEdge struct {
+	From   string        `json:"from"`
+	To     string        `json:"to"`
+	Type   GraphEdgeType `json:"type"`
+	Import *GraphImport  `json:"import,omitempty"`
+}
+
+// GraphImport describes files of the import edge
+type GraphImport struct {
+	Add         string `json:"add"`
+	To          string `json:"to"`
+	Stage       string `json:"stage"`                 // user stage of the importing image, e.g. before install
+	SourceStage string `json:"sourceStage,omitempty"` // stage of the source image or artifact to import files from
+}
+
+// Graph is the build dependency graph of images, artifacts and git mappings described in werf.yaml
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+func (c *WerfConfig) GetGraph() *Graph {
+	graph := &Graph{}
+
+	var images []ImageInterface
+	for _, image := range c.StapelImages {
+		images = append(images, image)
+	}
+	for _, image := range c.ImagesFromDockerfile {
+		images = append(images, image)
+	}
+	for _, artifact := range c.Artifacts {
+		images = append(images, artifact)
+	}
+
+	for _, image := range images {
+		graph.Nodes = append(graph.Nodes, newImageGraphNode(image))
+	}
+
+	for _, image := range images {
+		stapelImage, ok := image.(StapelImageInterface)
+		if !ok {
+			continue
+		}
+
+		to := imageGraphNodeID(image)
+		if name := stapelImage.ImageBaseConfig().FromImageName; name != "" {
+			graph.addEdge(imageGraphNodeID(c.GetImage(name)), to, FromImageGraphEdge, nil)
+		}
+
+		if name := stapelImage.ImageBaseConfig().FromArtifactName; name != "" {
+			graph.addEdge(imageGraphNodeID(c.GetArtifact(name)), to, FromArtifactGraphEdge, nil)
+		}
+
+		for _, imp := range stapelImage.imports() {
+			if imp.ImageName != "" {
+				graph.addEdge(imageGraphNodeID(c.GetImage(imp.ImageName)), to, ImportGraphEdge, newGraphImport(imp))
+			} else if imp.ArtifactName != "" {
+				graph.addEdge(imageGraphNodeID(c.GetArtifact(imp.ArtifactName)), to, ImportGraphEdge, newGraphImport(imp))
+			}
+		}
+
+		if git := stapelImage.ImageBaseConfig().Git; git != nil {
+			var mappings []*GraphGitMapping
+			for _, gitLocal := range git.Local {
+				mappings = append(mappings, newGraphGitMapping(gitLocal.GitLocalExport, "", "", "", ""))
+			}
+			for _, gitRemote := range git.Remote {
+				mappings = append(mappings, newGraphGitMapping(gitRemote.GitLocalExport, gitRemote.Url, gitRemote.Branch, gitRemote.Tag, gitRemote.Commit))
+			}
+
+			for ind, mapping := range mappings {
+				node := &GraphNode{ID: fmt.Sprintf("%s/git/%d", to, ind), Name: mapping.name(), Type: GitGraphNode, Git: mapping}
+				graph.Nodes = append(graph.Nodes, node)
+				graph.addEdge(node.ID, to, GitGraphEdge, nil)
+			}
+		}
+	}
+
+	return graph
+}
+
+func newGraphImport(imp *Import) *GraphImport {
+	graphImport := &GraphImport{SourceStage: imp.Stage}
+	if imp.ArtifactExport != nil && imp.ExportBase != nil {
+		graphImport.Add = imp.Add
+		graphImport.To = imp.To
+	}
+
+	if imp.Before != "" {
+		graphImport.Stage = fmt.Sprintf("before %s", imp.Before)
+	} else if imp.After != "" {
+		graphImport.Stage = fmt.Sprintf("after %s", imp.After)
+	}
+
+	return graphImport
+}
+
+func newGraphGitMapping(export *GitLocalExport, url, branch, tag, commit string) *GraphGitMapping {
+	mapping := &GraphGitMapping{Url: url, Branch: branch, Tag: tag, Commit: commit}
+	if export == nil || export.GitExportBase == nil {
+		return mapping
+	}
+
+	if export.GitExport != nil && export.ExportBase != nil {
+		mapping.Add = export.Add
+		mapping.To = export.To
+	}
+
+	if stageDependencies := export.StageDependencies; stageDependencies != nil {
+		mapping.StageDependencies = map[string][]string{}
+		for stage, paths := range map[string][]string{
+			"install":     stageDependencies.Install,
+			"beforeSetup": stageDependencies.BeforeSetup,
+			"setup":       stageDependencies.Setup,
+		} {
+			if len(paths) != 0 {
+				mapping.StageDependencies[stage] = paths
+			}
+		}
+
+		if len(mapping.StageDependencies) == 0 {
+			mapping.StageDependencies = nil
+		}
+	}
+
+	return mapping
+}
+
+func (m *GraphGitMapping) name() string {
+	repo := "local"
+	if m.Url != "" {
+		repo = m.Url
+	}
+
+	return fmt.Sprintf("%s:%s -> %s", repo, m.Add, m.To)
+}
+
+// labelLines returns the node name and stage dependencies of the git node
+func (n *GraphNode) labelLines() []string {
+	lines := []string{n.Name}
+	if n.Git != nil {
+		for _, stage := range []string{"install", "beforeSetup", "setup"} {
+			if paths := n.Git.StageDependencies[stage]; len(paths) != 0 {
+				lines = append(lines, fmt.Sprintf("%s: %s", stage, strings.Join(paths, ", ")))
+			}
+		}
+	}
+
+	return lines
+}
+
+func (e *GraphEdge) label() string {
+	if e.Import == nil {
+		return string(e.Type)
+	}
+
+	label := fmt.Sprintf("%s %s -> %s", e.Type, e.Import.Add, e.Import.To)
+	if e.Import.Stage != "" {
+		label += fmt.Sprintf(" (%s)", e.Import.Stage)
+	}
+
+	return label
+}
+
+func newImageGraphNode(image ImageInterface) *GraphNode {
+	node := &GraphNode{ID: imageGraphNodeID(image), Name: image.GetName()}
+
+	switch i := image.(type) {
+	case StapelImageInterface:
+		if i.IsArtifact() {
+			node.Type = ArtifactGraphNode
+		} else {
+			node.Type = ImageGraphNode
+		}
+	case *ImageFromDockerfile:
+		node.Type = DockerfileImageGraphNode
+	}
+
+	if node.Name == "" {
+		node.Name = "~"
+	}
+
+	return node
+}
+
+func imageGraphNodeID(image ImageInterface) string {
+	name := image.GetName()
+	if name == "" {
+		name = "~"
+	}
+
+	if stapelImage, ok := image.(StapelImageInterface); ok && stapelImage.IsArtifact() {
+		return fmt.Sprintf("artifact/%s", name)
+	}
+
+	return fmt.Sprintf("image/%s", name)
+}
+
+func (g *Graph) addEdge(from, to string, edgeType GraphEdgeType, graphImport *GraphImport) {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Type == edgeType && (e.Import == graphImport || (e.Import != nil && graphImport != nil && *e.Import == *graphImport)) {
+			return
+		}
+	}
+
+	g.Edges = append(g.Edges, &GraphEdge{From: from, To: to, Type: edgeType, Import: graphImport})
+}
+
+// DOT returns the graph in the Graphviz DOT language
+func (g *Graph) DOT() string {
+	buf := bytes.NewBuffer(nil)
+
+	fmt.Fprintln(buf, "digraph werf {")
+	fmt.Fprintln(buf, "  rankdir=LR;")
+	for _, node := range g.Nodes {
+		shape := "box"
+		switch node.Type {
+		case ArtifactGraphNode:
+			shape = "ellipse"
+		case GitGraphNode:
+			shape = "note"
+		}
+
+		fmt.Fprintf(buf, "  %q [label=%q, shape=%s];\n", node.ID, strings.Join(node.labelLines(), "\n"), shape)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(buf, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.label())
+	}
+	fmt.Fprintln(buf, "}")
+
+	return buf.String()
+}
+
+// Mermaid returns the graph in the Mermaid flowchart syntax
+func (g *Graph) Mermaid() string {
+	buf := bytes.NewBuffer(nil)
+
+	ids := map[string]string{}
+	for i, node := range g.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	fmt.Fprintln(buf, "graph LR")
+	for _, node := range g.Nodes {
+		label := strings.Join(node.labelLines(), "<br/>")
+		switch node.Type {
+		case ArtifactGraphNode:
+			fmt.Fprintf(buf, "  %s([\"%s\"])\n", ids[node.ID], mermaidEscape(label))
+		case GitGraphNode:
+			fmt.Fprintf(buf, "  %s[/\"%s\"/]\n", ids[node.ID], mermaidEscape(label))
+		default:
+			fmt.Fprintf(buf, "  %s[\"%s\"]\n", ids[node.ID], mermaidEscape(label))
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(buf, "  %s -->|\"%s\"| %s\n", ids[edge.From], mermaidEscape(edge.label()), ids[edge.To])
+	}
+
+	return buf.String()
+}
+
+// JSON returns the graph as indented JSON
+func (g *Graph) JSON() (string, error) {
+	buf := bytes.NewBuffer(nil)
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(g); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
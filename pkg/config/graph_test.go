@@ -0,0 +1,71 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("images graph", func() {
+	artifact := &StapelImageArtifact{StapelImageBase: &StapelImageBase{Name: "assets"}}
+	base := &StapelImage{StapelImageBase: &StapelImageBase{Name: "base", From: "alpine"}}
+	app := &StapelImage{StapelImageBase: &StapelImageBase{
+		Name:          "app",
+		FromImageName: "base",
+		Import: []*Import{
+			{ArtifactName: "assets", ArtifactExport: &ArtifactExport{ExportBase: &ExportBase{Add: "/dist", To: "/app/public"}}, Before: "setup"},
+			{ArtifactName: "assets", ArtifactExport: &ArtifactExport{ExportBase: &ExportBase{Add: "/dist", To: "/app/public"}}, Before: "setup"},
+		},
+		Git: &GitManager{Local: []*GitLocal{{GitLocalExport: &GitLocalExport{GitExportBase: &GitExportBase{
+			GitExport:         &GitExport{ExportBase: &ExportBase{Add: "/", To: "/app"}},
+			StageDependencies: &StageDependencies{Install: []string{"package.json"}},
+		}}}}},
+	}}
+	backend := &ImageFromDockerfile{Name: "backend"}
+
+	werfConfig := &WerfConfig{
+		StapelImages:         []*StapelImage{base, app},
+		ImagesFromDockerfile: []*ImageFromDockerfile{backend},
+		Artifacts:            []*StapelImageArtifact{artifact},
+	}
+
+	It("returns image dependencies without duplicates", func() {
+		Ω(werfConfig.GetImageDependencies(app)).Should(Equal([]ImageInterface{base, artifact}))
+		Ω(werfConfig.GetImageDependencies(backend)).Should(BeEmpty())
+	})
+
+	It("builds graph nodes and edges", func() {
+		graph := werfConfig.GetGraph()
+
+		Ω(graph.Nodes).Should(Equal([]*GraphNode{
+			{ID: "image/base", Name: "base", Type: ImageGraphNode},
+			{ID: "image/app", Name: "app", Type: ImageGraphNode},
+			{ID: "image/backend", Name: "backend", Type: DockerfileImageGraphNode},
+			{ID: "artifact/assets", Name: "assets", Type: ArtifactGraphNode},
+			{ID: "image/app/git/0", Name: "local:/ -> /app", Type: GitGraphNode, Git: &GraphGitMapping{
+				Add:               "/",
+				To:                "/app",
+				StageDependencies: map[string][]string{"install": {"package.json"}},
+			}},
+		}))
+
+		Ω(graph.Edges).Should(Equal([]*GraphEdge{
+			{From: "image/base", To: "image/app", Type: FromImageGraphEdge},
+			{From: "artifact/assets", To: "image/app", Type: ImportGraphEdge, Import: &GraphImport{Add: "/dist", To: "/app/public", Stage: "before setup"}},
+			{From: "image/app/git/0", To: "image/app", Type: GitGraphEdge},
+		}))
+	})
+
+	It("renders DOT, Mermaid and JSON", func() {
+		graph := werfConfig.GetGraph()
+
+		Ω(graph.DOT()).Should(ContainSubstring(`"image/base" -> "image/app" [label="fromImage"];`))
+		Ω(graph.DOT()).Should(ContainSubstring(`"image/app/git/0" [label="local:/ -> /app\ninstall: package.json", shape=note];`))
+		Ω(graph.Mermaid()).Should(ContainSubstring(`n3 -->|"import /dist -> /app/public (before setup)"| n1`))
+		Ω(graph.Mermaid()).Should(ContainSubstring(`n4[/"local:/ -> /app<br/>install: package.json"/]`))
+
+		data, err := graph.JSON()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(data).Should(ContainSubstring(`"stageDependencies": {`))
+		Ω(data).Should(ContainSubstring(`"stage": "before setup"`))
+	})
+})
@@ -9,6 +9,7 @@ type ImageFromDockerfile struct {
 	AddHost    []string
 	Network    string
 	SSH        string
+	Platform   []string
 
 	raw *rawImageFromDockerfile
 }
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/util"
+)
+
+// IncludesLockFileName is the file next to werf.yaml, which pins commits of git includes specified by branch or tag
+const IncludesLockFileName = "werf-includes.lock"
+
+// updateIncludesLockHint describes the only way to write the lock file
+const updateIncludesLockHint = "run werf config update-includes-lock to update it"
+
+// Include is the source of werf.yaml templates shared between projects: git repository or local directory
+type Include struct {
+	Git    string
+	Branch string
+	Tag    string
+	Commit string
+	Add    string
+	Path   string
+
+	raw *rawInclude
+}
+
+type includeTemplate struct {
+	Name    string
+	Content string
+}
+
+type includesLock struct {
+	Includes []*includeLockRecord `yaml:"includes"`
+}
+
+type includeLockRecord struct {
+	Git    string `yaml:"git"`
+	Branch string `yaml:"branch,omitempty"`
+	Tag    string `yaml:"tag,omitempty"`
+	Commit string `yaml:"commit"`
+}
+
+func (l *includesLock) getCommit(include *Include) string {
+	for _, record := range l.Includes {
+		if record.Git == include.Git && record.Branch == include.Branch && record.Tag == include.Tag {
+			return record.Commit
+		}
+	}
+
+	return ""
+}
+
+func (l *includesLock) setCommit(include *Include, commit string) {
+	for _, record := range l.Includes {
+		if record.Git == include.Git && record.Branch == include.Branch && record.Tag == include.Tag {
+			record.Commit = commit
+			return
+		}
+	}
+
+	l.Includes = append(l.Includes, &includeLockRecord{Git: include.Git, Branch: include.Branch, Tag: include.Tag, Commit: commit})
+}
+
+// check requires the lock to have the records of all git includes specified by branch or tag and no other records
+func (l *includesLock) check(lockPath string, includes []*Include) error {
+	usedLock := &includesLock{}
+	for _, include := range includes {
+		if !include.isPinnedByLock() {
+			continue
+		}
+
+		commit := l.getCommit(include)
+		if commit == "" {
+			return fmt.Errorf("commit of include repo %s %s is not pinned in %s: %s", include.Git, include.refDescription(), lockPath, updateIncludesLockHint)
+		}
+		usedLock.setCommit(include, commit)
+	}
+
+	for _, record := range l.Includes {
+		recordInclude := &Include{Git: record.Git, Branch: record.Branch, Tag: record.Tag}
+		if usedLock.getCommit(recordInclude) == "" {
+			return fmt.Errorf("%s is out of date: include repo %s %s is not used in werf.yaml: %s", lockPath, recordInclude.Git, recordInclude.refDescription(), updateIncludesLockHint)
+		}
+	}
+
+	return nil
+}
+
+// UpdateIncludesLock resolves the latest commits of git includes specified by branch or tag and rewrites the lock file,
+// the lock file is removed when there are no such includes
+func UpdateIncludesLock(ctx context.Context, werfConfigPath string) error {
+	data, err := ioutil.ReadFile(werfConfigPath)
+	if err != nil {
+		return err
+	}
+
+	rawMeta, err := getRawMetaBeforeRender(data, werfConfigPath)
+	if err != nil {
+		return err
+	}
+
+	lock := &includesLock{}
+	for _, include := range rawMeta.toIncludes() {
+		if !include.isPinnedByLock() {
+			continue
+		}
+
+		commit, err := include.resolveGitCommit(ctx)
+		if err != nil {
+			return err
+		}
+
+		logboek.Context(ctx).Default().LogF("Include repo %s %s: commit %s\n", include.Git, include.refDescription(), commit)
+		lock.setCommit(include, commit)
+	}
+
+	lockPath := filepath.Join(filepath.Dir(werfConfigPath), IncludesLockFileName)
+	if len(lock.Includes) == 0 {
+		if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to remove %s: %s", lockPath, err)
+		}
+		return nil
+	}
+
+	return writeIncludesLock(lockPath, lock)
+}
+
+// getIncludesTemplates returns *.tmpl files of includes, commits of git includes specified by branch or tag are taken from the lock file,
+// which is never written here (see UpdateIncludesLock)
+func getIncludesTemplates(ctx context.Context, projectDir string, includes []*Include) ([]*includeTemplate, error) {
+	lockPath := filepath.Join(projectDir, IncludesLockFileName)
+	lock, err := readIncludesLock(lockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lock.check(lockPath, includes); err != nil {
+		return nil, err
+	}
+
+	var templates []*includeTemplate
+	for _, include := range includes {
+		var includeTemplates []*includeTemplate
+		if include.Path != "" {
+			includeTemplates, err = include.getLocalTemplates(projectDir)
+		} else {
+			includeTemplates, err = include.getGitTemplates(ctx, lock)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, includeTemplates...)
+	}
+
+	return templates, nil
+}
+
+// isPinnedByLock checks whether the commit of the git include is pinned in the lock file
+func (c *Include) isPinnedByLock() bool {
+	return c.Git != "" && c.Commit == ""
+}
+
+func (c *Include) refDescription() string {
+	if c.Branch != "" {
+		return fmt.Sprintf("branch %s", c.Branch)
+	}
+	return fmt.Sprintf("tag %s", c.Tag)
+}
+
+func (c *Include) getLocalTemplates(projectDir string) ([]*includeTemplate, error) {
+	dir := c.Path
+	if strings.HasPrefix(dir, "~") {
+		dir = util.ExpandPath(dir)
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(projectDir, dir)
+	}
+
+	if exists, err := util.DirExists(dir); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("include path %s does not exist or is not a directory", c.Path)
+	}
+
+	templatesPaths, err := getWerfConfigTemplates(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []*includeTemplate
+	for _, templatePath := range templatesPaths {
+		relPath, err := filepath.Rel(dir, templatePath)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadFile(templatePath)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, &includeTemplate{
+			Name:    path.Join("include", filepath.ToSlash(c.Path), filepath.ToSlash(relPath)),
+			Content: string(data),
+		})
+	}
+
+	return templates, nil
+}
+
+// resolveGitCommit fetches the remote repository and returns the latest commit of the branch or the commit of the tag
+func (c *Include) resolveGitCommit(ctx context.Context) (string, error) {
+	repo, err := git_repo.OpenRemoteRepo(getRepositoryID(c.Git), c.Git)
+	if err != nil {
+		return "", err
+	}
+
+	if err := repo.CloneAndFetch(ctx); err != nil {
+		return "", fmt.Errorf("unable to fetch include repo %s: %s", c.Git, err)
+	}
+
+	if c.Branch != "" {
+		return repo.LatestBranchCommit(ctx, c.Branch)
+	}
+	return repo.TagCommit(ctx, c.Tag)
+}
+
+// getGitTemplates reads templates from the commit of the remote repository, the remote is fetched only when the commit is not available locally
+func (c *Include) getGitTemplates(ctx context.Context, lock *includesLock) ([]*includeTemplate, error) {
+	repo, err := git_repo.OpenRemoteRepo(getRepositoryID(c.Git), c.Git)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := c.Commit
+	if commit == "" {
+		commit = lock.getCommit(c)
+	}
+
+	if _, err := repo.Clone(ctx); err != nil {
+		return nil, fmt.Errorf("unable to clone include repo %s: %s", c.Git, err)
+	}
+
+	if exists, err := repo.IsCommitExists(ctx, commit); err != nil {
+		return nil, err
+	} else if !exists {
+		if err := repo.Fetch(ctx); err != nil {
+			return nil, fmt.Errorf("unable to fetch include repo %s: %s", c.Git, err)
+		}
+	}
+
+	logboek.Context(ctx).Info().LogF("Using werf.yaml templates from repo %s commit %s\n", c.Git, commit)
+
+	files, err := repo.ReadCommitFiles(ctx, commit, c.Add, "*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read include repo %s templates: %s", c.Git, err)
+	}
+
+	var filesPaths []string
+	for filePath := range files {
+		filesPaths = append(filesPaths, filePath)
+	}
+	sort.Strings(filesPaths)
+
+	var templates []*includeTemplate
+	for _, filePath := range filesPaths {
+		templates = append(templates, &includeTemplate{
+			Name:    path.Join(c.gitTemplatesNamePrefix(), filePath),
+			Content: string(files[filePath]),
+		})
+	}
+
+	return templates, nil
+}
+
+// gitTemplatesNamePrefix distinguishes templates of the includes of the same repository with different refs or add paths
+func (c *Include) gitTemplatesNamePrefix() string {
+	var ref string
+	switch {
+	case c.Commit != "":
+		ref = path.Join("commit", c.Commit)
+	case c.Branch != "":
+		ref = path.Join("branch", c.Branch)
+	default:
+		ref = path.Join("tag", c.Tag)
+	}
+
+	return path.Join("include", getRepositoryID(c.Git), ref, strings.Trim(path.Clean("/"+c.Add), "/"))
+}
+
+func readIncludesLock(lockPath string) (*includesLock, error) {
+	lock := &includesLock{}
+
+	data, err := ioutil.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return lock, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", lockPath, err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, lock); err != nil {
+		return nil, fmt.Errorf("bad lock file %s: %s", lockPath, err)
+	}
+
+	return lock, nil
+}
+
+func writeIncludesLock(lockPath string, lock *includesLock) error {
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(lockPath, data, 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %s", lockPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("includes", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-config-includes-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	writeFile := func(relPath, content string) {
+		p := filepath.Join(tmpDir, relPath)
+		Ω(os.MkdirAll(filepath.Dir(p), 0755)).Should(Succeed())
+		Ω(ioutil.WriteFile(p, []byte(content), 0644)).Should(Succeed())
+	}
+
+	It("renders werf.yaml with templates from the local include, project templates redefine included ones", func() {
+		writeFile("shared/images/base.tmpl", `{{ define "base" }}from: alpine{{ end }}{{ define "install" }}install: [shared]{{ end }}`)
+		writeFile("project/.werf/install.tmpl", `{{ define "install" }}install: [project]{{ end }}`)
+		writeFile("project/werf.yaml", `configVersion: 1
+project: app
+include:
+- path: ../shared
+---
+image: app
+{{ include "base" . }}
+shell:
+  {{ include "install" . }}
+`)
+
+		werfConfigPath := filepath.Join(tmpDir, "project", "werf.yaml")
+		content, err := parseWerfConfigYaml(context.Background(), werfConfigPath, filepath.Join(tmpDir, "project", ".werf"), WerfConfigOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(content).Should(ContainSubstring("from: alpine"))
+		Ω(content).Should(ContainSubstring("install: [project]"))
+	})
+
+	It("rejects invalid includes", func() {
+		for _, content := range []string{
+			"configVersion: 1\ninclude:\n- git: https://github.com/werf/werf.git\n",
+			"configVersion: 1\ninclude:\n- path: ../shared\n  branch: main\n",
+			"configVersion: 1\ninclude:\n- git: https://github.com/werf/werf.git\n  tag: v1.2.0\n  add: templates\n",
+			"configVersion: 1\ninclude:\n{{- range $p := list \"a\" \"b\" }}\n- path: {{ $p }}\n{{- end }}\n",
+		} {
+			_, err := getRawMetaBeforeRender([]byte(content), "werf.yaml")
+			Ω(err).Should(HaveOccurred(), content)
+		}
+	})
+
+	It("pins commits in the lock file", func() {
+		lock := &includesLock{}
+		include := &Include{Git: "https://github.com/werf/werf.git", Branch: "main"}
+		lock.setCommit(include, "aaa")
+		lock.setCommit(include, "bbb")
+		lock.setCommit(&Include{Git: include.Git, Tag: "v1.2.0"}, "ccc")
+
+		lockPath := filepath.Join(tmpDir, IncludesLockFileName)
+		Ω(writeIncludesLock(lockPath, lock)).Should(Succeed())
+
+		readLock, err := readIncludesLock(lockPath)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(readLock.getCommit(include)).Should(Equal("bbb"))
+		Ω(readLock.getCommit(&Include{Git: include.Git, Tag: "v1.2.0"})).Should(Equal("ccc"))
+		Ω(readLock.getCommit(&Include{Git: include.Git, Branch: "develop"})).Should(BeEmpty())
+	})
+
+	It("requires the lock file to pin commits of all git includes specified by branch or tag and nothing else", func() {
+		repo := "https://github.com/werf/werf.git"
+		branchInclude := &Include{Git: repo, Branch: "main"}
+		tagInclude := &Include{Git: repo, Tag: "v1.2.0"}
+		commitInclude := &Include{Git: repo, Commit: "0123456789abcdef0123456789abcdef01234567"}
+		localInclude := &Include{Path: "templates"}
+
+		lock := &includesLock{}
+		lock.setCommit(branchInclude, "aaa")
+		lock.setCommit(tagInclude, "bbb")
+
+		Ω(lock.check(IncludesLockFileName, []*Include{branchInclude, tagInclude, commitInclude, localInclude})).Should(Succeed())
+		Ω(lock.check(IncludesLockFileName, []*Include{branchInclude, tagInclude, {Git: repo, Branch: "develop"}})).Should(MatchError(ContainSubstring("branch develop is not pinned")))
+		Ω(lock.check(IncludesLockFileName, []*Include{branchInclude})).Should(MatchError(ContainSubstring("is out of date: include repo %s tag v1.2.0 is not used", repo)))
+		Ω((&includesLock{}).check(IncludesLockFileName, []*Include{commitInclude, localInclude})).Should(Succeed())
+	})
+
+	It("does not write the lock file when rendering includes", func() {
+		_, err := getIncludesTemplates(context.Background(), tmpDir, []*Include{{Git: "https://github.com/werf/werf.git", Branch: "main"}})
+		Ω(err).Should(MatchError(ContainSubstring("werf config update-includes-lock")))
+
+		_, err = os.Stat(filepath.Join(tmpDir, IncludesLockFileName))
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("names templates of the includes of the same repo by ref and add path", func() {
+		repo := "https://github.com/werf/werf.git"
+		var prefixes []string
+		for _, include := range []*Include{
+			{Git: repo, Branch: "main"},
+			{Git: repo, Branch: "main", Add: "templates"},
+			{Git: repo, Branch: "main", Add: "/other/templates/"},
+			{Git: repo, Tag: "main"},
+			{Git: repo, Tag: "v1.2.0"},
+			{Git: repo, Commit: "0123456789abcdef0123456789abcdef01234567"},
+		} {
+			prefixes = append(prefixes, include.gitTemplatesNamePrefix())
+		}
+
+		Ω(prefixes[2]).Should(HaveSuffix("/branch/main/other/templates"))
+		for i := range prefixes {
+			for j := range prefixes {
+				if i != j {
+					Ω(prefixes[i]).ShouldNot(Equal(prefixes[j]))
+				}
+			}
+		}
+	})
+})
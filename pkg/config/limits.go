@@ -0,0 +1,61 @@
+package config
+
+import (
+	"time"
+)
+
+// StageLimits restricts resources of the stage assembly container, zero values mean no limit
+type StageLimits struct {
+	Timeout time.Duration
+	Memory  string
+	Cpus    string
+}
+
+// Limits are defined for all stages of the image and can be overridden for particular user stages
+type Limits struct {
+	StageLimits
+
+	BeforeInstall *StageLimits
+	Install       *StageLimits
+	BeforeSetup   *StageLimits
+	Setup         *StageLimits
+
+	raw *rawLimits
+}
+
+// GetStageLimits returns limits of the stage, limits of the user stage override limits of the image
+func (c *Limits) GetStageLimits(stageName string) StageLimits {
+	if c == nil {
+		return StageLimits{}
+	}
+
+	limits := c.StageLimits
+
+	var userStageLimits *StageLimits
+	switch stageName {
+	case "beforeInstall":
+		userStageLimits = c.BeforeInstall
+	case "install":
+		userStageLimits = c.Install
+	case "beforeSetup":
+		userStageLimits = c.BeforeSetup
+	case "setup":
+		userStageLimits = c.Setup
+	}
+
+	if userStageLimits != nil {
+		if userStageLimits.Timeout != 0 {
+			limits.Timeout = userStageLimits.Timeout
+		}
+
+		if userStageLimits.Memory != "" {
+			limits.Memory = userStageLimits.Memory
+		}
+
+		if userStageLimits.Cpus != "" {
+			limits.Cpus = userStageLimits.Cpus
+		}
+	}
+
+	return limits
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("limits", func() {
+	rawImage := &rawStapelImage{doc: &doc{RenderFilePath: "werf.yaml"}}
+
+	It("overrides image limits by user stage limits", func() {
+		raw := &rawLimits{
+			Timeout:    "30m",
+			Memory:     "2g",
+			Cpus:       1.5,
+			RawInstall: &rawStageLimits{Timeout: "1h", Cpus: 4},
+		}
+		raw.rawStapelImage = rawImage
+
+		limits, err := raw.toDirective()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(limits.GetStageLimits("beforeInstall")).Should(Equal(StageLimits{Timeout: 30 * time.Minute, Memory: "2g", Cpus: "1.5"}))
+		Ω(limits.GetStageLimits("install")).Should(Equal(StageLimits{Timeout: time.Hour, Memory: "2g", Cpus: "4"}))
+		Ω((*Limits)(nil).GetStageLimits("install")).Should(Equal(StageLimits{}))
+	})
+
+	It("rejects invalid limits", func() {
+		for _, raw := range []*rawLimits{
+			{Timeout: "forever"},
+			{Timeout: "-1m"},
+			{Memory: "1k"},
+			{Memory: "lots"},
+			{Cpus: 0},
+			{RawSetup: &rawStageLimits{Cpus: "many"}},
+		} {
+			raw.rawStapelImage = rawImage
+			_, err := raw.toDirective()
+			Ω(err).Should(HaveOccurred())
+		}
+	})
+})
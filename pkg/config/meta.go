@@ -5,4 +5,5 @@ type Meta struct {
 	Project         string
 	DeployTemplates MetaDeployTemplates
 	Cleanup         MetaCleanup
+	Includes        []*Include
 }
@@ -26,60 +26,72 @@ import (
 	"github.com/werf/werf/pkg/util"
 )
 
-func RenderWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, imagesToProcess []string) error {
-	werfConfig, err := GetWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, false)
+// WerfConfigOptions are the options of werf.yaml reading
+type WerfConfigOptions struct {
+	LogRenderedFilePath bool
+	Env                 string   // environment, which vars override default vars of the meta config section
+	ConfigVars          []string // KEY=VALUE vars, which override vars of the environment
+}
+
+func RenderWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, imagesToProcess []string, opts WerfConfigOptions) error {
+	content, err := renderWerfConfigContent(ctx, werfConfigPath, werfConfigTemplatesDir, imagesToProcess, opts)
 	if err != nil {
 		return err
 	}
 
-	if len(imagesToProcess) == 0 {
-		werfConfigRenderContent, err := parseWerfConfigYaml(ctx, werfConfigPath, werfConfigTemplatesDir)
-		if err != nil {
-			return fmt.Errorf("cannot parse config: %s", err)
-		}
-
-		fmt.Print(werfConfigRenderContent)
-	} else {
-		var imageDocs []string
-
-		for _, imageToProcess := range imagesToProcess {
-			if !werfConfig.HasImageOrArtifact(imageToProcess) {
-				return fmt.Errorf("specified image %s is not defined in werf.yaml", logging.ImageLogName(imageToProcess, false))
-			} else {
-				if i := werfConfig.GetArtifact(imageToProcess); i != nil {
-					imageDocs = append(imageDocs, string(i.raw.doc.Content))
-				} else if i := werfConfig.GetStapelImage(imageToProcess); i != nil {
-					imageDocs = append(imageDocs, string(i.raw.doc.Content))
-				} else if i := werfConfig.GetDockerfileImage(imageToProcess); i != nil {
-					imageDocs = append(imageDocs, string(i.raw.doc.Content))
-				}
-			}
-		}
-
-		fmt.Print(strings.Join(imageDocs, "---\n"))
-	}
+	fmt.Print(content)
 
 	return nil
 }
 
-func GetWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, logRenderedFilePath bool) (*WerfConfig, error) {
-	werfConfigRenderContent, err := parseWerfConfigYaml(ctx, werfConfigPath, werfConfigTemplatesDir)
+// renderWerfConfigContent returns the rendered config or the rendered docs of the specified images, both preceded by the resolved vars comment
+func renderWerfConfigContent(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, imagesToProcess []string, opts WerfConfigOptions) (string, error) {
+	werfConfig, err := GetWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, opts)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse config: %s", err)
+		return "", err
 	}
 
-	werfConfigRenderPath, err := tmp_manager.CreateWerfConfigRender(ctx)
+	vars, err := getWerfConfigVars(werfConfigPath, opts)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	if logRenderedFilePath {
-		logboek.Context(ctx).LogF("Using werf config render file: %s\n", werfConfigRenderPath)
+	varsComment, err := configVarsComment(vars, opts.Env)
+	if err != nil {
+		return "", err
 	}
 
-	err = writeWerfConfigRender(werfConfigRenderContent, werfConfigRenderPath)
+	if len(imagesToProcess) == 0 {
+		werfConfigRenderContent, err := parseWerfConfigYaml(ctx, werfConfigPath, werfConfigTemplatesDir, opts)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse config: %s", err)
+		}
+
+		return varsComment + werfConfigRenderContent, nil
+	}
+
+	var imageDocs []string
+	for _, imageToProcess := range imagesToProcess {
+		if !werfConfig.HasImageOrArtifact(imageToProcess) {
+			return "", fmt.Errorf("specified image %s is not defined in werf.yaml", logging.ImageLogName(imageToProcess, false))
+		} else {
+			if i := werfConfig.GetArtifact(imageToProcess); i != nil {
+				imageDocs = append(imageDocs, string(i.raw.doc.Content))
+			} else if i := werfConfig.GetStapelImage(imageToProcess); i != nil {
+				imageDocs = append(imageDocs, string(i.raw.doc.Content))
+			} else if i := werfConfig.GetDockerfileImage(imageToProcess); i != nil {
+				imageDocs = append(imageDocs, string(i.raw.doc.Content))
+			}
+		}
+	}
+
+	return varsComment + strings.Join(imageDocs, "---\n"), nil
+}
+
+func GetWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, opts WerfConfigOptions) (*WerfConfig, error) {
+	werfConfigRenderContent, werfConfigRenderPath, err := renderWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, opts)
 	if err != nil {
-		return nil, fmt.Errorf("unable to write rendered config to %s: %s", werfConfigRenderPath, err)
+		return nil, err
 	}
 
 	docs, err := splitByDocs(werfConfigRenderContent, werfConfigRenderPath)
@@ -122,6 +134,30 @@ func GetWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir s
 	return werfConfig, nil
 }
 
+// renderWerfConfig renders werf.yaml templates and writes the result into the render file, which is referred by config errors
+func renderWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, opts WerfConfigOptions) (string, string, error) {
+	werfConfigRenderContent, err := parseWerfConfigYaml(ctx, werfConfigPath, werfConfigTemplatesDir, opts)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse config: %s", err)
+	}
+
+	werfConfigRenderPath, err := tmp_manager.CreateWerfConfigRender(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	if opts.LogRenderedFilePath {
+		logboek.Context(ctx).LogF("Using werf config render file: %s\n", werfConfigRenderPath)
+	}
+
+	err = writeWerfConfigRender(werfConfigRenderContent, werfConfigRenderPath)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to write rendered config to %s: %s", werfConfigRenderPath, err)
+	}
+
+	return werfConfigRenderContent, werfConfigRenderPath, nil
+}
+
 func GetProjectName(ctx context.Context, projectDir string) (string, error) {
 	name := filepath.Base(projectDir)
 
@@ -203,7 +239,7 @@ func splitByDocs(werfConfigRenderContent string, werfConfigRenderPath string) ([
 	return docs, nil
 }
 
-func parseWerfConfigYaml(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string) (string, error) {
+func parseWerfConfigYaml(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, opts WerfConfigOptions) (string, error) {
 	data, err := ioutil.ReadFile(werfConfigPath)
 	if err != nil {
 		return "", err
@@ -212,6 +248,28 @@ func parseWerfConfigYaml(ctx context.Context, werfConfigPath, werfConfigTemplate
 	tmpl := template.New("werfConfig")
 	tmpl.Funcs(funcMap(tmpl))
 
+	rawMeta, err := getRawMetaBeforeRender(data, werfConfigPath)
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := rawMeta.resolveConfigVars(opts.Env, opts.ConfigVars)
+	if err != nil {
+		return "", err
+	}
+
+	// included templates are added first, so project templates can redefine them
+	includesTemplates, err := getIncludesTemplates(ctx, filepath.Dir(werfConfigPath), rawMeta.toIncludes())
+	if err != nil {
+		return "", err
+	}
+
+	for _, includeTemplate := range includesTemplates {
+		if err := addTemplate(tmpl, includeTemplate.Name, includeTemplate.Content); err != nil {
+			return "", err
+		}
+	}
+
 	werfConfigsTemplates, err := getWerfConfigTemplates(werfConfigTemplatesDir)
 	if err != nil {
 		return "", err
@@ -240,11 +298,44 @@ func parseWerfConfigYaml(ctx context.Context, werfConfigPath, werfConfigTemplate
 	}
 
 	files := files{ctx: ctx, ProjectDir: filepath.Dir(werfConfigPath)}
-	config, err := executeTemplate(tmpl, "werfConfig", map[string]interface{}{"Files": files})
+	config, err := executeTemplate(tmpl, "werfConfig", map[string]interface{}{"Files": files, "Vars": vars})
 
 	return config, err
 }
 
+var metaBeforeRenderDirectiveRegexp = regexp.MustCompile(`(?m)^(include|vars|environments)\s*:`)
+
+// getRawMetaBeforeRender reads the meta config section directives required for werf.yaml rendering (include, vars and environments),
+// config sections which cannot be parsed before rendering are skipped, so these directives cannot be templated
+func getRawMetaBeforeRender(werfConfigContent []byte, werfConfigPath string) (*rawMetaBeforeRender, error) {
+	docs, err := splitByDocs(string(werfConfigContent), werfConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	parentStack = util.NewStack()
+	for _, d := range docs {
+		rawMeta := &rawMetaBeforeRender{doc: d}
+		if err := yaml.Unmarshal(d.Content, rawMeta); err != nil {
+			if _, ok := err.(*configError); ok {
+				return nil, err
+			}
+
+			if metaBeforeRenderDirectiveRegexp.Match(d.Content) {
+				return nil, newDetailedConfigError(fmt.Sprintf("meta config section with `include`, `vars` or `environments` directives cannot be parsed before rendering: %s: Go templates are not supported in the meta config section with these directives!", err), nil, d)
+			}
+
+			continue
+		}
+
+		if rawMeta.ConfigVersion != nil {
+			return rawMeta, nil
+		}
+	}
+
+	return &rawMetaBeforeRender{}, nil
+}
+
 func addTemplate(tmpl *template.Template, templateName string, templateContent string) error {
 	extraTemplate := tmpl.New(templateName)
 	_, err := extraTemplate.Parse(templateContent)
@@ -511,6 +602,10 @@ func prepareWerfConfig(rawImages []*rawStapelImage, rawImagesFromDockerfile []*r
 	var imagesFromDockerfile []*ImageFromDockerfile
 	var artifacts []*StapelImageArtifact
 
+	if err := resolveStapelImagesExtends(rawImages); err != nil {
+		return nil, err
+	}
+
 	for _, rawImageFromDockerfile := range rawImagesFromDockerfile {
 		if sameImages, err := rawImageFromDockerfile.toImageFromDockerfileDirectives(); err != nil {
 			return nil, err
@@ -562,6 +657,10 @@ func prepareWerfConfig(rawImages []*rawStapelImage, rawImagesFromDockerfile []*r
 		return nil, err
 	}
 
+	if err := werfConfig.validateImagesPlatforms(); err != nil {
+		return nil, err
+	}
+
 	return werfConfig, nil
 }
 
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var platformRegexp = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+// GetImagePlatforms returns platforms specified by the `platform` directive, the image is built for the host platform when there are no platforms
+func GetImagePlatforms(interf ImageInterface) []string {
+	switch i := interf.(type) {
+	case StapelImageInterface:
+		return i.ImageBaseConfig().Platform
+	case *ImageFromDockerfile:
+		return i.Platform
+	}
+
+	return nil
+}
+
+func validatePlatforms(platforms []string, configSection interface{}, doc *doc) error {
+	platformsByName := map[string]bool{}
+	for _, platform := range platforms {
+		if !platformRegexp.MatchString(platform) {
+			return newDetailedConfigError(fmt.Sprintf("invalid platform `%s`: OS/ARCH[/VARIANT] expected (e.g. linux/amd64, linux/arm64 or linux/arm/v7)!", platform), configSection, doc)
+		}
+
+		if platformsByName[platform] {
+			return newDetailedConfigError(fmt.Sprintf("duplicated platform `%s`!", platform), configSection, doc)
+		}
+		platformsByName[platform] = true
+	}
+
+	return nil
+}
+
+// validateImagesPlatforms checks that images used by fromImage, fromArtifact and imports are built for all platforms of the dependent image.
+// The image without platforms is built for the host platform and can be used by the image of any platform.
+func (c *WerfConfig) validateImagesPlatforms() error {
+	for _, interf := range c.GetAllImages() {
+		if err := c.validateImagePlatforms(interf); err != nil {
+			return err
+		}
+	}
+
+	for _, artifact := range c.Artifacts {
+		if err := c.validateImagePlatforms(artifact); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *WerfConfig) validateImagePlatforms(interf ImageInterface) error {
+	platforms := GetImagePlatforms(interf)
+
+	for _, dep := range c.GetImageDependencies(interf) {
+		depPlatforms := GetImagePlatforms(dep)
+		if len(depPlatforms) == 0 {
+			continue
+		}
+
+		var missedPlatforms []string
+		if len(platforms) == 0 {
+			missedPlatforms = []string{"host platform"}
+		} else {
+		platformsLoop:
+			for _, platform := range platforms {
+				for _, depPlatform := range depPlatforms {
+					if platform == depPlatform {
+						continue platformsLoop
+					}
+				}
+				missedPlatforms = append(missedPlatforms, platform)
+			}
+		}
+
+		if len(missedPlatforms) != 0 {
+			return newDetailedConfigError(fmt.Sprintf("%s `%s` is not built for platforms required by `%s`: %s!", imageKindName(dep), dep.GetName(), interf.GetName(), strings.Join(missedPlatforms, ", ")), nil, imageConfigDoc(interf))
+		}
+	}
+
+	return nil
+}
+
+func imageKindName(interf ImageInterface) string {
+	if stapelImage, ok := interf.(StapelImageInterface); ok && stapelImage.IsArtifact() {
+		return "artifact"
+	}
+	return "image"
+}
+
+func imageConfigDoc(interf ImageInterface) *doc {
+	switch i := interf.(type) {
+	case StapelImageInterface:
+		return i.ImageBaseConfig().raw.doc
+	case *ImageFromDockerfile:
+		return i.raw.doc
+	}
+
+	return nil
+}
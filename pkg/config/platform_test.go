@@ -0,0 +1,39 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("images platforms", func() {
+	rawImage := &rawStapelImage{doc: &doc{RenderFilePath: "werf.yaml"}}
+
+	newImage := func(name string, platform []string, fromImageName string) *StapelImage {
+		return &StapelImage{StapelImageBase: &StapelImageBase{Name: name, Platform: platform, FromImageName: fromImageName, raw: rawImage}}
+	}
+
+	It("validates platforms format", func() {
+		Ω(validatePlatforms([]string{"linux/amd64", "linux/arm64", "linux/arm/v7"}, nil, rawImage.doc)).Should(Succeed())
+		Ω(validatePlatforms([]string{"arm64"}, nil, rawImage.doc)).ShouldNot(Succeed())
+		Ω(validatePlatforms([]string{"linux/amd64", "linux/amd64"}, nil, rawImage.doc)).ShouldNot(Succeed())
+	})
+
+	It("allows dependencies which are built for all platforms or for the host platform", func() {
+		base := newImage("base", []string{"linux/amd64", "linux/arm64"}, "")
+		app := newImage("app", []string{"linux/arm64"}, "base")
+		tool := newImage("tool", nil, "")
+		other := newImage("other", []string{"linux/amd64"}, "tool")
+
+		werfConfig := &WerfConfig{StapelImages: []*StapelImage{base, app, tool, other}}
+		Ω(werfConfig.validateImagesPlatforms()).Should(Succeed())
+	})
+
+	It("rejects dependencies which are not built for required platforms", func() {
+		base := newImage("base", []string{"linux/amd64"}, "")
+		app := newImage("app", []string{"linux/amd64", "linux/arm64"}, "base")
+		Ω((&WerfConfig{StapelImages: []*StapelImage{base, app}}).validateImagesPlatforms()).Should(MatchError(ContainSubstring("image `base` is not built for platforms required by `app`: linux/arm64!")))
+
+		hostApp := newImage("app", nil, "base")
+		Ω((&WerfConfig{StapelImages: []*StapelImage{base, hostApp}}).validateImagesPlatforms()).Should(MatchError(ContainSubstring("required by `app`: host platform!")))
+	})
+})
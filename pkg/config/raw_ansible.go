@@ -10,6 +10,11 @@ type rawAnsible struct {
 	InstallCacheVersion       string           `yaml:"installCacheVersion,omitempty"`
 	BeforeSetupCacheVersion   string           `yaml:"beforeSetupCacheVersion,omitempty"`
 	SetupCacheVersion         string           `yaml:"setupCacheVersion,omitempty"`
+	Retry                     *rawRetry        `yaml:"retry,omitempty"`
+	BeforeInstallRetry        *rawRetry        `yaml:"beforeInstallRetry,omitempty"`
+	InstallRetry              *rawRetry        `yaml:"installRetry,omitempty"`
+	BeforeSetupRetry          *rawRetry        `yaml:"beforeSetupRetry,omitempty"`
+	SetupRetry                *rawRetry        `yaml:"setupRetry,omitempty"`
 
 	rawImage *rawStapelImage `yaml:"-"` // parent
 
@@ -77,6 +82,23 @@ func (c *rawAnsible) toDirective() (ansible *Ansible, err error) {
 		}
 	}
 
+	for _, retry := range []struct {
+		raw    *rawRetry
+		policy **RetryPolicy
+	}{
+		{c.Retry, &ansible.Retry},
+		{c.BeforeInstallRetry, &ansible.BeforeInstallRetry},
+		{c.InstallRetry, &ansible.InstallRetry},
+		{c.BeforeSetupRetry, &ansible.BeforeSetupRetry},
+		{c.SetupRetry, &ansible.SetupRetry},
+	} {
+		if policy, err := retry.raw.toDirective(); err != nil {
+			return nil, err
+		} else {
+			*retry.policy = policy
+		}
+	}
+
 	ansible.raw = c
 
 	if err := c.validateDirective(ansible); err != nil {
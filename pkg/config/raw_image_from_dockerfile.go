@@ -14,6 +14,7 @@ type rawImageFromDockerfile struct {
 	AddHost    interface{}            `yaml:"addHost,omitempty"`
 	Network    string                 `yaml:"network,omitempty"`
 	SSH        string                 `yaml:"ssh,omitempty"`
+	Platform   interface{}            `yaml:"platform,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -93,6 +94,14 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(imageName string
 	image.Network = c.Network
 	image.SSH = c.SSH
 
+	if platform, err := InterfaceToStringArray(c.Platform, c, c.doc); err != nil {
+		return nil, err
+	} else if err := validatePlatforms(platform, c, c.doc); err != nil {
+		return nil, err
+	} else {
+		image.Platform = platform
+	}
+
 	image.raw = c
 
 	return image, nil
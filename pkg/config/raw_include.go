@@ -0,0 +1,81 @@
+package config
+
+import (
+	"path"
+)
+
+type rawInclude struct {
+	Git    string `yaml:"git,omitempty"`
+	Branch string `yaml:"branch,omitempty"`
+	Tag    string `yaml:"tag,omitempty"`
+	Commit string `yaml:"commit,omitempty"`
+	Add    string `yaml:"add,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+
+	doc *doc `yaml:"-"` // parent doc
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawInclude) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawMeta:
+		c.doc = parent.doc
+	case *rawMetaBeforeRender:
+		c.doc = parent.doc
+	}
+
+	type plain rawInclude
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return c.validate()
+}
+
+func (c *rawInclude) validate() error {
+	if (c.Git == "") == (c.Path == "") {
+		return newDetailedConfigError("one of `git: URL` or `path: PATH` required for include!", c, c.doc)
+	}
+
+	if c.Path != "" {
+		if c.Branch != "" || c.Tag != "" || c.Commit != "" || c.Add != "" {
+			return newDetailedConfigError("`branch`, `tag`, `commit` and `add` directives are only supported for git include!", c, c.doc)
+		}
+
+		return nil
+	}
+
+	refs := 0
+	for _, ref := range []string{c.Branch, c.Tag, c.Commit} {
+		if ref != "" {
+			refs++
+		}
+	}
+
+	if refs != 1 {
+		return newDetailedConfigError("one of `branch: BRANCH`, `tag: TAG` or `commit: COMMIT` required for git include!", c, c.doc)
+	}
+
+	if c.Add != "" && !path.IsAbs(c.Add) {
+		return newDetailedConfigError("`add: PATH` absolute path in the repository required for git include!", c, c.doc)
+	}
+
+	return nil
+}
+
+func (c *rawInclude) toDirective() *Include {
+	return &Include{
+		Git:    c.Git,
+		Branch: c.Branch,
+		Tag:    c.Tag,
+		Commit: c.Commit,
+		Add:    c.Add,
+		Path:   c.Path,
+		raw:    c,
+	}
+}
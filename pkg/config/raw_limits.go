@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// minimal memory limit allowed by docker
+const minMemoryLimit = 6 * 1024 * 1024
+
+type rawLimits struct {
+	Timeout          string          `yaml:"timeout,omitempty"`
+	Memory           interface{}     `yaml:"memory,omitempty"`
+	Cpus             interface{}     `yaml:"cpus,omitempty"`
+	RawBeforeInstall *rawStageLimits `yaml:"beforeInstall,omitempty"`
+	RawInstall       *rawStageLimits `yaml:"install,omitempty"`
+	RawBeforeSetup   *rawStageLimits `yaml:"beforeSetup,omitempty"`
+	RawSetup         *rawStageLimits `yaml:"setup,omitempty"`
+
+	rawStapelImage *rawStapelImage `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawStageLimits struct {
+	Timeout string      `yaml:"timeout,omitempty"`
+	Memory  interface{} `yaml:"memory,omitempty"`
+	Cpus    interface{} `yaml:"cpus,omitempty"`
+
+	rawLimits *rawLimits `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawLimits) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawStapelImage); ok {
+		c.rawStapelImage = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawLimits
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawStageLimits) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawLimits); ok {
+		c.rawLimits = parent
+	}
+
+	type plain rawStageLimits
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawLimits.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawLimits) toDirective() (limits *Limits, err error) {
+	limits = &Limits{}
+
+	if stageLimits, err := c.toStageLimits(c.Timeout, c.Memory, c.Cpus, c); err != nil {
+		return nil, err
+	} else {
+		limits.StageLimits = *stageLimits
+	}
+
+	for _, userStage := range []struct {
+		raw    *rawStageLimits
+		limits **StageLimits
+	}{
+		{c.RawBeforeInstall, &limits.BeforeInstall},
+		{c.RawInstall, &limits.Install},
+		{c.RawBeforeSetup, &limits.BeforeSetup},
+		{c.RawSetup, &limits.Setup},
+	} {
+		if userStage.raw == nil {
+			continue
+		}
+
+		if stageLimits, err := c.toStageLimits(userStage.raw.Timeout, userStage.raw.Memory, userStage.raw.Cpus, userStage.raw); err != nil {
+			return nil, err
+		} else {
+			*userStage.limits = stageLimits
+		}
+	}
+
+	limits.raw = c
+
+	return limits, nil
+}
+
+func (c *rawLimits) toStageLimits(timeout string, memory, cpus interface{}, configSection interface{}) (*StageLimits, error) {
+	stageLimits := &StageLimits{}
+
+	if timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid `timeout: %s`: positive duration expected (e.g. 30s, 10m or 1h30m)!", timeout), configSection, c.rawStapelImage.doc)
+		} else {
+			stageLimits.Timeout = d
+		}
+	}
+
+	if memory != nil {
+		value := fmt.Sprintf("%v", memory)
+		if bytes, err := units.RAMInBytes(value); err != nil || bytes < minMemoryLimit {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid `memory: %s`: amount of memory not less than 6m expected (e.g. 512m or 2g)!", value), configSection, c.rawStapelImage.doc)
+		}
+		stageLimits.Memory = value
+	}
+
+	if cpus != nil {
+		value := fmt.Sprintf("%v", cpus)
+		if n, err := strconv.ParseFloat(value, 64); err != nil || n <= 0 {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid `cpus: %s`: positive number of CPUs expected (e.g. 0.5 or 2)!", value), configSection, c.rawStapelImage.doc)
+		}
+		stageLimits.Cpus = value
+	}
+
+	return stageLimits, nil
+}
@@ -8,10 +8,13 @@ import (
 )
 
 type rawMeta struct {
-	ConfigVersion   *int                    `yaml:"configVersion,omitempty"`
-	Project         *string                 `yaml:"project,omitempty"`
-	DeployTemplates *rawMetaDeployTemplates `yaml:"deploy,omitempty"`
-	Cleanup         *rawMetaCleanup         `yaml:"cleanup,omitempty"`
+	ConfigVersion   *int                           `yaml:"configVersion,omitempty"`
+	Project         *string                        `yaml:"project,omitempty"`
+	DeployTemplates *rawMetaDeployTemplates        `yaml:"deploy,omitempty"`
+	Cleanup         *rawMetaCleanup                `yaml:"cleanup,omitempty"`
+	RawInclude      []*rawInclude                  `yaml:"include,omitempty"`
+	Vars            map[string]interface{}         `yaml:"vars,omitempty"`
+	RawEnvironments map[string]*rawMetaEnvironment `yaml:"environments,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -70,5 +73,36 @@ func (c *rawMeta) toMeta() *Meta {
 		meta.DeployTemplates = c.DeployTemplates.toDeployTemplates()
 	}
 
+	for _, rawInclude := range c.RawInclude {
+		meta.Includes = append(meta.Includes, rawInclude.toDirective())
+	}
+
 	return meta
 }
+
+// rawMetaBeforeRender is the part of the meta config section, which is read from werf.yaml before rendering
+type rawMetaBeforeRender struct {
+	ConfigVersion   interface{}                    `yaml:"configVersion,omitempty"`
+	RawInclude      []*rawInclude                  `yaml:"include,omitempty"`
+	Vars            map[string]interface{}         `yaml:"vars,omitempty"`
+	RawEnvironments map[string]*rawMetaEnvironment `yaml:"environments,omitempty"`
+
+	doc *doc `yaml:"-"` // parent
+}
+
+func (c *rawMetaBeforeRender) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	parentStack.Push(c)
+	type plain rawMetaBeforeRender
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	return err
+}
+
+func (c *rawMetaBeforeRender) toIncludes() []*Include {
+	var includes []*Include
+	for _, rawInclude := range c.RawInclude {
+		includes = append(includes, rawInclude.toDirective())
+	}
+
+	return includes
+}
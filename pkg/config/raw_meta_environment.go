@@ -0,0 +1,29 @@
+package config
+
+type rawMetaEnvironment struct {
+	Vars map[string]interface{} `yaml:"vars,omitempty"`
+
+	doc *doc `yaml:"-"` // parent doc
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaEnvironment) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawMeta:
+		c.doc = parent.doc
+	case *rawMetaBeforeRender:
+		c.doc = parent.doc
+	}
+
+	type plain rawMetaEnvironment
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type rawRetry struct {
+	Count          int      `yaml:"count,omitempty"`
+	Delay          string   `yaml:"delay,omitempty"`
+	ExitCodes      []int    `yaml:"exitCodes,omitempty"`
+	OutputPatterns []string `yaml:"outputPatterns,omitempty"`
+
+	doc *doc `yaml:"-"` // parent doc
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawRetry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawShell:
+		c.doc = parent.rawStapelImage.doc
+	case *rawAnsible:
+		c.doc = parent.rawImage.doc
+	}
+
+	type plain rawRetry
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawRetry) toDirective() (retry *RetryPolicy, err error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	retry = &RetryPolicy{}
+
+	if c.Count <= 0 {
+		return nil, newDetailedConfigError("`count: N` positive number of retries required for retry!", c, c.doc)
+	}
+	retry.Count = c.Count
+
+	if c.Delay != "" {
+		if d, err := time.ParseDuration(c.Delay); err != nil || d < 0 {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid `delay: %s` for retry: duration expected (e.g. 10s or 1m)!", c.Delay), c, c.doc)
+		} else {
+			retry.Delay = d
+		}
+	}
+
+	retry.ExitCodes = c.ExitCodes
+
+	for _, pattern := range c.OutputPatterns {
+		if r, err := regexp.Compile(pattern); err != nil {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid output pattern `%s` for retry: %s!", pattern, err), c, c.doc)
+		} else {
+			retry.OutputPatterns = append(retry.OutputPatterns, r)
+		}
+	}
+
+	retry.raw = c
+
+	return retry, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+type rawSecret struct {
+	FromPath string `yaml:"fromPath,omitempty"`
+	FromEnv  string `yaml:"fromEnv,omitempty"`
+	To       string `yaml:"to,omitempty"`
+	Env      string `yaml:"env,omitempty"`
+
+	rawStapelImage *rawStapelImage `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawSecret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawStapelImage); ok {
+		c.rawStapelImage = parent
+	}
+
+	type plain rawSecret
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawSecret) toDirective() (secret *Secret, err error) {
+	secret = &Secret{}
+	secret.FromPath = c.FromPath
+	secret.FromEnv = c.FromEnv
+	secret.To = c.To
+	secret.Env = c.Env
+
+	secret.raw = c
+
+	if err := secret.validate(); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
@@ -10,6 +10,11 @@ type rawShell struct {
 	InstallCacheVersion       string      `yaml:"installCacheVersion,omitempty"`
 	BeforeSetupCacheVersion   string      `yaml:"beforeSetupCacheVersion,omitempty"`
 	SetupCacheVersion         string      `yaml:"setupCacheVersion,omitempty"`
+	Retry                     *rawRetry   `yaml:"retry,omitempty"`
+	BeforeInstallRetry        *rawRetry   `yaml:"beforeInstallRetry,omitempty"`
+	InstallRetry              *rawRetry   `yaml:"installRetry,omitempty"`
+	BeforeSetupRetry          *rawRetry   `yaml:"beforeSetupRetry,omitempty"`
+	SetupRetry                *rawRetry   `yaml:"setupRetry,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
 
@@ -21,8 +26,11 @@ func (c *rawShell) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		c.rawStapelImage = parent
 	}
 
+	parentStack.Push(c)
 	type plain rawShell
-	if err := unmarshal((*plain)(c)); err != nil {
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
 		return err
 	}
 
@@ -65,6 +73,23 @@ func (c *rawShell) toDirective() (shell *Shell, err error) {
 		shell.Setup = setup
 	}
 
+	for _, retry := range []struct {
+		raw    *rawRetry
+		policy **RetryPolicy
+	}{
+		{c.Retry, &shell.Retry},
+		{c.BeforeInstallRetry, &shell.BeforeInstallRetry},
+		{c.InstallRetry, &shell.InstallRetry},
+		{c.BeforeSetupRetry, &shell.BeforeSetupRetry},
+		{c.SetupRetry, &shell.SetupRetry},
+	} {
+		if policy, err := retry.raw.toDirective(); err != nil {
+			return nil, err
+		} else {
+			*retry.policy = policy
+		}
+	}
+
 	shell.raw = c
 
 	if err := c.validateDirective(shell); err != nil {
@@ -14,10 +14,14 @@ type rawStapelImage struct {
 	FromCacheVersion                                    string       `yaml:"fromCacheVersion,omitempty"`
 	FromImage                                           string       `yaml:"fromImage,omitempty"`
 	FromArtifact                                        string       `yaml:"fromArtifact,omitempty"`
+	Platform                                            interface{}  `yaml:"platform,omitempty"`
+	Extends                                             string       `yaml:"extends,omitempty"`
 	RawGit                                              []*rawGit    `yaml:"git,omitempty"`
 	RawShell                                            *rawShell    `yaml:"shell,omitempty"`
 	RawAnsible                                          *rawAnsible  `yaml:"ansible,omitempty"`
 	RawMount                                            []*rawMount  `yaml:"mount,omitempty"`
+	RawSecrets                                          []*rawSecret `yaml:"secrets,omitempty"`
+	RawLimits                                           *rawLimits   `yaml:"limits,omitempty"`
 	RawDocker                                           *rawDocker   `yaml:"docker,omitempty"`
 	RawImport                                           []*rawImport `yaml:"import,omitempty"`
 	AsLayers                                            bool         `yaml:"asLayers,omitempty"`
@@ -665,6 +669,14 @@ func (c *rawStapelImage) toBaseStapelImageBaseDirective(name string) (imageBase
 	imageBase = &StapelImageBase{}
 	imageBase.Name = name
 
+	if platform, err := InterfaceToStringArray(c.Platform, nil, c.doc); err != nil {
+		return nil, err
+	} else if err := validatePlatforms(platform, nil, c.doc); err != nil {
+		return nil, err
+	} else {
+		imageBase.Platform = platform
+	}
+
 	for _, mount := range c.RawMount {
 		if imageMount, err := mount.toDirective(); err != nil {
 			return nil, err
@@ -673,6 +685,22 @@ func (c *rawStapelImage) toBaseStapelImageBaseDirective(name string) (imageBase
 		}
 	}
 
+	for _, secret := range c.RawSecrets {
+		if imageSecret, err := secret.toDirective(); err != nil {
+			return nil, err
+		} else {
+			imageBase.Secrets = append(imageBase.Secrets, imageSecret)
+		}
+	}
+
+	if c.RawLimits != nil {
+		if limits, err := c.RawLimits.toDirective(); err != nil {
+			return nil, err
+		} else {
+			imageBase.Limits = limits
+		}
+	}
+
 	imageBase.Git = &GitManager{}
 
 	imageBase.raw = c
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"regexp"
+	"time"
+)
+
+// RetryPolicy defines when the failed user stage build should be retried.
+// The build is retried on any failure if neither exit codes nor output patterns are specified.
+type RetryPolicy struct {
+	Count          int
+	Delay          time.Duration
+	ExitCodes      []int
+	OutputPatterns []*regexp.Regexp
+
+	raw *rawRetry
+}
+
+// ShouldRetry checks whether the failure with the exit code and the output matches the policy
+func (c *RetryPolicy) ShouldRetry(exitCode int, output string) bool {
+	if len(c.ExitCodes) == 0 && len(c.OutputPatterns) == 0 {
+		return true
+	}
+
+	for _, code := range c.ExitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+
+	for _, pattern := range c.OutputPatterns {
+		if pattern.MatchString(output) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectUserStageRetryPolicy returns the policy of the user stage, the common policy is used if the user stage policy is not specified
+func selectUserStageRetryPolicy(userStageName string, retry, beforeInstallRetry, installRetry, beforeSetupRetry, setupRetry *RetryPolicy) *RetryPolicy {
+	var userStageRetry *RetryPolicy
+	switch userStageName {
+	case "beforeInstall":
+		userStageRetry = beforeInstallRetry
+	case "install":
+		userStageRetry = installRetry
+	case "beforeSetup":
+		userStageRetry = beforeSetupRetry
+	case "setup":
+		userStageRetry = setupRetry
+	}
+
+	if userStageRetry != nil {
+		return userStageRetry
+	}
+
+	return retry
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("retry", func() {
+	rawImage := &rawStapelImage{doc: &doc{RenderFilePath: "werf.yaml"}}
+
+	It("retries on the specified exit codes and output patterns", func() {
+		raw := &rawRetry{Count: 3, Delay: "10s", ExitCodes: []int{100}, OutputPatterns: []string{`Could not resolve host`}, doc: rawImage.doc}
+
+		retry, err := raw.toDirective()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(retry.Count).Should(Equal(3))
+		Ω(retry.Delay).Should(Equal(10 * time.Second))
+		Ω(retry.ShouldRetry(100, "")).Should(BeTrue())
+		Ω(retry.ShouldRetry(1, "curl: (6) Could not resolve host: example.com")).Should(BeTrue())
+		Ω(retry.ShouldRetry(1, "No such file or directory")).Should(BeFalse())
+	})
+
+	It("retries on any failure when exit codes and output patterns are not specified", func() {
+		retry, err := (&rawRetry{Count: 1, doc: rawImage.doc}).toDirective()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(retry.ShouldRetry(1, "")).Should(BeTrue())
+	})
+
+	It("selects the user stage retry policy", func() {
+		common := &RetryPolicy{Count: 1}
+		install := &RetryPolicy{Count: 2}
+		shell := &Shell{Retry: common, InstallRetry: install}
+
+		Ω(shell.GetRetryPolicy("install")).Should(Equal(install))
+		Ω(shell.GetRetryPolicy("setup")).Should(Equal(common))
+		Ω((&Shell{}).GetRetryPolicy("setup")).Should(BeNil())
+	})
+
+	It("rejects invalid retry", func() {
+		for _, raw := range []*rawRetry{
+			{},
+			{Count: -1},
+			{Count: 1, Delay: "soon"},
+			{Count: 1, OutputPatterns: []string{"("}},
+		} {
+			raw.doc = rawImage.doc
+			_, err := raw.toDirective()
+			Ω(err).Should(HaveOccurred())
+		}
+	})
+})
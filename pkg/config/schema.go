@@ -0,0 +1,282 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const jsonSchemaDefinitionsPrefix = "#/definitions/"
+
+var stringOrStringArrayJsonSchema = map[string]interface{}{
+	"anyOf": []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	},
+}
+
+var stringOrNumberJsonSchema = map[string]interface{}{
+	"type": []interface{}{"string", "number"},
+}
+
+var imageNameJsonSchema = map[string]interface{}{
+	"anyOf": []interface{}{
+		map[string]interface{}{"type": "null"},
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	},
+}
+
+// jsonSchemaFieldOverrides defines schemas of the fields which cannot be derived from the raw config types (TYPE.YAML_KEY)
+var jsonSchemaFieldOverrides = map[string]map[string]interface{}{
+	"rawShell.beforeInstall":           stringOrStringArrayJsonSchema,
+	"rawShell.install":                 stringOrStringArrayJsonSchema,
+	"rawShell.beforeSetup":             stringOrStringArrayJsonSchema,
+	"rawShell.setup":                   stringOrStringArrayJsonSchema,
+	"rawStageDependencies.install":     stringOrStringArrayJsonSchema,
+	"rawStageDependencies.beforeSetup": stringOrStringArrayJsonSchema,
+	"rawStageDependencies.setup":       stringOrStringArrayJsonSchema,
+	"rawExportBase.includePaths":       stringOrStringArrayJsonSchema,
+	"rawExportBase.excludePaths":       stringOrStringArrayJsonSchema,
+	"rawDocker.VOLUME":                 stringOrStringArrayJsonSchema,
+	"rawDocker.EXPOSE":                 stringOrStringArrayJsonSchema,
+	"rawDocker.CMD":                    stringOrStringArrayJsonSchema,
+	"rawDocker.ENTRYPOINT":             stringOrStringArrayJsonSchema,
+	"rawStapelImage.platform":          stringOrStringArrayJsonSchema,
+	"rawImageFromDockerfile.platform":  stringOrStringArrayJsonSchema,
+	"rawImageFromDockerfile.addHost":   stringOrStringArrayJsonSchema,
+	"rawLimits.memory":                 stringOrNumberJsonSchema,
+	"rawLimits.cpus":                   stringOrNumberJsonSchema,
+	"rawStageLimits.memory":            stringOrNumberJsonSchema,
+	"rawStageLimits.cpus":              stringOrNumberJsonSchema,
+}
+
+// Config document kinds, each kind is described by the same name definition of the werf.yaml JSON Schema
+const (
+	metaDocKind            = "meta"
+	imageDocKind           = "image"
+	artifactDocKind        = "artifact"
+	dockerfileImageDocKind = "dockerfileImage"
+)
+
+// GetWerfConfigJsonSchema returns JSON Schema of werf.yaml documents, which is generated from the raw config types.
+// The schema is intended for editors and does not cover restrictions checked by werf when the config is parsed.
+func GetWerfConfigJsonSchema() map[string]interface{} {
+	g := &jsonSchemaGenerator{definitions: map[string]interface{}{}}
+
+	stapelImage := g.definition(reflect.TypeOf(rawStapelImage{}))
+	dockerfileImage := g.definition(reflect.TypeOf(rawImageFromDockerfile{}))
+	meta := g.definition(reflect.TypeOf(rawMeta{}))
+
+	image := copyJsonSchemaObject(stapelImage, "artifact")
+	image["properties"].(map[string]interface{})["image"] = imageNameJsonSchema
+	image["required"] = []interface{}{"image"}
+
+	artifact := copyJsonSchemaObject(stapelImage, "docker")
+	artifact["required"] = []interface{}{"artifact"}
+
+	dockerfileImage = copyJsonSchemaObject(dockerfileImage)
+	dockerfileImage["properties"].(map[string]interface{})["image"] = imageNameJsonSchema
+	dockerfileImage["required"] = []interface{}{"image", "dockerfile"}
+
+	meta = copyJsonSchemaObject(meta)
+	meta["required"] = []interface{}{"configVersion", "project"}
+
+	delete(g.definitions, jsonSchemaDefinitionName(reflect.TypeOf(rawStapelImage{})))
+	delete(g.definitions, jsonSchemaDefinitionName(reflect.TypeOf(rawImageFromDockerfile{})))
+	g.definitions[metaDocKind] = meta
+	g.definitions[imageDocKind] = image
+	g.definitions[artifactDocKind] = artifact
+	g.definitions[dockerfileImageDocKind] = dockerfileImage
+
+	var docs []interface{}
+	for _, kind := range []string{metaDocKind, imageDocKind, artifactDocKind, dockerfileImageDocKind} {
+		docs = append(docs, map[string]interface{}{"$ref": jsonSchemaDefinitionsPrefix + kind})
+	}
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "werf.yaml",
+		"description": "werf.yaml document: meta, stapel image, artifact or dockerfile image",
+		"anyOf":       docs,
+		"definitions": g.definitions,
+	}
+}
+
+// GetWerfConfigJsonSchemaJson returns indented JSON Schema of werf.yaml documents
+func GetWerfConfigJsonSchemaJson() ([]byte, error) {
+	return json.MarshalIndent(GetWerfConfigJsonSchema(), "", "  ")
+}
+
+// getWerfConfigDocJsonSchema returns the schema which validates the document of the specified kind
+func getWerfConfigDocJsonSchema(kind string) map[string]interface{} {
+	schema := GetWerfConfigJsonSchema()
+	delete(schema, "anyOf")
+	schema["$ref"] = jsonSchemaDefinitionsPrefix + kind
+	return schema
+}
+
+type jsonSchemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+func (g *jsonSchemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]interface{}{"type": "object"}
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := jsonSchemaDefinitionName(t)
+		g.definition(t)
+		return map[string]interface{}{"$ref": jsonSchemaDefinitionsPrefix + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *jsonSchemaGenerator) definition(t reflect.Type) map[string]interface{} {
+	name := jsonSchemaDefinitionName(t)
+	if d, ok := g.definitions[name]; ok {
+		return d.(map[string]interface{})
+	}
+
+	properties := map[string]interface{}{}
+	schema := map[string]interface{}{
+		"type":       []interface{}{"object", "null"},
+		"properties": properties,
+	}
+	g.definitions[name] = schema
+
+	additionalProperties := g.collectProperties(t, properties)
+	schema["additionalProperties"] = additionalProperties
+
+	return schema
+}
+
+// collectProperties adds yaml fields of the struct type into properties, inline structs are flattened.
+// Result is true if the struct allows arbitrary fields (inline map which is not UnsupportedAttributes).
+func (g *jsonSchemaGenerator) collectProperties(t reflect.Type, properties map[string]interface{}) bool {
+	additionalProperties := false
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tagParts := strings.Split(f.Tag.Get("yaml"), ",")
+		name, options := tagParts[0], tagParts[1:]
+		if name == "-" {
+			continue
+		}
+
+		if hasYamlTagOption(options, "inline") {
+			switch f.Type.Kind() {
+			case reflect.Struct:
+				if g.collectProperties(f.Type, properties) {
+					additionalProperties = true
+				}
+			case reflect.Map:
+				if f.Name != "UnsupportedAttributes" {
+					additionalProperties = true
+				}
+			}
+			continue
+		}
+
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		// werf config parser accepts empty values for all directives
+		if schema, ok := jsonSchemaFieldOverrides[t.Name()+"."+name]; ok {
+			properties[name] = nullableJsonSchema(schema)
+		} else {
+			properties[name] = nullableJsonSchema(g.typeSchema(f.Type))
+		}
+	}
+
+	return additionalProperties
+}
+
+// nullableJsonSchema allows null value, definitions of the raw config types are nullable themselves
+func nullableJsonSchema(schema map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range schema {
+		res[k] = v
+	}
+
+	if t, ok := schema["type"].(string); ok {
+		res["type"] = []interface{}{t, "null"}
+	} else if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		res["anyOf"] = append(append([]interface{}{}, anyOf...), map[string]interface{}{"type": "null"})
+	}
+
+	return res
+}
+
+func hasYamlTagOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonSchemaDefinitionName returns the raw config type name without raw prefix (rawMetaCleanup -> metaCleanup)
+func jsonSchemaDefinitionName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "raw")
+	if name == "" {
+		return t.Name()
+	}
+
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// copyJsonSchemaObject returns the config document schema based on the raw config type definition
+func copyJsonSchemaObject(schema map[string]interface{}, excludeProperties ...string) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range schema {
+		res[k] = v
+	}
+	res["type"] = "object"
+
+	properties := map[string]interface{}{}
+propertiesLoop:
+	for k, v := range schema["properties"].(map[string]interface{}) {
+		for _, exclude := range excludeProperties {
+			if k == exclude {
+				continue propertiesLoop
+			}
+		}
+		properties[k] = v
+	}
+	res["properties"] = properties
+
+	return res
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"regexp"
+)
+
+var secretEnvNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secret is available to shell and ansible instructions during the build only.
+// The secret is not taken into account in the stage digest and is not saved into the image.
+type Secret struct {
+	FromPath string
+	FromEnv  string
+	To       string
+	Env      string
+
+	raw *rawSecret
+}
+
+func (c *Secret) validate() error {
+	if (c.FromPath == "") == (c.FromEnv == "") {
+		return newDetailedConfigError("one and only one of `fromPath: PATH` or `fromEnv: ENV_NAME` required for secret!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if (c.To == "") == (c.Env == "") {
+		return newDetailedConfigError("one and only one of `to: PATH` or `env: ENV_NAME` required for secret!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.To != "" && !isAbsolutePath(c.To) {
+		return newDetailedConfigError("`to: PATH` absolute path required for secret!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.Env != "" && !secretEnvNameRegexp.MatchString(c.Env) {
+		return newDetailedConfigError("invalid `env: ENV_NAME` for secret: environment variable name expected!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.FromEnv != "" && !secretEnvNameRegexp.MatchString(c.FromEnv) {
+		return newDetailedConfigError("invalid `fromEnv: ENV_NAME` for secret: environment variable name expected!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	return nil
+}
@@ -10,10 +10,20 @@ type Shell struct {
 	InstallCacheVersion       string
 	BeforeSetupCacheVersion   string
 	SetupCacheVersion         string
+	Retry                     *RetryPolicy
+	BeforeInstallRetry        *RetryPolicy
+	InstallRetry              *RetryPolicy
+	BeforeSetupRetry          *RetryPolicy
+	SetupRetry                *RetryPolicy
 
 	raw *rawShell
 }
 
+// GetRetryPolicy returns the retry policy of the user stage, nil means the stage build is not retried
+func (c *Shell) GetRetryPolicy(userStageName string) *RetryPolicy {
+	return selectUserStageRetryPolicy(userStageName, c.Retry, c.BeforeInstallRetry, c.InstallRetry, c.BeforeSetupRetry, c.SetupRetry)
+}
+
 func (c *Shell) GetDumpConfigSection() string {
 	return dumpConfigDoc(c.raw.rawStapelImage.doc)
 }
@@ -12,10 +12,13 @@ type StapelImageBase struct {
 	FromImageName                                       string
 	FromArtifactName                                    string
 	FromCacheVersion                                    string
+	Platform                                            []string
 	Git                                                 *GitManager
 	Shell                                               *Shell
 	Ansible                                             *Ansible
 	Mount                                               []*Mount
+	Secrets                                             []*Secret
+	Limits                                              *Limits
 	Import                                              []*Import
 
 	raw *rawStapelImage
@@ -33,6 +36,18 @@ func (c *StapelImageBase) ImageBaseConfig() *StapelImageBase {
 	return c
 }
 
+// GetUserStageRetryPolicy returns the retry policy of the user stage defined in the shell or ansible section
+func (c *StapelImageBase) GetUserStageRetryPolicy(userStageName string) *RetryPolicy {
+	switch {
+	case c.Shell != nil:
+		return c.Shell.GetRetryPolicy(userStageName)
+	case c.Ansible != nil:
+		return c.Ansible.GetRetryPolicy(userStageName)
+	}
+
+	return nil
+}
+
 func (c *StapelImageBase) IsArtifact() bool {
 	return false
 }
@@ -103,6 +118,24 @@ We do not recommend using the actual base image such way. Use a particular uncha
 		mountByTo[mount.To] = true
 	}
 
+	secretByTarget := map[string]bool{}
+	for _, secret := range c.Secrets {
+		target := "to:" + secret.To
+		if secret.Env != "" {
+			target = "env:" + secret.Env
+		}
+
+		if secretByTarget[target] {
+			return newDetailedConfigError("conflict between secrets!", nil, c.raw.doc)
+		}
+
+		if secret.To != "" && mountByTo[secret.To] {
+			return newDetailedConfigError(fmt.Sprintf("conflict between secret and mount `to: %s`!", secret.To), nil, c.raw.doc)
+		}
+
+		secretByTarget[target] = true
+	}
+
 	if !oneOrNone([]bool{c.From != "", c.raw.FromImage != "", c.raw.FromArtifact != ""}) {
 		return newDetailedConfigError("conflict between `from`, `fromImage` and `fromArtifact` directives!", nil, c.raw.doc)
 	}
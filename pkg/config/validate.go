@@ -0,0 +1,338 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	ghodssYaml "github.com/ghodss/yaml"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v2"
+)
+
+// ValidationError is the werf.yaml problem with the position in the rendered config
+type ValidationError struct {
+	File    string
+	Line    int // 0 if the line is unknown
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// ValidateWerfConfig renders werf.yaml and validates all documents by JSON Schema and werf config parser.
+// Unlike GetWerfConfig, it does not stop at the first invalid document and returns all found problems.
+func ValidateWerfConfig(ctx context.Context, werfConfigPath, werfConfigTemplatesDir string, opts WerfConfigOptions) ([]*ValidationError, error) {
+	werfConfigRenderContent, werfConfigRenderPath, err := renderWerfConfig(ctx, werfConfigPath, werfConfigTemplatesDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := splitByDocs(werfConfigRenderContent, werfConfigRenderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var validationErrors []*ValidationError
+	var validDocs []*doc
+	var metaDoc *doc
+	for _, d := range docs {
+		kind, err := getDocKind(d)
+		if err != nil {
+			validationErrors = append(validationErrors, newValidationError(err, d))
+			continue
+		}
+
+		if kind == metaDocKind {
+			if metaDoc != nil {
+				validationErrors = append(validationErrors, &ValidationError{File: d.RenderFilePath, Line: d.Line + 1, Message: fmt.Sprintf("duplicate meta config section definition: meta config section is already defined at line %d", metaDoc.Line+1)})
+				continue
+			}
+			metaDoc = d
+		}
+
+		if docErrors, err := validateDocByJsonSchema(d, kind); err != nil {
+			return nil, err
+		} else if len(docErrors) != 0 {
+			validationErrors = append(validationErrors, docErrors...)
+			continue
+		}
+
+		if err := validateDocDirectives(d); err != nil {
+			validationErrors = append(validationErrors, newValidationError(err, d))
+			continue
+		}
+
+		validDocs = append(validDocs, d)
+	}
+
+	if metaDoc == nil {
+		validationErrors = append(validationErrors, &ValidationError{File: werfConfigRenderPath, Message: "meta config section with `configVersion: 1` and `project: NAME` is not defined"})
+	}
+
+	// relations between images are checked only when all documents are valid to avoid errors caused by invalid documents
+	if len(validationErrors) == 0 {
+		meta, rawStapelImages, rawImagesFromDockerfile, err := splitByMetaAndRawImages(validDocs)
+		if err == nil {
+			_, err = prepareWerfConfig(rawStapelImages, rawImagesFromDockerfile, meta)
+		}
+
+		if err != nil {
+			validationErrors = append(validationErrors, newValidationError(err, &doc{RenderFilePath: werfConfigRenderPath, Line: -1}))
+		}
+	}
+
+	return validationErrors, nil
+}
+
+func getDocKind(d *doc) (string, error) {
+	var raw map[string]interface{}
+	if err := yaml.UnmarshalStrict(d.Content, &raw); err != nil {
+		return "", newYamlUnmarshalError(err, d)
+	}
+
+	switch {
+	case isMetaDoc(raw):
+		return metaDocKind, nil
+	case isImageFromDockerfileDoc(raw):
+		return dockerfileImageDocKind, nil
+	case isImageDoc(raw):
+		if _, ok := raw["image"]; ok {
+			return imageDocKind, nil
+		}
+		return artifactDocKind, nil
+	default:
+		return "", newDetailedConfigError("cannot recognize type of config section: 'configVersion' required for meta config section, 'image' required for the image config sections, 'artifact' required for the artifact config sections", nil, d)
+	}
+}
+
+func validateDocByJsonSchema(d *doc, kind string) ([]*ValidationError, error) {
+	docJson, err := ghodssYaml.YAMLToJSON(d.Content)
+	if err != nil {
+		return []*ValidationError{newValidationError(newYamlUnmarshalError(err, d), d)}, nil
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(getWerfConfigDocJsonSchema(kind)), gojsonschema.NewBytesLoader(docJson))
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate config section by JSON Schema: %s", err)
+	}
+
+	var validationErrors []*ValidationError
+	for _, resultErr := range result.Errors() {
+		message := resultErr.Description()
+		if field := resultErr.Field(); field != gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			message = fmt.Sprintf("%s: %s", field, message)
+		}
+
+		path := jsonSchemaErrorPath(resultErr)
+
+		validationErrors = append(validationErrors, &ValidationError{
+			File:    d.RenderFilePath,
+			Line:    d.Line + 1 + findYamlPathLine(d.Content, path),
+			Message: message,
+		})
+	}
+
+	sort.SliceStable(validationErrors, func(i, j int) bool {
+		return validationErrors[i].Line < validationErrors[j].Line
+	})
+
+	return validationErrors, nil
+}
+
+func jsonSchemaErrorPath(resultErr gojsonschema.ResultError) []string {
+	var path []string
+	if field := resultErr.Field(); field != gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		path = strings.Split(field, ".")
+	}
+
+	if resultErr.Type() == "additional_property_not_allowed" {
+		if property, ok := resultErr.Details()["property"].(string); ok {
+			path = append(path, property)
+		}
+	}
+
+	return path
+}
+
+// validateDocDirectives parses the document by werf config parser, which checks restrictions not covered by JSON Schema
+func validateDocDirectives(d *doc) error {
+	meta, rawStapelImages, rawImagesFromDockerfile, err := splitByMetaAndRawImages([]*doc{d})
+	if err != nil {
+		return err
+	}
+
+	if meta != nil {
+		return nil
+	}
+
+	for _, rawImageFromDockerfile := range rawImagesFromDockerfile {
+		if _, err := rawImageFromDockerfile.toImageFromDockerfileDirectives(); err != nil {
+			return err
+		}
+	}
+
+	for _, rawImage := range rawStapelImages {
+		if rawImage.stapelImageType() == "images" {
+			if _, err := rawImage.toStapelImageDirectives(); err != nil {
+				return err
+			}
+		} else if _, err := rawImage.toStapelImageArtifactDirectives(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newValidationError(err error, d *doc) *ValidationError {
+	validationErr := &ValidationError{File: d.RenderFilePath, Line: d.Line + 1, Message: err.Error()}
+
+	if configErr, ok := err.(*configError); ok {
+		validationErr.Message = configErr.message
+		if configErr.doc != nil {
+			validationErr.File = configErr.doc.RenderFilePath
+			validationErr.Line = configErr.doc.Line + 1
+		}
+	}
+
+	if validationErr.Line < 0 {
+		validationErr.Line = 0
+	}
+
+	return validationErr
+}
+
+var yamlListItemPrefixRegexp = regexp.MustCompile(`^-(\s+|$)`)
+
+// findYamlPathLine returns the line index of the node in the block style yaml content (e.g. path [git 0 add]),
+// the line of the closest found parent node is returned if the node cannot be found
+func findYamlPathLine(content []byte, path []string) int {
+	lines := getLines(content)
+
+	line := 0
+	start, end := 0, len(lines)
+	for _, segment := range path {
+		if index, err := strconv.Atoi(segment); err == nil {
+			itemLine, itemEnd, ok := findYamlListItem(lines, start, end, index)
+			if !ok {
+				return line
+			}
+			line, start, end = itemLine, itemLine, itemEnd
+		} else {
+			keyLine, valueEnd, ok := findYamlMappingKey(lines, start, end, segment)
+			if !ok {
+				return line
+			}
+			line, start, end = keyLine, keyLine+1, valueEnd
+		}
+	}
+
+	return line
+}
+
+func findYamlListItem(lines [][]byte, start, end, index int) (int, int, bool) {
+	itemsIndent := -1
+	itemInd := -1
+	itemLine := -1
+	for i := start; i < end; i++ {
+		indent, text, ok := yamlSignificantLine(lines[i])
+		if !ok {
+			continue
+		}
+
+		if itemsIndent == -1 {
+			itemsIndent = indent
+		}
+
+		if indent < itemsIndent {
+			break
+		}
+
+		if indent == itemsIndent {
+			if !yamlListItemPrefixRegexp.MatchString(text) {
+				break
+			}
+
+			if itemLine != -1 {
+				return itemLine, i, true
+			}
+
+			itemInd++
+			if itemInd == index {
+				itemLine = i
+			}
+		}
+	}
+
+	if itemLine != -1 {
+		return itemLine, end, true
+	}
+
+	return 0, 0, false
+}
+
+func findYamlMappingKey(lines [][]byte, start, end int, key string) (int, int, bool) {
+	keyRegexp := regexp.MustCompile(fmt.Sprintf(`^(%s|"%s"|'%s')\s*:(\s|$)`, regexp.QuoteMeta(key), regexp.QuoteMeta(key), regexp.QuoteMeta(key)))
+
+	keysIndent := -1
+	for i := start; i < end; i++ {
+		indent, text, ok := yamlSignificantLine(lines[i])
+		if !ok {
+			continue
+		}
+
+		// the first key of the list item is on the same line with the dash
+		for yamlListItemPrefixRegexp.MatchString(text) && i == start {
+			trimmed := yamlListItemPrefixRegexp.ReplaceAllString(text, "")
+			indent += len(text) - len(trimmed)
+			text = trimmed
+		}
+
+		if keysIndent == -1 {
+			keysIndent = indent
+		}
+
+		if indent < keysIndent {
+			break
+		}
+
+		if indent == keysIndent && keyRegexp.MatchString(text) {
+			return i, findYamlValueEnd(lines, i+1, end, keysIndent), true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// findYamlValueEnd returns the end of the key value block, the value list items can have the same indent with the key
+func findYamlValueEnd(lines [][]byte, start, end, keyIndent int) int {
+	for i := start; i < end; i++ {
+		indent, text, ok := yamlSignificantLine(lines[i])
+		if !ok {
+			continue
+		}
+
+		if indent < keyIndent || (indent == keyIndent && !yamlListItemPrefixRegexp.MatchString(text)) {
+			return i
+		}
+	}
+
+	return end
+}
+
+func yamlSignificantLine(line []byte) (int, string, bool) {
+	text := strings.TrimLeft(string(line), " ")
+	if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+		return 0, "", false
+	}
+
+	return len(line) - len(text), strings.TrimRight(text, " \r"), true
+}
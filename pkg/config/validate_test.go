@@ -0,0 +1,79 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("werf.yaml validation", func() {
+	newDoc := func(content string) *doc {
+		return &doc{Content: []byte(content), Line: 10, RenderFilePath: "werf.yaml"}
+	}
+
+	It("finds the line of the yaml node", func() {
+		content := []byte(`image: app
+from: alpine
+git:
+- add: /
+  to: /app
+  stageDependencies:
+    install:
+    - "*.lock"
+- url: https://github.com/werf/werf
+  # comment
+  to: /werf
+shell:
+  install: [make]
+`)
+
+		Ω(findYamlPathLine(content, []string{"from"})).Should(Equal(1))
+		Ω(findYamlPathLine(content, []string{"git", "0", "to"})).Should(Equal(4))
+		Ω(findYamlPathLine(content, []string{"git", "0", "stageDependencies", "install", "0"})).Should(Equal(7))
+		Ω(findYamlPathLine(content, []string{"git", "1"})).Should(Equal(8))
+		Ω(findYamlPathLine(content, []string{"git", "1", "to"})).Should(Equal(10))
+		Ω(findYamlPathLine(content, []string{"shell", "install", "0"})).Should(Equal(12))
+		Ω(findYamlPathLine(content, []string{"shell", "unknown"})).Should(Equal(11))
+	})
+
+	It("reports all JSON Schema errors of the document with lines", func() {
+		d := newDoc(`image: app
+from: alpine
+fromLatest: "yes"
+shell:
+  install: make
+  unknown: value
+`)
+
+		validationErrors, err := validateDocByJsonSchema(d, imageDocKind)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var errorsStrings []string
+		for _, validationErr := range validationErrors {
+			errorsStrings = append(errorsStrings, validationErr.Error())
+		}
+
+		Ω(errorsStrings).Should(ConsistOf(
+			"werf.yaml:13: fromLatest: Invalid type. Expected: [boolean,null], given: string",
+			"werf.yaml:16: shell: Additional property unknown is not allowed",
+		))
+	})
+
+	It("accepts valid documents", func() {
+		for kind, content := range map[string]string{
+			metaDocKind:            "configVersion: 1\nproject: app\ncleanup:\n  keepPolicies:\n  - references:\n      tag: /.*/\n      limit:\n        in: 72h\n",
+			imageDocKind:           "image: [app, worker]\nfrom: alpine\nplatform: [linux/amd64]\nansible:\n  install:\n  - name: Install\n    apk: {name: curl}\nlimits:\n  cpus: 1.5\n  memory: 1g\n",
+			artifactDocKind:        "artifact: builder\nfrom: golang\nshell:\n  install:\n  - go build\n  retry:\n    count: 2\n",
+			dockerfileImageDocKind: "image: ~\ndockerfile: Dockerfile\nargs:\n  VERSION: 1\n",
+		} {
+			validationErrors, err := validateDocByJsonSchema(newDoc(content), kind)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(validationErrors).Should(BeEmpty(), kind)
+		}
+	})
+
+	It("checks restrictions which are not covered by JSON Schema", func() {
+		err := validateDocDirectives(newDoc("image: app\nfrom: alpine\nfromImage: base\n"))
+		Ω(err).Should(HaveOccurred())
+		Ω(newValidationError(err, newDoc("")).Line).Should(Equal(11))
+	})
+})
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// resolveConfigVars returns values of the vars defined in the meta config section,
+// defaults are overridden by vars of the environment and then by KEY=VALUE config vars specified by the user
+func (c *rawMetaBeforeRender) resolveConfigVars(env string, configVars []string) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for name, value := range c.Vars {
+		vars[name] = value
+	}
+
+	if rawEnvironment, ok := c.RawEnvironments[env]; ok && env != "" && rawEnvironment != nil {
+		for _, name := range sortedConfigVarsNames(rawEnvironment.Vars) {
+			value, err := convertConfigVarValue(name, c.Vars, rawEnvironment.Vars[name])
+			if err != nil {
+				return nil, newDetailedConfigError(fmt.Sprintf("invalid var of the environment %q: %s!", env, err), rawEnvironment, rawEnvironment.doc)
+			}
+
+			vars[name] = value
+		}
+	}
+
+	for _, configVar := range configVars {
+		parts := strings.SplitN(configVar, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("bad config var %q: KEY=VALUE format expected", configVar)
+		}
+
+		name, rawValue := parts[0], parts[1]
+
+		var value interface{} = rawValue
+		if _, isString := c.Vars[name].(string); !isString {
+			if err := yaml.Unmarshal([]byte(rawValue), &value); err != nil {
+				return nil, fmt.Errorf("bad config var %q: unable to parse value: %s", configVar, err)
+			}
+		}
+
+		value, err := convertConfigVarValue(name, c.Vars, value)
+		if err != nil {
+			return nil, fmt.Errorf("bad config var %q: %s", configVar, err)
+		}
+
+		vars[name] = value
+	}
+
+	return vars, nil
+}
+
+// convertConfigVarValue checks that the value has the type of the var default value, null default value allows any type
+func convertConfigVarValue(name string, defaults map[string]interface{}, value interface{}) (interface{}, error) {
+	defaultValue, ok := defaults[name]
+	if !ok {
+		return nil, fmt.Errorf("var %q is not defined in the `vars` directive of the meta config section", name)
+	}
+
+	if defaultValue == nil || value == nil {
+		return value, nil
+	}
+
+	var valid bool
+	switch defaultValue.(type) {
+	case string:
+		_, valid = value.(string)
+	case bool:
+		_, valid = value.(bool)
+	case int, int64, uint64:
+		switch value.(type) {
+		case int, int64, uint64:
+			valid = true
+		}
+	case float64:
+		switch v := value.(type) {
+		case float64:
+			valid = true
+		case int:
+			value, valid = float64(v), true
+		}
+	case []interface{}:
+		_, valid = value.([]interface{})
+	case map[interface{}]interface{}:
+		_, valid = value.(map[interface{}]interface{})
+	default:
+		valid = true
+	}
+
+	if !valid {
+		return nil, fmt.Errorf("var %q value `%v` does not match the type of the default value `%v`", name, value, defaultValue)
+	}
+
+	return value, nil
+}
+
+func sortedConfigVarsNames(vars map[string]interface{}) []string {
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// getWerfConfigVars returns resolved vars of werf.yaml without rendering
+func getWerfConfigVars(werfConfigPath string, opts WerfConfigOptions) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(werfConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMeta, err := getRawMetaBeforeRender(data, werfConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return rawMeta.resolveConfigVars(opts.Env, opts.ConfigVars)
+}
+
+// configVarsComment describes resolved vars as yaml comment, which is printed before the rendered config
+func configVarsComment(vars map[string]interface{}, env string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+
+	data, err := yaml.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+
+	comment := "# Config vars:\n"
+	if env != "" {
+		comment = fmt.Sprintf("# Config vars (env %s):\n", env)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		comment += "#   " + line + "\n"
+	}
+
+	return comment, nil
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+var _ = Describe("vars", func() {
+	const werfConfig = `configVersion: 1
+project: app
+vars:
+  replicas: 1
+  debug: false
+  version: "1.0"
+  ratio: 0.5
+  packages: [curl]
+environments:
+  production:
+    vars:
+      replicas: 3
+      packages: [curl, ca-certificates]
+---
+image: app
+from: alpine:{{ .Vars.version }}
+shell:
+  install:
+  - echo replicas={{ .Vars.replicas }} debug={{ .Vars.debug }} ratio={{ .Vars.ratio }}
+{{- range .Vars.packages }}
+  - apk add {{ . }}
+{{- end }}
+`
+
+	resolveVars := func(content string, env string, configVars ...string) (map[string]interface{}, error) {
+		rawMeta, err := getRawMetaBeforeRender([]byte(content), "werf.yaml")
+		if err != nil {
+			return nil, err
+		}
+
+		return rawMeta.resolveConfigVars(env, configVars)
+	}
+
+	It("overrides defaults by vars of the environment and config vars", func() {
+		vars, err := resolveVars(werfConfig, "")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vars["replicas"]).Should(Equal(1))
+
+		vars, err = resolveVars(werfConfig, "production")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vars["replicas"]).Should(Equal(3))
+		Ω(vars["packages"]).Should(Equal([]interface{}{"curl", "ca-certificates"}))
+
+		vars, err = resolveVars(werfConfig, "production", "replicas=5", "debug=true", "version=2", "ratio=1")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vars["replicas"]).Should(Equal(5))
+		Ω(vars["debug"]).Should(Equal(true))
+		Ω(vars["version"]).Should(Equal("2"))
+		Ω(vars["ratio"]).Should(Equal(1.0))
+	})
+
+	It("rejects unknown vars and values of another type", func() {
+		for _, configVar := range []string{"replica=2", "replicas=many", "debug=1", "packages=curl", "replicas"} {
+			_, err := resolveVars(werfConfig, "", configVar)
+			Ω(err).Should(HaveOccurred(), configVar)
+		}
+
+		_, err := resolveVars("configVersion: 1\nvars:\n  replicas: 1\nenvironments:\n  production:\n    vars:\n      replicas: three\n", "production")
+		Ω(err).Should(HaveOccurred())
+
+		_, err = resolveVars("configVersion: 1\nvars:\n  replicas: 1\nenvironments:\n  production:\n    replicas: 3\n", "production")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("exposes vars to templates", func() {
+		tmpDir, err := ioutil.TempDir("", "werf-config-vars-")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+
+		werfConfigPath := filepath.Join(tmpDir, "werf.yaml")
+		Ω(ioutil.WriteFile(werfConfigPath, []byte(werfConfig), 0644)).Should(Succeed())
+
+		content, err := parseWerfConfigYaml(context.Background(), werfConfigPath, filepath.Join(tmpDir, ".werf"), WerfConfigOptions{Env: "production", ConfigVars: []string{"version=3.13"}})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(content).Should(ContainSubstring("from: alpine:3.13"))
+		Ω(content).Should(ContainSubstring("echo replicas=3 debug=false ratio=0.5"))
+		Ω(content).Should(ContainSubstring("apk add ca-certificates"))
+	})
+
+	It("prints vars comment before the rendered config and the rendered images", func() {
+		tmpDir, err := ioutil.TempDir("", "werf-config-vars-")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+
+		Ω(werf.Init(tmpDir, tmpDir)).Should(Succeed())
+
+		werfConfigPath := filepath.Join(tmpDir, "werf.yaml")
+		Ω(ioutil.WriteFile(werfConfigPath, []byte(werfConfig), 0644)).Should(Succeed())
+
+		for _, imagesToProcess := range [][]string{nil, {"app"}} {
+			content, err := renderWerfConfigContent(context.Background(), werfConfigPath, filepath.Join(tmpDir, ".werf"), imagesToProcess, WerfConfigOptions{Env: "production"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(content).Should(HavePrefix("# Config vars (env production):\n"), "%v", imagesToProcess)
+			Ω(content).Should(ContainSubstring("#   replicas: 3\n"), "%v", imagesToProcess)
+			Ω(content).Should(ContainSubstring("image: app"), "%v", imagesToProcess)
+		}
+	})
+})
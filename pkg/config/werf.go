@@ -316,7 +316,7 @@ func (c *WerfConfig) imageDependenciesInOrder(images []ImageInterface) (imageDep
 		current := stack[0]
 		stack = stack[1:]
 
-		imageDeps[current] = c.imageDependencies(current)
+		imageDeps[current] = c.GetImageDependencies(current)
 
 	outerLoop:
 		for _, dep := range imageDeps[current] {
@@ -333,22 +333,33 @@ func (c *WerfConfig) imageDependenciesInOrder(images []ImageInterface) (imageDep
 	return imageDeps
 }
 
-func (c *WerfConfig) imageDependencies(interf ImageInterface) (deps []ImageInterface) {
+// GetImageDependencies returns images and artifacts which should be built before the image (fromImage, fromArtifact and imports) without duplicates
+func (c *WerfConfig) GetImageDependencies(interf ImageInterface) (deps []ImageInterface) {
+	addDep := func(dep ImageInterface) {
+		for _, d := range deps {
+			if d == dep {
+				return
+			}
+		}
+
+		deps = append(deps, dep)
+	}
+
 	switch i := interf.(type) {
 	case StapelImageInterface:
 		if i.ImageBaseConfig().FromImageName != "" {
-			deps = append(deps, c.GetImage(i.ImageBaseConfig().FromImageName))
+			addDep(c.GetImage(i.ImageBaseConfig().FromImageName))
 		}
 
 		if i.ImageBaseConfig().FromArtifactName != "" {
-			deps = append(deps, c.GetArtifact(i.ImageBaseConfig().FromArtifactName))
+			addDep(c.GetArtifact(i.ImageBaseConfig().FromArtifactName))
 		}
 
 		for _, imp := range i.imports() {
 			if imp.ImageName != "" {
-				deps = append(deps, c.GetImage(imp.ImageName))
+				addDep(c.GetImage(imp.ImageName))
 			} else if imp.ArtifactName != "" {
-				deps = append(deps, c.GetArtifact(imp.ArtifactName))
+				addDep(c.GetArtifact(imp.ArtifactName))
 			}
 		}
 	case *ImageFromDockerfile:
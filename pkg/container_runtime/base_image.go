@@ -7,7 +7,6 @@ import (
 	"github.com/werf/werf/pkg/image"
 
 	"github.com/docker/docker/api/types"
-	"github.com/werf/werf/pkg/docker"
 )
 
 type baseImage struct {
@@ -15,13 +14,13 @@ type baseImage struct {
 	inspect   *types.ImageInspect
 	stageDesc *image.StageDescription
 
-	LocalDockerServerRuntime *LocalDockerServerRuntime
+	ContainerRuntime StageImageRuntime
 }
 
-func newBaseImage(name string, localDockerServerRuntime *LocalDockerServerRuntime) *baseImage {
+func newBaseImage(name string, containerRuntime StageImageRuntime) *baseImage {
 	image := &baseImage{}
 	image.name = name
-	image.LocalDockerServerRuntime = localDockerServerRuntime
+	image.ContainerRuntime = containerRuntime
 	return image
 }
 
@@ -34,7 +33,7 @@ func (i *baseImage) SetName(name string) {
 }
 
 func (i *baseImage) MustResetInspect(ctx context.Context) error {
-	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.Name()); err != nil {
+	if inspect, err := i.ContainerRuntime.GetImageInspect(ctx, i.Name()); err != nil {
 		return fmt.Errorf("unable to get inspect for image %s: %s", i.Name(), err)
 	} else {
 		i.SetInspect(inspect)
@@ -59,7 +58,7 @@ func (i *baseImage) UnsetInspect() {
 }
 
 func (i *baseImage) Untag(ctx context.Context) error {
-	if err := docker.CliRmi(ctx, i.name, "--force"); err != nil {
+	if err := i.ContainerRuntime.rmiImage(ctx, i.name, true); err != nil {
 		return err
 	}
 
@@ -0,0 +1,511 @@
+package container_runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/stapel"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	buildahMaxAttempts = 5
+	buildahCpuPeriod   = 100000
+)
+
+// BuildahRuntime builds stapel stages with the buildah cli and keeps images in the buildah local storage, so docker daemon is not required.
+// Stapel image is mounted into stage containers instead of the docker volumes-from.
+// Rootless buildah requires werf to be run inside the user namespace (buildah unshare) to mount images.
+type BuildahRuntime struct {
+	// Isolation is the buildah run isolation type (oci, rootless or chroot), buildah default is used if empty
+	Isolation string
+
+	mux              sync.Mutex
+	stapelMountPoint string
+}
+
+func NewBuildahRuntime(isolation string) (*BuildahRuntime, error) {
+	if _, err := exec.LookPath("buildah"); err != nil {
+		return nil, fmt.Errorf("buildah binary is required for buildah container runtime: %s", err)
+	}
+
+	return &BuildahRuntime{Isolation: isolation}, nil
+}
+
+func (runtime *BuildahRuntime) String() string {
+	return "buildah"
+}
+
+type buildahImageInspect struct {
+	FromImageID string `json:"FromImageID"`
+	Docker      struct {
+		Created      time.Time         `json:"created"`
+		Author       string            `json:"author"`
+		Architecture string            `json:"architecture"`
+		OS           string            `json:"os"`
+		Config       *container.Config `json:"config"`
+	} `json:"Docker"`
+}
+
+// GetImageInspect returns docker-compatible inspect of the local image or nil if the image does not exist
+func (runtime *BuildahRuntime) GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error) {
+	output, err := runtime.buildahOutput(ctx, "inspect", "--type", "image", buildahImageRef(ref))
+	if err != nil {
+		if isBuildahImageNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var inspect buildahImageInspect
+	if err := json.Unmarshal(output, &inspect); err != nil {
+		return nil, fmt.Errorf("unable to parse buildah inspect of image %s: %s", ref, err)
+	}
+
+	config := inspect.Docker.Config
+	if config == nil {
+		config = &container.Config{}
+	}
+
+	return &types.ImageInspect{
+		ID:           fmt.Sprintf("sha256:%s", inspect.FromImageID),
+		Created:      inspect.Docker.Created.Format(time.RFC3339Nano),
+		Author:       inspect.Docker.Author,
+		Architecture: inspect.Docker.Architecture,
+		Os:           inspect.Docker.OS,
+		Config:       config,
+	}, nil
+}
+
+func (runtime *BuildahRuntime) PullImage(ctx context.Context, ref string) error {
+	if err := runtime.pullImageWithRetries(ctx, ref); err != nil {
+		return fmt.Errorf("unable to pull image %s: %s", ref, err)
+	}
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) RefreshImageObject(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if inspect, err := runtime.GetImageInspect(ctx, dockerImage.Image.Name()); err != nil {
+		return err
+	} else {
+		dockerImage.Image.SetInspect(inspect)
+	}
+	return nil
+}
+
+func (runtime *BuildahRuntime) PullImageFromRegistry(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if err := dockerImage.Image.Pull(ctx); err != nil {
+		return fmt.Errorf("unable to pull image %s: %s", dockerImage.Image.Name(), err)
+	}
+
+	if inspect, err := runtime.GetImageInspect(ctx, dockerImage.Image.Name()); err != nil {
+		return fmt.Errorf("unable to get inspect of image %s: %s", dockerImage.Image.Name(), err)
+	} else {
+		dockerImage.Image.SetInspect(inspect)
+	}
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) RenameImage(ctx context.Context, img Image, newImageName string, removeOldName bool) error {
+	dockerImage := img.(*DockerImage)
+
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Tagging image %s by name %s", dockerImage.Image.Name(), newImageName)).DoError(func() error {
+		if err := runtime.tagImage(ctx, dockerImage.Image.Name(), newImageName); err != nil {
+			return fmt.Errorf("unable to tag image %s by name %s: %s", dockerImage.Image.Name(), newImageName, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if removeOldName {
+		if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Removing old image tag %s", dockerImage.Image.Name())).DoError(func() error {
+			return runtime.rmiImage(ctx, dockerImage.Image.Name(), false)
+		}); err != nil {
+			return err
+		}
+	}
+
+	dockerImage.Image.SetName(newImageName)
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) RemoveImage(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Removing image tag %s", dockerImage.Image.Name())).DoError(func() error {
+		return runtime.rmiImage(ctx, dockerImage.Image.Name(), false)
+	})
+}
+
+func (runtime *BuildahRuntime) PushImage(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", dockerImage.Image.Name())).DoError(func() error {
+		return runtime.pushImageWithRetries(ctx, dockerImage.Image.Name())
+	})
+}
+
+func (runtime *BuildahRuntime) PushBuiltImage(ctx context.Context, img Image) error {
+	if err := runtime.TagBuiltImageByName(ctx, img); err != nil {
+		return err
+	}
+
+	return runtime.PushImage(ctx, img)
+}
+
+func (runtime *BuildahRuntime) TagBuiltImageByName(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if err := dockerImage.Image.TagBuiltImage(ctx, dockerImage.Image.Name()); err != nil {
+		return fmt.Errorf("unable to tag image %s: %s", dockerImage.Image.Name(), err)
+	}
+	return nil
+}
+
+func (runtime *BuildahRuntime) tagImage(ctx context.Context, ref, newRef string) error {
+	return runtime.buildah(ctx, "tag", buildahImageRef(ref), newRef)
+}
+
+func (runtime *BuildahRuntime) rmiImage(ctx context.Context, ref string, force bool) error {
+	args := []string{"rmi"}
+	if force {
+		args = append(args, "--force")
+	}
+	args = append(args, buildahImageRef(ref))
+
+	return runtime.buildah(ctx, args...)
+}
+
+func (runtime *BuildahRuntime) pullImageWithRetries(ctx context.Context, ref string) error {
+	return runtime.withRetries(ctx, "pull", func() error {
+		return runtime.buildah(ctx, "pull", ref)
+	})
+}
+
+func (runtime *BuildahRuntime) pushImageWithRetries(ctx context.Context, ref string) error {
+	return runtime.withRetries(ctx, "push", func() error {
+		return runtime.buildah(ctx, "push", ref)
+	})
+}
+
+func (runtime *BuildahRuntime) withRetries(ctx context.Context, operation string, f func() error) error {
+	var err error
+	for attempt := 1; attempt <= buildahMaxAttempts; attempt++ {
+		if err = f(); err == nil {
+			return nil
+		}
+
+		if attempt != buildahMaxAttempts {
+			logboek.Context(ctx).Warn().LogF("Retrying buildah %s in %d seconds (%d/%d) ...\n", operation, attempt, attempt, buildahMaxAttempts)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+
+	return err
+}
+
+func (runtime *BuildahRuntime) runStageContainer(ctx context.Context, c *StageImageContainer) error {
+	runOptions, err := c.prepareRunOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(runOptions.VolumesFrom) != 0 {
+		return fmt.Errorf("container run failed: volumes from containers %v are not supported by buildah container runtime", runOptions.VolumesFrom)
+	}
+
+	fromArgs, err := buildahResourcesArgs(runOptions)
+	if err != nil {
+		return err
+	}
+
+	fromArgs = append([]string{"from", "--name", c.Name(), "--pull-never"}, fromArgs...)
+	fromArgs = append(fromArgs, buildahImageRef(c.image.fromImage.GetID()))
+	if err := runtime.buildah(ctx, fromArgs...); err != nil {
+		return fmt.Errorf("unable to create container %s: %s", c.Name(), err)
+	}
+
+	args := []string{"run"}
+	if runtime.Isolation != "" {
+		args = append(args, "--isolation", runtime.Isolation)
+	}
+	if runOptions.User != "" {
+		args = append(args, "--user", runOptions.User)
+	}
+	if runOptions.Workdir != "" {
+		args = append(args, "--workingdir", runOptions.Workdir)
+	}
+	for _, volume := range runOptions.Volume {
+		args = append(args, "--volume", volume)
+	}
+	for _, env := range sortedEnvs(runOptions.Env) {
+		args = append(args, "--env", env)
+	}
+	args = append(args, "--env", fmt.Sprintf("COLUMNS=%d", logboek.Context(ctx).Streams().ContentWidth()))
+	args = append(args, "--", c.Name(), runOptions.Entrypoint, "-ec", c.prepareRunCommand())
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Buildah run command:\nbuildah %s\n", strings.Join(args, " "))
+
+		if len(c.prepareAllRunCommands()) != 0 {
+			fmt.Printf("Decoded command:\n%s\n", strings.Join(c.prepareAllRunCommands(), " && "))
+		}
+	}
+
+	output := newTailBuffer(runFailureOutputTailSize)
+	if err := runtime.buildahWithOutputTail(ctx, output, args...); err != nil {
+		if e, ok := err.(*buildahError); ok {
+			if exitErr, ok := e.err.(*exec.ExitError); ok {
+				if isBuildahMemoryLimitExceeded(runOptions, exitErr.ExitCode()) {
+					return memoryLimitExceededError(runOptions.Memory)
+				}
+
+				c.lastRunFailure = &ContainerRunFailure{ExitCode: exitErr.ExitCode(), Output: output.String()}
+			}
+		}
+
+		return fmt.Errorf("container run failed: %s", err)
+	}
+
+	return nil
+}
+
+// buildahResourcesArgs returns resources limits of the working container, buildah has no --cpus option so the CPU quota is used
+func buildahResourcesArgs(runOptions *StageImageContainerOptions) ([]string, error) {
+	var args []string
+	if runOptions.Memory != "" {
+		args = append(args, "--memory", runOptions.Memory)
+	}
+
+	if runOptions.Cpus != "" {
+		cpus, err := strconv.ParseFloat(runOptions.Cpus, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpus value %q: %s", runOptions.Cpus, err)
+		}
+
+		args = append(args, "--cpu-period", strconv.Itoa(buildahCpuPeriod), "--cpu-quota", strconv.Itoa(int(cpus*buildahCpuPeriod)))
+	}
+
+	return args, nil
+}
+
+// isBuildahMemoryLimitExceeded checks whether the container with the memory limit has been killed by the OOM killer:
+// buildah does not report the OOM kill, the command killed by SIGKILL exits with code 137 (128 + 9)
+func isBuildahMemoryLimitExceeded(runOptions *StageImageContainerOptions, exitCode int) bool {
+	return runOptions.Memory != "" && exitCode == 137
+}
+
+func (runtime *BuildahRuntime) introspectStageContainer(_ context.Context, _ *StageImageContainer, _ bool) error {
+	return fmt.Errorf("stage introspection is not supported by buildah container runtime")
+}
+
+func (runtime *BuildahRuntime) commitStageContainer(ctx context.Context, c *StageImageContainer) (string, error) {
+	commitOptions, err := c.prepareCommitOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := runtime.buildah(ctx, append(append([]string{"config"}, buildahConfigArgs(commitOptions)...), c.Name())...); err != nil {
+		return "", fmt.Errorf("unable to configure container %s: %s", c.Name(), err)
+	}
+
+	output, err := runtime.buildahOutput(ctx, "commit", "--format", "docker", "--quiet", c.Name())
+	if err != nil {
+		return "", fmt.Errorf("unable to commit container %s: %s", c.Name(), err)
+	}
+
+	return fmt.Sprintf("sha256:%s", strings.TrimSpace(string(output))), nil
+}
+
+func (runtime *BuildahRuntime) rmStageContainer(ctx context.Context, c *StageImageContainer) error {
+	return runtime.buildah(ctx, "rm", c.Name())
+}
+
+// getStapelVolume returns the volume with stapel tools from the mounted stapel image, the image is mounted once per werf process
+func (runtime *BuildahRuntime) getStapelVolume(ctx context.Context) (string, error) {
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	if runtime.stapelMountPoint == "" {
+		containerName := fmt.Sprintf("werf-%s", strings.NewReplacer("/", "-", ":", "-").Replace(stapel.ImageName()))
+
+		if _, lock, err := werf.AcquireHostLock(ctx, containerName, lockgate.AcquireOptions{}); err != nil {
+			return "", fmt.Errorf("failed to lock %s: %s", containerName, err)
+		} else {
+			defer werf.ReleaseHostLock(lock)
+		}
+
+		if _, err := runtime.buildahOutput(ctx, "inspect", "--type", "container", containerName); err != nil {
+			if err := runtime.buildah(ctx, "from", "--name", containerName, stapel.ImageName()); err != nil {
+				return "", fmt.Errorf("unable to create stapel container %s: %s", containerName, err)
+			}
+		}
+
+		output, err := runtime.buildahOutput(ctx, "mount", containerName)
+		if err != nil {
+			return "", fmt.Errorf("unable to mount stapel container %s: %s", containerName, err)
+		}
+
+		runtime.stapelMountPoint = strings.TrimSpace(string(output))
+	}
+
+	return fmt.Sprintf("%s:/.werf/stapel:ro", filepath.Join(runtime.stapelMountPoint, ".werf", "stapel")), nil
+}
+
+func (runtime *BuildahRuntime) buildah(ctx context.Context, args ...string) error {
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.CommandContext(ctx, "buildah", args...)
+	cmd.Stdout = logboek.Context(ctx).ProxyOutStream()
+	cmd.Stderr = io.MultiWriter(stderr, logboek.Context(ctx).ProxyErrStream())
+
+	if err := cmd.Run(); err != nil {
+		return newBuildahError(args, stderr.String(), err)
+	}
+
+	return nil
+}
+
+// buildahWithOutputTail runs buildah with the live output and also writes the output to the tail buffer
+func (runtime *BuildahRuntime) buildahWithOutputTail(ctx context.Context, tail *tailBuffer, args ...string) error {
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.CommandContext(ctx, "buildah", args...)
+	cmd.Stdout = io.MultiWriter(tail, logboek.Context(ctx).ProxyOutStream())
+	cmd.Stderr = io.MultiWriter(tail, stderr, logboek.Context(ctx).ProxyErrStream())
+
+	if err := cmd.Run(); err != nil {
+		return newBuildahError(args, stderr.String(), err)
+	}
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) buildahOutput(ctx context.Context, args ...string) ([]byte, error) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.CommandContext(ctx, "buildah", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, newBuildahError(args, stderr.String(), err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+type buildahError struct {
+	args   []string
+	stderr string
+	err    error
+}
+
+func newBuildahError(args []string, stderr string, err error) error {
+	return &buildahError{args: args, stderr: strings.TrimSpace(stderr), err: err}
+}
+
+func (e *buildahError) Error() string {
+	if e.stderr != "" {
+		return fmt.Sprintf("buildah %s failed: %s: %s", e.args[0], e.err, e.stderr)
+	}
+	return fmt.Sprintf("buildah %s failed: %s", e.args[0], e.err)
+}
+
+func isBuildahImageNotFoundErr(err error) bool {
+	if e, ok := err.(*buildahError); ok {
+		for _, msg := range []string{"image not known", "no such image", "unable to find"} {
+			if strings.Contains(strings.ToLower(e.stderr), msg) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// buildahImageRef trims the sha256: prefix of image id, which is kept for compatibility with docker image ids
+func buildahImageRef(ref string) string {
+	return strings.TrimPrefix(ref, "sha256:")
+}
+
+func buildahConfigArgs(co *StageImageContainerOptions) []string {
+	var args []string
+
+	for _, volume := range co.Volume {
+		args = append(args, "--volume", volume)
+	}
+
+	for _, expose := range co.Expose {
+		args = append(args, "--port", expose)
+	}
+
+	for _, env := range sortedEnvs(co.Env) {
+		args = append(args, "--env", env)
+	}
+
+	for _, label := range sortedEnvs(co.Label) {
+		args = append(args, "--label", label)
+	}
+
+	if co.Workdir != "" {
+		args = append(args, "--workingdir", co.Workdir)
+	}
+
+	if co.User != "" {
+		args = append(args, "--user", co.User)
+	}
+
+	if co.Entrypoint != "" {
+		args = append(args, "--entrypoint", co.Entrypoint)
+	} else {
+		args = append(args, "--entrypoint", "[]")
+	}
+
+	if co.Cmd != "" {
+		args = append(args, "--cmd", co.Cmd)
+	} else if co.Entrypoint == "" {
+		args = append(args, "--cmd", "[]")
+	}
+
+	if co.HealthCheck != "" {
+		args = append(args, "--healthcheck", co.HealthCheck)
+	}
+
+	return args
+}
+
+func sortedEnvs(envs map[string]string) []string {
+	var res []string
+	for key, value := range envs {
+		res = append(res, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(res)
+
+	return res
+}
@@ -0,0 +1,75 @@
+package container_runtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBuildahConfigArgs(t *testing.T) {
+	co := newStageContainerOptions()
+	co.AddEnv(map[string]string{"B": "2", "A": "1"})
+	co.AddLabel(map[string]string{"werf": "test"})
+	co.AddExpose("80/tcp")
+	co.AddWorkdir("/app")
+	co.AddUser("app")
+
+	expected := []string{
+		"--port", "80/tcp",
+		"--env", "A=1",
+		"--env", "B=2",
+		"--label", "werf=test",
+		"--workingdir", "/app",
+		"--user", "app",
+		"--entrypoint", "[]",
+		"--cmd", "[]",
+	}
+
+	if args := buildahConfigArgs(co); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected %v, got %v", expected, args)
+	}
+
+	co.AddEntrypoint(`["/bin/sh"]`)
+	args := buildahConfigArgs(co)
+	if args[len(args)-2] != "--entrypoint" || args[len(args)-1] != `["/bin/sh"]` {
+		t.Fatalf("expected entrypoint without cmd reset, got %v", args)
+	}
+}
+
+func TestIsBuildahImageNotFoundErr(t *testing.T) {
+	if !isBuildahImageNotFoundErr(newBuildahError([]string{"inspect"}, "Error: image not known", errors.New("exit status 125"))) {
+		t.Fatal("expected image not found error")
+	}
+
+	if isBuildahImageNotFoundErr(newBuildahError([]string{"inspect"}, "Error: permission denied", errors.New("exit status 125"))) {
+		t.Fatal("unexpected image not found error")
+	}
+}
+
+func TestBuildahResourcesArgs(t *testing.T) {
+	co := newStageContainerOptions()
+	co.AddMemory("2g")
+	co.AddCpus("1.5")
+
+	expected := []string{"--memory", "2g", "--cpu-period", "100000", "--cpu-quota", "150000"}
+	if args, err := buildahResourcesArgs(co); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected %v, got %v", expected, args)
+	}
+}
+
+func TestIsBuildahMemoryLimitExceeded(t *testing.T) {
+	co := newStageContainerOptions()
+	if isBuildahMemoryLimitExceeded(co, 137) {
+		t.Fatal("unexpected memory limit exceeded without memory limit")
+	}
+
+	co.AddMemory("2g")
+	if !isBuildahMemoryLimitExceeded(co, 137) {
+		t.Fatal("expected memory limit exceeded")
+	}
+	if isBuildahMemoryLimitExceeded(co, 1) {
+		t.Fatal("unexpected memory limit exceeded for generic exit code")
+	}
+}
@@ -0,0 +1,34 @@
+package container_runtime
+
+import (
+	"context"
+	"time"
+)
+
+// cleanupTimeout limits removal and killing of the stage containers, which are performed even when the build context is done
+const cleanupTimeout = time.Minute
+
+// newCleanupContext returns the context with values of ctx (logger, docker cli), which is not done when ctx is done
+func newCleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent: ctx}, cleanupTimeout)
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
@@ -3,9 +3,11 @@ package container_runtime
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 
 	"github.com/werf/logboek"
 
@@ -20,6 +22,23 @@ type ContainerRuntime interface {
 	String() string
 }
 
+// StageImageRuntime is a container runtime which keeps stage images locally and builds stapel stages in containers.
+// Images and containers of the package use it instead of calling the specific backend.
+type StageImageRuntime interface {
+	ContainerRuntime
+	GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error)
+
+	tagImage(ctx context.Context, ref, newRef string) error
+	rmiImage(ctx context.Context, ref string, force bool) error
+	pullImageWithRetries(ctx context.Context, ref string) error
+	pushImageWithRetries(ctx context.Context, ref string) error
+
+	runStageContainer(ctx context.Context, container *StageImageContainer) error
+	introspectStageContainer(ctx context.Context, container *StageImageContainer, before bool) error
+	commitStageContainer(ctx context.Context, container *StageImageContainer) (string, error)
+	rmStageContainer(ctx context.Context, container *StageImageContainer) error
+}
+
 type LocalDockerServerRuntime struct{}
 
 // GetImageInspect only available for LocalDockerServerRuntime
@@ -158,6 +177,140 @@ func (runtime *LocalDockerServerRuntime) String() string {
 	return "local-docker-server"
 }
 
+func (runtime *LocalDockerServerRuntime) tagImage(ctx context.Context, ref, newRef string) error {
+	return docker.CliTag(ctx, ref, newRef)
+}
+
+func (runtime *LocalDockerServerRuntime) rmiImage(ctx context.Context, ref string, force bool) error {
+	if force {
+		return docker.CliRmi(ctx, ref, "--force")
+	}
+	return docker.CliRmi(ctx, ref)
+}
+
+func (runtime *LocalDockerServerRuntime) pullImageWithRetries(ctx context.Context, ref string) error {
+	return docker.CliPullWithRetries(ctx, ref)
+}
+
+func (runtime *LocalDockerServerRuntime) pushImageWithRetries(ctx context.Context, ref string) error {
+	return docker.CliPushWithRetries(ctx, ref)
+}
+
+func (runtime *LocalDockerServerRuntime) runStageContainer(ctx context.Context, container *StageImageContainer) error {
+	runArgs, err := container.prepareRunArgs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
+
+		if len(container.prepareAllRunCommands()) != 0 {
+			fmt.Printf("Decoded command:\n%s\n", strings.Join(container.prepareAllRunCommands(), " && "))
+		}
+	}
+
+	runErrCh := make(chan error, 1)
+	go func() {
+		runErrCh <- docker.CliRun_LiveOutput(ctx, runArgs...)
+	}()
+
+	var runErr error
+	select {
+	case runErr = <-runErrCh:
+	case <-ctx.Done():
+		// docker cli does not stop the container when the context is done
+		killCtx, cancel := newCleanupContext(ctx)
+		err := docker.ContainerKill(killCtx, container.Name(), "KILL")
+		cancel()
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: unable to kill container %s: %s\n", container.Name(), err)
+		}
+		<-runErrCh
+
+		return fmt.Errorf("container run failed: %s", ctx.Err())
+	}
+
+	if runErr != nil {
+		inspect, err := docker.ContainerInspect(context.Background(), container.Name())
+		if err == nil && inspect.ContainerJSONBase != nil && inspect.State != nil {
+			if container.runOptions.Memory != "" && inspect.State.OOMKilled {
+				return memoryLimitExceededError(container.runOptions.Memory)
+			}
+
+			container.lastRunFailure = &ContainerRunFailure{
+				ExitCode: inspect.State.ExitCode,
+				Output:   runtime.containerOutputTail(ctx, container.Name()),
+			}
+		}
+
+		return fmt.Errorf("container run failed: %s", runErr.Error())
+	}
+
+	return nil
+}
+
+// memoryLimitExceededError is returned when the stage container with the memory limit has been killed by the OOM killer
+func memoryLimitExceededError(memory string) error {
+	return fmt.Errorf("container run failed: memory limit %s exceeded: container has been killed by the OOM killer", memory)
+}
+
+func (runtime *LocalDockerServerRuntime) containerOutputTail(ctx context.Context, containerName string) string {
+	logs, err := docker.ContainerLogs(context.Background(), containerName, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: unable to get container %s logs: %s\n", containerName, err)
+		return ""
+	}
+	defer logs.Close()
+
+	output := newTailBuffer(runFailureOutputTailSize)
+	if _, err := stdcopy.StdCopy(output, output, logs); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: unable to read container %s logs: %s\n", containerName, err)
+	}
+
+	return output.String()
+}
+
+func (runtime *LocalDockerServerRuntime) introspectStageContainer(ctx context.Context, container *StageImageContainer, before bool) error {
+	var runArgs []string
+	var err error
+	if before {
+		runArgs, err = container.prepareIntrospectBeforeArgs(ctx)
+	} else {
+		runArgs, err = container.prepareIntrospectArgs(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := docker.CliRun_LiveOutput(ctx, runArgs...); err != nil {
+		if !strings.Contains(err.Error(), "Code: ") || IsStartContainerErr(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (runtime *LocalDockerServerRuntime) commitStageContainer(ctx context.Context, container *StageImageContainer) (string, error) {
+	commitChanges, err := container.prepareCommitChanges(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	commitOptions := types.ContainerCommitOptions{Changes: commitChanges}
+	id, err := docker.ContainerCommit(ctx, container.Name(), commitOptions)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (runtime *LocalDockerServerRuntime) rmStageContainer(ctx context.Context, container *StageImageContainer) error {
+	return docker.ContainerRemove(ctx, container.Name(), types.ContainerRemoveOptions{})
+}
+
 type LocalHostRuntime struct {
 	ContainerRuntime // TODO: kaniko-like builds
 }
@@ -48,6 +48,9 @@ type Container interface {
 
 	AddServiceRunCommands(commands ...string)
 	AddRunCommands(commands ...string)
+	AddSecrets(secrets ...*Secret)
+
+	LastRunFailure() *ContainerRunFailure
 
 	RunOptions() ContainerOptions
 	CommitChangeOptions() ContainerOptions
@@ -76,4 +79,6 @@ type ContainerOptions interface {
 	AddUser(user string)
 	AddEntrypoint(entrypoint string)
 	AddHealthCheck(check string)
+	AddMemory(memory string)
+	AddCpus(cpus string)
 }
@@ -0,0 +1,44 @@
+package container_runtime
+
+import (
+	"sync"
+)
+
+// runFailureOutputTailSize is the size of the failed container output tail which is matched against retry output patterns
+const runFailureOutputTailSize = 64 * 1024
+
+// ContainerRunFailure describes the failed run of the stage container
+type ContainerRunFailure struct {
+	ExitCode int
+	Output   string
+}
+
+// tailBuffer keeps the last size bytes written
+type tailBuffer struct {
+	mu   sync.Mutex
+	size int
+	data []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > b.size {
+		b.data = b.data[len(b.data)-b.size:]
+	}
+
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return string(b.data)
+}
@@ -0,0 +1,17 @@
+package container_runtime
+
+import "testing"
+
+func TestTailBuffer(t *testing.T) {
+	b := newTailBuffer(5)
+
+	for _, s := range []string{"abc", "defg", "h"} {
+		if _, err := b.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := b.String(); got != "defgh" {
+		t.Fatalf("expected %q, got %q", "defgh", got)
+	}
+}
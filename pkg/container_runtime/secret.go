@@ -0,0 +1,87 @@
+package container_runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	containerSecretsDir = "/.werf/secrets"
+	// secretsHostTmpfsDir is used to keep secrets data in memory, werf tmp dir is used when the tmpfs is not available
+	secretsHostTmpfsDir = "/dev/shm"
+)
+
+// Secret is available in the stage container during the run only.
+// The secret data is neither committed into the image layer nor into the image config.
+type Secret struct {
+	Data []byte
+	// Path is the absolute path of the secret file in the container
+	Path string
+	// Env is the name of the environment variable with the secret data
+	Env string
+}
+
+// prepareSecretsRunOptions writes secrets data into the host dir which is mounted into the container read-only.
+// Env secrets are exported by the service run commands, thus the secret data is not passed to the container config.
+func (c *StageImageContainer) prepareSecretsRunOptions() (*StageImageContainerOptions, error) {
+	if err := c.removeSecrets(); err != nil {
+		return nil, err
+	}
+
+	options := newStageContainerOptions()
+	if len(c.secrets) == 0 {
+		return options, nil
+	}
+
+	baseDir := secretsHostTmpfsDir
+	if fi, err := os.Stat(secretsHostTmpfsDir); err != nil || !fi.IsDir() {
+		baseDir = werf.GetTmpDir()
+	}
+
+	secretsDir, err := ioutil.TempDir(baseDir, "werf-secrets-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create secrets dir: %s", err)
+	}
+	c.secretsDir = secretsDir
+
+	for ind, secret := range c.secrets {
+		secretName := fmt.Sprintf("%d", ind)
+		if err := ioutil.WriteFile(filepath.Join(secretsDir, secretName), secret.Data, 0600); err != nil {
+			return nil, fmt.Errorf("unable to write secret: %s", err)
+		}
+
+		if secret.Path != "" {
+			options.AddVolume(fmt.Sprintf("%s:%s:ro", filepath.Join(secretsDir, secretName), secret.Path))
+		}
+
+		if secret.Env != "" {
+			c.secretsRunCommands = append(c.secretsRunCommands, fmt.Sprintf("export %s=\"$(< %s)\"", secret.Env, path.Join(containerSecretsDir, secretName)))
+		}
+	}
+
+	if len(c.secretsRunCommands) != 0 {
+		options.AddVolume(fmt.Sprintf("%s:%s:ro", secretsDir, containerSecretsDir))
+	}
+
+	return options, nil
+}
+
+func (c *StageImageContainer) removeSecrets() error {
+	c.secretsRunCommands = nil
+
+	if c.secretsDir == "" {
+		return nil
+	}
+
+	if err := os.RemoveAll(c.secretsDir); err != nil {
+		return fmt.Errorf("unable to remove secrets dir %s: %s", c.secretsDir, err)
+	}
+	c.secretsDir = ""
+
+	return nil
+}
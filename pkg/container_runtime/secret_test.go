@@ -0,0 +1,98 @@
+package container_runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStageImageContainerSecrets(t *testing.T) {
+	c := newStageImageContainer(&StageImage{})
+	c.AddSecrets(
+		&Secret{Data: []byte("file-secret"), Path: "/root/.npmrc"},
+		&Secret{Data: []byte("env-secret"), Env: "NPM_TOKEN"},
+	)
+
+	options, err := c.prepareSecretsRunOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretsDir := c.secretsDir
+	if len(options.Volume) != 2 || !strings.HasSuffix(options.Volume[0], ":/root/.npmrc:ro") || !strings.HasSuffix(options.Volume[1], ":"+containerSecretsDir+":ro") {
+		t.Fatalf("unexpected secrets volumes %v", options.Volume)
+	}
+
+	data, err := ioutil.ReadFile(strings.SplitN(options.Volume[0], ":", 2)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "file-secret" {
+		t.Fatalf("unexpected secret file data %q", data)
+	}
+
+	commands := strings.Join(c.prepareAllRunCommands(), " && ")
+	if !strings.Contains(commands, "export NPM_TOKEN=") || strings.Contains(commands, "env-secret") {
+		t.Fatalf("unexpected run commands %q", commands)
+	}
+
+	for _, change := range c.UserCommitChanges() {
+		if strings.Contains(change, "secret") || strings.Contains(change, "NPM_TOKEN") {
+			t.Fatalf("secret reached commit changes: %q", change)
+		}
+	}
+
+	if err := c.removeSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(secretsDir); !os.IsNotExist(err) {
+		t.Fatalf("expected secrets dir %s to be removed", secretsDir)
+	}
+
+	if strings.Contains(strings.Join(c.prepareAllRunCommands(), " && "), "NPM_TOKEN") {
+		t.Fatal("expected secrets run commands to be removed")
+	}
+}
+
+func TestStageImageContainerSecretsAreNotTraced(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+
+	os.Setenv("WERF_DEBUG_DOCKER_RUN_COMMAND", "1")
+	defer os.Unsetenv("WERF_DEBUG_DOCKER_RUN_COMMAND")
+
+	c := newStageImageContainer(&StageImage{})
+	c.AddSecrets(&Secret{Data: []byte("env-secret"), Env: "NPM_TOKEN"})
+	c.AddRunCommands(`[ "${#NPM_TOKEN}" -eq 10 ]`)
+
+	if _, err := c.prepareSecretsRunOptions(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.removeSecrets()
+
+	commands := strings.Join(c.prepareAllRunCommands(), " && ")
+	if !strings.Contains(commands, "set -x") {
+		t.Fatalf("expected traced run commands, got %q", commands)
+	}
+
+	// the secrets dir is mounted into the container, the host dir is used instead to run commands locally
+	commands = strings.Replace(commands, containerSecretsDir, c.secretsDir, -1)
+
+	output, err := exec.Command(bash, "-ec", commands).CombinedOutput()
+	if err != nil {
+		t.Fatalf("run commands failed: %s\n%s", err, output)
+	}
+
+	if !strings.Contains(string(output), "+ '[' 10 -eq 10 ']'") {
+		t.Fatalf("expected traced user command, got %q", output)
+	}
+
+	if strings.Contains(string(output), "env-secret") {
+		t.Fatalf("secret value reached the traced commands: %q", output)
+	}
+}
@@ -13,7 +13,6 @@ import (
 
 	"github.com/werf/logboek"
 
-	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
 )
 
@@ -25,9 +24,9 @@ type StageImage struct {
 	dockerfileImageBuilder *DockerfileImageBuilder
 }
 
-func NewStageImage(fromImage *StageImage, name string, localDockerServerRuntime *LocalDockerServerRuntime) *StageImage {
+func NewStageImage(fromImage *StageImage, name string, containerRuntime StageImageRuntime) *StageImage {
 	stage := &StageImage{}
-	stage.baseImage = newBaseImage(name, localDockerServerRuntime)
+	stage.baseImage = newBaseImage(name, containerRuntime)
 	stage.fromImage = fromImage
 	stage.container = newStageImageContainer(stage)
 	return stage
@@ -66,19 +65,6 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 			defer werf.ReleaseHostLock(lock)
 		}
 
-		if debugDockerRunCommand() {
-			runArgs, err := i.container.prepareRunArgs(ctx)
-			if err != nil {
-				return err
-			}
-
-			fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
-
-			if len(i.container.prepareAllRunCommands()) != 0 {
-				fmt.Printf("Decoded command:\n%s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-			}
-		}
-
 		if containerRunErr := i.container.run(ctx); containerRunErr != nil {
 			if strings.HasPrefix(containerRunErr.Error(), "container run failed") {
 				if options.IntrospectBeforeError {
@@ -112,6 +98,10 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 		}
 
 		if err := i.Commit(ctx); err != nil {
+			if rmErr := i.container.rm(ctx); rmErr != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: unable to remove container %s: %s\n", i.container.Name(), rmErr)
+			}
+
 			return err
 		}
 
@@ -120,7 +110,7 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 		}
 	}
 
-	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.MustGetBuiltId()); err != nil {
+	if inspect, err := i.ContainerRuntime.GetImageInspect(ctx, i.MustGetBuiltId()); err != nil {
 		return err
 	} else {
 		i.SetInspect(inspect)
@@ -139,7 +129,7 @@ func (i *StageImage) Commit(ctx context.Context) error {
 		return err
 	}
 
-	i.buildImage = newBuildImage(builtId, i.LocalDockerServerRuntime)
+	i.buildImage = newBuildImage(builtId, i.ContainerRuntime)
 
 	return nil
 }
@@ -195,15 +185,15 @@ func (i *StageImage) GetBuiltId() string {
 }
 
 func (i *StageImage) TagBuiltImage(ctx context.Context, name string) error {
-	return docker.CliTag(ctx, i.MustGetBuiltId(), i.name)
+	return i.ContainerRuntime.tagImage(ctx, i.MustGetBuiltId(), i.name)
 }
 
 func (i *StageImage) Tag(ctx context.Context, name string) error {
-	return docker.CliTag(ctx, i.GetID(), name)
+	return i.ContainerRuntime.tagImage(ctx, i.GetID(), name)
 }
 
 func (i *StageImage) Pull(ctx context.Context) error {
-	if err := docker.CliPullWithRetries(ctx, i.name); err != nil {
+	if err := i.ContainerRuntime.pullImageWithRetries(ctx, i.name); err != nil {
 		return err
 	}
 
@@ -213,23 +203,23 @@ func (i *StageImage) Pull(ctx context.Context) error {
 }
 
 func (i *StageImage) Push(ctx context.Context) error {
-	return docker.CliPushWithRetries(ctx, i.name)
+	return i.ContainerRuntime.pushImageWithRetries(ctx, i.name)
 }
 
 func (i *StageImage) Import(ctx context.Context, name string) error {
-	importedImage := newBaseImage(name, i.LocalDockerServerRuntime)
+	importedImage := newBaseImage(name, i.ContainerRuntime)
 
-	if err := docker.CliPullWithRetries(ctx, name); err != nil {
+	if err := i.ContainerRuntime.pullImageWithRetries(ctx, name); err != nil {
 		return err
 	}
 
 	importedImageId := importedImage.GetStageDescription().Info.ID
 
-	if err := docker.CliTag(ctx, importedImageId, i.name); err != nil {
+	if err := i.ContainerRuntime.tagImage(ctx, importedImageId, i.name); err != nil {
 		return err
 	}
 
-	if err := docker.CliRmi(ctx, name); err != nil {
+	if err := i.ContainerRuntime.rmiImage(ctx, name, false); err != nil {
 		return err
 	}
 
@@ -245,7 +235,7 @@ func (i *StageImage) Export(ctx context.Context, name string) error {
 
 	defer func() {
 		if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Untagging %s", name)).DoError(func() error {
-			return docker.CliRmi(ctx, name)
+			return i.ContainerRuntime.rmiImage(ctx, name, false)
 		}); err != nil {
 			// TODO: errored image state
 			logboek.Context(ctx).Error().LogF("Unable to remote temporary image %q: %s", name, err)
@@ -253,7 +243,7 @@ func (i *StageImage) Export(ctx context.Context, name string) error {
 	}()
 
 	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", name)).DoError(func() error {
-		return docker.CliPushWithRetries(ctx, name)
+		return i.ContainerRuntime.pushImageWithRetries(ctx, name)
 	}); err != nil {
 		return err
 	}
@@ -8,10 +8,7 @@ import (
 
 	"github.com/werf/werf/pkg/image"
 
-	"github.com/docker/docker/api/types"
-
 	"github.com/werf/logboek"
-	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/util"
 )
@@ -24,6 +21,12 @@ type StageImageContainer struct {
 	runOptions                 *StageImageContainerOptions
 	commitChangeOptions        *StageImageContainerOptions
 	serviceCommitChangeOptions *StageImageContainerOptions
+
+	secrets            []*Secret
+	secretsDir         string
+	secretsRunCommands []string
+
+	lastRunFailure *ContainerRunFailure
 }
 
 func newStageImageContainer(img *StageImage) *StageImageContainer {
@@ -56,6 +59,16 @@ func (c *StageImageContainer) AddServiceRunCommands(commands ...string) {
 	c.serviceRunCommands = append(c.serviceRunCommands, commands...)
 }
 
+// AddSecrets adds secrets which are available in the container during the run only
+func (c *StageImageContainer) AddSecrets(secrets ...*Secret) {
+	c.secrets = append(c.secrets, secrets...)
+}
+
+// LastRunFailure returns the exit code and the output tail of the last failed run, nil if the last run has not failed
+func (c *StageImageContainer) LastRunFailure() *ContainerRunFailure {
+	return c.lastRunFailure
+}
+
 func (c *StageImageContainer) RunOptions() ContainerOptions {
 	return c.runOptions
 }
@@ -111,6 +124,9 @@ func (c *StageImageContainer) prepareRunCommands() []string {
 func (c *StageImageContainer) prepareAllRunCommands() []string {
 	var commands []string
 
+	// secrets are exported before tracing is enabled, so secret values do not get into the build log
+	commands = append(commands, c.secretsRunCommands...)
+
 	if debugDockerRunCommand() {
 		commands = append(commands, "set -x")
 	}
@@ -179,7 +195,13 @@ func (c *StageImageContainer) prepareRunOptions(ctx context.Context) (*StageImag
 	if err != nil {
 		return nil, err
 	}
-	return serviceRunOptions.merge(c.runOptions), nil
+
+	secretsRunOptions, err := c.prepareSecretsRunOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceRunOptions.merge(secretsRunOptions).merge(c.runOptions), nil
 }
 
 func (c *StageImageContainer) prepareServiceRunOptions(ctx context.Context) (*StageImageContainerOptions, error) {
@@ -188,12 +210,22 @@ func (c *StageImageContainer) prepareServiceRunOptions(ctx context.Context) (*St
 	serviceRunOptions.Entrypoint = stapel.BashBinPath()
 	serviceRunOptions.User = "0:0"
 
-	stapelContainerName, err := stapel.GetOrCreateContainer(ctx)
-	if err != nil {
-		return nil, err
-	}
+	switch runtime := c.image.ContainerRuntime.(type) {
+	case *BuildahRuntime:
+		stapelVolume, err := runtime.getStapelVolume(ctx)
+		if err != nil {
+			return nil, err
+		}
 
-	serviceRunOptions.VolumesFrom = []string{stapelContainerName}
+		serviceRunOptions.Volume = []string{stapelVolume}
+	default:
+		stapelContainerName, err := stapel.GetOrCreateContainer(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		serviceRunOptions.VolumesFrom = []string{stapelContainerName}
+	}
 
 	return serviceRunOptions, nil
 }
@@ -255,46 +287,32 @@ func (c *StageImageContainer) prepareInheritedCommitOptions(ctx context.Context)
 }
 
 func (c *StageImageContainer) run(ctx context.Context) error {
-	runArgs, err := c.prepareRunArgs(ctx)
-	if err != nil {
-		return err
-	}
-
-	if err := docker.CliRun_LiveOutput(ctx, runArgs...); err != nil {
-		return fmt.Errorf("container run failed: %s", err.Error())
-	}
-
-	return nil
+	c.lastRunFailure = nil
+	return c.withSecretsCleanup(func() error {
+		return c.image.ContainerRuntime.runStageContainer(ctx, c)
+	})
 }
 
 func (c *StageImageContainer) introspect(ctx context.Context) error {
-	runArgs, err := c.prepareIntrospectArgs(ctx)
-	if err != nil {
-		return err
-	}
-
-	if err := docker.CliRun_LiveOutput(ctx, runArgs...); err != nil {
-		if !strings.Contains(err.Error(), "Code: ") || IsStartContainerErr(err) {
-			return err
-		}
-	}
-
-	return nil
+	return c.withSecretsCleanup(func() error {
+		return c.image.ContainerRuntime.introspectStageContainer(ctx, c, false)
+	})
 }
 
 func (c *StageImageContainer) introspectBefore(ctx context.Context) error {
-	runArgs, err := c.prepareIntrospectBeforeArgs(ctx)
-	if err != nil {
-		return err
-	}
+	return c.withSecretsCleanup(func() error {
+		return c.image.ContainerRuntime.introspectStageContainer(ctx, c, true)
+	})
+}
 
-	if err := docker.CliRun_LiveOutput(ctx, runArgs...); err != nil {
-		if !strings.Contains(err.Error(), "Code: ") || IsStartContainerErr(err) {
-			return err
-		}
+func (c *StageImageContainer) withSecretsCleanup(f func() error) error {
+	err := f()
+
+	if removeErr := c.removeSecrets(); removeErr != nil && err == nil {
+		return removeErr
 	}
 
-	return nil
+	return err
 }
 
 // https://docs.docker.com/engine/reference/run/#exit-status
@@ -309,20 +327,13 @@ func IsStartContainerErr(err error) bool {
 }
 
 func (c *StageImageContainer) commit(ctx context.Context) (string, error) {
-	commitChanges, err := c.prepareCommitChanges(ctx)
-	if err != nil {
-		return "", err
-	}
-
-	commitOptions := types.ContainerCommitOptions{Changes: commitChanges}
-	id, err := docker.ContainerCommit(ctx, c.name, commitOptions)
-	if err != nil {
-		return "", err
-	}
-
-	return id, nil
+	return c.image.ContainerRuntime.commitStageContainer(ctx, c)
 }
 
+// rm removes the container even if ctx is done (e.g. the stage build timeout exceeded), so containers are not leaked
 func (c *StageImageContainer) rm(ctx context.Context) error {
-	return docker.ContainerRemove(ctx, c.name, types.ContainerRemoveOptions{})
+	cleanupCtx, cancel := newCleanupContext(ctx)
+	defer cancel()
+
+	return c.image.ContainerRuntime.rmStageContainer(cleanupCtx, c)
 }
@@ -20,6 +20,8 @@ type StageImageContainerOptions struct {
 	User        string
 	Entrypoint  string
 	HealthCheck string
+	Memory      string
+	Cpus        string
 }
 
 func newStageContainerOptions() *StageImageContainerOptions {
@@ -73,6 +75,16 @@ func (co *StageImageContainerOptions) AddEntrypoint(entrypoint string) {
 	co.Entrypoint = entrypoint
 }
 
+// AddMemory sets the memory limit of the container, the limit is not committed into the image
+func (co *StageImageContainerOptions) AddMemory(memory string) {
+	co.Memory = memory
+}
+
+// AddCpus sets the number of CPUs available to the container, the limit is not committed into the image
+func (co *StageImageContainerOptions) AddCpus(cpus string) {
+	co.Cpus = cpus
+}
+
 func (co *StageImageContainerOptions) merge(co2 *StageImageContainerOptions) *StageImageContainerOptions {
 	mergedCo := newStageContainerOptions()
 	mergedCo.Volume = append(co.Volume, co2.Volume...)
@@ -123,6 +135,18 @@ func (co *StageImageContainerOptions) merge(co2 *StageImageContainerOptions) *St
 		mergedCo.HealthCheck = co2.HealthCheck
 	}
 
+	if co2.Memory == "" {
+		mergedCo.Memory = co.Memory
+	} else {
+		mergedCo.Memory = co2.Memory
+	}
+
+	if co2.Cpus == "" {
+		mergedCo.Cpus = co.Cpus
+	} else {
+		mergedCo.Cpus = co2.Cpus
+	}
+
 	return mergedCo
 }
 
@@ -157,6 +181,14 @@ func (co *StageImageContainerOptions) toRunArgs() ([]string, error) {
 		args = append(args, fmt.Sprintf("--entrypoint=%s", co.Entrypoint))
 	}
 
+	if co.Memory != "" {
+		args = append(args, fmt.Sprintf("--memory=%s", co.Memory))
+	}
+
+	if co.Cpus != "" {
+		args = append(args, fmt.Sprintf("--cpus=%s", co.Cpus))
+	}
+
 	return args, nil
 }
 
@@ -0,0 +1,65 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+type timeoutStageImageRuntime struct {
+	StageImageRuntime
+
+	removedContainers []string
+	rmCtxErr          error
+}
+
+func (runtime *timeoutStageImageRuntime) String() string {
+	return "timeout"
+}
+
+func (runtime *timeoutStageImageRuntime) runStageContainer(ctx context.Context, c *StageImageContainer) error {
+	<-ctx.Done()
+	return fmt.Errorf("container run failed: %s", ctx.Err())
+}
+
+func (runtime *timeoutStageImageRuntime) rmStageContainer(ctx context.Context, c *StageImageContainer) error {
+	runtime.rmCtxErr = ctx.Err()
+	runtime.removedContainers = append(runtime.removedContainers, c.Name())
+	return ctx.Err()
+}
+
+func TestStageImageBuildRemovesContainerOnTimeout(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-stage-image-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := &timeoutStageImageRuntime{}
+	img := NewStageImage(NewStageImage(nil, "from", runtime), "stage", runtime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = img.Build(ctx, BuildOptions{})
+	if err == nil || ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("expected build to fail by timeout, got %v", err)
+	}
+
+	if len(runtime.removedContainers) != 1 || runtime.removedContainers[0] != img.container.Name() {
+		t.Fatalf("expected container %s to be removed, got %v", img.container.Name(), runtime.removedContainers)
+	}
+
+	if runtime.rmCtxErr != nil {
+		t.Fatalf("expected container to be removed with active context, got %s", runtime.rmCtxErr)
+	}
+}
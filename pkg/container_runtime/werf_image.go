@@ -6,8 +6,8 @@ type WerfImage struct {
 	*StageImage
 }
 
-func NewWerfImage(fromImage *StageImage, name string, localDockerServerRuntime *LocalDockerServerRuntime) *WerfImage {
-	return &WerfImage{StageImage: NewStageImage(fromImage, name, localDockerServerRuntime)}
+func NewWerfImage(fromImage *StageImage, name string, containerRuntime StageImageRuntime) *WerfImage {
+	return &WerfImage{StageImage: NewStageImage(fromImage, name, containerRuntime)}
 }
 
 func (i *WerfImage) Tag(ctx context.Context) error {
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"io"
+
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/command/container"
 	"github.com/docker/docker/api/types"
@@ -35,6 +37,14 @@ func ContainerCommit(ctx context.Context, ref string, commitOptions types.Contai
 	return response.ID, nil
 }
 
+func ContainerKill(ctx context.Context, ref, signal string) error {
+	return apiCli(ctx).ContainerKill(ctx, ref, signal)
+}
+
+func ContainerLogs(ctx context.Context, ref string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	return apiCli(ctx).ContainerLogs(ctx, ref, options)
+}
+
 func ContainerRemove(ctx context.Context, ref string, options types.ContainerRemoveOptions) error {
 	return apiCli(ctx).ContainerRemove(ctx, ref, options)
 }
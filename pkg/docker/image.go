@@ -2,6 +2,8 @@ package docker
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"strings"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/docker/cli/cli/command/image"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/werf/logboek"
 	"golang.org/x/net/context"
 )
@@ -60,6 +63,26 @@ func ImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error)
 	return &inspect, nil
 }
 
+// ImageSave returns a tar stream of the specified images in the docker-save format
+func ImageSave(ctx context.Context, refs ...string) (io.ReadCloser, error) {
+	return apiCli(ctx).ImageSave(ctx, refs)
+}
+
+// ImageLoad loads images from the tar stream in the docker-save format
+func ImageLoad(ctx context.Context, input io.Reader) error {
+	response, err := apiCli(ctx).ImageLoad(ctx, input, true)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(response.Body, ioutil.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("image load failed: %s", err)
+	}
+
+	return nil
+}
+
 func doCliPull(c command.Cli, args ...string) error {
 	return prepareCliCmd(image.NewPullCommand(c), args...).Execute()
 }
@@ -77,50 +77,84 @@ func (api *api) GetRepoImageConfigFile(_ context.Context, reference string) (*v1
 	return imageInfo.ConfigFile()
 }
 
-func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info, error) {
+func (api *api) GetRepoImageManifest(_ context.Context, reference string) (*v1.Manifest, error) {
 	imageInfo, _, err := api.image(reference)
 	if err != nil {
 		return nil, err
 	}
 
-	digest, err := imageInfo.Digest()
+	return imageInfo.Manifest()
+}
+
+func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info, error) {
+	repoImage, _, err := api.getRepoImage(reference)
+	return repoImage, err
+}
+
+// GetRepoImageForPlatform returns the image of the platform, the reference can point to the manifest list or to the image itself
+func (api *api) GetRepoImageForPlatform(_ context.Context, reference, platform string) (*image.Info, error) {
+	p, err := ParsePlatform(platform)
 	if err != nil {
 		return nil, err
 	}
 
-	manifest, err := imageInfo.Manifest()
+	repoImage, configFile, err := api.getRepoImage(reference, remote.WithPlatform(p))
 	if err != nil {
 		return nil, err
 	}
 
+	// the platform is not checked by the registry client if the reference points to the image itself rather than to the manifest list
+	if configFile.OS != p.OS || configFile.Architecture != p.Architecture {
+		return nil, fmt.Errorf("image %q is not available for platform %s: image is built for %s/%s", reference, platform, configFile.OS, configFile.Architecture)
+	}
+
+	return repoImage, nil
+}
+
+func (api *api) getRepoImage(reference string, extraOptions ...remote.Option) (*image.Info, *v1.ConfigFile, error) {
+	imageInfo, parsedReference, err := api.image(reference, extraOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digest, err := imageInfo.Digest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest, err := imageInfo.Manifest()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	configFile, err := imageInfo.ConfigFile()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var totalSize int64
 	if layers, err := imageInfo.Layers(); err != nil {
-		return nil, err
+		return nil, nil, err
 	} else {
 		for _, l := range layers {
 			if lSize, err := l.Size(); err != nil {
-				return nil, err
+				return nil, nil, err
 			} else {
 				totalSize += lSize
 			}
 		}
 	}
 
-	parsedReference, err := name.NewTag(reference, api.parseReferenceOptions()...)
-	if err != nil {
-		return nil, err
+	var tag string
+	if parsedTag, ok := parsedReference.(name.Tag); ok {
+		tag = parsedTag.TagStr()
 	}
 
 	repoImage := &image.Info{
 		Name:       reference,
-		Repository: strings.Join([]string{parsedReference.RegistryStr(), parsedReference.RepositoryStr()}, "/"),
+		Repository: strings.Join([]string{parsedReference.Context().RegistryStr(), parsedReference.Context().RepositoryStr()}, "/"),
 		ID:         manifest.Config.Digest.String(),
-		Tag:        parsedReference.TagStr(),
+		Tag:        tag,
 		RepoDigest: digest.String(),
 		ParentID:   configFile.Config.Image,
 		Labels:     configFile.Config.Labels,
@@ -129,7 +163,7 @@ func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info,
 
 	repoImage.SetCreatedAtUnix(configFile.Created.Unix())
 
-	return repoImage, nil
+	return repoImage, configFile, nil
 }
 
 func (api *api) list(reference string) ([]string, error) {
@@ -215,7 +249,7 @@ func (api *api) pushImage(_ context.Context, reference string, opts *PushImageOp
 	return nil
 }
 
-func (api *api) image(reference string) (v1.Image, name.Reference, error) {
+func (api *api) image(reference string, extraOptions ...remote.Option) (v1.Image, name.Reference, error) {
 	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing reference %q: %v", reference, err)
@@ -226,7 +260,7 @@ func (api *api) image(reference string) (v1.Image, name.Reference, error) {
 	// FIXME: Needed for the insecure https registry to work.
 	oldDefaultTransport := http.DefaultTransport
 	http.DefaultTransport = api.getHttpTransport()
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	img, err := remote.Image(ref, append([]remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain)}, extraOptions...)...)
 	http.DefaultTransport = oldDefaultTransport
 
 	if err != nil {
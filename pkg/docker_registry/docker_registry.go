@@ -26,6 +26,8 @@ type DockerRegistry interface {
 	IsRepoImageExists(ctx context.Context, reference string) (bool, error)
 	DeleteRepoImage(ctx context.Context, repoImage *image.Info) error
 	PushImage(ctx context.Context, reference string, opts *PushImageOptions) error
+	PushManifestList(ctx context.Context, reference string, images []ManifestListImage) error
+	GetRepoManifestList(ctx context.Context, reference string) (*ManifestListInfo, error)
 
 	ResolveRepoMode(ctx context.Context, registryOrRepositoryAddress, repoMode string) (string, error)
 	String() string
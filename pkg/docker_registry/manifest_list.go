@@ -0,0 +1,133 @@
+package docker_registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// ManifestListImage is the platform image referred by the manifest list
+type ManifestListImage struct {
+	Reference string
+	Platform  string
+}
+
+// ManifestListInfo is the manifest list stored in the repo
+type ManifestListInfo struct {
+	Info *image.Info
+	// ImagesRepoDigests are repo digests of the platform images referred by the manifest list
+	ImagesRepoDigests []string
+}
+
+// ParsePlatform parses the platform in the OS/ARCH[/VARIANT] format
+func ParsePlatform(platform string) (v1.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v1.Platform{}, fmt.Errorf("invalid platform %q: OS/ARCH[/VARIANT] expected", platform)
+	}
+
+	p := v1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	return p, nil
+}
+
+// PushManifestList publishes the manifest list which refers to the platform images.
+// Platform images should exist in the same repository.
+func (api *api) PushManifestList(_ context.Context, reference string, images []ManifestListImage) error {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	var adds []mutate.IndexAddendum
+	for _, img := range images {
+		platform, err := ParsePlatform(img.Platform)
+		if err != nil {
+			return err
+		}
+
+		platformImg, _, err := api.image(img.Reference)
+		if err != nil {
+			return err
+		}
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        platformImg,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	index := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.DockerManifestList), adds...)
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	err = remote.WriteIndex(ref, index, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return fmt.Errorf("write manifest list to the remote %s have failed: %s", ref.String(), err)
+	}
+
+	return nil
+}
+
+// GetRepoManifestList returns the manifest list and repo digests of the platform images it refers to
+func (api *api) GetRepoManifestList(_ context.Context, reference string) (*ManifestListInfo, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	index, err := remote.Index(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest list %q: %v", ref, err)
+	}
+
+	digest, err := index.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var tag string
+	if parsedTag, ok := ref.(name.Tag); ok {
+		tag = parsedTag.TagStr()
+	}
+
+	manifestList := &ManifestListInfo{
+		Info: &image.Info{
+			Name:       reference,
+			Repository: strings.Join([]string{ref.Context().RegistryStr(), ref.Context().RepositoryStr()}, "/"),
+			Tag:        tag,
+			RepoDigest: digest.String(),
+		},
+	}
+
+	for _, desc := range indexManifest.Manifests {
+		manifestList.ImagesRepoDigests = append(manifestList.ImagesRepoDigests, desc.Digest.String())
+	}
+
+	return manifestList, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/ini.v1"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 
@@ -296,6 +299,58 @@ func (repo *Remote) IsCommitExists(ctx context.Context, commit string) (bool, er
 	return repo.isCommitExists(ctx, repo.GetClonePath(), repo.GetClonePath(), commit)
 }
 
+// ReadCommitFiles returns contents of the files from the dir of the commit tree, which names match the pattern.
+// Result keys are file paths relative to the dir.
+func (repo *Remote) ReadCommitFiles(_ context.Context, commit, dir, pattern string) (map[string][]byte, error) {
+	rawRepo, err := git.PlainOpenWithOptions(repo.GetClonePath(), &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo: %s", err)
+	}
+
+	commitHash, err := newHash(commit)
+	if err != nil {
+		return nil, fmt.Errorf("bad commit hash `%s`: %s", commit, err)
+	}
+
+	commitObj, err := rawRepo.CommitObject(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("bad commit `%s` of repo %s: %s", commit, repo.String(), err)
+	}
+
+	tree, err := commitObj.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit `%s` tree: %s", commit, err)
+	}
+
+	if dir := strings.Trim(path.Clean(dir), "/"); dir != "" && dir != "." {
+		if tree, err = tree.Tree(dir); err != nil {
+			return nil, fmt.Errorf("cannot get dir `%s` of commit `%s` tree: %s", dir, commit, err)
+		}
+	}
+
+	res := map[string][]byte{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if matched, err := path.Match(pattern, path.Base(f.Name)); err != nil {
+			return err
+		} else if !matched {
+			return nil
+		}
+
+		content, err := f.Contents()
+		if err != nil {
+			return fmt.Errorf("cannot read file `%s`: %s", f.Name, err)
+		}
+		res[f.Name] = []byte(content)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (repo *Remote) getWorkTreeCacheDir() string {
 	return filepath.Join(GetWorkTreeCacheDir(), repo.getFilesystemRelativePathByEndpoint())
 }
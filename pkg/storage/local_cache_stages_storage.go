@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	LocalCacheStagesStorageCacheVersion = "1"
+
+	localCacheStagesStorageArchiveExt  = ".tar"
+	localCacheStagesStorageMetadataExt = ".json"
+)
+
+func GetLocalCacheStagesStorageDefaultDir() string {
+	return filepath.Join(werf.GetLocalCacheDir(), "stages", LocalCacheStagesStorageCacheVersion)
+}
+
+type LocalCacheStagesStorageOptions struct {
+	// Dir is the cache directory, GetLocalCacheStagesStorageDefaultDir() is used by default
+	Dir string
+	// MaxSize is the maximum total size of the cached archives in bytes, least recently used archives are evicted when exceeded
+	MaxSize int64
+}
+
+// LocalCacheStagesStorage wraps a remote stages storage with a bounded local directory of saved stages images.
+// Fetched and stored stages are written into the cache, so repeated fetches load images from the cache instead of pulling.
+// The cache is kept in the werf local cache dir and does not depend on the docker daemon state.
+type LocalCacheStagesStorage struct {
+	StagesStorage
+
+	ContainerRuntime container_runtime.ContainerRuntime
+	Dir              string
+	MaxSize          int64
+}
+
+type localCacheStagesStorageRecord struct {
+	ImageName    string `json:"imageName"`
+	Sha256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	LastAccessAt int64  `json:"lastAccessAt"`
+
+	key string
+}
+
+func NewLocalCacheStagesStorage(stagesStorage StagesStorage, containerRuntime container_runtime.ContainerRuntime, options LocalCacheStagesStorageOptions) *LocalCacheStagesStorage {
+	dir := options.Dir
+	if dir == "" {
+		dir = GetLocalCacheStagesStorageDefaultDir()
+	}
+
+	return &LocalCacheStagesStorage{
+		StagesStorage:    stagesStorage,
+		ContainerRuntime: containerRuntime,
+		Dir:              dir,
+		MaxSize:          options.MaxSize,
+	}
+}
+
+func (storage *LocalCacheStagesStorage) String() string {
+	return storage.StagesStorage.String()
+}
+
+func (storage *LocalCacheStagesStorage) FetchImage(ctx context.Context, img container_runtime.Image) error {
+	switch containerRuntime := storage.ContainerRuntime.(type) {
+	case *container_runtime.LocalDockerServerRuntime:
+		imageName := img.(*container_runtime.DockerImage).Image.Name()
+
+		if loaded, err := storage.loadImageFromCache(ctx, imageName); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to load image %s from the local stages cache %s: %s\n", imageName, storage.Dir, err)
+		} else if loaded {
+			return containerRuntime.RefreshImageObject(ctx, img)
+		}
+
+		if err := storage.StagesStorage.FetchImage(ctx, img); err != nil {
+			return err
+		}
+
+		storage.saveImageToCache(ctx, imageName)
+
+		return nil
+	default:
+		return fmt.Errorf("local stages cache supports only docker server container runtime, got %s", containerRuntime.String())
+	}
+}
+
+func (storage *LocalCacheStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image) error {
+	if err := storage.StagesStorage.StoreImage(ctx, img); err != nil {
+		return err
+	}
+
+	storage.saveImageToCache(ctx, img.(*container_runtime.DockerImage).Image.Name())
+
+	return nil
+}
+
+func (storage *LocalCacheStagesStorage) DeleteStage(ctx context.Context, stageDescription *image.StageDescription, options DeleteImageOptions) error {
+	if err := storage.StagesStorage.DeleteStage(ctx, stageDescription, options); err != nil {
+		return err
+	}
+
+	return storage.withLock(ctx, func() error {
+		return storage.removeRecord(storage.recordKey(stageDescription.Info.Name))
+	})
+}
+
+func (storage *LocalCacheStagesStorage) recordKey(imageName string) string {
+	return util.Sha256Hash(imageName)
+}
+
+func (storage *LocalCacheStagesStorage) archivePath(key string) string {
+	return filepath.Join(storage.Dir, key+localCacheStagesStorageArchiveExt)
+}
+
+func (storage *LocalCacheStagesStorage) metadataPath(key string) string {
+	return filepath.Join(storage.Dir, key+localCacheStagesStorageMetadataExt)
+}
+
+func (storage *LocalCacheStagesStorage) withLock(ctx context.Context, f func() error) error {
+	return werf.WithHostLock(ctx, fmt.Sprintf("local-cache-stages-storage-%s", util.Sha256Hash(storage.Dir)), lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
+}
+
+// loadImageFromCache loads the image into the docker server if the image archive is cached and the archive checksum matches the record
+func (storage *LocalCacheStagesStorage) loadImageFromCache(ctx context.Context, imageName string) (bool, error) {
+	key := storage.recordKey(imageName)
+
+	var record *localCacheStagesStorageRecord
+	if err := storage.withLock(ctx, func() error {
+		var err error
+		if record, err = storage.readRecord(key); err != nil || record == nil {
+			return err
+		}
+
+		record.LastAccessAt = time.Now().UnixNano()
+		return storage.writeRecord(record)
+	}); err != nil {
+		return false, err
+	} else if record == nil {
+		return false, nil
+	}
+
+	f, err := os.Open(storage.archivePath(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if checksum, err := fileSha256(f); err != nil {
+		return false, err
+	} else if checksum != record.Sha256 {
+		logboek.Context(ctx).Warn().LogF("WARNING: Image %s archive in the local stages cache is corrupted: expected sha256 %s, got %s\n", imageName, record.Sha256, checksum)
+		return false, storage.withLock(ctx, func() error {
+			return storage.removeRecord(key)
+		})
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess("Loading %s from the local stages cache", imageName).DoError(func() error {
+		return docker.ImageLoad(ctx, f)
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// saveImageToCache writes the image archive into the cache and evicts least recently used archives, errors are not fatal for the cache
+func (storage *LocalCacheStagesStorage) saveImageToCache(ctx context.Context, imageName string) {
+	if err := storage.doSaveImageToCache(ctx, imageName); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Unable to save image %s into the local stages cache %s: %s\n", imageName, storage.Dir, err)
+	}
+}
+
+func (storage *LocalCacheStagesStorage) doSaveImageToCache(ctx context.Context, imageName string) error {
+	key := storage.recordKey(imageName)
+
+	if err := os.MkdirAll(storage.Dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(storage.Dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	archive, err := docker.ImageSave(ctx, imageName)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), archive)
+	if err != nil {
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if storage.MaxSize > 0 && size > storage.MaxSize {
+		return fmt.Errorf("image archive size %d exceeds the cache size limit %d", size, storage.MaxSize)
+	}
+
+	return storage.withLock(ctx, func() error {
+		if err := os.Rename(tmpFile.Name(), storage.archivePath(key)); err != nil {
+			return err
+		}
+
+		if err := storage.writeRecord(&localCacheStagesStorageRecord{
+			ImageName:    imageName,
+			Sha256:       fmt.Sprintf("%x", hash.Sum(nil)),
+			Size:         size,
+			LastAccessAt: time.Now().UnixNano(),
+			key:          key,
+		}); err != nil {
+			return err
+		}
+
+		return storage.evict(ctx)
+	})
+}
+
+// evict removes least recently used archives until total size of the cache fits the size limit
+func (storage *LocalCacheStagesStorage) evict(ctx context.Context) error {
+	if storage.MaxSize <= 0 {
+		return nil
+	}
+
+	records, err := storage.readRecords()
+	if err != nil {
+		return err
+	}
+
+	var totalSize int64
+	for _, record := range records {
+		totalSize += record.Size
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastAccessAt < records[j].LastAccessAt
+	})
+
+	for _, record := range records {
+		if totalSize <= storage.MaxSize {
+			break
+		}
+
+		logboek.Context(ctx).Info().LogF("Evicting %s from the local stages cache\n", record.ImageName)
+		if err := storage.removeRecord(record.key); err != nil {
+			return err
+		}
+		totalSize -= record.Size
+	}
+
+	return nil
+}
+
+func (storage *LocalCacheStagesStorage) readRecords() ([]*localCacheStagesStorageRecord, error) {
+	files, err := ioutil.ReadDir(storage.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var records []*localCacheStagesStorageRecord
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), localCacheStagesStorageMetadataExt) {
+			continue
+		}
+
+		record, err := storage.readRecord(strings.TrimSuffix(file.Name(), localCacheStagesStorageMetadataExt))
+		if err != nil {
+			return nil, err
+		} else if record != nil {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func (storage *LocalCacheStagesStorage) readRecord(key string) (*localCacheStagesStorageRecord, error) {
+	data, err := ioutil.ReadFile(storage.metadataPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	record := &localCacheStagesStorageRecord{key: key}
+	if err := json.Unmarshal(data, record); err != nil {
+		// broken record will be replaced on the next save
+		return nil, storage.removeRecord(key)
+	}
+
+	return record, nil
+}
+
+func (storage *LocalCacheStagesStorage) writeRecord(record *localCacheStagesStorageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := storage.metadataPath(record.key) + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, storage.metadataPath(record.key))
+}
+
+func (storage *LocalCacheStagesStorage) removeRecord(key string) error {
+	for _, path := range []string{storage.metadataPath(key), storage.archivePath(key)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fileSha256(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
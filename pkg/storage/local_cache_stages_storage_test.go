@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/werf/pkg/container_runtime"
+)
+
+func TestLocalCacheStagesStorageEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "werf-local-cache-stages-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storage := &LocalCacheStagesStorage{Dir: dir, MaxSize: 25}
+
+	for i, imageName := range []string{"image-a", "image-b", "image-c"} {
+		key := storage.recordKey(imageName)
+		if err := ioutil.WriteFile(storage.archivePath(key), make([]byte, 10), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.writeRecord(&localCacheStagesStorageRecord{ImageName: imageName, Size: 10, LastAccessAt: int64(i), key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// broken record should be removed on read
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken"+localCacheStagesStorageMetadataExt), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.evict(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := storage.readRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	for _, record := range records {
+		if record.ImageName == "image-a" {
+			t.Errorf("least recently used image-a should be evicted")
+		}
+	}
+
+	if _, err := os.Stat(storage.archivePath(storage.recordKey("image-a"))); !os.IsNotExist(err) {
+		t.Errorf("image-a archive should be removed, got: %v", err)
+	}
+}
+
+type unsupportedContainerRuntime struct {
+	container_runtime.ContainerRuntime
+}
+
+func (runtime *unsupportedContainerRuntime) String() string { return "unsupported" }
+
+func TestLocalCacheStagesStorageFetchImageUnsupportedContainerRuntime(t *testing.T) {
+	storage := &LocalCacheStagesStorage{ContainerRuntime: &unsupportedContainerRuntime{}}
+
+	if err := storage.FetchImage(context.Background(), nil); err == nil {
+		t.Fatal("expected unsupported container runtime error")
+	}
+}
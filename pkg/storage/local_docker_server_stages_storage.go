@@ -318,6 +318,22 @@ func (storage *LocalDockerServerStagesStorage) Address() string {
 	return LocalStorageAddress
 }
 
+func (storage *LocalDockerServerStagesStorage) ConstructManifestListImageName(_ string, _ []*ManifestListPlatformImage) (string, error) {
+	return "", fmt.Errorf("manifest lists are not supported by %s stages storage: docker registry should be specified with --repo", storage.String())
+}
+
+func (storage *LocalDockerServerStagesStorage) StoreManifestList(_ context.Context, _ string, _ []*ManifestListPlatformImage) error {
+	return fmt.Errorf("manifest lists are not supported by %s stages storage: docker registry should be specified with --repo", storage.String())
+}
+
+func (storage *LocalDockerServerStagesStorage) GetManifestLists(_ context.Context, _ string) ([]*ManifestListDescription, error) {
+	return nil, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) DeleteManifestList(_ context.Context, _ string, _ *ManifestListDescription) error {
+	return fmt.Errorf("manifest lists are not supported by %s stages storage", storage.String())
+}
+
 func (storage *LocalDockerServerStagesStorage) GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error) {
 	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetClientID for project %s\n", projectName)
 
@@ -378,6 +394,24 @@ func (storage *LocalDockerServerStagesStorage) PostClientIDRecord(ctx context.Co
 	return nil
 }
 
+func (storage *LocalDockerServerStagesStorage) RmClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.RmClientIDRecord %s for project %s\n", rec.ClientID, projectName)
+
+	fullImageName := fmt.Sprintf(LocalClientIDRecord_ImageFormat, projectName, rec.ClientID, rec.TimestampMillisec)
+
+	if exsts, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to check existence of image %q: %s", fullImageName, err)
+	} else if !exsts {
+		return nil
+	}
+
+	if err := docker.CliRmi(ctx, "--force", fullImageName); err != nil {
+		return fmt.Errorf("unable to remove image %q: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
 type processRelatedContainersOptions struct {
 	skipUsedImages           bool
 	rmContainersThatUseImage bool
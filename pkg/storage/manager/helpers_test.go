@@ -0,0 +1,12 @@
+package manager
+
+import (
+	"github.com/werf/werf/pkg/image"
+)
+
+func newTestStageDescription(digest string, uniqueID int64, imageID, parentID string, labels map[string]string) *image.StageDescription {
+	return &image.StageDescription{
+		StageID: &image.StageID{Digest: digest, UniqueID: uniqueID},
+		Info:    &image.Info{ID: imageID, ParentID: parentID, Labels: labels},
+	}
+}
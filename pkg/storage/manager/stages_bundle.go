@@ -0,0 +1,471 @@
+package manager
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+const (
+	StagesBundleMetadataMediaType = "application/vnd.werf.stages-bundle.metadata.v1+json"
+
+	StagesBundleProjectAnnotation       = "io.werf.project"
+	StagesBundleStageDigestAnnotation   = "io.werf.stage.digest"
+	StagesBundleStageUniqueIDAnnotation = "io.werf.stage.unique-id"
+	OCIImageRefNameAnnotation           = "org.opencontainers.image.ref.name"
+)
+
+// StagesBundleMetadata is stored in the stages bundle as a separate blob referenced by the OCI layout index
+type StagesBundleMetadata struct {
+	ProjectName    string                         `json:"projectName"`
+	ManagedImages  []string                       `json:"managedImages"`
+	ImagesMetadata map[string]map[string][]string `json:"imagesMetadata"`
+}
+
+// ExportStagesBundle writes all project stages, managed images and images metadata from the stages storage into the tar archive of the OCI image layout
+func ExportStagesBundle(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime, tmpDir, outputPath string) error {
+	localDockerServerRuntime, err := getStagesBundleContainerRuntime(containerRuntime)
+	if err != nil {
+		return err
+	}
+
+	layoutDir := filepath.Join(tmpDir, "stages-bundle")
+	layoutPath, err := layout.Write(layoutDir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("unable to create OCI image layout in %s: %s", layoutDir, err)
+	}
+
+	stages, err := stagesStorage.GetStagesIDs(ctx, projectName)
+	if err != nil {
+		return fmt.Errorf("unable to get stages from %s: %s", stagesStorage.String(), err)
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Exporting %d stages from %s", len(stages), stagesStorage.String()).DoError(func() error {
+		for i, stageID := range stages {
+			if err := exportStage(ctx, projectName, stageID, stagesStorage, localDockerServerRuntime, tmpDir, layoutPath); err != nil {
+				return err
+			}
+			logboek.Context(ctx).Default().LogF("%5d/%d exported %s\n", i+1, len(stages), stageID.String())
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	imagesMetadata, err := getSourceImageMetadataByImageName(ctx, projectName, stagesStorage, nil)
+	if err != nil {
+		return err
+	}
+
+	managedImages, err := stagesStorage.GetManagedImages(ctx, projectName)
+	if err != nil {
+		return fmt.Errorf("unable to get managed images from %s: %s", stagesStorage.String(), err)
+	}
+
+	if err := writeStagesBundleMetadata(layoutPath, &StagesBundleMetadata{
+		ProjectName:    projectName,
+		ManagedImages:  managedImages,
+		ImagesMetadata: imagesMetadata,
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Writing stages bundle %s", outputPath).DoError(func() error {
+		return writeDirToTarFile(layoutDir, outputPath)
+	})
+}
+
+func exportStage(ctx context.Context, projectName string, stageID image.StageID, stagesStorage storage.StagesStorage, localDockerServerRuntime *container_runtime.LocalDockerServerRuntime, tmpDir string, layoutPath layout.Path) error {
+	stageDesc, err := stagesStorage.GetStageDescription(ctx, projectName, stageID.Digest, stageID.UniqueID)
+	if err != nil {
+		return fmt.Errorf("error getting stage %s description from %s: %s", stageID.String(), stagesStorage.String(), err)
+	} else if stageDesc == nil {
+		logboek.Context(ctx).Warn().LogF("Ignoring stage %s: cannot get stage description from %s\n", stageID.String(), stagesStorage.String())
+		return nil
+	}
+
+	img := &container_runtime.DockerImage{Image: container_runtime.NewStageImage(nil, stageDesc.Info.Name, localDockerServerRuntime)}
+
+	logboek.Context(ctx).Info().LogF("Fetching %s\n", stageDesc.Info.Name)
+	if err := stagesStorage.FetchImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to fetch %s from %s: %s", stageDesc.Info.Name, stagesStorage.String(), err)
+	}
+
+	archivePath := filepath.Join(tmpDir, fmt.Sprintf("stage-%s.tar", stageID.String()))
+	defer os.Remove(archivePath)
+
+	if err := saveImageToFile(ctx, stageDesc.Info.Name, archivePath); err != nil {
+		return err
+	}
+
+	tag, err := name.NewTag(stageDesc.Info.Name)
+	if err != nil {
+		return fmt.Errorf("bad stage image name %s: %s", stageDesc.Info.Name, err)
+	}
+
+	stageImage, err := tarball.ImageFromPath(archivePath, &tag)
+	if err != nil {
+		return fmt.Errorf("unable to read image %s archive: %s", stageDesc.Info.Name, err)
+	}
+
+	if err := layoutPath.AppendImage(stageImage, layout.WithAnnotations(map[string]string{
+		OCIImageRefNameAnnotation:           stageDesc.Info.Name,
+		StagesBundleProjectAnnotation:       projectName,
+		StagesBundleStageDigestAnnotation:   stageID.Digest,
+		StagesBundleStageUniqueIDAnnotation: fmt.Sprintf("%d", stageID.UniqueID),
+	})); err != nil {
+		return fmt.Errorf("unable to append image %s into OCI image layout: %s", stageDesc.Info.Name, err)
+	}
+
+	if stagesStorage.Address() != storage.LocalStorageAddress {
+		if err := localDockerServerRuntime.RemoveImage(ctx, img); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportStagesBundle loads stages, managed images and images metadata from the stages bundle into the stages storage,
+// stages which already exist in the stages storage are skipped
+func ImportStagesBundle(ctx context.Context, projectName string, bundlePath string, stagesStorage storage.StagesStorage, storageLockManager storage.LockManager, containerRuntime container_runtime.ContainerRuntime, tmpDir string) error {
+	localDockerServerRuntime, err := getStagesBundleContainerRuntime(containerRuntime)
+	if err != nil {
+		return err
+	}
+
+	layoutDir := filepath.Join(tmpDir, "stages-bundle")
+	if err := logboek.Context(ctx).Default().LogProcess("Reading stages bundle %s", bundlePath).DoError(func() error {
+		return extractTarFileToDir(bundlePath, layoutDir)
+	}); err != nil {
+		return err
+	}
+
+	layoutPath, err := layout.FromPath(layoutDir)
+	if err != nil {
+		return fmt.Errorf("bad stages bundle %s: unable to read OCI image layout: %s", bundlePath, err)
+	}
+
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("bad stages bundle %s: unable to read OCI image layout index: %s", bundlePath, err)
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("bad stages bundle %s: unable to read OCI image layout index: %s", bundlePath, err)
+	}
+
+	var metadata *StagesBundleMetadata
+	var stagesDescriptors []v1.Descriptor
+	for _, desc := range indexManifest.Manifests {
+		if desc.MediaType == StagesBundleMetadataMediaType {
+			if metadata, err = readStagesBundleMetadata(layoutPath, desc); err != nil {
+				return fmt.Errorf("bad stages bundle %s: %s", bundlePath, err)
+			}
+		} else if desc.Annotations[StagesBundleStageDigestAnnotation] != "" {
+			stagesDescriptors = append(stagesDescriptors, desc)
+		}
+	}
+
+	if metadata == nil {
+		return fmt.Errorf("bad stages bundle %s: werf metadata not found", bundlePath)
+	}
+
+	if metadata.ProjectName != projectName {
+		return fmt.Errorf("stages bundle %s contains stages of the project %q, expected project %q", bundlePath, metadata.ProjectName, projectName)
+	}
+
+	if lock, err := storageLockManager.LockStagesAndImages(ctx, projectName, storage.LockStagesAndImagesOptions{GetOrCreateImagesOnly: true}); err != nil {
+		return fmt.Errorf("unable to lock stages and images of project %q: %s", projectName, err)
+	} else {
+		defer storageLockManager.Unlock(ctx, lock)
+	}
+
+	existingStages, err := stagesStorage.GetStagesIDs(ctx, projectName)
+	if err != nil {
+		return fmt.Errorf("unable to get stages from %s: %s", stagesStorage.String(), err)
+	}
+
+	destinationStages := make(map[string]bool)
+	for _, stageID := range existingStages {
+		destinationStages[stageID.String()] = true
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Importing %d stages into %s", len(stagesDescriptors), stagesStorage.String()).DoError(func() error {
+		for i, desc := range stagesDescriptors {
+			stageID, err := getStagesBundleStageID(desc)
+			if err != nil {
+				return fmt.Errorf("bad stages bundle %s: %s", bundlePath, err)
+			}
+
+			if destinationStages[stageID.String()] {
+				logboek.Context(ctx).Default().LogF("%5d/%d skipped %s: already exists\n", i+1, len(stagesDescriptors), stageID.String())
+				continue
+			}
+
+			if err := importStage(ctx, projectName, stageID, desc, layoutPath, stagesStorage, localDockerServerRuntime); err != nil {
+				return err
+			}
+			destinationStages[stageID.String()] = true
+
+			logboek.Context(ctx).Default().LogF("%5d/%d imported %s\n", i+1, len(stagesDescriptors), stageID.String())
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, imageName := range metadata.ManagedImages {
+		if _, hasKey := metadata.ImagesMetadata[imageName]; !hasKey {
+			metadata.ImagesMetadata[imageName] = map[string][]string{}
+		}
+	}
+
+	return syncImagesMetadata(ctx, projectName, stagesStorage, metadata.ImagesMetadata, destinationStages, false)
+}
+
+func importStage(ctx context.Context, projectName string, stageID image.StageID, desc v1.Descriptor, layoutPath layout.Path, stagesStorage storage.StagesStorage, localDockerServerRuntime *container_runtime.LocalDockerServerRuntime) error {
+	stageImage, err := layoutPath.Image(desc.Digest)
+	if err != nil {
+		return fmt.Errorf("unable to read stage %s image from stages bundle: %s", stageID.String(), err)
+	}
+
+	imageName := stagesStorage.ConstructStageImageName(projectName, stageID.Digest, stageID.UniqueID)
+	tag, err := name.NewTag(imageName)
+	if err != nil {
+		return fmt.Errorf("bad stage image name %s: %s", imageName, err)
+	}
+
+	logboek.Context(ctx).Info().LogF("Loading %s\n", imageName)
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(tarball.Write(tag, stageImage, writer))
+	}()
+
+	if err := docker.ImageLoad(ctx, reader); err != nil {
+		reader.CloseWithError(err)
+		return fmt.Errorf("unable to load image %s: %s", imageName, err)
+	}
+
+	if stagesStorage.Address() == storage.LocalStorageAddress {
+		return nil
+	}
+
+	img := &container_runtime.DockerImage{Image: container_runtime.NewStageImage(nil, imageName, localDockerServerRuntime)}
+
+	logboek.Context(ctx).Info().LogF("Storing %s\n", imageName)
+	if err := stagesStorage.StoreImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to store %s to %s: %s", imageName, stagesStorage.String(), err)
+	}
+
+	return localDockerServerRuntime.RemoveImage(ctx, img)
+}
+
+// getStagesBundleContainerRuntime returns the docker server runtime, stages are saved to and loaded from the bundle through the docker server
+func getStagesBundleContainerRuntime(containerRuntime container_runtime.ContainerRuntime) (*container_runtime.LocalDockerServerRuntime, error) {
+	localDockerServerRuntime, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime)
+	if !ok {
+		return nil, fmt.Errorf("stages bundle requires docker server container runtime, got %s", containerRuntime.String())
+	}
+
+	return localDockerServerRuntime, nil
+}
+
+func getStagesBundleStageID(desc v1.Descriptor) (image.StageID, error) {
+	uniqueID, err := strconv.ParseInt(desc.Annotations[StagesBundleStageUniqueIDAnnotation], 10, 64)
+	if err != nil {
+		return image.StageID{}, fmt.Errorf("bad %s annotation of %s: %s", StagesBundleStageUniqueIDAnnotation, desc.Digest, err)
+	}
+	return image.StageID{Digest: desc.Annotations[StagesBundleStageDigestAnnotation], UniqueID: uniqueID}, nil
+}
+
+func writeStagesBundleMetadata(layoutPath layout.Path, metadata *StagesBundleMetadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("unable to marshal stages bundle metadata: %s", err)
+	}
+
+	hash, size, err := v1.SHA256(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if err := layoutPath.WriteBlob(hash, ioutil.NopCloser(bytes.NewReader(data))); err != nil {
+		return fmt.Errorf("unable to write stages bundle metadata: %s", err)
+	}
+
+	return layoutPath.AppendDescriptor(v1.Descriptor{
+		MediaType:   StagesBundleMetadataMediaType,
+		Size:        size,
+		Digest:      hash,
+		Annotations: map[string]string{StagesBundleProjectAnnotation: metadata.ProjectName},
+	})
+}
+
+func readStagesBundleMetadata(layoutPath layout.Path, desc v1.Descriptor) (*StagesBundleMetadata, error) {
+	data, err := layoutPath.Bytes(desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read werf metadata: %s", err)
+	}
+
+	var metadata *StagesBundleMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal werf metadata: %s", err)
+	}
+
+	if metadata.ImagesMetadata == nil {
+		metadata.ImagesMetadata = map[string]map[string][]string{}
+	}
+
+	return metadata, nil
+}
+
+func saveImageToFile(ctx context.Context, imageName, path string) error {
+	archive, err := docker.ImageSave(ctx, imageName)
+	if err != nil {
+		return fmt.Errorf("unable to save image %s: %s", imageName, err)
+	}
+	defer archive.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %s", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, archive); err != nil {
+		return fmt.Errorf("unable to save image %s into %s: %s", imageName, path, err)
+	}
+
+	return nil
+}
+
+func writeDirToTarFile(dir, tarPath string) error {
+	f, err := os.Create(tarPath)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %s", tarPath, err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if _, err := io.Copy(tw, file); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to write %s into %s: %s", dir, tarPath, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write %s: %s", tarPath, err)
+	}
+
+	return nil
+}
+
+func extractTarFileToDir(tarPath, dir string) error {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", tarPath, err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("unable to read %s: %s", tarPath, err)
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("bad path %q in %s", header.Name, tarPath)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				return err
+			}
+
+			if err := writeFileFromReader(path, tr); err != nil {
+				return fmt.Errorf("unable to extract %s from %s: %s", header.Name, tarPath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeFileFromReader(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
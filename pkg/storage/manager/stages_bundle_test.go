@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+func TestStagesBundleMetadataArchive(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-stages-bundle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	layoutPath, err := layout.Write(filepath.Join(tmpDir, "layout"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := &StagesBundleMetadata{
+		ProjectName:    "project",
+		ManagedImages:  []string{"backend"},
+		ImagesMetadata: map[string]map[string][]string{"backend": {"digest-1": {"commit-1", "commit-2"}}},
+	}
+	if err := writeStagesBundleMetadata(layoutPath, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	bundlePath := filepath.Join(tmpDir, "bundle.tar")
+	if err := writeDirToTarFile(filepath.Join(tmpDir, "layout"), bundlePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractTarFileToDir(bundlePath, filepath.Join(tmpDir, "extracted")); err != nil {
+		t.Fatal(err)
+	}
+
+	extractedLayoutPath, err := layout.FromPath(filepath.Join(tmpDir, "extracted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := extractedLayoutPath.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexManifest.Manifests) != 1 || indexManifest.Manifests[0].MediaType != StagesBundleMetadataMediaType {
+		t.Fatalf("unexpected index manifests: %+v", indexManifest.Manifests)
+	}
+
+	readMetadata, err := readStagesBundleMetadata(extractedLayoutPath, indexManifest.Manifests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metadata, readMetadata) {
+		t.Fatalf("expected %+v, got %+v", metadata, readMetadata)
+	}
+}
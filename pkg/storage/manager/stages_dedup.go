@@ -0,0 +1,399 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+type StageLayer struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// DuplicateStagesGroup is a group of stages with the same content: stages with equal content digest
+// or stages which add identical layers. The oldest stage of the group is canonical, other stages are duplicates.
+type DuplicateStagesGroup struct {
+	CanonicalStage  string   `json:"canonicalStage"`
+	DuplicateStages []string `json:"duplicateStages"`
+	IdenticalLayers bool     `json:"identicalLayers"`
+	WastedSize      int64    `json:"wastedSize"`
+}
+
+// StagesDedupReport describes layers of all project stages: ReferencedSize counts each layer of each stage,
+// StoredSize counts each layer blob once as the registry does, WastedSize counts layer blobs of duplicate stages
+// which are not used by the canonical stages
+type StagesDedupReport struct {
+	StagesCount    int                     `json:"stagesCount"`
+	LayersCount    int                     `json:"layersCount"`
+	ReferencedSize int64                   `json:"referencedSize"`
+	StoredSize     int64                   `json:"storedSize"`
+	WastedSize     int64                   `json:"wastedSize"`
+	Groups         []*DuplicateStagesGroup `json:"groups"`
+}
+
+type MergeDuplicateStagesOptions struct {
+	DryRun             bool
+	MaxNumberOfWorkers int
+}
+
+type stagesDedup struct {
+	*stagesIndex
+	LayersByStageID map[string][]StageLayer
+	StageByStageID  map[string]*image.StageDescription
+}
+
+func newStagesDedup(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, maxNumberOfWorkers int) (*stagesDedup, error) {
+	if address := stagesStorage.Address(); address == storage.LocalStorageAddress || storage.IsS3StorageAddress(address) {
+		return nil, fmt.Errorf("stages layers comparison is supported only for the docker registry stages storage, got %s", stagesStorage.String())
+	}
+
+	index, err := newStagesIndex(ctx, projectName, stagesStorage, maxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	dedup := &stagesDedup{
+		stagesIndex:     index,
+		LayersByStageID: make(map[string][]StageLayer),
+		StageByStageID:  make(map[string]*image.StageDescription),
+	}
+
+	var mutex sync.Mutex
+	if err := parallel.DoTasks(ctx, len(index.Stages), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: maxNumberOfWorkers,
+	}, func(ctx context.Context, taskId int) error {
+		stageDesc := index.Stages[taskId]
+
+		manifest, err := docker_registry.API().GetRepoImageManifest(ctx, stageDesc.Info.Name)
+		if err != nil {
+			return fmt.Errorf("unable to get image %s manifest: %s", stageDesc.Info.Name, err)
+		}
+
+		var layers []StageLayer
+		for _, layer := range manifest.Layers {
+			layers = append(layers, StageLayer{Digest: layer.Digest.String(), Size: layer.Size})
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		dedup.LayersByStageID[stageDesc.StageID.String()] = layers
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, stageDesc := range index.Stages {
+		dedup.StageByStageID[stageDesc.StageID.String()] = stageDesc
+	}
+
+	return dedup, nil
+}
+
+func (dedup *stagesDedup) layersKey(stageID string) string {
+	var digests []string
+	for _, layer := range dedup.LayersByStageID[stageID] {
+		digests = append(digests, layer.Digest)
+	}
+	return strings.Join(digests, ",")
+}
+
+// hasOwnLayers checks whether the stage adds layers to the parent stage,
+// stages without own layers (for example stage with docker instructions) have the same layers as the parent stage
+func (dedup *stagesDedup) hasOwnLayers(stageDesc *image.StageDescription) bool {
+	layers := dedup.LayersByStageID[stageDesc.StageID.String()]
+	if parentDesc := dedup.StageByImageID[stageDesc.Info.ParentID]; parentDesc != nil {
+		return len(layers) > len(dedup.LayersByStageID[parentDesc.StageID.String()])
+	}
+	return len(layers) > 0
+}
+
+// groups joins stages with equal content digest and stages with own layers and identical layers digests
+func (dedup *stagesDedup) groups() []*DuplicateStagesGroup {
+	parent := make(map[string]string)
+	var find func(stageID string) string
+	find = func(stageID string) string {
+		if parent[stageID] == "" || parent[stageID] == stageID {
+			return stageID
+		}
+		parent[stageID] = find(parent[stageID])
+		return parent[stageID]
+	}
+
+	firstStageByKey := make(map[string]string)
+	for _, stageDesc := range dedup.Stages {
+		stageID := stageDesc.StageID.String()
+
+		var keys []string
+		if contentDigest := stageDesc.Info.Labels[image.WerfStageContentDigestLabel]; contentDigest != "" {
+			keys = append(keys, "content:"+contentDigest)
+		}
+		if dedup.hasOwnLayers(stageDesc) {
+			keys = append(keys, "layers:"+dedup.layersKey(stageID))
+		}
+
+		for _, key := range keys {
+			if firstStageID, hasKey := firstStageByKey[key]; hasKey {
+				parent[find(stageID)] = find(firstStageID)
+			} else {
+				firstStageByKey[key] = stageID
+			}
+		}
+	}
+
+	// dedup.Stages are sorted by creation time, so the first stage of each group is the oldest one
+	groupByRoot := make(map[string]*DuplicateStagesGroup)
+	var res []*DuplicateStagesGroup
+	for _, stageDesc := range dedup.Stages {
+		stageID := stageDesc.StageID.String()
+		root := find(stageID)
+
+		group, hasKey := groupByRoot[root]
+		if !hasKey {
+			group = &DuplicateStagesGroup{
+				CanonicalStage:  stageID,
+				IdenticalLayers: true,
+			}
+			groupByRoot[root] = group
+			res = append(res, group)
+			continue
+		}
+
+		group.DuplicateStages = append(group.DuplicateStages, stageID)
+		if dedup.layersKey(stageID) != dedup.layersKey(group.CanonicalStage) {
+			group.IdenticalLayers = false
+		}
+	}
+
+	var groups []*DuplicateStagesGroup
+	for _, group := range res {
+		if len(group.DuplicateStages) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+func (dedup *stagesDedup) report() *StagesDedupReport {
+	report := &StagesDedupReport{StagesCount: len(dedup.Stages)}
+
+	storedLayers := make(map[string]bool)
+	for _, stageDesc := range dedup.Stages {
+		for _, layer := range dedup.LayersByStageID[stageDesc.StageID.String()] {
+			report.ReferencedSize += layer.Size
+			if !storedLayers[layer.Digest] {
+				storedLayers[layer.Digest] = true
+				report.LayersCount++
+				report.StoredSize += layer.Size
+			}
+		}
+	}
+
+	wastedLayers := make(map[string]bool)
+	for _, group := range dedup.groups() {
+		canonicalLayers := make(map[string]bool)
+		for _, layer := range dedup.LayersByStageID[group.CanonicalStage] {
+			canonicalLayers[layer.Digest] = true
+		}
+
+		groupLayers := make(map[string]bool)
+		for _, stageID := range group.DuplicateStages {
+			for _, layer := range dedup.LayersByStageID[stageID] {
+				if canonicalLayers[layer.Digest] || groupLayers[layer.Digest] {
+					continue
+				}
+				groupLayers[layer.Digest] = true
+				group.WastedSize += layer.Size
+
+				if !wastedLayers[layer.Digest] {
+					wastedLayers[layer.Digest] = true
+					report.WastedSize += layer.Size
+				}
+			}
+		}
+
+		report.Groups = append(report.Groups, group)
+	}
+
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		return report.Groups[i].WastedSize > report.Groups[j].WastedSize
+	})
+
+	return report
+}
+
+// isStageUsedByOtherStages checks whether the stage is the parent or the import source of any other stage
+func (dedup *stagesDedup) isStageUsedByOtherStages(stageDesc *image.StageDescription) bool {
+	for _, desc := range dedup.Stages {
+		if desc.Info.ParentID == stageDesc.Info.ID {
+			return true
+		}
+
+		for label, imageID := range desc.Info.Labels {
+			if strings.HasPrefix(label, image.WerfImportLabelPrefix) && imageID == stageDesc.Info.ID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (dedup *stagesDedup) hasCanonicalStageDigest(stageDesc *image.StageDescription, canonicalStageID string) bool {
+	canonicalStageDesc := dedup.StageByStageID[canonicalStageID]
+	return canonicalStageDesc != nil && canonicalStageDesc.StageID.Digest == stageDesc.StageID.Digest
+}
+
+func (dedup *stagesDedup) removeStage(stageDesc *image.StageDescription) {
+	var stages []*image.StageDescription
+	for _, desc := range dedup.Stages {
+		if desc != stageDesc {
+			stages = append(stages, desc)
+		}
+	}
+	dedup.Stages = stages
+
+	delete(dedup.StageByStageID, stageDesc.StageID.String())
+	delete(dedup.LayersByStageID, stageDesc.StageID.String())
+}
+
+// GetStagesDedupReport compares layers of the project stages through the docker registry API and finds stages with duplicate content
+func GetStagesDedupReport(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, maxNumberOfWorkers int) (*StagesDedupReport, error) {
+	dedup, err := newStagesDedup(ctx, projectName, stagesStorage, maxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	return dedup.report(), nil
+}
+
+// MergeDuplicateStages moves images metadata of the duplicate stages to the canonical stage of the group and deletes duplicates.
+// Only groups of stages with identical layers are merged, stages with the same content digest and different layers are kept.
+// Only duplicates with the same stage digest as the canonical stage are merged: images metadata commits and git commit labels
+// of the duplicate stage with another stage digest do not correspond to the canonical stage, such duplicates are kept.
+// Duplicates which are parents or import sources of other stages are kept, stages are processed until nothing can be merged,
+// so whole chains of duplicate stages are merged starting from the last stage.
+func MergeDuplicateStages(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, stagesStorageCache storage.StagesStorageCache, storageLockManager storage.LockManager, opts MergeDuplicateStagesOptions) (*StagesDedupReport, error) {
+	if !opts.DryRun {
+		if lock, err := storageLockManager.LockStagesAndImages(ctx, projectName, storage.LockStagesAndImagesOptions{GetOrCreateImagesOnly: false}); err != nil {
+			return nil, fmt.Errorf("unable to lock stages and images of project %q: %s", projectName, err)
+		} else {
+			defer storageLockManager.Unlock(ctx, lock)
+		}
+	}
+
+	dedup, err := newStagesDedup(ctx, projectName, stagesStorage, opts.MaxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	report := dedup.report()
+
+	if err := dedup.merge(func(stageDesc *image.StageDescription, canonicalStageID string) error {
+		return mergeDuplicateStage(ctx, projectName, stagesStorage, stagesStorageCache, dedup.ImagesMetadata, stageDesc, canonicalStageID, opts.DryRun)
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, group := range report.Groups {
+		for _, stageID := range group.DuplicateStages {
+			if _, hasKey := dedup.StageByStageID[stageID]; !hasKey {
+				continue
+			}
+
+			if !group.IdenticalLayers {
+				logboek.Context(ctx).Warn().LogF("Duplicate stage %s is kept: its layers differ from the canonical stage %s\n", stageID, group.CanonicalStage)
+			} else if !dedup.hasCanonicalStageDigest(dedup.StageByStageID[stageID], group.CanonicalStage) {
+				logboek.Context(ctx).Warn().LogF("Duplicate stage %s is kept: its stage digest differs from the canonical stage %s\n", stageID, group.CanonicalStage)
+			} else {
+				logboek.Context(ctx).Warn().LogF("Duplicate stage %s is kept: it is used by other stages\n", stageID)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// merge calls mergeStage for each duplicate stage of the groups with identical layers, which has the same stage digest as the canonical stage and is not used by other stages
+func (dedup *stagesDedup) merge(mergeStage func(stageDesc *image.StageDescription, canonicalStageID string) error) error {
+	for {
+		var merged bool
+
+		for _, group := range dedup.groups() {
+			if !group.IdenticalLayers {
+				continue
+			}
+
+			for i := len(group.DuplicateStages) - 1; i >= 0; i-- {
+				stageDesc := dedup.StageByStageID[group.DuplicateStages[i]]
+				if !dedup.hasCanonicalStageDigest(stageDesc, group.CanonicalStage) || dedup.isStageUsedByOtherStages(stageDesc) {
+					continue
+				}
+
+				if err := mergeStage(stageDesc, group.CanonicalStage); err != nil {
+					return err
+				}
+
+				dedup.removeStage(stageDesc)
+				merged = true
+			}
+		}
+
+		if !merged {
+			return nil
+		}
+	}
+}
+
+func mergeDuplicateStage(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, stagesStorageCache storage.StagesStorageCache, imagesMetadata map[string]map[string][]string, stageDesc *image.StageDescription, canonicalStageID string, dryRun bool) error {
+	stageID := stageDesc.StageID.String()
+
+	logboek.Context(ctx).Default().LogF("Merging duplicate stage %s into %s\n", stageID, canonicalStageID)
+
+	for imageName, commitListByStageID := range imagesMetadata {
+		for _, commit := range commitListByStageID[stageID] {
+			logboek.Context(ctx).Default().LogF("  image %s commit %s: %s -> %s\n", logImageName(imageName), commit, stageID, canonicalStageID)
+			if dryRun {
+				continue
+			}
+
+			if err := stagesStorage.PutImageMetadata(ctx, projectName, imageName, commit, canonicalStageID); err != nil {
+				return fmt.Errorf("unable to put image %s metadata for commit %s and stage %s: %s", logImageName(imageName), commit, canonicalStageID, err)
+			}
+
+			if err := stagesStorage.RmImageMetadata(ctx, projectName, imageName, commit, stageID); err != nil {
+				return fmt.Errorf("unable to remove image %s metadata for commit %s and stage %s: %s", logImageName(imageName), commit, stageID, err)
+			}
+		}
+
+		if commits, hasKey := commitListByStageID[stageID]; hasKey {
+			commitListByStageID[canonicalStageID] = append(commitListByStageID[canonicalStageID], commits...)
+			delete(commitListByStageID, stageID)
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if err := stagesStorageCache.DeleteStagesByDigest(ctx, projectName, stageDesc.StageID.Digest); err != nil {
+		return fmt.Errorf("unable to delete stages storage cache record (%s): %s", stageDesc.StageID.Digest, err)
+	}
+
+	if err := stagesStorage.DeleteStage(ctx, stageDesc, storage.DeleteImageOptions{}); err != nil {
+		return fmt.Errorf("unable to delete stage %s: %s", stageID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+type stagesDedupFixture struct {
+	dedup                                                               *stagesDedup
+	base, install, rebuiltInstall, setup, setupCopy, dockerInstructions *image.StageDescription
+}
+
+func newStagesDedupFixture(rebuiltInstallDigest string) *stagesDedupFixture {
+	base := newTestStageDescription("base", 1, "id-base", "", map[string]string{image.WerfStageContentDigestLabel: "content-base"})
+	install := newTestStageDescription("install", 2, "id-install", "id-base", map[string]string{image.WerfStageContentDigestLabel: "content-install"})
+	rebuiltInstall := newTestStageDescription(rebuiltInstallDigest, 3, "id-rebuilt-install", "id-base", map[string]string{image.WerfStageContentDigestLabel: "content-rebuilt-install"})
+	setup := newTestStageDescription("setup", 4, "id-setup", "id-install", map[string]string{image.WerfStageContentDigestLabel: "content-setup"})
+	setupCopy := newTestStageDescription("setup", 5, "id-setup-copy", "id-install", map[string]string{image.WerfStageContentDigestLabel: "content-setup"})
+	dockerInstructions := newTestStageDescription("docker-instructions", 6, "id-docker-instructions", "id-setup", map[string]string{image.WerfStageContentDigestLabel: "content-docker-instructions"})
+
+	dedup := &stagesDedup{
+		stagesIndex: &stagesIndex{
+			Stages: []*image.StageDescription{base, install, rebuiltInstall, setup, setupCopy, dockerInstructions},
+			StageByImageID: map[string]*image.StageDescription{
+				"id-base": base, "id-install": install, "id-rebuilt-install": rebuiltInstall,
+				"id-setup": setup, "id-setup-copy": setupCopy, "id-docker-instructions": dockerInstructions,
+			},
+		},
+		LayersByStageID: map[string][]StageLayer{
+			base.StageID.String():               {{"l1", 10}},
+			install.StageID.String():            {{"l1", 10}, {"l2", 20}},
+			rebuiltInstall.StageID.String():     {{"l1", 10}, {"l2", 20}},
+			setup.StageID.String():              {{"l1", 10}, {"l2", 20}, {"l3", 30}},
+			setupCopy.StageID.String():          {{"l1", 10}, {"l2", 20}, {"l4", 40}},
+			dockerInstructions.StageID.String(): {{"l1", 10}, {"l2", 20}, {"l3", 30}},
+		},
+	}
+
+	dedup.StageByStageID = map[string]*image.StageDescription{}
+	for _, stageDesc := range dedup.Stages {
+		dedup.StageByStageID[stageDesc.StageID.String()] = stageDesc
+	}
+
+	return &stagesDedupFixture{dedup, base, install, rebuiltInstall, setup, setupCopy, dockerInstructions}
+}
+
+func TestStagesDedupReport(t *testing.T) {
+	f := newStagesDedupFixture("install")
+	dedup, setup, setupCopy, install, rebuiltInstall := f.dedup, f.setup, f.setupCopy, f.install, f.rebuiltInstall
+
+	report := dedup.report()
+
+	if report.StagesCount != 6 || report.LayersCount != 4 || report.StoredSize != 100 || report.ReferencedSize != 260 || report.WastedSize != 40 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	if len(report.Groups) != 2 {
+		t.Fatalf("expected 2 duplicate groups, got %d", len(report.Groups))
+	}
+
+	setupGroup := report.Groups[0]
+	if setupGroup.CanonicalStage != setup.StageID.String() || len(setupGroup.DuplicateStages) != 1 || setupGroup.DuplicateStages[0] != setupCopy.StageID.String() || setupGroup.IdenticalLayers || setupGroup.WastedSize != 40 {
+		t.Errorf("unexpected setup group: %+v", setupGroup)
+	}
+
+	installGroup := report.Groups[1]
+	if installGroup.CanonicalStage != install.StageID.String() || len(installGroup.DuplicateStages) != 1 || installGroup.DuplicateStages[0] != rebuiltInstall.StageID.String() || !installGroup.IdenticalLayers || installGroup.WastedSize != 0 {
+		t.Errorf("unexpected install group: %+v", installGroup)
+	}
+
+	if dedup.isStageUsedByOtherStages(setupCopy) {
+		t.Errorf("stage %s should not be used by other stages", setupCopy.StageID.String())
+	}
+	if !dedup.isStageUsedByOtherStages(install) {
+		t.Errorf("stage %s should be used by other stages", install.StageID.String())
+	}
+}
+
+func TestMergeDuplicateStagesSkipsNonIdenticalLayers(t *testing.T) {
+	f := newStagesDedupFixture("install")
+
+	var merged []string
+	if err := f.dedup.merge(func(stageDesc *image.StageDescription, canonicalStageID string) error {
+		merged = append(merged, stageDesc.StageID.String()+"->"+canonicalStageID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := f.rebuiltInstall.StageID.String() + "->" + f.install.StageID.String()
+	if len(merged) != 1 || merged[0] != expected {
+		t.Fatalf("expected only %s to be merged, got %v", expected, merged)
+	}
+
+	if _, hasKey := f.dedup.StageByStageID[f.setupCopy.StageID.String()]; !hasKey {
+		t.Errorf("stage %s with different layers should be kept", f.setupCopy.StageID.String())
+	}
+	if _, hasKey := f.dedup.StageByStageID[f.rebuiltInstall.StageID.String()]; hasKey {
+		t.Errorf("stage %s should be removed after merge", f.rebuiltInstall.StageID.String())
+	}
+}
+
+func TestMergeDuplicateStagesSkipsDifferentStageDigests(t *testing.T) {
+	f := newStagesDedupFixture("other-install")
+
+	if err := f.dedup.merge(func(stageDesc *image.StageDescription, canonicalStageID string) error {
+		t.Errorf("unexpected merge of %s into %s", stageDesc.StageID.String(), canonicalStageID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, hasKey := f.dedup.StageByStageID[f.rebuiltInstall.StageID.String()]; !hasKey {
+		t.Errorf("stage %s with different stage digest should be kept", f.rebuiltInstall.StageID.String())
+	}
+}
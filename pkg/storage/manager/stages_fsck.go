@@ -0,0 +1,379 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+type StagesFsckProblemType string
+
+const (
+	OrphanedImageMetadataProblem   StagesFsckProblemType = "orphaned-image-metadata"
+	BrokenParentChainProblem       StagesFsckProblemType = "broken-parent-chain"
+	UnreadableLabelsProblem        StagesFsckProblemType = "unreadable-labels"
+	DuplicateClientIDRecordProblem StagesFsckProblemType = "duplicate-client-id-record"
+)
+
+// StagesFsckProblem is a single inconsistency of the stages storage.
+// Subject is the stage ID, the image metadata record or the client ID record which has the problem.
+type StagesFsckProblem struct {
+	Type        StagesFsckProblemType `json:"type"`
+	Subject     string                `json:"subject"`
+	Description string                `json:"description"`
+	Repairable  bool                  `json:"repairable"`
+	Repaired    bool                  `json:"repaired"`
+
+	stageDesc      *image.StageDescription
+	imageName      string
+	commit         string
+	stageID        string
+	clientIDRecord *storage.ClientIDRecord
+}
+
+type StagesFsckReport struct {
+	StagesCount int                  `json:"stagesCount"`
+	Problems    []*StagesFsckProblem `json:"problems"`
+}
+
+func (report *StagesFsckReport) UnrepairedProblemsCount() int {
+	var count int
+	for _, problem := range report.Problems {
+		if !problem.Repaired {
+			count++
+		}
+	}
+	return count
+}
+
+type FsckStagesOptions struct {
+	Repair             bool
+	DryRun             bool
+	MaxNumberOfWorkers int
+}
+
+type stagesFsckInput struct {
+	Stages           []*image.StageDescription
+	UnreadableStages map[string]error
+	ImagesMetadata   map[string]map[string][]string
+	ClientIDRecords  []*storage.ClientIDRecord
+}
+
+// FsckStages walks all project stages, images metadata and client ID records of the stages storage and reports:
+//   - images metadata which points to missing stages;
+//   - stages with broken parent chain;
+//   - stages with unreadable or inconsistent werf labels;
+//   - duplicate client ID records.
+//
+// The parent of the first stage of an image is an external base image, which is not stored in the stages storage,
+// so the stage chain is considered broken only when the stage carries werf git or import labels
+// (which cannot be inherited from a base image) and its parent is missing, or when an import source stage is missing.
+// This is a heuristic and stages built on top of the images published by werf may be reported too,
+// so stages with broken chain are reported only and never repaired.
+//
+// With Repair option werf removes images metadata which points to missing stages, deletes stages with inconsistent labels
+// along with the images metadata which points to them and removes all client ID records except the oldest one, which is used by the synchronization server.
+func FsckStages(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, stagesStorageCache storage.StagesStorageCache, storageLockManager storage.LockManager, opts FsckStagesOptions) (*StagesFsckReport, error) {
+	if opts.Repair && !opts.DryRun {
+		if lock, err := storageLockManager.LockStagesAndImages(ctx, projectName, storage.LockStagesAndImagesOptions{GetOrCreateImagesOnly: false}); err != nil {
+			return nil, fmt.Errorf("unable to lock stages and images of project %q: %s", projectName, err)
+		} else {
+			defer storageLockManager.Unlock(ctx, lock)
+		}
+	}
+
+	input, err := getStagesFsckInput(ctx, projectName, stagesStorage, opts.MaxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StagesFsckReport{
+		StagesCount: len(input.Stages) + len(input.UnreadableStages),
+		Problems:    detectStagesFsckProblems(projectName, input),
+	}
+
+	if !opts.Repair {
+		return report, nil
+	}
+
+	for _, problem := range report.Problems {
+		if !problem.Repairable {
+			continue
+		}
+
+		if err := repairStagesFsckProblem(ctx, projectName, stagesStorage, stagesStorageCache, problem, opts.DryRun); err != nil {
+			return nil, err
+		}
+
+		problem.Repaired = !opts.DryRun
+	}
+
+	return report, nil
+}
+
+func getStagesFsckInput(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, maxNumberOfWorkers int) (*stagesFsckInput, error) {
+	stages, err := stagesStorage.GetStagesIDs(ctx, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stages from %s: %s", stagesStorage.String(), err)
+	}
+
+	input := &stagesFsckInput{UnreadableStages: make(map[string]error)}
+
+	var mutex sync.Mutex
+	if err := parallel.DoTasks(ctx, len(stages), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: maxNumberOfWorkers,
+	}, func(ctx context.Context, taskId int) error {
+		stageID := stages[taskId]
+
+		stageDesc, err := stagesStorage.GetStageDescription(ctx, projectName, stageID.Digest, stageID.UniqueID)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err != nil {
+			input.UnreadableStages[stageID.String()] = err
+		} else if stageDesc != nil {
+			input.Stages = append(input.Stages, stageDesc)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(input.Stages, func(i, j int) bool {
+		return input.Stages[i].StageID.UniqueID < input.Stages[j].StageID.UniqueID
+	})
+
+	if input.ImagesMetadata, err = getSourceImageMetadataByImageName(ctx, projectName, stagesStorage, nil); err != nil {
+		return nil, err
+	}
+
+	if input.ClientIDRecords, err = stagesStorage.GetClientIDRecords(ctx, projectName); err != nil {
+		return nil, fmt.Errorf("unable to get client ID records from %s: %s", stagesStorage.String(), err)
+	}
+
+	return input, nil
+}
+
+func detectStagesFsckProblems(projectName string, input *stagesFsckInput) []*StagesFsckProblem {
+	var problems []*StagesFsckProblem
+
+	var unreadableStageIDs []string
+	for stageID := range input.UnreadableStages {
+		unreadableStageIDs = append(unreadableStageIDs, stageID)
+	}
+	sort.Strings(unreadableStageIDs)
+
+	for _, stageID := range unreadableStageIDs {
+		problems = append(problems, &StagesFsckProblem{
+			Type:        UnreadableLabelsProblem,
+			Subject:     stageID,
+			Description: fmt.Sprintf("unable to get stage description: %s", input.UnreadableStages[stageID]),
+		})
+	}
+
+	stageByImageID := make(map[string]*image.StageDescription)
+	for _, stageDesc := range input.Stages {
+		stageByImageID[stageDesc.Info.ID] = stageDesc
+	}
+
+	// brokenStages are image IDs of reported stages, descendants of broken stages are reported too.
+	// Only stages with inconsistent labels are deleted on repair, the broken chain detection is a heuristic.
+	brokenStages := make(map[string]bool)
+	deletedStageIDs := make(map[string]bool)
+	for _, stageDesc := range input.Stages {
+		stageID := stageDesc.StageID.String()
+
+		var description string
+		var problemType StagesFsckProblemType
+		if description = checkStageLabels(projectName, stageDesc); description != "" {
+			problemType = UnreadableLabelsProblem
+		} else if description = checkStageChain(stageDesc, stageByImageID, brokenStages); description != "" {
+			problemType = BrokenParentChainProblem
+		} else {
+			continue
+		}
+
+		repairable := problemType == UnreadableLabelsProblem
+
+		brokenStages[stageDesc.Info.ID] = true
+		if repairable {
+			deletedStageIDs[stageID] = true
+		}
+
+		problems = append(problems, &StagesFsckProblem{
+			Type:        problemType,
+			Subject:     stageID,
+			Description: description,
+			Repairable:  repairable,
+			stageDesc:   stageDesc,
+		})
+	}
+
+	existingStageIDs := make(map[string]bool)
+	for _, stageDesc := range input.Stages {
+		existingStageIDs[stageDesc.StageID.String()] = true
+	}
+	for stageID := range input.UnreadableStages {
+		existingStageIDs[stageID] = true
+	}
+
+	var imageNameList []string
+	for imageName := range input.ImagesMetadata {
+		imageNameList = append(imageNameList, imageName)
+	}
+	sort.Strings(imageNameList)
+
+	for _, imageName := range imageNameList {
+		var stageIDs []string
+		for stageID := range input.ImagesMetadata[imageName] {
+			stageIDs = append(stageIDs, stageID)
+		}
+		sort.Strings(stageIDs)
+
+		for _, stageID := range stageIDs {
+			var description string
+			if deletedStageIDs[stageID] {
+				description = fmt.Sprintf("image metadata points to stage %s with inconsistent labels", stageID)
+			} else if !existingStageIDs[stageID] {
+				description = fmt.Sprintf("image metadata points to missing stage %s", stageID)
+			} else {
+				continue
+			}
+
+			for _, commit := range input.ImagesMetadata[imageName][stageID] {
+				problems = append(problems, &StagesFsckProblem{
+					Type:        OrphanedImageMetadataProblem,
+					Subject:     fmt.Sprintf("%s@%s", logImageName(imageName), commit),
+					Description: description,
+					Repairable:  true,
+					imageName:   imageName,
+					commit:      commit,
+					stageID:     stageID,
+				})
+			}
+		}
+	}
+
+	if len(input.ClientIDRecords) > 1 {
+		records := append([]*storage.ClientIDRecord(nil), input.ClientIDRecords...)
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].TimestampMillisec < records[j].TimestampMillisec
+		})
+
+		for _, rec := range records[1:] {
+			problems = append(problems, &StagesFsckProblem{
+				Type:           DuplicateClientIDRecordProblem,
+				Subject:        rec.String(),
+				Description:    fmt.Sprintf("client ID %s is used by the synchronization server", records[0].ClientID),
+				Repairable:     true,
+				clientIDRecord: rec,
+			})
+		}
+	}
+
+	return problems
+}
+
+func checkStageLabels(projectName string, stageDesc *image.StageDescription) string {
+	labels := stageDesc.Info.Labels
+	if len(labels) == 0 {
+		return "stage has no labels"
+	}
+
+	if value := labels[image.WerfLabel]; value != projectName {
+		return fmt.Sprintf("stage label %s=%q does not match the project %q", image.WerfLabel, value, projectName)
+	}
+
+	if value, hasKey := labels[image.WerfStageDigestLabel]; hasKey && value != stageDesc.StageID.Digest {
+		return fmt.Sprintf("stage label %s=%q does not match the stage digest %q", image.WerfStageDigestLabel, value, stageDesc.StageID.Digest)
+	}
+
+	return ""
+}
+
+func checkStageChain(stageDesc *image.StageDescription, stageByImageID map[string]*image.StageDescription, brokenStages map[string]bool) string {
+	var hasWerfLabels bool
+	var importSourceIDs []string
+	for label, value := range stageDesc.Info.Labels {
+		switch {
+		case gitMappingLabelRegexp.MatchString(label):
+			hasWerfLabels = true
+		case strings.HasPrefix(label, image.WerfImportLabelPrefix):
+			hasWerfLabels = true
+			importSourceIDs = append(importSourceIDs, value)
+		}
+	}
+	sort.Strings(importSourceIDs)
+
+	parentID := stageDesc.Info.ParentID
+	if parentID != "" {
+		if parent := stageByImageID[parentID]; parent != nil {
+			if brokenStages[parentID] {
+				return fmt.Sprintf("parent stage %s is broken", parent.StageID.String())
+			}
+		} else if hasWerfLabels {
+			return fmt.Sprintf("parent stage image %s not found", parentID)
+		}
+	}
+
+	for _, importSourceID := range importSourceIDs {
+		if importSource := stageByImageID[importSourceID]; importSource == nil {
+			return fmt.Sprintf("import source stage image %s not found", importSourceID)
+		} else if brokenStages[importSourceID] {
+			return fmt.Sprintf("import source stage %s is broken", importSource.StageID.String())
+		}
+	}
+
+	return ""
+}
+
+func repairStagesFsckProblem(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, stagesStorageCache storage.StagesStorageCache, problem *StagesFsckProblem, dryRun bool) error {
+	switch problem.Type {
+	case OrphanedImageMetadataProblem:
+		logboek.Context(ctx).Default().LogF("Removing image %s metadata for commit %s and stage %s\n", logImageName(problem.imageName), problem.commit, problem.stageID)
+		if dryRun {
+			return nil
+		}
+
+		if err := stagesStorage.RmImageMetadata(ctx, projectName, problem.imageName, problem.commit, problem.stageID); err != nil {
+			return fmt.Errorf("unable to remove image %s metadata for commit %s and stage %s: %s", logImageName(problem.imageName), problem.commit, problem.stageID, err)
+		}
+
+	case UnreadableLabelsProblem:
+		stageDesc := problem.stageDesc
+		logboek.Context(ctx).Default().LogF("Deleting stage %s: %s\n", problem.Subject, problem.Description)
+		if dryRun {
+			return nil
+		}
+
+		if err := stagesStorageCache.DeleteStagesByDigest(ctx, projectName, stageDesc.StageID.Digest); err != nil {
+			return fmt.Errorf("unable to delete stages storage cache record (%s): %s", stageDesc.StageID.Digest, err)
+		}
+
+		if err := stagesStorage.DeleteStage(ctx, stageDesc, storage.DeleteImageOptions{}); err != nil {
+			return fmt.Errorf("unable to delete stage %s: %s", problem.Subject, err)
+		}
+
+	case DuplicateClientIDRecordProblem:
+		logboek.Context(ctx).Default().LogF("Removing duplicate client ID record %s\n", problem.clientIDRecord.String())
+		if dryRun {
+			return nil
+		}
+
+		if err := stagesStorage.RmClientIDRecord(ctx, projectName, problem.clientIDRecord); err != nil {
+			return fmt.Errorf("unable to remove client ID record %s: %s", problem.clientIDRecord.String(), err)
+		}
+	}
+
+	return nil
+}
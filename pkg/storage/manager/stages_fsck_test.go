@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func newFsckTestStage(digest string, uniqueID int64, imageID, parentID string, labels map[string]string) *image.StageDescription {
+	stageLabels := map[string]string{image.WerfLabel: "project", image.WerfStageDigestLabel: digest}
+	for k, v := range labels {
+		stageLabels[k] = v
+	}
+
+	return newTestStageDescription(digest, uniqueID, imageID, parentID, stageLabels)
+}
+
+func TestDetectStagesFsckProblems(t *testing.T) {
+	from := newFsckTestStage("from", 1, "id-from", "id-alpine", nil)
+	gitArchive := newFsckTestStage("git-archive", 2, "id-git-archive", "id-from", map[string]string{"werf-git-abc-commit": "c1"})
+	orphan := newFsckTestStage("orphan", 3, "id-orphan", "id-deleted", map[string]string{"werf-git-abc-commit": "c1"})
+	orphanChild := newFsckTestStage("orphan-child", 4, "id-orphan-child", "id-orphan", nil)
+	badDigest := newFsckTestStage("bad-digest", 5, "id-bad-digest", "id-alpine", map[string]string{image.WerfStageDigestLabel: "other"})
+	missingImport := newFsckTestStage("missing-import", 6, "id-missing-import", "id-git-archive", map[string]string{image.WerfImportLabelPrefix + "artifact": "id-deleted-artifact"})
+
+	input := &stagesFsckInput{
+		Stages:           []*image.StageDescription{from, gitArchive, orphan, orphanChild, badDigest, missingImport},
+		UnreadableStages: map[string]error{"unreadable-7": fmt.Errorf("manifest unknown")},
+		ImagesMetadata: map[string]map[string][]string{
+			"app": {
+				gitArchive.StageID.String():  {"c1"},
+				orphanChild.StageID.String(): {"c2"},
+				"missing-8":                  {"c3"},
+			},
+		},
+		ClientIDRecords: []*storage.ClientIDRecord{
+			{ClientID: "newer", TimestampMillisec: 20},
+			{ClientID: "older", TimestampMillisec: 10},
+		},
+	}
+
+	expected := []struct {
+		Type    StagesFsckProblemType
+		Subject string
+	}{
+		{UnreadableLabelsProblem, "unreadable-7"},
+		{BrokenParentChainProblem, orphan.StageID.String()},
+		{BrokenParentChainProblem, orphanChild.StageID.String()},
+		{UnreadableLabelsProblem, badDigest.StageID.String()},
+		{BrokenParentChainProblem, missingImport.StageID.String()},
+		{OrphanedImageMetadataProblem, "app@c3"},
+		{DuplicateClientIDRecordProblem, input.ClientIDRecords[0].String()},
+	}
+
+	problems := detectStagesFsckProblems("project", input)
+	if len(problems) != len(expected) {
+		for _, problem := range problems {
+			t.Logf("%s %s: %s", problem.Type, problem.Subject, problem.Description)
+		}
+		t.Fatalf("expected %d problems, got %d", len(expected), len(problems))
+	}
+
+	for i, problem := range problems {
+		if problem.Type != expected[i].Type || problem.Subject != expected[i].Subject {
+			t.Errorf("problem %d: expected %s %s, got %s %s: %s", i, expected[i].Type, expected[i].Subject, problem.Type, problem.Subject, problem.Description)
+		}
+	}
+
+	for i, problem := range problems {
+		expectedRepairable := problem.Type != BrokenParentChainProblem && i != 0
+		if problem.Repairable != expectedRepairable {
+			t.Errorf("problem %s %s: expected repairable %v, got %v", problem.Type, problem.Subject, expectedRepairable, problem.Repairable)
+		}
+	}
+}
+
+func TestDetectStagesFsckProblemsValidChain(t *testing.T) {
+	artifactFrom := newFsckTestStage("artifact-from", 1, "id-artifact-from", "id-node", nil)
+	artifactGit := newFsckTestStage("artifact-git", 2, "id-artifact-git", "id-artifact-from", map[string]string{"werf-git-abc-commit": "c1"})
+	from := newFsckTestStage("from", 3, "id-from", "id-alpine", nil)
+	gitArchive := newFsckTestStage("git-archive", 4, "id-git-archive", "id-from", map[string]string{"werf-git-abc-commit": "c1"})
+	install := newFsckTestStage("install", 5, "id-install", "id-git-archive", map[string]string{image.WerfImportLabelPrefix + "artifact": "id-artifact-git"})
+	setup := newFsckTestStage("setup", 6, "id-setup", "id-install", nil)
+
+	input := &stagesFsckInput{
+		Stages:           []*image.StageDescription{artifactFrom, artifactGit, from, gitArchive, install, setup},
+		UnreadableStages: map[string]error{},
+		ImagesMetadata: map[string]map[string][]string{
+			"app": {setup.StageID.String(): {"c1"}},
+		},
+		ClientIDRecords: []*storage.ClientIDRecord{{ClientID: "client", TimestampMillisec: 10}},
+	}
+
+	if problems := detectStagesFsckProblems("project", input); len(problems) != 0 {
+		for _, problem := range problems {
+			t.Errorf("unexpected problem %s %s: %s", problem.Type, problem.Subject, problem.Description)
+		}
+	}
+}
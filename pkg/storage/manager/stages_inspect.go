@@ -0,0 +1,259 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+)
+
+var gitMappingLabelRegexp = regexp.MustCompile(`^werf-git-([0-9a-f]+)-(commit|virtual-merge|virtual-merge-from-commit|virtual-merge-into-commit)$`)
+
+type StageImageUsage struct {
+	ImageName string   `json:"imageName"`
+	Commits   []string `json:"commits"`
+}
+
+type StageListItem struct {
+	StageID       string            `json:"stageID"`
+	Digest        string            `json:"digest"`
+	UniqueID      int64             `json:"uniqueID"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	Size          int64             `json:"size"`
+	ParentStageID string            `json:"parentStageID,omitempty"`
+	UsedBy        []StageImageUsage `json:"usedBy,omitempty"`
+}
+
+type StageChainItem struct {
+	StageID   string `json:"stageID,omitempty"`
+	ImageName string `json:"imageName"`
+	ImageID   string `json:"imageID"`
+}
+
+// GitMappingCommitInfo is the git commit info recorded into the stage labels by GitMapping.AddGitCommitToImageLabels
+type GitMappingCommitInfo struct {
+	ParamsHash             string `json:"paramsHash"`
+	Commit                 string `json:"commit"`
+	VirtualMerge           bool   `json:"virtualMerge"`
+	VirtualMergeFromCommit string `json:"virtualMergeFromCommit,omitempty"`
+	VirtualMergeIntoCommit string `json:"virtualMergeIntoCommit,omitempty"`
+}
+
+type StageInspectInfo struct {
+	StageListItem
+	ImageName  string                 `json:"imageName"`
+	ImageID    string                 `json:"imageID"`
+	Labels     map[string]string      `json:"labels"`
+	Chain      []StageChainItem       `json:"chain"`
+	GitCommits []GitMappingCommitInfo `json:"gitCommits,omitempty"`
+}
+
+type stagesIndex struct {
+	Stages         []*image.StageDescription
+	StageByImageID map[string]*image.StageDescription
+	UsageByStageID map[string][]StageImageUsage
+	ImagesMetadata map[string]map[string][]string
+}
+
+func newStagesIndex(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, maxNumberOfWorkers int) (*stagesIndex, error) {
+	stages, err := stagesStorage.GetStagesIDs(ctx, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stages from %s: %s", stagesStorage.String(), err)
+	}
+
+	stagesDescriptions, err := getStagesDescriptions(ctx, projectName, stagesStorage, stages, maxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	imagesMetadata, err := getSourceImageMetadataByImageName(ctx, projectName, stagesStorage, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &stagesIndex{
+		Stages:         stagesDescriptions,
+		StageByImageID: make(map[string]*image.StageDescription),
+		UsageByStageID: make(map[string][]StageImageUsage),
+		ImagesMetadata: imagesMetadata,
+	}
+
+	stageByStageID := make(map[string]*image.StageDescription)
+	for _, stageDesc := range stagesDescriptions {
+		stageByStageID[stageDesc.StageID.String()] = stageDesc
+		index.StageByImageID[stageDesc.Info.ID] = stageDesc
+	}
+
+	sort.Slice(index.Stages, func(i, j int) bool {
+		return index.Stages[i].StageID.UniqueID < index.Stages[j].StageID.UniqueID
+	})
+
+	var imageNameList []string
+	for imageName := range imagesMetadata {
+		imageNameList = append(imageNameList, imageName)
+	}
+	sort.Strings(imageNameList)
+
+	for _, imageName := range imageNameList {
+		for stageID, commits := range imagesMetadata[imageName] {
+			for stageDesc := stageByStageID[stageID]; stageDesc != nil; stageDesc = index.StageByImageID[stageDesc.Info.ParentID] {
+				key := stageDesc.StageID.String()
+				index.UsageByStageID[key] = addStageImageUsage(index.UsageByStageID[key], imageName, commits)
+			}
+		}
+	}
+
+	return index, nil
+}
+
+func addStageImageUsage(usages []StageImageUsage, imageName string, commits []string) []StageImageUsage {
+	for i := range usages {
+		if usages[i].ImageName == imageName {
+			for _, commit := range commits {
+				if !util.IsStringsContainValue(usages[i].Commits, commit) {
+					usages[i].Commits = append(usages[i].Commits, commit)
+				}
+			}
+			return usages
+		}
+	}
+
+	return append(usages, StageImageUsage{ImageName: imageName, Commits: append([]string(nil), commits...)})
+}
+
+func (index *stagesIndex) newStageListItem(stageDesc *image.StageDescription) *StageListItem {
+	item := &StageListItem{
+		StageID:   stageDesc.StageID.String(),
+		Digest:    stageDesc.StageID.Digest,
+		UniqueID:  stageDesc.StageID.UniqueID,
+		CreatedAt: stageDesc.StageID.UniqueIDAsTime(),
+		Size:      stageDesc.Info.Size,
+		UsedBy:    index.UsageByStageID[stageDesc.StageID.String()],
+	}
+
+	if parent := index.StageByImageID[stageDesc.Info.ParentID]; parent != nil {
+		item.ParentStageID = parent.StageID.String()
+	}
+
+	return item
+}
+
+// ListStages returns all project stages from the stages storage sorted by creation time along with the werf images and commits which use each stage
+func ListStages(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, maxNumberOfWorkers int) ([]*StageListItem, error) {
+	index, err := newStagesIndex(ctx, projectName, stagesStorage, maxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*StageListItem
+	for _, stageDesc := range index.Stages {
+		res = append(res, index.newStageListItem(stageDesc))
+	}
+
+	return res, nil
+}
+
+// InspectStage returns the stage labels, the chain of stages back to the base image and the git commit info of the specified stage
+func InspectStage(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, stageID image.StageID, maxNumberOfWorkers int) (*StageInspectInfo, error) {
+	index, err := newStagesIndex(ctx, projectName, stagesStorage, maxNumberOfWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	var stageDesc *image.StageDescription
+	for _, desc := range index.Stages {
+		if desc.StageID.String() == stageID.String() {
+			stageDesc = desc
+			break
+		}
+	}
+
+	if stageDesc == nil {
+		return nil, fmt.Errorf("stage %s not found in %s", stageID.String(), stagesStorage.String())
+	}
+
+	info := &StageInspectInfo{
+		StageListItem: *index.newStageListItem(stageDesc),
+		ImageName:     stageDesc.Info.Name,
+		ImageID:       stageDesc.Info.ID,
+		Labels:        stageDesc.Info.Labels,
+		GitCommits:    GetGitMappingCommitInfoFromLabels(stageDesc.Info.Labels),
+	}
+
+	for desc := stageDesc; desc != nil; {
+		info.Chain = append(info.Chain, StageChainItem{
+			StageID:   desc.StageID.String(),
+			ImageName: desc.Info.Name,
+			ImageID:   desc.Info.ID,
+		})
+
+		parentID := desc.Info.ParentID
+		if desc = index.StageByImageID[parentID]; desc == nil && parentID != "" {
+			info.Chain = append(info.Chain, StageChainItem{ImageID: parentID})
+		}
+	}
+
+	return info, nil
+}
+
+// GetGitMappingCommitInfoFromLabels parses labels set by GitMapping.AddGitCommitToImageLabels, one record per git mapping
+func GetGitMappingCommitInfoFromLabels(labels map[string]string) []GitMappingCommitInfo {
+	infoByParamsHash := make(map[string]*GitMappingCommitInfo)
+	var paramsHashList []string
+
+	for label, value := range labels {
+		match := gitMappingLabelRegexp.FindStringSubmatch(label)
+		if match == nil {
+			continue
+		}
+
+		paramsHash := match[1]
+		info, hasKey := infoByParamsHash[paramsHash]
+		if !hasKey {
+			info = &GitMappingCommitInfo{ParamsHash: paramsHash}
+			infoByParamsHash[paramsHash] = info
+			paramsHashList = append(paramsHashList, paramsHash)
+		}
+
+		switch match[2] {
+		case "commit":
+			info.Commit = value
+		case "virtual-merge":
+			info.VirtualMerge = value == "true"
+		case "virtual-merge-from-commit":
+			info.VirtualMergeFromCommit = value
+		case "virtual-merge-into-commit":
+			info.VirtualMergeIntoCommit = value
+		}
+	}
+
+	sort.Strings(paramsHashList)
+
+	var res []GitMappingCommitInfo
+	for _, paramsHash := range paramsHashList {
+		res = append(res, *infoByParamsHash[paramsHash])
+	}
+
+	return res
+}
+
+// ParseStageID parses stage ID in the DIGEST-UNIQUEID format
+func ParseStageID(stageID string) (image.StageID, error) {
+	parts := strings.Split(stageID, "-")
+	if len(parts) != 2 || parts[0] == "" {
+		return image.StageID{}, fmt.Errorf("bad stage ID %q: expected DIGEST-UNIQUEID", stageID)
+	}
+
+	uniqueID, err := image.ParseUniqueIDAsTimestamp(parts[1])
+	if err != nil {
+		return image.StageID{}, fmt.Errorf("bad stage ID %q: unable to parse unique ID: %s", stageID, err)
+	}
+
+	return image.StageID{Digest: parts[0], UniqueID: uniqueID}, nil
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestGetGitMappingCommitInfoFromLabels(t *testing.T) {
+	labels := map[string]string{
+		image.WerfLabel:                                  "project",
+		"werf-git-0a1b-commit":                           "commit-1",
+		"werf-git-0a1b-virtual-merge":                    "false",
+		"werf-git-ff00-commit":                           "commit-2",
+		"werf-git-ff00-virtual-merge":                    "true",
+		"werf-git-ff00-virtual-merge-from-commit":        "commit-3",
+		"werf-git-ff00-virtual-merge-into-commit":        "commit-4",
+		image.WerfImportLabelPrefix + "0123456789abcdef": "sha256:0123",
+	}
+
+	expected := []GitMappingCommitInfo{
+		{ParamsHash: "0a1b", Commit: "commit-1"},
+		{ParamsHash: "ff00", Commit: "commit-2", VirtualMerge: true, VirtualMergeFromCommit: "commit-3", VirtualMergeIntoCommit: "commit-4"},
+	}
+
+	if res := GetGitMappingCommitInfoFromLabels(labels); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected %+v, got %+v", expected, res)
+	}
+}
+
+func TestParseStageID(t *testing.T) {
+	stageID, err := ParseStageID("0f1e2d-1601234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (image.StageID{Digest: "0f1e2d", UniqueID: 1601234567890}); stageID != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stageID)
+	}
+
+	for _, bad := range []string{"0f1e2d", "0f1e2d-abc", "-1601234567890", "a-b-c"} {
+		if _, err := ParseStageID(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/trace"
 	"github.com/werf/werf/pkg/util/parallel"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -44,6 +45,11 @@ type StagesStorageManager struct {
 	StorageLockManager storage.LockManager
 	StagesStorage      storage.StagesStorage
 	StagesStorageCache storage.StagesStorageCache
+
+	// CacheStagesStorageList contains read-only stages storages which are consulted when the StagesStorage has no stages by digest,
+	// selected suitable stage is copied into the StagesStorage using the ContainerRuntime
+	CacheStagesStorageList []storage.StagesStorage
+	ContainerRuntime       container_runtime.ContainerRuntime
 }
 
 func newStagesStorageManager(projectName string, storageLockManager storage.LockManager, stagesStorageCache storage.StagesStorageCache) *StagesStorageManager {
@@ -143,6 +149,11 @@ func (m *StagesStorageManager) UseStagesStorage(ctx context.Context, stagesStora
 	return nil
 }
 
+func (m *StagesStorageManager) UseCacheStagesStorageList(cacheStagesStorageList []storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) {
+	m.CacheStagesStorageList = cacheStagesStorageList
+	m.ContainerRuntime = containerRuntime
+}
+
 func (m *StagesStorageManager) GetStageDescriptionList(ctx context.Context) ([]*image.StageDescription, error) {
 	stageIDs, err := m.StagesStorage.GetStagesIDs(ctx, m.ProjectName)
 	if err != nil {
@@ -233,11 +244,20 @@ func (m *StagesStorageManager) FetchStage(ctx context.Context, stg stage.Interfa
 	return nil
 }
 
+// SelectSuitableStage selects suitable stage from the stages by digest,
+// when there are no such stages in the stages storage the suitable stage is selected from the cache stages storages.
 func (m *StagesStorageManager) SelectSuitableStage(ctx context.Context, c stage.Conveyor, stg stage.Interface, stages []*image.StageDescription) (*image.StageDescription, error) {
 	if len(stages) == 0 {
+		if len(m.CacheStagesStorageList) != 0 {
+			return m.selectSuitableStageFromCacheStagesStorageList(ctx, c, stg)
+		}
 		return nil, nil
 	}
 
+	return selectSuitableStage(ctx, c, stg, stages)
+}
+
+func selectSuitableStage(ctx context.Context, c stage.Conveyor, stg stage.Interface, stages []*image.StageDescription) (*image.StageDescription, error) {
 	var stageDesc *image.StageDescription
 	if err := logboek.Context(ctx).Info().LogProcess("Selecting suitable image for stage %s by digest %s", stg.Name(), stg.GetDigest()).
 		DoError(func() error {
@@ -283,7 +303,14 @@ func (m *StagesStorageManager) AtomicStoreStagesByDigestToCache(ctx context.Cont
 		})
 }
 
-func (m *StagesStorageManager) GetStagesByDigest(ctx context.Context, stageName, stageSig string) ([]*image.StageDescription, error) {
+func (m *StagesStorageManager) GetStagesByDigest(ctx context.Context, stageName, stageSig string) (_ []*image.StageDescription, err error) {
+	span := trace.StartSpan(ctx, trace.RegistryCategory, fmt.Sprintf("get stages %s by digest", stageName), "digest", stageSig)
+	defer func() { span.EndWithError(err) }()
+
+	return m.getStagesByDigest(ctx, stageName, stageSig)
+}
+
+func (m *StagesStorageManager) getStagesByDigest(ctx context.Context, stageName, stageSig string) ([]*image.StageDescription, error) {
 	cacheExists, cacheStages, err := m.getStagesByDigestFromCache(ctx, stageName, stageSig)
 	if err != nil {
 		return nil, err
@@ -299,6 +326,89 @@ func (m *StagesStorageManager) GetStagesByDigest(ctx context.Context, stageName,
 	return m.atomicGetStagesByDigestWithCacheReset(ctx, stageName, stageSig)
 }
 
+// selectSuitableStageFromCacheStagesStorageList selects suitable stage by digest from the first cache stages storage which has it
+// and copies only the selected stage into the stages storage.
+// Cache stages storages are optional, so errors of these storages are only reported as warnings.
+func (m *StagesStorageManager) selectSuitableStageFromCacheStagesStorageList(ctx context.Context, c stage.Conveyor, stg stage.Interface) (_ *image.StageDescription, err error) {
+	span := trace.StartSpan(ctx, trace.RegistryCategory, fmt.Sprintf("select stage %s from cache repo", stg.LogDetailedName()), "digest", stg.GetDigest())
+	defer func() { span.EndWithError(err) }()
+
+	for _, cacheStagesStorage := range m.CacheStagesStorageList {
+		cacheStages, err := m.getStagesByDigestFromCacheStagesStorage(ctx, cacheStagesStorage, stg)
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to get stage %s images by digest %s from cache repo %s: %s\n", stg.LogDetailedName(), stg.GetDigest(), cacheStagesStorage.String(), err)
+			continue
+		}
+
+		if len(cacheStages) == 0 {
+			continue
+		}
+
+		cacheStageDesc, err := selectSuitableStage(ctx, c, stg, cacheStages)
+		if err != nil {
+			return nil, err
+		} else if cacheStageDesc == nil {
+			continue
+		}
+
+		if err := m.atomicCopyStageFromCacheStagesStorage(ctx, cacheStagesStorage, stg.LogDetailedName(), *cacheStageDesc.StageID); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to copy stage %s image %s from cache repo %s: %s\n", stg.LogDetailedName(), cacheStageDesc.StageID.String(), cacheStagesStorage.String(), err)
+			continue
+		}
+
+		stages, err := m.atomicGetStagesByDigestWithCacheReset(ctx, stg.LogDetailedName(), stg.GetDigest())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, stageDesc := range stages {
+			if *stageDesc.StageID == *cacheStageDesc.StageID {
+				return stageDesc, nil
+			}
+		}
+
+		return nil, fmt.Errorf("stage %s image %s copied from cache repo %s not found in the %s", stg.LogDetailedName(), cacheStageDesc.StageID.String(), cacheStagesStorage.String(), m.StagesStorage.String())
+	}
+
+	return nil, nil
+}
+
+func (m *StagesStorageManager) getStagesByDigestFromCacheStagesStorage(ctx context.Context, cacheStagesStorage storage.StagesStorage, stg stage.Interface) ([]*image.StageDescription, error) {
+	stageIDs, err := cacheStagesStorage.GetStagesIDsByDigest(ctx, m.ProjectName, stg.GetDigest())
+	if err != nil {
+		return nil, err
+	}
+
+	var stages []*image.StageDescription
+	for _, stageID := range stageIDs {
+		if stageDesc, err := cacheStagesStorage.GetStageDescription(ctx, m.ProjectName, stageID.Digest, stageID.UniqueID); err != nil {
+			return nil, fmt.Errorf("error getting stage %s description: %s", stageID.String(), err)
+		} else if stageDesc == nil {
+			logboek.Context(ctx).Warn().LogF("Ignoring stage %s: cannot get stage description from %s\n", stageID.String(), cacheStagesStorage.String())
+		} else {
+			stages = append(stages, stageDesc)
+		}
+	}
+
+	return stages, nil
+}
+
+func (m *StagesStorageManager) atomicCopyStageFromCacheStagesStorage(ctx context.Context, cacheStagesStorage storage.StagesStorage, stageName string, stageID image.StageID) error {
+	if lock, err := m.StorageLockManager.LockStageCache(ctx, m.ProjectName, stageID.Digest); err != nil {
+		return fmt.Errorf("error locking project %s stage %s cache: %s", m.ProjectName, stageID.Digest, err)
+	} else {
+		defer m.StorageLockManager.Unlock(ctx, lock)
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Copying stage %s image %s from cache repo %s", stageName, stageID.String(), cacheStagesStorage.String()).
+		Options(func(options types.LogProcessOptionsInterface) {
+			options.Style(style.Highlight())
+		}).
+		DoError(func() error {
+			return syncStage(ctx, m.ProjectName, stageID, cacheStagesStorage, m.StagesStorage, m.ContainerRuntime, SyncStagesOptions{})
+		})
+}
+
 func (m *StagesStorageManager) getStagesByDigestFromCache(ctx context.Context, stageName, stageSig string) (bool, []*image.StageDescription, error) {
 	var cacheExists bool
 	var cacheStagesIDs []image.StageID
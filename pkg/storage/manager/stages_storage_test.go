@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type fakeStagesStorage struct {
+	storage.StagesStorage
+
+	address string
+	stages  []*image.StageDescription
+	// storableStages are added to the stages when stored
+	storableStages []*image.StageDescription
+
+	fetchedImages []string
+	storedImages  []string
+}
+
+func (s *fakeStagesStorage) String() string  { return s.address }
+func (s *fakeStagesStorage) Address() string { return s.address }
+
+func (s *fakeStagesStorage) ConstructStageImageName(_, digest string, uniqueID int64) string {
+	return fmt.Sprintf("%s:%s-%d", s.address, digest, uniqueID)
+}
+
+// newStage returns stage description with the image name of the storage and the suitable label (see fakeStage)
+func (s *fakeStagesStorage) newStage(digest string, uniqueID int64, suitable bool) *image.StageDescription {
+	stageDesc := newTestStageDescription(digest, uniqueID, "", "", map[string]string{"suitable": fmt.Sprintf("%v", suitable)})
+	stageDesc.Info.Name = s.ConstructStageImageName("", digest, uniqueID)
+	return stageDesc
+}
+
+func (s *fakeStagesStorage) GetStagesIDsByDigest(_ context.Context, _, digest string) ([]image.StageID, error) {
+	var stageIDs []image.StageID
+	for _, stageDesc := range s.stages {
+		if stageDesc.StageID.Digest == digest {
+			stageIDs = append(stageIDs, *stageDesc.StageID)
+		}
+	}
+	return stageIDs, nil
+}
+
+func (s *fakeStagesStorage) GetStageDescription(_ context.Context, _, digest string, uniqueID int64) (*image.StageDescription, error) {
+	for _, stageDesc := range s.stages {
+		if stageDesc.StageID.Digest == digest && stageDesc.StageID.UniqueID == uniqueID {
+			return stageDesc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStagesStorage) FetchImage(_ context.Context, img container_runtime.Image) error {
+	s.fetchedImages = append(s.fetchedImages, img.(*container_runtime.DockerImage).Image.Name())
+	return nil
+}
+
+func (s *fakeStagesStorage) StoreImage(_ context.Context, img container_runtime.Image) error {
+	imageName := img.(*container_runtime.DockerImage).Image.Name()
+	s.storedImages = append(s.storedImages, imageName)
+	for _, stageDesc := range s.storableStages {
+		if stageDesc.Info.Name == imageName {
+			s.stages = append(s.stages, stageDesc)
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected image %s", imageName)
+}
+
+type fakeStageImageRuntime struct {
+	container_runtime.StageImageRuntime
+}
+
+func (runtime *fakeStageImageRuntime) String() string { return "fake" }
+
+func (runtime *fakeStageImageRuntime) RenameImage(_ context.Context, img container_runtime.Image, newImageName string, _ bool) error {
+	img.(*container_runtime.DockerImage).Image.SetName(newImageName)
+	return nil
+}
+
+// fakeStage selects the stage with the latest uniqueID among the stages with the suitable label
+type fakeStage struct {
+	stage.Interface
+
+	digest string
+}
+
+func (s *fakeStage) Name() stage.StageName   { return "install" }
+func (s *fakeStage) LogDetailedName() string { return "image/install" }
+func (s *fakeStage) GetDigest() string       { return s.digest }
+
+func (s *fakeStage) SelectSuitableStage(_ context.Context, _ stage.Conveyor, stages []*image.StageDescription) (*image.StageDescription, error) {
+	var suitableStage *image.StageDescription
+	for _, stageDesc := range stages {
+		if stageDesc.Info.Labels["suitable"] != "true" {
+			continue
+		}
+		if suitableStage == nil || stageDesc.StageID.UniqueID > suitableStage.StageID.UniqueID {
+			suitableStage = stageDesc
+		}
+	}
+	return suitableStage, nil
+}
+
+func TestSelectSuitableStageFromCacheStagesStorageList(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-stages-storage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := image.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	emptyCacheStagesStorage := &fakeStagesStorage{address: "empty-cache"}
+	unsuitableCacheStagesStorage := &fakeStagesStorage{address: "unsuitable-cache"}
+	unsuitableCacheStagesStorage.stages = []*image.StageDescription{unsuitableCacheStagesStorage.newStage("digest", 1, false)}
+	cacheStagesStorage := &fakeStagesStorage{address: "cache"}
+	cacheStagesStorage.stages = []*image.StageDescription{
+		cacheStagesStorage.newStage("digest", 1, true),
+		cacheStagesStorage.newStage("digest", 2, true),
+		cacheStagesStorage.newStage("digest", 3, false),
+	}
+	stagesStorage := &fakeStagesStorage{address: "repo"}
+	copiedStage := stagesStorage.newStage("digest", 2, true)
+	stagesStorage.storableStages = []*image.StageDescription{copiedStage}
+
+	m := newStagesStorageManager("project", storage.NewGenericLockManager(werf.GetHostLocker()), storage.NewFileStagesStorageCache(filepath.Join(tmpDir, "stages_storage_cache")))
+	m.StagesStorage = stagesStorage
+	m.UseCacheStagesStorageList([]storage.StagesStorage{emptyCacheStagesStorage, unsuitableCacheStagesStorage, cacheStagesStorage}, &fakeStageImageRuntime{})
+
+	stageDesc, err := m.SelectSuitableStage(context.Background(), nil, &fakeStage{digest: "digest"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stageDesc == nil || *stageDesc.StageID != *copiedStage.StageID || stageDesc.Info.Name != copiedStage.Info.Name {
+		t.Fatalf("expected copied stage %#v to be selected, got %#v", copiedStage, stageDesc)
+	}
+
+	if len(unsuitableCacheStagesStorage.fetchedImages) != 0 {
+		t.Errorf("expected no images to be fetched from the cache repo without suitable stages, got %v", unsuitableCacheStagesStorage.fetchedImages)
+	}
+	if len(cacheStagesStorage.fetchedImages) != 1 || cacheStagesStorage.fetchedImages[0] != "cache:digest-2" {
+		t.Errorf("expected only suitable stage cache:digest-2 to be fetched from the cache repo, got %v", cacheStagesStorage.fetchedImages)
+	}
+	if len(stagesStorage.storedImages) != 1 || stagesStorage.storedImages[0] != "repo:digest-2" {
+		t.Errorf("expected only suitable stage to be stored as repo:digest-2, got %v", stagesStorage.storedImages)
+	}
+}
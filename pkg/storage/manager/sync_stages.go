@@ -3,22 +3,39 @@ package manager
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/werf/logboek"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/util/parallel"
 )
 
 type SyncStagesOptions struct {
 	RemoveSource      bool
 	CleanupLocalCache bool
 	WithoutLock       bool
+
+	// DryRun only reports stages, managed images and images metadata which will be synced
+	DryRun bool
+	// ImageNameList limits synced stages to the stages of specified werf images (including parent and imported stages)
+	ImageNameList []string
+	// MaxAge limits synced stages to the stages created not earlier than MaxAge ago (including parent and imported stages regardless of their age),
+	// zero means no limit
+	MaxAge time.Duration
+	// MaxNumberOfWorkers limits parallel stages syncing, zero or negative value means no limit
+	MaxNumberOfWorkers int
 }
 
 // SyncStages will make sure, that destination stages storage contains all stages from source stages storage.
 // Repeatedly calling SyncStages will copy stages from source stages storage to destination, that already exists in the destination.
 // SyncStages will not delete excess stages from destination storage, that does not exists in the source.
+// Managed images and images metadata of synced stages are copied into the destination as well.
 func SyncStages(ctx context.Context, projectName string, fromStagesStorage storage.StagesStorage, toStagesStorage storage.StagesStorage, storageLockManager storage.LockManager, containerRuntime container_runtime.ContainerRuntime, opts SyncStagesOptions) error {
 	isOk := false
 	logProcess := logboek.Context(ctx).Default().LogProcess("Sync %q project stages", projectName)
@@ -31,7 +48,7 @@ func SyncStages(ctx context.Context, projectName string, fromStagesStorage stora
 		}
 	}()
 
-	if !opts.WithoutLock {
+	if !opts.WithoutLock && !opts.DryRun {
 		if lock, err := storageLockManager.LockStagesAndImages(ctx, projectName, storage.LockStagesAndImagesOptions{GetOrCreateImagesOnly: true}); err != nil {
 			return fmt.Errorf("unable to lock stages and images of project %q: %s", projectName, err)
 		} else {
@@ -73,25 +90,52 @@ func SyncStages(ctx context.Context, projectName string, fromStagesStorage stora
 		existingDestinationStages = stages
 	}
 
-	var stagesToSync []image.StageID
+	imageMetadataByImageName, err := getSourceImageMetadataByImageName(ctx, projectName, fromStagesStorage, opts.ImageNameList)
+	if err != nil {
+		return err
+	}
 
-	for _, sourceStageDesc := range existingSourceStages {
-		stageExistsInDestination := false
-		for _, destStageDesc := range existingDestinationStages {
-			if sourceStageDesc.Digest == destStageDesc.Digest && sourceStageDesc.UniqueID == destStageDesc.UniqueID {
-				stageExistsInDestination = true
-				break
-			}
+	if len(opts.ImageNameList) > 0 || opts.MaxAge != 0 {
+		if stages, err := selectStagesWithDependencies(ctx, projectName, fromStagesStorage, existingSourceStages, imageMetadataByImageName, opts); err != nil {
+			return err
+		} else {
+			existingSourceStages = stages
 		}
+	}
+
+	destinationStages := make(map[string]bool)
+	for _, stageID := range existingDestinationStages {
+		destinationStages[stageID.String()] = true
+	}
 
-		if !stageExistsInDestination || opts.RemoveSource {
+	var stagesToSync []image.StageID
+	for _, sourceStageDesc := range existingSourceStages {
+		if !destinationStages[sourceStageDesc.String()] || opts.RemoveSource {
 			stagesToSync = append(stagesToSync, sourceStageDesc)
 		}
 	}
 
 	logboek.Context(ctx).Default().LogFDetails("Stages to sync: %d\n", len(stagesToSync))
 
-	maxWorkers := 10
+	if opts.DryRun {
+		for _, stageID := range stagesToSync {
+			destinationStages[stageID.String()] = true
+		}
+
+		printSyncStagesDryRunReport(ctx, stagesToSync)
+
+		if err := syncImagesMetadata(ctx, projectName, toStagesStorage, imageMetadataByImageName, destinationStages, true); err != nil {
+			return err
+		}
+
+		isOk = true
+		return nil
+	}
+
+	maxWorkers := opts.MaxNumberOfWorkers
+	if maxWorkers <= 0 || maxWorkers > len(stagesToSync) {
+		maxWorkers = len(stagesToSync)
+	}
 	resultsChan := make(chan struct {
 		error
 		image.StageID
@@ -102,10 +146,12 @@ func SyncStages(ctx context.Context, projectName string, fromStagesStorage stora
 		go runSyncWorker(ctx, projectName, fromStagesStorage, toStagesStorage, containerRuntime, opts, w, jobsChan, resultsChan)
 	}
 
-	for _, stageDesc := range stagesToSync {
-		jobsChan <- stageDesc
-	}
-	close(jobsChan)
+	go func() {
+		for _, stageDesc := range stagesToSync {
+			jobsChan <- stageDesc
+		}
+		close(jobsChan)
+	}()
 
 	failedCounter := 0
 	succeededCounter := 0
@@ -118,10 +164,15 @@ func SyncStages(ctx context.Context, projectName string, fromStagesStorage stora
 			errors = append(errors, desc.error)
 		} else {
 			succeededCounter++
+			destinationStages[desc.StageID.String()] = true
 			logboek.Context(ctx).Default().LogF("%5d/%d synced\n", succeededCounter, len(stagesToSync))
 		}
 	}
 
+	if err := syncImagesMetadata(ctx, projectName, toStagesStorage, imageMetadataByImageName, destinationStages, false); err != nil {
+		errors = append(errors, err)
+	}
+
 	if len(errors) > 0 {
 		logboek.Context(ctx).Default().LogLn()
 		logboek.Context(ctx).Default().LogFHighlight("synced %d/%d, failed %d/%d\n", succeededCounter, len(stagesToSync), failedCounter, len(stagesToSync))
@@ -168,7 +219,12 @@ func syncStage(ctx context.Context, projectName string, stageID image.StageID, f
 	if destStageDesc, err := toStagesStorage.GetStageDescription(ctx, projectName, stageID.Digest, stageID.UniqueID); err != nil {
 		return fmt.Errorf("error getting stage %s description from %s: %s", stageID.String(), toStagesStorage.String(), err)
 	} else if destStageDesc == nil {
-		img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime.(*container_runtime.LocalDockerServerRuntime))
+		stageImageRuntime, ok := containerRuntime.(container_runtime.StageImageRuntime)
+		if !ok {
+			return fmt.Errorf("unable to sync stage %s: unsupported container runtime %s", stageID.String(), containerRuntime.String())
+		}
+
+		img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, stageImageRuntime)
 
 		logboek.Context(ctx).Info().LogF("Fetching %s\n", img.Name())
 		if err := fromStagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: img}); err != nil {
@@ -210,3 +266,211 @@ func syncStage(ctx context.Context, projectName string, stageID image.StageID, f
 
 	return nil
 }
+
+// getSourceImageMetadataByImageName returns map[imageName]map[stageID][]commit of managed and not managed images of the source stages storage,
+// result is limited by the imageNameList if specified
+func getSourceImageMetadataByImageName(ctx context.Context, projectName string, fromStagesStorage storage.StagesStorage, imageNameList []string) (map[string]map[string][]string, error) {
+	managedImages, err := fromStagesStorage.GetManagedImages(ctx, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get managed images from %s: %s", fromStagesStorage.String(), err)
+	}
+
+	imageMetadataByManagedImageName, imageMetadataByNotManagedImageName, err := fromStagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, projectName, managedImages)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get images metadata from %s: %s", fromStagesStorage.String(), err)
+	}
+
+	res := make(map[string]map[string][]string)
+	for _, imageMetadata := range []map[string]map[string][]string{imageMetadataByManagedImageName, imageMetadataByNotManagedImageName} {
+		for imageName, stageIDCommitList := range imageMetadata {
+			if len(imageNameList) > 0 && !util.IsStringsContainValue(imageNameList, imageName) {
+				continue
+			}
+			res[imageName] = stageIDCommitList
+		}
+	}
+
+	for _, imageName := range managedImages {
+		if len(imageNameList) > 0 && !util.IsStringsContainValue(imageNameList, imageName) {
+			continue
+		}
+		if _, hasKey := res[imageName]; !hasKey {
+			res[imageName] = map[string][]string{}
+		}
+	}
+
+	return res, nil
+}
+
+// getStagesDescriptions gets descriptions of the specified stages in parallel, stages without description are skipped
+func getStagesDescriptions(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, stages []image.StageID, maxNumberOfWorkers int) ([]*image.StageDescription, error) {
+	var mutex sync.Mutex
+	var stagesDescriptions []*image.StageDescription
+
+	if err := parallel.DoTasks(ctx, len(stages), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: maxNumberOfWorkers,
+	}, func(ctx context.Context, taskId int) error {
+		stageID := stages[taskId]
+
+		if stageDesc, err := stagesStorage.GetStageDescription(ctx, projectName, stageID.Digest, stageID.UniqueID); err != nil {
+			return fmt.Errorf("error getting stage %s description from %s: %s", stageID.String(), stagesStorage.String(), err)
+		} else if stageDesc != nil {
+			mutex.Lock()
+			defer mutex.Unlock()
+			stagesDescriptions = append(stagesDescriptions, stageDesc)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return stagesDescriptions, nil
+}
+
+// selectStagesWithDependencies selects stages created not earlier than opts.MaxAge ago (all stages when not limited),
+// only stages referenced by the images metadata are selected when opts.ImageNameList is specified.
+// Max age is applied after the parent and imported stages chains are computed: dependencies of the selected stages are always selected
+// regardless of their age, so that synced images are complete.
+func selectStagesWithDependencies(ctx context.Context, projectName string, fromStagesStorage storage.StagesStorage, stages []image.StageID, imageMetadataByImageName map[string]map[string][]string, opts SyncStagesOptions) ([]image.StageID, error) {
+	var stagesDescriptions []*image.StageDescription
+	if err := logboek.Context(ctx).Default().LogProcess("Getting stages descriptions from source stages storage %s", fromStagesStorage.String()).DoError(func() error {
+		var err error
+		stagesDescriptions, err = getStagesDescriptions(ctx, projectName, fromStagesStorage, stages, opts.MaxNumberOfWorkers)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	stageByStageID := make(map[string]*image.StageDescription)
+	stageByImageID := make(map[string]*image.StageDescription)
+	for _, stageDesc := range stagesDescriptions {
+		stageByStageID[stageDesc.StageID.String()] = stageDesc
+		stageByImageID[stageDesc.Info.ID] = stageDesc
+	}
+
+	selected := make(map[string]bool)
+
+	var selectStageFunc func(stageDesc *image.StageDescription)
+	selectStageFunc = func(stageDesc *image.StageDescription) {
+		for stageDesc != nil && !selected[stageDesc.StageID.String()] {
+			selected[stageDesc.StageID.String()] = true
+
+			for label, imageID := range stageDesc.Info.Labels {
+				if strings.HasPrefix(label, image.WerfImportLabelPrefix) {
+					selectStageFunc(stageByImageID[imageID])
+				}
+			}
+
+			stageDesc = stageByImageID[stageDesc.Info.ParentID]
+		}
+	}
+
+	createdAfter := time.Now().Add(-opts.MaxAge)
+	isStageSuitableByAge := func(stageDesc *image.StageDescription) bool {
+		return stageDesc != nil && (opts.MaxAge == 0 || stageDesc.StageID.UniqueIDAsTime().After(createdAfter))
+	}
+
+	if len(opts.ImageNameList) > 0 {
+		for _, stageIDCommitList := range imageMetadataByImageName {
+			for stageID := range stageIDCommitList {
+				if stageDesc := stageByStageID[stageID]; isStageSuitableByAge(stageDesc) {
+					selectStageFunc(stageDesc)
+				}
+			}
+		}
+	} else {
+		for _, stageDesc := range stagesDescriptions {
+			if isStageSuitableByAge(stageDesc) {
+				selectStageFunc(stageDesc)
+			}
+		}
+	}
+
+	var res []image.StageID
+	for _, stageID := range stages {
+		if selected[stageID.String()] {
+			res = append(res, stageID)
+		}
+	}
+
+	return res, nil
+}
+
+func syncImagesMetadata(ctx context.Context, projectName string, toStagesStorage storage.StagesStorage, imageMetadataByImageName map[string]map[string][]string, destinationStages map[string]bool, dryRun bool) error {
+	managedImages, err := toStagesStorage.GetManagedImages(ctx, projectName)
+	if err != nil {
+		return fmt.Errorf("unable to get managed images from %s: %s", toStagesStorage.String(), err)
+	}
+
+	var imageNames []string
+	for imageName := range imageMetadataByImageName {
+		imageNames = append(imageNames, imageName)
+	}
+	sort.Strings(imageNames)
+
+	var managedImagesToAdd []string
+	var imageMetadataCounter int
+
+	for _, imageName := range imageNames {
+		if !util.IsStringsContainValue(managedImages, imageName) {
+			managedImagesToAdd = append(managedImagesToAdd, imageName)
+
+			if !dryRun {
+				if err := toStagesStorage.AddManagedImage(ctx, projectName, imageName); err != nil {
+					return fmt.Errorf("unable to add managed image %q into %s: %s", imageName, toStagesStorage.String(), err)
+				}
+			}
+		}
+
+		for stageID, commitList := range imageMetadataByImageName[imageName] {
+			if !destinationStages[stageID] {
+				continue
+			}
+
+			for _, commit := range commitList {
+				if exists, err := toStagesStorage.IsImageMetadataExist(ctx, projectName, imageName, commit, stageID); err != nil {
+					return fmt.Errorf("unable to check image %q metadata existence in %s: %s", imageName, toStagesStorage.String(), err)
+				} else if exists {
+					continue
+				}
+
+				imageMetadataCounter++
+				logboek.Context(ctx).Info().LogF("Image %q commit %s stage %s metadata\n", logImageName(imageName), commit, stageID)
+
+				if !dryRun {
+					if err := toStagesStorage.PutImageMetadata(ctx, projectName, imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to put image %q metadata into %s: %s", imageName, toStagesStorage.String(), err)
+					}
+				}
+			}
+		}
+	}
+
+	for _, imageName := range managedImagesToAdd {
+		logboek.Context(ctx).Default().LogF("Managed image to sync: %s\n", logImageName(imageName))
+	}
+	logboek.Context(ctx).Default().LogFDetails("Managed images to sync: %d\n", len(managedImagesToAdd))
+	logboek.Context(ctx).Default().LogFDetails("Images metadata records to sync: %d\n", imageMetadataCounter)
+
+	return nil
+}
+
+func printSyncStagesDryRunReport(ctx context.Context, stagesToSync []image.StageID) {
+	sort.Slice(stagesToSync, func(i, j int) bool {
+		return stagesToSync[i].UniqueID < stagesToSync[j].UniqueID
+	})
+
+	logboek.Context(ctx).Default().LogOptionalLn()
+	logboek.Context(ctx).Default().LogFHighlight("Dry run: %d stages will be synced\n", len(stagesToSync))
+	for _, stageID := range stagesToSync {
+		logboek.Context(ctx).Default().LogF("%s  %s\n", stageID.String(), stageID.UniqueIDAsTime().Format(time.RFC3339))
+	}
+}
+
+func logImageName(imageName string) string {
+	if imageName == "" {
+		return "~"
+	}
+	return imageName
+}
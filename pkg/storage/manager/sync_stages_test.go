@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestSelectStagesWithDependencies(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-30*24*time.Hour).Unix() * 1000
+	recent := now.Add(-time.Hour).Unix() * 1000
+
+	oldBase := newTestStageDescription("base", old, "sha256:base", "", nil)
+	oldArtifact := newTestStageDescription("artifact", old, "sha256:artifact", "", nil)
+	recentInstall := newTestStageDescription("install", recent, "sha256:install", "sha256:base", map[string]string{image.WerfImportLabelPrefix + "artifact": "sha256:artifact"})
+	oldOther := newTestStageDescription("other", old, "sha256:other", "", nil)
+	recentOtherInstall := newTestStageDescription("otherinstall", recent, "sha256:otherinstall", "sha256:other", nil)
+
+	stagesStorage := &fakeStagesStorage{
+		address: "source",
+		stages:  []*image.StageDescription{oldBase, oldArtifact, recentInstall, oldOther, recentOtherInstall},
+	}
+
+	var stageIDs []image.StageID
+	for _, stageDesc := range stagesStorage.stages {
+		stageIDs = append(stageIDs, *stageDesc.StageID)
+	}
+
+	imageMetadataByImageName := map[string]map[string][]string{
+		"app":   {recentInstall.StageID.String(): {"commit"}},
+		"other": {oldOther.StageID.String(): {"commit"}},
+	}
+
+	for _, test := range []struct {
+		name           string
+		opts           SyncStagesOptions
+		expectedStages []*image.StageDescription
+	}{
+		{
+			name:           "max age keeps parent and imported stages of the recent stages",
+			opts:           SyncStagesOptions{MaxAge: 24 * time.Hour},
+			expectedStages: []*image.StageDescription{oldBase, oldArtifact, recentInstall, oldOther, recentOtherInstall},
+		},
+		{
+			name:           "max age is applied to the stages of the specified images",
+			opts:           SyncStagesOptions{MaxAge: 24 * time.Hour, ImageNameList: []string{"app", "other"}},
+			expectedStages: []*image.StageDescription{oldBase, oldArtifact, recentInstall},
+		},
+		{
+			name:           "stages of the specified images",
+			opts:           SyncStagesOptions{ImageNameList: []string{"app", "other"}},
+			expectedStages: []*image.StageDescription{oldBase, oldArtifact, recentInstall, oldOther},
+		},
+		{
+			name: "max age without recent stages",
+			opts: SyncStagesOptions{MaxAge: time.Minute},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stages, err := selectStagesWithDependencies(context.Background(), "project", stagesStorage, stageIDs, imageMetadataByImageName, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var selected, expected []string
+			for _, stageID := range stages {
+				selected = append(selected, stageID.Digest)
+			}
+			for _, stageDesc := range test.expectedStages {
+				expected = append(expected, stageDesc.StageID.Digest)
+			}
+			sort.Strings(selected)
+			sort.Strings(expected)
+
+			if !reflect.DeepEqual(selected, expected) {
+				t.Errorf("expected stages %v, got %v", expected, selected)
+			}
+		})
+	}
+}
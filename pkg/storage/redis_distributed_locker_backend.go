@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	"github.com/werf/lockgate/pkg/distributed_locker/optimistic_locking_store"
+)
+
+const redisOptimisticLockingStoreObservedValueTTL = time.Minute
+
+// NewRedisDistributedLockerBackend creates lockgate optimistic locking storage based backend,
+// which stores lock lease records in redis keys, so that any number of synchronization server replicas could share the same locks.
+func NewRedisDistributedLockerBackend(client *redis.Client, keyPrefix string) *distributed_locker.OptimisticLockingStorageBasedBackend {
+	return distributed_locker.NewOptimisticLockingStorageBasedBackend(NewRedisOptimisticLockingStore(client, keyPrefix))
+}
+
+func NewRedisOptimisticLockingStore(client *redis.Client, keyPrefix string) *RedisOptimisticLockingStore {
+	return &RedisOptimisticLockingStore{
+		Client:         client,
+		KeyPrefix:      keyPrefix,
+		observedValues: make(map[*optimistic_locking_store.Value]*redisObservedValue),
+	}
+}
+
+// RedisOptimisticLockingStore implements lockgate OptimisticLockingStore using redis keys.
+// PutValue stores the value only if the redis key still contains the data observed by the GetValue,
+// which is checked in the optimistic locking transaction (WATCH/MULTI/EXEC).
+type RedisOptimisticLockingStore struct {
+	Client    *redis.Client
+	KeyPrefix string
+
+	observedValues map[*optimistic_locking_store.Value]*redisObservedValue
+	mux            sync.Mutex
+}
+
+type redisObservedValue struct {
+	Data       string
+	ObservedAt time.Time
+}
+
+func (store *RedisOptimisticLockingStore) keyName(key string) string {
+	return fmt.Sprintf("%s:%s", store.KeyPrefix, key)
+}
+
+func (store *RedisOptimisticLockingStore) GetValue(key string) (*optimistic_locking_store.Value, error) {
+	keyName := store.keyName(key)
+
+	data, err := store.Client.Get(keyName).Result()
+	if err == redis.Nil {
+		data = ""
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get redis key %s: %s", keyName, err)
+	}
+
+	value := &optimistic_locking_store.Value{Data: data}
+	store.setObservedValue(value, data)
+
+	return value, nil
+}
+
+func (store *RedisOptimisticLockingStore) PutValue(key string, value *optimistic_locking_store.Value) error {
+	observedValue := store.popObservedValue(value)
+	if observedValue == nil {
+		return optimistic_locking_store.ErrRecordVersionChanged
+	}
+
+	keyName := store.keyName(key)
+
+	err := store.Client.Watch(func(tx *redis.Tx) error {
+		currentData, err := tx.Get(keyName).Result()
+		if err == redis.Nil {
+			currentData = ""
+		} else if err != nil {
+			return fmt.Errorf("unable to get redis key %s: %s", keyName, err)
+		}
+
+		if currentData != observedValue.Data {
+			return optimistic_locking_store.ErrRecordVersionChanged
+		}
+
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			if value.Data == "" {
+				pipe.Del(keyName)
+			} else {
+				pipe.Set(keyName, value.Data, 0)
+			}
+			return nil
+		})
+		return err
+	}, keyName)
+
+	if err == redis.TxFailedErr {
+		return optimistic_locking_store.ErrRecordVersionChanged
+	} else if err == optimistic_locking_store.ErrRecordVersionChanged {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to put redis key %s: %s", keyName, err)
+	}
+
+	return nil
+}
+
+// setObservedValue remembers the data of the value returned by the GetValue,
+// values which have not been put back (e.g. lock is busy) are forgotten after redisOptimisticLockingStoreObservedValueTTL.
+func (store *RedisOptimisticLockingStore) setObservedValue(value *optimistic_locking_store.Value, data string) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	now := time.Now()
+	for v, observedValue := range store.observedValues {
+		if now.Sub(observedValue.ObservedAt) > redisOptimisticLockingStoreObservedValueTTL {
+			delete(store.observedValues, v)
+		}
+	}
+
+	store.observedValues[value] = &redisObservedValue{Data: data, ObservedAt: now}
+}
+
+func (store *RedisOptimisticLockingStore) popObservedValue(value *optimistic_locking_store.Value) *redisObservedValue {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	observedValue := store.observedValues[value]
+	delete(store.observedValues, value)
+
+	return observedValue
+}
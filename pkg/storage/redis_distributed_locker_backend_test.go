@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	"github.com/werf/lockgate/pkg/distributed_locker/optimistic_locking_store"
+)
+
+func newMiniredisClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to run miniredis: %s", err)
+	}
+	t.Cleanup(server.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return server, client
+}
+
+func TestRedisOptimisticLockingStore(t *testing.T) {
+	server, client := newMiniredisClient(t)
+	store := NewRedisOptimisticLockingStore(client, "prefix")
+
+	value, err := store.GetValue("key")
+	if err != nil {
+		t.Fatalf("unexpected get error: %s", err)
+	}
+	if value.Data != "" {
+		t.Fatalf("expected empty value, got %q", value.Data)
+	}
+
+	value.Data = "data-1"
+	if err := store.PutValue("key", value); err != nil {
+		t.Fatalf("unexpected put error: %s", err)
+	}
+	if data, err := server.Get("prefix:key"); err != nil || data != "data-1" {
+		t.Fatalf("expected redis key prefix:key to contain %q, got %q (%v)", "data-1", data, err)
+	}
+
+	value, err = store.GetValue("key")
+	if err != nil {
+		t.Fatalf("unexpected get error: %s", err)
+	}
+	if value.Data != "data-1" {
+		t.Fatalf("expected value %q, got %q", "data-1", value.Data)
+	}
+
+	value.Data = ""
+	if err := store.PutValue("key", value); err != nil {
+		t.Fatalf("unexpected put error: %s", err)
+	}
+	if server.Exists("prefix:key") {
+		t.Fatalf("expected redis key prefix:key to be deleted")
+	}
+}
+
+func TestRedisOptimisticLockingStoreRecordVersionChanged(t *testing.T) {
+	server, client := newMiniredisClient(t)
+	store := NewRedisOptimisticLockingStore(client, "prefix")
+
+	value, err := store.GetValue("key")
+	if err != nil {
+		t.Fatalf("unexpected get error: %s", err)
+	}
+
+	if err := server.Set("prefix:key", "concurrent-data"); err != nil {
+		t.Fatalf("unable to set redis key: %s", err)
+	}
+
+	value.Data = "data"
+	if err := store.PutValue("key", value); !optimistic_locking_store.IsErrRecordVersionChanged(err) {
+		t.Fatalf("expected record version changed error, got %v", err)
+	}
+	if data, _ := server.Get("prefix:key"); data != "concurrent-data" {
+		t.Fatalf("expected concurrent data to be kept, got %q", data)
+	}
+
+	// value cannot be put twice without getting it again
+	if err := store.PutValue("key", value); !optimistic_locking_store.IsErrRecordVersionChanged(err) {
+		t.Fatalf("expected record version changed error, got %v", err)
+	}
+}
+
+func TestRedisDistributedLockerBackend(t *testing.T) {
+	server, client := newMiniredisClient(t)
+	backend := NewRedisDistributedLockerBackend(client, "prefix")
+
+	exclusiveHandle, err := backend.Acquire("exclusive", distributed_locker.AcquireOptions{})
+	if err != nil {
+		t.Fatalf("unexpected acquire error: %s", err)
+	}
+	if _, err := backend.Acquire("exclusive", distributed_locker.AcquireOptions{}); err != distributed_locker.ErrShouldWait {
+		t.Fatalf("expected %v, got %v", distributed_locker.ErrShouldWait, err)
+	}
+	if _, err := backend.Acquire("exclusive", distributed_locker.AcquireOptions{Shared: true}); err != distributed_locker.ErrShouldWait {
+		t.Fatalf("expected %v, got %v", distributed_locker.ErrShouldWait, err)
+	}
+	if err := backend.RenewLease(exclusiveHandle); err != nil {
+		t.Fatalf("unexpected renew lease error: %s", err)
+	}
+
+	sharedHandle1, err := backend.Acquire("shared", distributed_locker.AcquireOptions{Shared: true})
+	if err != nil {
+		t.Fatalf("unexpected acquire error: %s", err)
+	}
+	sharedHandle2, err := backend.Acquire("shared", distributed_locker.AcquireOptions{Shared: true})
+	if err != nil {
+		t.Fatalf("unexpected acquire error: %s", err)
+	}
+	if sharedHandle1.UUID != sharedHandle2.UUID {
+		t.Fatalf("expected shared lock handles to share the same lease, got %q and %q", sharedHandle1.UUID, sharedHandle2.UUID)
+	}
+	if _, err := backend.Acquire("shared", distributed_locker.AcquireOptions{}); err != distributed_locker.ErrShouldWait {
+		t.Fatalf("expected %v, got %v", distributed_locker.ErrShouldWait, err)
+	}
+
+	if err := backend.Release(exclusiveHandle); err != nil {
+		t.Fatalf("unexpected release error: %s", err)
+	}
+	if err := backend.Release(exclusiveHandle); err != distributed_locker.ErrNoExistingLockLeaseFound {
+		t.Fatalf("expected %v, got %v", distributed_locker.ErrNoExistingLockLeaseFound, err)
+	}
+
+	if err := backend.Release(sharedHandle1); err != nil {
+		t.Fatalf("unexpected release error: %s", err)
+	}
+	if keys := server.Keys(); len(keys) != 1 {
+		t.Fatalf("expected only shared lock lease to be kept in redis, got keys %v", keys)
+	}
+	if err := backend.Release(sharedHandle2); err != nil {
+		t.Fatalf("unexpected release error: %s", err)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("expected all lock leases to be deleted from redis, got keys %v", keys)
+	}
+}
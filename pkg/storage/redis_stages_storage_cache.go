@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func NewRedisStagesStorageCache(client *redis.Client, keyPrefix string) *RedisStagesStorageCache {
+	return &RedisStagesStorageCache{Client: client, KeyPrefix: keyPrefix}
+}
+
+// RedisStagesStorageCache keeps stages by digest of each project in the separate redis hash,
+// so that any number of synchronization server replicas could share the same cache.
+type RedisStagesStorageCache struct {
+	Client    *redis.Client
+	KeyPrefix string
+}
+
+func (cache *RedisStagesStorageCache) String() string {
+	return fmt.Sprintf("redis %s/%s", cache.Client.Options().Addr, cache.KeyPrefix)
+}
+
+func (cache *RedisStagesStorageCache) keyName(projectName string) string {
+	return fmt.Sprintf("%s:stages-storage-cache:%s", cache.KeyPrefix, projectName)
+}
+
+func (cache *RedisStagesStorageCache) GetAllStages(ctx context.Context, projectName string) (bool, []image.StageID, error) {
+	key := cache.keyName(projectName)
+
+	data, err := cache.Client.HGetAll(key).Result()
+	if err != nil {
+		return false, nil, fmt.Errorf("unable to get redis hash %s: %s", key, err)
+	}
+
+	if len(data) == 0 {
+		return false, nil, nil
+	}
+
+	var res []image.StageID
+	for digest, value := range data {
+		if stages, err := cache.unmarshalStages(ctx, key, digest, value); err != nil {
+			return false, nil, err
+		} else if stages == nil {
+			return false, nil, nil
+		} else {
+			res = append(res, stages...)
+		}
+	}
+
+	return true, res, nil
+}
+
+func (cache *RedisStagesStorageCache) DeleteAllStages(_ context.Context, projectName string) error {
+	key := cache.keyName(projectName)
+	if err := cache.Client.Del(key).Err(); err != nil {
+		return fmt.Errorf("unable to delete redis hash %s: %s", key, err)
+	}
+	return nil
+}
+
+func (cache *RedisStagesStorageCache) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	key := cache.keyName(projectName)
+
+	value, err := cache.Client.HGet(key, digest).Result()
+	if err == redis.Nil {
+		return false, nil, nil
+	} else if err != nil {
+		return false, nil, fmt.Errorf("unable to get field %s of redis hash %s: %s", digest, key, err)
+	}
+
+	if stages, err := cache.unmarshalStages(ctx, key, digest, value); err != nil {
+		return false, nil, err
+	} else if stages == nil {
+		return false, nil, nil
+	} else {
+		return true, stages, nil
+	}
+}
+
+func (cache *RedisStagesStorageCache) StoreStagesByDigest(_ context.Context, projectName, digest string, stages []image.StageID) error {
+	key := cache.keyName(projectName)
+
+	data, err := json.Marshal(&StagesStorageCacheRecord{Stages: stages})
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %s", err)
+	}
+
+	if err := cache.Client.HSet(key, digest, string(data)).Err(); err != nil {
+		return fmt.Errorf("unable to set field %s of redis hash %s: %s", digest, key, err)
+	}
+	return nil
+}
+
+func (cache *RedisStagesStorageCache) DeleteStagesByDigest(_ context.Context, projectName, digest string) error {
+	key := cache.keyName(projectName)
+	if err := cache.Client.HDel(key, digest).Err(); err != nil {
+		return fmt.Errorf("unable to delete field %s of redis hash %s: %s", digest, key, err)
+	}
+	return nil
+}
+
+func (cache *RedisStagesStorageCache) unmarshalStages(ctx context.Context, key, digest, value string) ([]image.StageID, error) {
+	var res *StagesStorageCacheRecord
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		logboek.Context(ctx).Error().LogF("Error unmarshalling stages storage cache json in redis hash %s by field %q: %s: will ignore cache\n", key, digest, err)
+		return nil, nil
+	}
+
+	if res == nil || res.Stages == nil {
+		return []image.StageID{}, nil
+	}
+	return res.Stages, nil
+}
@@ -25,6 +25,9 @@ const (
 	RepoImageMetadataByCommitRecord_ImageTagPrefix = "meta-"
 	RepoImageMetadataByCommitRecord_TagFormat      = "meta-%s_%s_%s"
 
+	RepoManifestList_ImageTagPrefix  = "manifest-list-"
+	RepoManifestList_ImageNameFormat = "%s:manifest-list-%s"
+
 	RepoClientIDRecrod_ImageTagPrefix  = "client-id-"
 	RepoClientIDRecrod_ImageNameFormat = "%s:client-id-%s-%d"
 
@@ -88,7 +91,7 @@ func (storage *RepoStagesStorage) GetStagesIDs(ctx context.Context, projectName
 		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetRepoImagesByDigest fetched tags for %q: %#v\n", storage.RepoAddress, tags)
 
 		for _, tag := range tags {
-			if strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) || strings.HasPrefix(tag, RepoImageMetadataByCommitRecord_ImageTagPrefix) {
+			if strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) || strings.HasPrefix(tag, RepoImageMetadataByCommitRecord_ImageTagPrefix) || strings.HasPrefix(tag, RepoManifestList_ImageTagPrefix) {
 				continue
 			}
 
@@ -247,6 +250,8 @@ func (storage *RepoStagesStorage) FetchImage(ctx context.Context, img container_
 	switch containerRuntime := storage.ContainerRuntime.(type) {
 	case *container_runtime.LocalDockerServerRuntime:
 		return containerRuntime.PullImageFromRegistry(ctx, img)
+	case *container_runtime.BuildahRuntime:
+		return containerRuntime.PullImageFromRegistry(ctx, img)
 	default:
 		// TODO: case *container_runtime.LocalHostRuntime:
 		panic("not implemented")
@@ -263,7 +268,14 @@ func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_
 		} else {
 			return containerRuntime.PushImage(ctx, img)
 		}
+	case *container_runtime.BuildahRuntime:
+		dockerImage := img.(*container_runtime.DockerImage)
 
+		if dockerImage.Image.GetBuiltId() != "" {
+			return containerRuntime.PushBuiltImage(ctx, img)
+		} else {
+			return containerRuntime.PushImage(ctx, img)
+		}
 	default:
 		// TODO: case *container_runtime.LocalHostRuntime:
 		panic("not implemented")
@@ -272,7 +284,7 @@ func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_
 
 func (storage *RepoStagesStorage) ShouldFetchImage(_ context.Context, img container_runtime.Image) (bool, error) {
 	switch storage.ContainerRuntime.(type) {
-	case *container_runtime.LocalDockerServerRuntime:
+	case *container_runtime.LocalDockerServerRuntime, *container_runtime.BuildahRuntime:
 		dockerImage := img.(*container_runtime.DockerImage)
 		return !dockerImage.Image.IsExistsLocally(), nil
 	default:
@@ -464,6 +476,77 @@ func validateImageName(name string) error {
 	return nil
 }
 
+func (storage *RepoStagesStorage) ConstructManifestListImageName(_ string, platformImages []*ManifestListPlatformImage) (string, error) {
+	var args []string
+	for _, platformImage := range platformImages {
+		args = append(args, platformImage.Platform, platformImage.StageDescription.Info.Tag)
+	}
+
+	return fmt.Sprintf(RepoManifestList_ImageNameFormat, storage.RepoAddress, util.Sha256Hash(args...)), nil
+}
+
+func (storage *RepoStagesStorage) StoreManifestList(ctx context.Context, projectName string, platformImages []*ManifestListPlatformImage) error {
+	fullImageName, err := storage.ConstructManifestListImageName(projectName, platformImages)
+	if err != nil {
+		return err
+	}
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.StoreManifestList full image name: %s\n", fullImageName)
+
+	var images []docker_registry.ManifestListImage
+	for _, platformImage := range platformImages {
+		images = append(images, docker_registry.ManifestListImage{
+			Reference: platformImage.StageDescription.Info.Name,
+			Platform:  platformImage.Platform,
+		})
+	}
+
+	if err := storage.DockerRegistry.PushManifestList(ctx, fullImageName, images); err != nil {
+		return fmt.Errorf("unable to push manifest list %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) GetManifestLists(ctx context.Context, projectName string) ([]*ManifestListDescription, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetManifestLists %s\n", projectName)
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var res []*ManifestListDescription
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoManifestList_ImageTagPrefix) {
+			continue
+		}
+
+		fullImageName := strings.Join([]string{storage.RepoAddress, tag}, ":")
+		manifestList, err := storage.DockerRegistry.GetRepoManifestList(ctx, fullImageName)
+		if err != nil {
+			if docker_registry.IsManifestUnknownError(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("unable to get manifest list %s: %s", fullImageName, err)
+		}
+
+		res = append(res, &ManifestListDescription{Info: manifestList.Info, PlatformImagesRepoDigests: manifestList.ImagesRepoDigests})
+	}
+
+	return res, nil
+}
+
+func (storage *RepoStagesStorage) DeleteManifestList(ctx context.Context, projectName string, manifestList *ManifestListDescription) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.DeleteManifestList %s %s\n", projectName, manifestList.Info.Name)
+
+	if err := storage.DockerRegistry.DeleteRepoImage(ctx, manifestList.Info); err != nil {
+		return fmt.Errorf("unable to delete manifest list %s: %s", manifestList.Info.Name, err)
+	}
+
+	return nil
+}
+
 func (storage *RepoStagesStorage) GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error) {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetClientIDRecords for project %s\n", projectName)
 
@@ -522,3 +605,20 @@ func (storage *RepoStagesStorage) PostClientIDRecord(ctx context.Context, projec
 
 	return nil
 }
+
+func (storage *RepoStagesStorage) RmClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmClientIDRecord %s for project %s\n", rec.ClientID, projectName)
+
+	fullImageName := fmt.Sprintf(RepoClientIDRecrod_ImageNameFormat, storage.RepoAddress, rec.ClientID, rec.TimestampMillisec)
+
+	if imgInfo, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to get repo image %q info: %s", fullImageName, err)
+	} else if imgInfo == nil {
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmClientIDRecord record %q does not exist => exiting\n", fullImageName)
+		return nil
+	} else if err := storage.DockerRegistry.DeleteRepoImage(ctx, imgInfo); err != nil {
+		return fmt.Errorf("unable to delete image %q from repo: %s", fullImageName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,638 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/golang/example/stringutil"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+)
+
+const (
+	S3StorageAddressPrefix = "s3://"
+
+	S3Stage_ImageFormat = "werf-stages-storage-s3/%s:%s-%d"
+
+	S3Stage_KeyPrefix               = "stages/"
+	S3Stage_ArchiveKeyFormat        = "stages/%s-%d.tar"
+	S3Stage_DescriptionKeyFormat    = "stages/%s-%d.json"
+	S3Stage_DescriptionKeySuffix    = ".json"
+	S3ManagedImageRecord_KeyPrefix  = "managed-images/"
+	S3ImageMetadataRecord_KeyPrefix = "meta/"
+	S3ImageMetadataRecord_KeyFormat = "meta/%s_%s_%s"
+	S3ClientIDRecord_KeyPrefix      = "client-id/"
+	S3ClientIDRecord_KeyFormat      = "client-id/%s-%d"
+)
+
+var ErrBadS3StorageAddress = errors.New("bad s3 storage address")
+
+func IsS3StorageAddress(address string) bool {
+	return strings.HasPrefix(address, S3StorageAddressPrefix)
+}
+
+// ParseS3StorageAddress parses s3://BUCKET[/PREFIX] address and returns bucket and prefix
+func ParseS3StorageAddress(address string) (string, string, error) {
+	if !IsS3StorageAddress(address) {
+		return "", "", ErrBadS3StorageAddress
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(address, S3StorageAddressPrefix), "/", 2)
+	if parts[0] == "" {
+		return "", "", ErrBadS3StorageAddress
+	}
+
+	var prefix string
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+
+	return parts[0], prefix, nil
+}
+
+type S3StagesStorage struct {
+	StorageAddress string
+	Bucket         string
+	Prefix         string
+
+	S3Client         s3iface.S3API
+	Uploader         s3manageriface.UploaderAPI
+	ContainerRuntime container_runtime.ContainerRuntime
+}
+
+type S3StagesStorageOptions struct {
+	// Endpoint of the S3-compatible service (e.g. http://localhost:9000 for MinIO), AWS S3 is used by default
+	Endpoint string
+	// Region of the bucket, default region from the AWS shared config or $AWS_REGION is used when not specified
+	Region string
+}
+
+func NewS3StagesStorage(address string, containerRuntime container_runtime.ContainerRuntime, options S3StagesStorageOptions) (*S3StagesStorage, error) {
+	bucket, prefix, err := ParseS3StorageAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse s3 storage address %q: %s", address, err)
+	}
+
+	config := aws.NewConfig()
+	if options.Region != "" {
+		config = config.WithRegion(options.Region)
+	}
+	if options.Endpoint != "" {
+		// S3-compatible services such as MinIO do not support virtual-hosted-style requests
+		config = config.WithEndpoint(options.Endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create s3 session for %q: %s", address, err)
+	}
+
+	return &S3StagesStorage{
+		StorageAddress:   address,
+		Bucket:           bucket,
+		Prefix:           prefix,
+		S3Client:         s3.New(sess),
+		Uploader:         s3manager.NewUploader(sess),
+		ContainerRuntime: containerRuntime,
+	}, nil
+}
+
+func (storage *S3StagesStorage) projectKey(projectName, key string) string {
+	return path.Join(storage.Prefix, projectName, key)
+}
+
+func (storage *S3StagesStorage) ConstructStageImageName(projectName, digest string, uniqueID int64) string {
+	return fmt.Sprintf(S3Stage_ImageFormat, projectName, digest, uniqueID)
+}
+
+func (storage *S3StagesStorage) GetStagesIDs(ctx context.Context, projectName string) ([]image.StageID, error) {
+	return storage.getStagesIDsByKeyPrefix(ctx, projectName, S3Stage_KeyPrefix)
+}
+
+func (storage *S3StagesStorage) GetStagesIDsByDigest(ctx context.Context, projectName, digest string) ([]image.StageID, error) {
+	return storage.getStagesIDsByKeyPrefix(ctx, projectName, fmt.Sprintf("%s%s-", S3Stage_KeyPrefix, digest))
+}
+
+func (storage *S3StagesStorage) getStagesIDsByKeyPrefix(ctx context.Context, projectName, keyPrefix string) ([]image.StageID, error) {
+	var res []image.StageID
+
+	names, err := storage.listNames(ctx, projectName, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		// Description is uploaded after the stage archive, so only completely stored stages are selected
+		if !strings.HasSuffix(name, S3Stage_DescriptionKeySuffix) {
+			continue
+		}
+
+		tag := strings.TrimSuffix(strings.TrimPrefix(name, S3Stage_KeyPrefix), S3Stage_DescriptionKeySuffix)
+		if digest, uniqueID, err := getDigestAndUniqueIDFromRepoStageImageTag(tag); err != nil {
+			if isUnexpectedTagFormatError(err) {
+				logboek.Context(ctx).Debug().LogLn(err.Error())
+				continue
+			}
+			return nil, err
+		} else {
+			res = append(res, image.StageID{Digest: digest, UniqueID: uniqueID})
+		}
+	}
+
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.getStagesIDsByKeyPrefix %q result: %#v\n", keyPrefix, res)
+
+	return res, nil
+}
+
+func (storage *S3StagesStorage) GetStageDescription(ctx context.Context, projectName, digest string, uniqueID int64) (*image.StageDescription, error) {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.GetStageDescription %s %s %d\n", projectName, digest, uniqueID)
+
+	data, err := storage.getObject(ctx, storage.projectKey(projectName, fmt.Sprintf(S3Stage_DescriptionKeyFormat, digest, uniqueID)))
+	if err != nil {
+		return nil, err
+	} else if data == nil {
+		return nil, nil
+	}
+
+	info := &image.Info{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal stage %s-%d description: %s", digest, uniqueID, err)
+	}
+
+	return &image.StageDescription{
+		StageID: &image.StageID{Digest: digest, UniqueID: uniqueID},
+		Info:    info,
+	}, nil
+}
+
+func (storage *S3StagesStorage) DeleteStage(ctx context.Context, stageDescription *image.StageDescription, _ DeleteImageOptions) error {
+	projectName := storage.getProjectNameByImageName(stageDescription.Info.Name)
+
+	for _, keyFormat := range []string{S3Stage_DescriptionKeyFormat, S3Stage_ArchiveKeyFormat} {
+		key := storage.projectKey(projectName, fmt.Sprintf(keyFormat, stageDescription.StageID.Digest, stageDescription.StageID.UniqueID))
+		if err := storage.deleteObject(ctx, key); err != nil {
+			return fmt.Errorf("unable to delete stage %s: %s", stageDescription.StageID.String(), err)
+		}
+	}
+
+	return nil
+}
+
+func (storage *S3StagesStorage) FilterStagesAndProcessRelatedData(_ context.Context, stageDescriptions []*image.StageDescription, _ FilterStagesAndProcessRelatedDataOptions) ([]*image.StageDescription, error) {
+	return stageDescriptions, nil
+}
+
+func (storage *S3StagesStorage) CreateRepo(ctx context.Context) error {
+	if _, err := storage.S3Client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(storage.Bucket)}); err == nil {
+		return nil
+	} else if !isS3NotFoundError(err) {
+		return fmt.Errorf("unable to check bucket %q: %s", storage.Bucket, err)
+	}
+
+	if _, err := storage.S3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(storage.Bucket)}); err != nil {
+		return fmt.Errorf("unable to create bucket %q: %s", storage.Bucket, err)
+	}
+
+	return nil
+}
+
+// DeleteRepo deletes werf objects of all projects stored by the address prefix.
+// The bucket itself and objects which do not match werf keys layout (PREFIX/PROJECT/{stages,managed-images,meta,client-id}/...) are kept,
+// so other data of the bucket is not deleted even if the address has no prefix.
+func (storage *S3StagesStorage) DeleteRepo(ctx context.Context) error {
+	addressPrefix := storage.keyPrefix(storage.Prefix)
+
+	var keys []string
+	if err := storage.S3Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(storage.Bucket),
+		Prefix: aws.String(addressPrefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			key := aws.StringValue(obj.Key)
+			if isS3StagesStorageProjectKey(strings.TrimPrefix(key, addressPrefix)) {
+				keys = append(keys, key)
+			}
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list objects of %s: %s", storage.StorageAddress, err)
+	}
+
+	for _, key := range keys {
+		if err := storage.deleteObject(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isS3StagesStorageProjectKey checks that the key relative to the address prefix is PROJECT/KEY_PREFIX... key of werf
+func isS3StagesStorageProjectKey(key string) bool {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return false
+	}
+
+	for _, keyPrefix := range []string{S3Stage_KeyPrefix, S3ManagedImageRecord_KeyPrefix, S3ImageMetadataRecord_KeyPrefix, S3ClientIDRecord_KeyPrefix} {
+		if strings.HasPrefix(parts[1], keyPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (storage *S3StagesStorage) AddManagedImage(ctx context.Context, projectName, imageName string) error {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.AddManagedImage %s %s\n", projectName, imageName)
+
+	if validateImageName(imageName) != nil {
+		return nil
+	}
+
+	return storage.putObject(ctx, storage.projectKey(projectName, S3ManagedImageRecord_KeyPrefix+slugImageNameAsDockerImageTag(imageName)), nil)
+}
+
+func (storage *S3StagesStorage) RmManagedImage(ctx context.Context, projectName, imageName string) error {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.RmManagedImage %s %s\n", projectName, imageName)
+
+	return storage.deleteObject(ctx, storage.projectKey(projectName, S3ManagedImageRecord_KeyPrefix+slugImageNameAsDockerImageTag(imageName)))
+}
+
+func (storage *S3StagesStorage) GetManagedImages(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.GetManagedImages %s\n", projectName)
+
+	names, err := storage.listNames(ctx, projectName, S3ManagedImageRecord_KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, name := range names {
+		managedImageName := unslugDockerImageTagAsImageName(strings.TrimPrefix(name, S3ManagedImageRecord_KeyPrefix))
+
+		if validateImageName(managedImageName) != nil {
+			continue
+		}
+
+		res = append(res, managedImageName)
+	}
+
+	return res, nil
+}
+
+func (storage *S3StagesStorage) FetchImage(ctx context.Context, img container_runtime.Image) error {
+	switch containerRuntime := storage.ContainerRuntime.(type) {
+	case *container_runtime.LocalDockerServerRuntime:
+		dockerImage := img.(*container_runtime.DockerImage)
+		imageName := dockerImage.Image.Name()
+		projectName := storage.getProjectNameByImageName(imageName)
+
+		_, tag := image.ParseRepositoryAndTag(imageName)
+		digest, uniqueID, err := getDigestAndUniqueIDFromRepoStageImageTag(tag)
+		if err != nil {
+			return err
+		}
+
+		archive, err := storage.fetchStageArchive(ctx, projectName, digest, uniqueID)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		if err := docker.ImageLoad(ctx, archive); err != nil {
+			return fmt.Errorf("unable to load image %s: %s", imageName, err)
+		}
+
+		return containerRuntime.RefreshImageObject(ctx, img)
+	default:
+		return storage.unsupportedContainerRuntimeError()
+	}
+}
+
+func (storage *S3StagesStorage) StoreImage(ctx context.Context, img container_runtime.Image) error {
+	switch containerRuntime := storage.ContainerRuntime.(type) {
+	case *container_runtime.LocalDockerServerRuntime:
+		dockerImage := img.(*container_runtime.DockerImage)
+		imageName := dockerImage.Image.Name()
+		projectName := storage.getProjectNameByImageName(imageName)
+
+		if dockerImage.Image.GetBuiltId() != "" {
+			if err := containerRuntime.TagBuiltImageByName(ctx, img); err != nil {
+				return err
+			}
+		}
+
+		inspect, err := containerRuntime.GetImageInspect(ctx, imageName)
+		if err != nil {
+			return fmt.Errorf("unable to get image %s inspect: %s", imageName, err)
+		} else if inspect == nil {
+			return fmt.Errorf("image %s does not exist", imageName)
+		}
+
+		_, tag := image.ParseRepositoryAndTag(imageName)
+		digest, uniqueID, err := getDigestAndUniqueIDFromRepoStageImageTag(tag)
+		if err != nil {
+			return err
+		}
+
+		archive, err := docker.ImageSave(ctx, imageName)
+		if err != nil {
+			return fmt.Errorf("unable to save image %s: %s", imageName, err)
+		}
+		defer archive.Close()
+
+		return logboek.Context(ctx).Info().LogProcess("Uploading %s", imageName).DoError(func() error {
+			return storage.storeStageArchive(ctx, projectName, digest, uniqueID, archive, image.NewInfoFromInspect(imageName, inspect))
+		})
+	default:
+		return storage.unsupportedContainerRuntimeError()
+	}
+}
+
+// storeStageArchive uploads the stage archive and then the stage description, so the stage is listed only when completely stored
+func (storage *S3StagesStorage) storeStageArchive(ctx context.Context, projectName, digest string, uniqueID int64, archive io.Reader, info *image.Info) error {
+	archiveKey := storage.projectKey(projectName, fmt.Sprintf(S3Stage_ArchiveKeyFormat, digest, uniqueID))
+	if _, err := storage.Uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(archiveKey),
+		Body:   archive,
+	}); err != nil {
+		return fmt.Errorf("unable to upload object %q: %s", archiveKey, err)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("unable to marshal stage %s-%d description: %s", digest, uniqueID, err)
+	}
+
+	return storage.putObject(ctx, storage.projectKey(projectName, fmt.Sprintf(S3Stage_DescriptionKeyFormat, digest, uniqueID)), data)
+}
+
+func (storage *S3StagesStorage) fetchStageArchive(ctx context.Context, projectName, digest string, uniqueID int64) (io.ReadCloser, error) {
+	key := storage.projectKey(projectName, fmt.Sprintf(S3Stage_ArchiveKeyFormat, digest, uniqueID))
+
+	output, err := storage.S3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get object %q: %s", key, err)
+	}
+
+	return output.Body, nil
+}
+
+func (storage *S3StagesStorage) ShouldFetchImage(_ context.Context, img container_runtime.Image) (bool, error) {
+	switch storage.ContainerRuntime.(type) {
+	case *container_runtime.LocalDockerServerRuntime:
+		dockerImage := img.(*container_runtime.DockerImage)
+		return !dockerImage.Image.IsExistsLocally(), nil
+	default:
+		return false, storage.unsupportedContainerRuntimeError()
+	}
+}
+
+// unsupportedContainerRuntimeError is returned for container runtimes other than the docker server, stages are saved to and loaded from s3 through the docker server
+func (storage *S3StagesStorage) unsupportedContainerRuntimeError() error {
+	return fmt.Errorf("%s stages storage supports only docker server container runtime, got %s", storage.StorageAddress, storage.ContainerRuntime.String())
+}
+
+func (storage *S3StagesStorage) PutImageMetadata(ctx context.Context, projectName, imageName, commit, stageID string) error {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.PutImageMetadata %s %s %s %s\n", projectName, imageName, commit, stageID)
+
+	if err := storage.putObject(ctx, storage.projectKey(projectName, fmt.Sprintf(S3ImageMetadataRecord_KeyFormat, imageNameID(imageName), commit, stageID)), nil); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Info().LogF("Put image %s commit %s stage ID %s\n", imageName, commit, stageID)
+
+	return nil
+}
+
+func (storage *S3StagesStorage) RmImageMetadata(ctx context.Context, projectName, imageNameOrID, commit, stageID string) error {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.RmImageMetadata %s %s %s %s\n", projectName, imageNameOrID, commit, stageID)
+
+	for _, imageID := range []string{imageNameID(imageNameOrID), imageNameOrID} {
+		key := storage.projectKey(projectName, fmt.Sprintf(S3ImageMetadataRecord_KeyFormat, imageID, commit, stageID))
+
+		if exists, err := storage.isObjectExist(ctx, key); err != nil {
+			return err
+		} else if !exists {
+			continue
+		}
+
+		if err := storage.deleteObject(ctx, key); err != nil {
+			return err
+		}
+
+		logboek.Context(ctx).Info().LogF("Removed image %s commit %s stage ID %s\n", imageNameOrID, commit, stageID)
+		return nil
+	}
+
+	return nil
+}
+
+func (storage *S3StagesStorage) IsImageMetadataExist(ctx context.Context, projectName, imageName, commit, stageID string) (bool, error) {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.IsImageMetadataExist %s %s %s %s\n", projectName, imageName, commit, stageID)
+
+	return storage.isObjectExist(ctx, storage.projectKey(projectName, fmt.Sprintf(S3ImageMetadataRecord_KeyFormat, imageNameID(imageName), commit, stageID)))
+}
+
+func (storage *S3StagesStorage) GetAllAndGroupImageMetadataByImageName(ctx context.Context, projectName string, imageNameList []string) (map[string]map[string][]string, map[string]map[string][]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.GetAllAndGroupImageMetadataByImageName %s\n", projectName)
+
+	names, err := storage.listNames(ctx, projectName, S3ImageMetadataRecord_KeyPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groupImageMetadataTagsByImageName(ctx, imageNameList, names, S3ImageMetadataRecord_KeyPrefix)
+}
+
+func (storage *S3StagesStorage) ConstructManifestListImageName(_ string, _ []*ManifestListPlatformImage) (string, error) {
+	return "", fmt.Errorf("manifest lists are not supported by %s stages storage: docker registry should be specified with --repo", storage.String())
+}
+
+func (storage *S3StagesStorage) StoreManifestList(_ context.Context, _ string, _ []*ManifestListPlatformImage) error {
+	return fmt.Errorf("manifest lists are not supported by %s stages storage: docker registry should be specified with --repo", storage.String())
+}
+
+func (storage *S3StagesStorage) GetManifestLists(_ context.Context, _ string) ([]*ManifestListDescription, error) {
+	return nil, nil
+}
+
+func (storage *S3StagesStorage) DeleteManifestList(_ context.Context, _ string, _ *ManifestListDescription) error {
+	return fmt.Errorf("manifest lists are not supported by %s stages storage", storage.String())
+}
+
+func (storage *S3StagesStorage) GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.GetClientIDRecords for project %s\n", projectName)
+
+	names, err := storage.listNames(ctx, projectName, S3ClientIDRecord_KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*ClientIDRecord
+	for _, name := range names {
+		dataParts := strings.SplitN(stringutil.Reverse(strings.TrimPrefix(name, S3ClientIDRecord_KeyPrefix)), "-", 2)
+		if len(dataParts) != 2 {
+			continue
+		}
+
+		clientID, timestampMillisecStr := stringutil.Reverse(dataParts[1]), stringutil.Reverse(dataParts[0])
+
+		timestampMillisec, err := strconv.ParseInt(timestampMillisecStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		rec := &ClientIDRecord{ClientID: clientID, TimestampMillisec: timestampMillisec}
+		res = append(res, rec)
+
+		logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.GetClientIDRecords got clientID record: %s\n", rec)
+	}
+
+	return res, nil
+}
+
+func (storage *S3StagesStorage) PostClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.PostClientID %s for project %s\n", rec.ClientID, projectName)
+
+	if err := storage.putObject(ctx, storage.projectKey(projectName, fmt.Sprintf(S3ClientIDRecord_KeyFormat, rec.ClientID, rec.TimestampMillisec)), nil); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Info().LogF("Posted new clientID %q for project %s\n", rec.ClientID, projectName)
+
+	return nil
+}
+
+func (storage *S3StagesStorage) RmClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- S3StagesStorage.RmClientIDRecord %s for project %s\n", rec.ClientID, projectName)
+
+	return storage.deleteObject(ctx, storage.projectKey(projectName, fmt.Sprintf(S3ClientIDRecord_KeyFormat, rec.ClientID, rec.TimestampMillisec)))
+}
+
+func (storage *S3StagesStorage) String() string {
+	return storage.StorageAddress
+}
+
+func (storage *S3StagesStorage) Address() string {
+	return storage.StorageAddress
+}
+
+func (storage *S3StagesStorage) getProjectNameByImageName(imageName string) string {
+	repository, _ := image.ParseRepositoryAndTag(imageName)
+	return repository[strings.LastIndex(repository, "/")+1:]
+}
+
+func (storage *S3StagesStorage) keyPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+// listNames returns names of all project objects with the specified key prefix, names are relative to the project directory
+func (storage *S3StagesStorage) listNames(ctx context.Context, projectName, keyPrefix string) ([]string, error) {
+	projectPrefix := storage.keyPrefix(storage.projectKey(projectName, ""))
+
+	var res []string
+	if err := storage.S3Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(storage.Bucket),
+		Prefix: aws.String(projectPrefix + keyPrefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			res = append(res, strings.TrimPrefix(aws.StringValue(obj.Key), projectPrefix))
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list objects of %s by prefix %q: %s", storage.StorageAddress, projectPrefix+keyPrefix, err)
+	}
+
+	return res, nil
+}
+
+func (storage *S3StagesStorage) getObject(ctx context.Context, key string) ([]byte, error) {
+	output, err := storage.S3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(key),
+	})
+	if isS3NotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get object %q: %s", key, err)
+	}
+	defer output.Body.Close()
+
+	if data, err := ioutil.ReadAll(output.Body); err != nil {
+		return nil, fmt.Errorf("unable to read object %q: %s", key, err)
+	} else {
+		return data, nil
+	}
+}
+
+func (storage *S3StagesStorage) putObject(ctx context.Context, key string, data []byte) error {
+	if _, err := storage.S3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		return fmt.Errorf("unable to put object %q: %s", key, err)
+	}
+	return nil
+}
+
+func (storage *S3StagesStorage) deleteObject(ctx context.Context, key string) error {
+	if _, err := storage.S3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(key),
+	}); err != nil && !isS3NotFoundError(err) {
+		return fmt.Errorf("unable to delete object %q: %s", key, err)
+	}
+	return nil
+}
+
+func (storage *S3StagesStorage) isObjectExist(ctx context.Context, key string) (bool, error) {
+	if _, err := storage.S3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(key),
+	}); isS3NotFoundError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to check existence of object %q: %s", key, err)
+	}
+	return true, nil
+}
+
+func isS3NotFoundError(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() == http.StatusNotFound
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestParseS3StorageAddress(t *testing.T) {
+	for _, address := range []string{"registry.example.com/project", "s3://", "s3:///prefix"} {
+		if bucket, prefix, err := ParseS3StorageAddress(address); err != ErrBadS3StorageAddress {
+			t.Errorf("unexpected parse response for %q: bucket=%q prefix=%q err=%v", address, bucket, prefix, err)
+		}
+	}
+
+	checkS3StorageAddress(t, "s3://werf-stages", "werf-stages", "")
+	checkS3StorageAddress(t, "s3://werf-stages/", "werf-stages", "")
+	checkS3StorageAddress(t, "s3://werf-stages/ci/cache/", "werf-stages", "ci/cache")
+}
+
+func checkS3StorageAddress(t *testing.T, address, expectedBucket, expectedPrefix string) {
+	if bucket, prefix, err := ParseS3StorageAddress(address); err != nil {
+		t.Error(err)
+	} else {
+		if bucket != expectedBucket {
+			t.Errorf("expected bucket %#v, got %#v", expectedBucket, bucket)
+		}
+		if prefix != expectedPrefix {
+			t.Errorf("expected prefix %#v, got %#v", expectedPrefix, prefix)
+		}
+	}
+}
+
+type fakeS3Client struct {
+	s3iface.S3API
+	objects map[string][]byte
+}
+
+func newFakeS3Client(keys ...string) *fakeS3Client {
+	client := &fakeS3Client{objects: map[string][]byte{}}
+	for _, key := range keys {
+		client.objects[key] = nil
+	}
+	return client
+}
+
+func (client *fakeS3Client) keys() []string {
+	var keys []string
+	for key := range client.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (client *fakeS3Client) notFoundError(key string) error {
+	return awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("object %q not found", key), nil), http.StatusNotFound, "")
+}
+
+func (client *fakeS3Client) ListObjectsV2PagesWithContext(_ aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	page := &s3.ListObjectsV2Output{}
+	for _, key := range client.keys() {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+		}
+	}
+	fn(page, true)
+	return nil
+}
+
+func (client *fakeS3Client) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	data, ok := client.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, client.notFoundError(aws.StringValue(input.Key))
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (client *fakeS3Client) HeadObjectWithContext(_ aws.Context, input *s3.HeadObjectInput, _ ...request.Option) (*s3.HeadObjectOutput, error) {
+	if _, ok := client.objects[aws.StringValue(input.Key)]; !ok {
+		return nil, client.notFoundError(aws.StringValue(input.Key))
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (client *fakeS3Client) PutObjectWithContext(_ aws.Context, input *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	client.objects[aws.StringValue(input.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (client *fakeS3Client) DeleteObjectWithContext(_ aws.Context, input *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	delete(client.objects, aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+type fakeS3Uploader struct {
+	s3manageriface.UploaderAPI
+	client *fakeS3Client
+}
+
+func (uploader *fakeS3Uploader) UploadWithContext(_ aws.Context, input *s3manager.UploadInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	uploader.client.objects[aws.StringValue(input.Key)] = data
+	return &s3manager.UploadOutput{}, nil
+}
+
+func newFakeS3StagesStorage(address string, client *fakeS3Client) *S3StagesStorage {
+	bucket, prefix, err := ParseS3StorageAddress(address)
+	if err != nil {
+		panic(err)
+	}
+
+	return &S3StagesStorage{
+		StorageAddress:   address,
+		Bucket:           bucket,
+		Prefix:           prefix,
+		S3Client:         client,
+		Uploader:         &fakeS3Uploader{client: client},
+		ContainerRuntime: &container_runtime.BuildahRuntime{},
+	}
+}
+
+func TestS3StagesStorageStages(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeS3Client()
+	storage := newFakeS3StagesStorage("s3://werf-stages/ci", client)
+
+	for _, stageID := range []image.StageID{{Digest: "digesta", UniqueID: 1}, {Digest: "digesta", UniqueID: 2}, {Digest: "digestb", UniqueID: 3}} {
+		info := &image.Info{Name: storage.ConstructStageImageName("app", stageID.Digest, stageID.UniqueID), ID: "id-" + stageID.String()}
+		if err := storage.storeStageArchive(ctx, "app", stageID.Digest, stageID.UniqueID, strings.NewReader("archive-"+stageID.String()), info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// archive without description is not completely stored stage
+	client.objects["ci/app/stages/digesta-4.tar"] = nil
+
+	if _, ok := client.objects["ci/app/stages/digesta-1.tar"]; !ok {
+		t.Fatalf("expected stage archive in project dir of the address prefix, got %v", client.keys())
+	}
+
+	if stages, err := storage.GetStagesIDs(ctx, "app"); err != nil {
+		t.Fatal(err)
+	} else if len(stages) != 3 {
+		t.Fatalf("expected 3 stages, got %v", stages)
+	}
+
+	if stages, err := storage.GetStagesIDsByDigest(ctx, "app", "digesta"); err != nil {
+		t.Fatal(err)
+	} else if len(stages) != 2 || stages[0].UniqueID != 1 || stages[1].UniqueID != 2 {
+		t.Fatalf("unexpected stages by digest: %v", stages)
+	}
+
+	stageDesc, err := storage.GetStageDescription(ctx, "app", "digestb", 3)
+	if err != nil {
+		t.Fatal(err)
+	} else if stageDesc == nil || stageDesc.StageID.String() != "digestb-3" || stageDesc.Info.ID != "id-digestb-3" {
+		t.Fatalf("unexpected stage description: %+v", stageDesc)
+	}
+
+	archive, err := storage.fetchStageArchive(ctx, "app", "digestb", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(archive)
+	archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	} else if string(data) != "archive-digestb-3" {
+		t.Fatalf("unexpected stage archive %q", data)
+	}
+
+	if err := storage.DeleteStage(ctx, stageDesc, DeleteImageOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stageDesc, err := storage.GetStageDescription(ctx, "app", "digestb", 3); err != nil {
+		t.Fatal(err)
+	} else if stageDesc != nil {
+		t.Fatalf("expected stage to be deleted, got %+v", stageDesc)
+	}
+
+	if _, ok := client.objects["ci/app/stages/digestb-3.tar"]; ok {
+		t.Fatal("expected stage archive to be deleted")
+	}
+
+	if _, err := storage.fetchStageArchive(ctx, "app", "digestb", 3); err == nil {
+		t.Fatal("expected error fetching deleted stage")
+	}
+}
+
+func TestS3StagesStorageImageMetadata(t *testing.T) {
+	ctx := context.Background()
+	storage := newFakeS3StagesStorage("s3://werf-stages", newFakeS3Client())
+
+	for _, commit := range []string{"commit-1", "commit-2"} {
+		if err := storage.PutImageMetadata(ctx, "app", "backend", commit, "digesta-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.PutImageMetadata(ctx, "app", "removed", "commit-1", "digestb-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := storage.IsImageMetadataExist(ctx, "app", "backend", "commit-1", "digesta-1"); err != nil {
+		t.Fatal(err)
+	} else if !exists {
+		t.Fatal("expected image metadata to exist")
+	}
+
+	metadata, notManagedMetadata, err := storage.GetAllAndGroupImageMetadataByImageName(ctx, "app", []string{"backend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commits := metadata["backend"]["digesta-1"]; len(commits) != 2 || commits[0] != "commit-1" || commits[1] != "commit-2" {
+		t.Fatalf("unexpected image metadata: %v", metadata)
+	}
+
+	if commits := notManagedMetadata[imageNameID("removed")]["digestb-2"]; len(commits) != 1 || commits[0] != "commit-1" {
+		t.Fatalf("unexpected not managed image metadata: %v", notManagedMetadata)
+	}
+
+	if err := storage.RmImageMetadata(ctx, "app", "backend", "commit-1", "digesta-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// not managed image metadata is removed by image ID
+	if err := storage.RmImageMetadata(ctx, "app", imageNameID("removed"), "commit-1", "digestb-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, notManagedMetadata, err = storage.GetAllAndGroupImageMetadataByImageName(ctx, "app", []string{"backend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commits := metadata["backend"]["digesta-1"]; len(commits) != 1 || commits[0] != "commit-2" || len(notManagedMetadata) != 0 {
+		t.Fatalf("unexpected image metadata after removal: %v %v", metadata, notManagedMetadata)
+	}
+}
+
+func TestS3StagesStorageDeleteRepo(t *testing.T) {
+	ctx := context.Background()
+
+	werfKeys := []string{"app/stages/digesta-1.tar", "app/stages/digesta-1.json", "app/managed-images/backend", "app/meta/id_commit_digesta-1", "app/client-id/client-1"}
+	foreignKeys := []string{"backups/db.tar", "app/README.md", "app/stagesX", "stages/digesta-1.json"}
+
+	client := newFakeS3Client(append(append([]string{}, werfKeys...), foreignKeys...)...)
+	if err := newFakeS3StagesStorage("s3://werf-stages", client).DeleteRepo(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(foreignKeys)
+	if keys := client.keys(); strings.Join(keys, " ") != strings.Join(foreignKeys, " ") {
+		t.Fatalf("expected only foreign objects to be kept, got %v", keys)
+	}
+
+	client = newFakeS3Client("ci/app/stages/digesta-1.json", "ci-other/app/stages/digesta-1.json", "app/stages/digesta-1.json")
+	if err := newFakeS3StagesStorage("s3://werf-stages/ci", client).DeleteRepo(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := client.keys(); len(keys) != 2 || keys[0] != "app/stages/digesta-1.json" || keys[1] != "ci-other/app/stages/digesta-1.json" {
+		t.Fatalf("expected objects out of the address prefix to be kept, got %v", keys)
+	}
+}
+
+func TestS3StagesStorageUnsupportedContainerRuntime(t *testing.T) {
+	storage := newFakeS3StagesStorage("s3://werf-stages", newFakeS3Client())
+	img := &container_runtime.DockerImage{Image: container_runtime.NewStageImage(nil, storage.ConstructStageImageName("app", "digesta", 1), &container_runtime.BuildahRuntime{})}
+
+	if _, err := storage.ShouldFetchImage(context.Background(), img); err == nil {
+		t.Error("expected ShouldFetchImage error for buildah runtime")
+	}
+	if err := storage.FetchImage(context.Background(), img); err == nil {
+		t.Error("expected FetchImage error for buildah runtime")
+	}
+	if err := storage.StoreImage(context.Background(), img); err == nil {
+		t.Error("expected StoreImage error for buildah runtime")
+	}
+}
+
+func TestS3StagesStorageManifestLists(t *testing.T) {
+	storage := newFakeS3StagesStorage("s3://werf-stages", newFakeS3Client())
+
+	if _, err := storage.ConstructManifestListImageName("app", nil); err == nil {
+		t.Error("expected ConstructManifestListImageName error")
+	}
+
+	if manifestLists, err := storage.GetManifestLists(context.Background(), "app"); err != nil || len(manifestLists) != 0 {
+		t.Errorf("expected no manifest lists, got %v, %v", manifestLists, err)
+	}
+}
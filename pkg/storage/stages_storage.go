@@ -41,8 +41,15 @@ type StagesStorage interface {
 	IsImageMetadataExist(ctx context.Context, projectName, imageName, commit, stageID string) (bool, error)
 	GetAllAndGroupImageMetadataByImageName(ctx context.Context, projectName string, imageNameList []string) (map[string]map[string][]string, map[string]map[string][]string, error)
 
+	// ConstructManifestListImageName returns the name of the manifest list of the multi-platform image, the name depends on platform images only
+	ConstructManifestListImageName(projectName string, platformImages []*ManifestListPlatformImage) (string, error)
+	StoreManifestList(ctx context.Context, projectName string, platformImages []*ManifestListPlatformImage) error
+	GetManifestLists(ctx context.Context, projectName string) ([]*ManifestListDescription, error)
+	DeleteManifestList(ctx context.Context, projectName string, manifestList *ManifestListDescription) error
+
 	GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error)
 	PostClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error
+	RmClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error
 
 	String() string
 	Address() string
@@ -57,17 +64,45 @@ func (rec *ClientIDRecord) String() string {
 	return fmt.Sprintf("clientID:%s tsMillisec:%d", rec.ClientID, rec.TimestampMillisec)
 }
 
+// ManifestListPlatformImage is the last stage of the image built for the platform, which is referred by the manifest list of the multi-platform image
+type ManifestListPlatformImage struct {
+	Platform         string
+	StageDescription *image.StageDescription
+}
+
+// ManifestListDescription is the manifest list of the multi-platform image stored in the stages storage
+type ManifestListDescription struct {
+	Info *image.Info
+	// PlatformImagesRepoDigests are repo digests of the platform images referred by the manifest list
+	PlatformImagesRepoDigests []string
+}
+
+// IsManifestListSupported returns true if manifest lists of multi-platform images can be published into the stages storage
+func IsManifestListSupported(stagesStorageAddress string) bool {
+	return stagesStorageAddress != LocalStorageAddress && !IsS3StorageAddress(stagesStorageAddress)
+}
+
 type ImageMetadata struct {
 	ContentDigest string
 }
 
 type StagesStorageOptions struct {
 	RepoStagesStorageOptions
+	S3StagesStorageOptions
 }
 
 func NewStagesStorage(stagesStorageAddress string, containerRuntime container_runtime.ContainerRuntime, options StagesStorageOptions) (StagesStorage, error) {
 	if stagesStorageAddress == LocalStorageAddress {
-		return NewLocalDockerServerStagesStorage(containerRuntime.(*container_runtime.LocalDockerServerRuntime)), nil
+		localDockerServerRuntime, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime)
+		if !ok {
+			return nil, fmt.Errorf("%s stages storage is not supported by %s container runtime: docker registry should be specified with --repo", LocalStorageAddress, containerRuntime.String())
+		}
+		return NewLocalDockerServerStagesStorage(localDockerServerRuntime), nil
+	} else if IsS3StorageAddress(stagesStorageAddress) {
+		if _, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime); !ok {
+			return nil, fmt.Errorf("s3 stages storage is not supported by %s container runtime: docker registry should be specified with --repo", containerRuntime.String())
+		}
+		return NewS3StagesStorage(stagesStorageAddress, containerRuntime, options.S3StagesStorageOptions)
 	} else { // Docker registry based stages storage
 		return NewRepoStagesStorage(stagesStorageAddress, containerRuntime, options.RepoStagesStorageOptions)
 	}
@@ -0,0 +1,154 @@
+package synchronization_server
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/werf/logboek"
+)
+
+type SynchronizationServerOptions struct {
+	// Token enables bearer-token authentication of all requests except health checks
+	Token string
+
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile enables mutual TLS: only clients with certificates signed by this CA are accepted
+	TLSClientCAFile string
+}
+
+func (opts SynchronizationServerOptions) IsTLSEnabled() bool {
+	return opts.TLSCertFile != "" || opts.TLSKeyFile != ""
+}
+
+func (opts SynchronizationServerOptions) Validate() error {
+	if opts.IsTLSEnabled() && (opts.TLSCertFile == "" || opts.TLSKeyFile == "") {
+		return fmt.Errorf("both tls certificate and tls key should be specified")
+	}
+	if opts.TLSClientCAFile != "" && !opts.IsTLSEnabled() {
+		return fmt.Errorf("tls certificate and tls key should be specified to verify client certificates")
+	}
+	return nil
+}
+
+func (opts SynchronizationServerOptions) GetTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.TLSClientCAFile != "" {
+		if pool, err := loadCertPool(opts.TLSClientCAFile); err != nil {
+			return nil, err
+		} else {
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func NewBearerTokenAuthHandler(token string, handler http.Handler) *BearerTokenAuthHandler {
+	return &BearerTokenAuthHandler{Token: token, Handler: handler}
+}
+
+type BearerTokenAuthHandler struct {
+	Token   string
+	Handler http.Handler
+}
+
+func (h *BearerTokenAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		logboek.Debug().LogF("BearerTokenAuthHandler -- no bearer token in request %s %q\n", r.Method, r.URL.Path)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="werf-synchronization"`)
+		http.Error(w, "Unauthorized: bearer token required", http.StatusUnauthorized)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authHeader, "Bearer ")), []byte(h.Token)) != 1 {
+		logboek.Debug().LogF("BearerTokenAuthHandler -- bad bearer token in request %s %q\n", r.Method, r.URL.Path)
+		http.Error(w, "Forbidden: bad bearer token", http.StatusForbidden)
+		return
+	}
+
+	h.Handler.ServeHTTP(w, r)
+}
+
+type HttpClientOptions struct {
+	Token string
+
+	// TLSCACertFile is used to verify synchronization server certificate instead of system CA certificates
+	TLSCACertFile string
+	// TLSClientCertFile and TLSClientKeyFile are used for mutual TLS
+	TLSClientCertFile string
+	TLSClientKeyFile  string
+}
+
+func NewHttpClient(opts HttpClientOptions) (*http.Client, error) {
+	if opts.TLSCACertFile == "" && opts.TLSClientCertFile == "" && opts.TLSClientKeyFile == "" && opts.Token == "" {
+		return &http.Client{}, nil
+	}
+
+	if (opts.TLSClientCertFile == "") != (opts.TLSClientKeyFile == "") {
+		return nil, fmt.Errorf("both tls client certificate and tls client key should be specified")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.TLSCACertFile != "" {
+		if pool, err := loadCertPool(opts.TLSCACertFile); err != nil {
+			return nil, err
+		} else {
+			transport.TLSClientConfig.RootCAs = pool
+		}
+	}
+
+	if opts.TLSClientCertFile != "" {
+		if cert, err := tls.LoadX509KeyPair(opts.TLSClientCertFile, opts.TLSClientKeyFile); err != nil {
+			return nil, fmt.Errorf("unable to load tls client certificate %s and key %s: %s", opts.TLSClientCertFile, opts.TLSClientKeyFile, err)
+		} else {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if opts.Token != "" {
+		roundTripper = &bearerTokenRoundTripper{Token: opts.Token, Base: transport}
+	}
+
+	return &http.Client{Transport: roundTripper}, nil
+}
+
+type bearerTokenRoundTripper struct {
+	Token string
+	Base  http.RoundTripper
+}
+
+func (rt *bearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	newReq := req.Clone(req.Context())
+	newReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rt.Token))
+	return rt.Base.RoundTrip(newReq)
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA certificate %s: %s", caFile, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid PEM certificates found in %s", caFile)
+	}
+	return pool, nil
+}
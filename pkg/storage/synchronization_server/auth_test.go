@@ -0,0 +1,47 @@
+package synchronization_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearerTokenAuthHandler(t *testing.T) {
+	handler := NewBearerTokenAuthHandler("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	checkBearerTokenAuthStatus(t, server.URL+"/client-id/locker/acquire", HttpClientOptions{Token: "secret"}, http.StatusOK)
+	checkBearerTokenAuthStatus(t, server.URL+"/client-id/locker/acquire", HttpClientOptions{}, http.StatusUnauthorized)
+	checkBearerTokenAuthStatus(t, server.URL+"/client-id/locker/acquire", HttpClientOptions{Token: "bad"}, http.StatusForbidden)
+	checkBearerTokenAuthStatus(t, server.URL+"/health", HttpClientOptions{}, http.StatusOK)
+}
+
+func TestNewHttpClient(t *testing.T) {
+	if _, err := NewHttpClient(HttpClientOptions{TLSClientCertFile: "client.crt"}); err == nil {
+		t.Errorf("expected error when tls client key is not specified")
+	}
+
+	if _, err := NewHttpClient(HttpClientOptions{TLSCACertFile: "/nonexistent/ca.crt"}); err == nil {
+		t.Errorf("expected error when CA certificate file does not exist")
+	}
+}
+
+func checkBearerTokenAuthStatus(t *testing.T, url string, opts HttpClientOptions, expectedStatus int) {
+	client, err := NewHttpClient(opts)
+	if err != nil {
+		t.Fatalf("unable to create http client: %s", err)
+	}
+
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("error requesting %s: %s", url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		t.Errorf("%s with token %q: expected status %d, got %d", url, opts.Token, expectedStatus, resp.StatusCode)
+	}
+}
@@ -0,0 +1,108 @@
+package synchronization_server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+)
+
+func NewDistributedLockerBackendWithMetrics(backend distributed_locker.DistributedLockerBackend, metrics *SynchronizationServerMetrics) *DistributedLockerBackendWithMetrics {
+	return &DistributedLockerBackendWithMetrics{
+		DistributedLockerBackend: backend,
+		Metrics:                  metrics,
+		activeLeases:             make(map[string]*activeLease),
+	}
+}
+
+// DistributedLockerBackendWithMetrics observes lock operations of the wrapped backend.
+// Active locks are tracked by the leases acquired through this server process only,
+// lease which has not been renewed in time is not considered active.
+type DistributedLockerBackendWithMetrics struct {
+	distributed_locker.DistributedLockerBackend
+	Metrics *SynchronizationServerMetrics
+
+	mux          sync.Mutex
+	activeLeases map[string]*activeLease
+}
+
+type activeLease struct {
+	HoldersCount int64
+	ExpireAt     time.Time
+}
+
+func (backend *DistributedLockerBackendWithMetrics) Acquire(lockName string, opts distributed_locker.AcquireOptions) (lockgate.LockHandle, error) {
+	startedAt := time.Now()
+
+	handle, err := backend.DistributedLockerBackend.Acquire(lockName, opts)
+	switch {
+	case distributed_locker.IsErrShouldWait(err):
+		backend.Metrics.observeLockAcquire("wait", startedAt)
+	case err != nil:
+		backend.Metrics.observeLockAcquire("error", startedAt)
+		backend.Metrics.observeError("lock-acquire", err)
+	default:
+		backend.Metrics.observeLockAcquire("acquired", startedAt)
+
+		backend.mux.Lock()
+		lease, hasKey := backend.activeLeases[handle.UUID]
+		if !hasKey || time.Now().After(lease.ExpireAt) {
+			lease = &activeLease{}
+			backend.activeLeases[handle.UUID] = lease
+		}
+		lease.HoldersCount++
+		lease.ExpireAt = time.Now().Add(distributed_locker.DistributedLockLeaseTTLSeconds * time.Second)
+		backend.mux.Unlock()
+	}
+
+	return handle, err
+}
+
+func (backend *DistributedLockerBackendWithMetrics) RenewLease(handle lockgate.LockHandle) error {
+	err := backend.DistributedLockerBackend.RenewLease(handle)
+	backend.Metrics.observeError("lock-renew-lease", err)
+
+	if err == nil {
+		backend.mux.Lock()
+		if lease, hasKey := backend.activeLeases[handle.UUID]; hasKey {
+			lease.ExpireAt = time.Now().Add(distributed_locker.DistributedLockLeaseTTLSeconds * time.Second)
+		}
+		backend.mux.Unlock()
+	}
+
+	return err
+}
+
+func (backend *DistributedLockerBackendWithMetrics) Release(handle lockgate.LockHandle) error {
+	err := backend.DistributedLockerBackend.Release(handle)
+	backend.Metrics.observeError("lock-release", err)
+
+	backend.mux.Lock()
+	if lease, hasKey := backend.activeLeases[handle.UUID]; hasKey {
+		lease.HoldersCount--
+		if lease.HoldersCount <= 0 || err != nil {
+			delete(backend.activeLeases, handle.UUID)
+		}
+	}
+	backend.mux.Unlock()
+
+	return err
+}
+
+func (backend *DistributedLockerBackendWithMetrics) ActiveLocksCount() float64 {
+	backend.mux.Lock()
+	defer backend.mux.Unlock()
+
+	var res float64
+	now := time.Now()
+	for uuid, lease := range backend.activeLeases {
+		if now.After(lease.ExpireAt) {
+			delete(backend.activeLeases, uuid)
+			continue
+		}
+		res++
+	}
+
+	return res
+}
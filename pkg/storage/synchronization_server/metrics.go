@@ -0,0 +1,113 @@
+package synchronization_server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const MetricsNamespace = "werf_synchronization_server"
+
+type SynchronizationServerMetrics struct {
+	Registry *prometheus.Registry
+
+	RequestsTotal                    *prometheus.CounterVec
+	ErrorsTotal                      *prometheus.CounterVec
+	LockAcquireDurationSeconds       *prometheus.HistogramVec
+	StagesByDigestCacheRequestsTotal *prometheus.CounterVec
+}
+
+func NewSynchronizationServerMetrics() *SynchronizationServerMetrics {
+	metrics := &SynchronizationServerMetrics{
+		Registry: prometheus.NewRegistry(),
+
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "requests_total",
+			Help:      "Total number of requests by client id, handler and http status code.",
+		}, []string{"client_id", "handler", "code"}),
+
+		ErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "errors_total",
+			Help:      "Total number of errors returned by lock-manager and stages-storage-cache operations by operation.",
+		}, []string{"operation"}),
+
+		LockAcquireDurationSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "lock_acquire_duration_seconds",
+			Help:      "Duration of lock acquire attempts by result (acquired, wait or error).",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		}, []string{"result"}),
+
+		StagesByDigestCacheRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "stages_by_digest_cache_requests_total",
+			Help:      "Total number of stages-storage-cache GetStagesByDigest requests by result (hit or miss).",
+		}, []string{"result"}),
+	}
+
+	metrics.Registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		metrics.RequestsTotal,
+		metrics.ErrorsTotal,
+		metrics.LockAcquireDurationSeconds,
+		metrics.StagesByDigestCacheRequestsTotal,
+	)
+
+	return metrics
+}
+
+func (metrics *SynchronizationServerMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterActiveLocksGauge registers gauge of currently leased locks of all clients.
+// Client id is not used as a label: it is an arbitrary part of the request URL path, which would make metrics cardinality unbounded.
+func (metrics *SynchronizationServerMetrics) RegisterActiveLocksGauge(activeLocksFunc func() float64) {
+	metrics.Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "active_locks",
+		Help:      "Number of currently leased locks.",
+	}, activeLocksFunc))
+}
+
+// InstrumentHandler counts requests of the handler by the client id label, which should be bounded (see SynchronizationServerHandler.clientIDMetricsLabel).
+func (metrics *SynchronizationServerMetrics) InstrumentHandler(clientIDLabel string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w, StatusCode: http.StatusOK}
+		handler.ServeHTTP(sw, r)
+
+		handlerName := strings.TrimPrefix(r.URL.Path, "/")
+		if sw.StatusCode == http.StatusNotFound {
+			// do not produce arbitrary label values for unknown paths
+			handlerName = "unknown"
+		}
+		metrics.RequestsTotal.WithLabelValues(clientIDLabel, handlerName, fmt.Sprintf("%d", sw.StatusCode)).Inc()
+	})
+}
+
+func (metrics *SynchronizationServerMetrics) observeError(operation string, err error) {
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues(operation).Inc()
+	}
+}
+
+func (metrics *SynchronizationServerMetrics) observeLockAcquire(result string, startedAt time.Time) {
+	metrics.LockAcquireDurationSeconds.WithLabelValues(result).Observe(time.Since(startedAt).Seconds())
+}
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	StatusCode int
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	w.StatusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
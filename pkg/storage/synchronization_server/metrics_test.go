@@ -0,0 +1,106 @@
+package synchronization_server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	"github.com/werf/lockgate/pkg/distributed_locker/optimistic_locking_store"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func TestSynchronizationServerMetrics(t *testing.T) {
+	handler := NewSynchronizationServerHandler(func(clientID string) (distributed_locker.DistributedLockerBackend, error) {
+		return distributed_locker.NewOptimisticLockingStorageBasedBackend(optimistic_locking_store.NewInMemoryStore()), nil
+	}, func(clientID string) (storage.StagesStorageCache, error) {
+		return &emptyStagesStorageCache{}, nil
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	clientID, err := NewSynchronizationClient(server.URL, &http.Client{}).NewClientID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locker := distributed_locker.NewHttpBackend(fmt.Sprintf("%s/%s/locker", server.URL, clientID))
+	if _, err := locker.Acquire("lock-1", distributed_locker.AcquireOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.Acquire("lock-1", distributed_locker.AcquireOptions{}); !distributed_locker.IsErrShouldWait(err) {
+		t.Fatalf("expected should wait error, got: %v", err)
+	}
+
+	cache := NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/%s/stages-storage-cache", server.URL, clientID), &http.Client{})
+	if _, _, err := cache.GetStagesByDigest(context.Background(), "project", "digest"); err != nil {
+		t.Fatal(err)
+	}
+
+	// client id which has not been issued by the server is an arbitrary part of the URL path and should be reported as unknown
+	if resp, err := http.Get(server.URL + "/arbitrary-client-id/stages-storage-cache/v1/get-all-stages"); err != nil {
+		t.Fatal(err)
+	} else {
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		fmt.Sprintf(`werf_synchronization_server_requests_total{client_id=%q,code="200",handler="locker/acquire"} 2`, clientID),
+		`client_id="unknown"`,
+		`werf_synchronization_server_lock_acquire_duration_seconds_count{result="acquired"} 1`,
+		`werf_synchronization_server_lock_acquire_duration_seconds_count{result="wait"} 1`,
+		`werf_synchronization_server_active_locks 1`,
+		`werf_synchronization_server_stages_by_digest_cache_requests_total{result="miss"} 1`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", expected, body)
+		}
+	}
+
+	for _, unexpected := range []string{"arbitrary-client-id"} {
+		if strings.Contains(string(body), unexpected) {
+			t.Errorf("expected metrics not to contain %q, got:\n%s", unexpected, body)
+		}
+	}
+}
+
+type emptyStagesStorageCache struct{}
+
+func (cache *emptyStagesStorageCache) String() string { return "empty" }
+
+func (cache *emptyStagesStorageCache) GetAllStages(_ context.Context, _ string) (bool, []image.StageID, error) {
+	return false, nil, nil
+}
+
+func (cache *emptyStagesStorageCache) DeleteAllStages(_ context.Context, _ string) error {
+	return nil
+}
+
+func (cache *emptyStagesStorageCache) GetStagesByDigest(_ context.Context, _, _ string) (bool, []image.StageID, error) {
+	return false, nil, nil
+}
+
+func (cache *emptyStagesStorageCache) StoreStagesByDigest(_ context.Context, _, _ string, _ []image.StageID) error {
+	return nil
+}
+
+func (cache *emptyStagesStorageCache) DeleteStagesByDigest(_ context.Context, _, _ string) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package synchronization_server
+
+import (
+	"context"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func NewStagesStorageCacheWithMetrics(cache storage.StagesStorageCache, metrics *SynchronizationServerMetrics) *StagesStorageCacheWithMetrics {
+	return &StagesStorageCacheWithMetrics{StagesStorageCache: cache, Metrics: metrics}
+}
+
+type StagesStorageCacheWithMetrics struct {
+	storage.StagesStorageCache
+	Metrics *SynchronizationServerMetrics
+}
+
+func (cache *StagesStorageCacheWithMetrics) GetAllStages(ctx context.Context, projectName string) (bool, []image.StageID, error) {
+	found, stages, err := cache.StagesStorageCache.GetAllStages(ctx, projectName)
+	cache.Metrics.observeError("get-all-stages", err)
+	return found, stages, err
+}
+
+func (cache *StagesStorageCacheWithMetrics) DeleteAllStages(ctx context.Context, projectName string) error {
+	err := cache.StagesStorageCache.DeleteAllStages(ctx, projectName)
+	cache.Metrics.observeError("delete-all-stages", err)
+	return err
+}
+
+func (cache *StagesStorageCacheWithMetrics) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	found, stages, err := cache.StagesStorageCache.GetStagesByDigest(ctx, projectName, digest)
+	if err != nil {
+		cache.Metrics.observeError("get-stages-by-digest", err)
+	} else if found {
+		cache.Metrics.StagesByDigestCacheRequestsTotal.WithLabelValues("hit").Inc()
+	} else {
+		cache.Metrics.StagesByDigestCacheRequestsTotal.WithLabelValues("miss").Inc()
+	}
+	return found, stages, err
+}
+
+func (cache *StagesStorageCacheWithMetrics) StoreStagesByDigest(ctx context.Context, projectName, digest string, stages []image.StageID) error {
+	err := cache.StagesStorageCache.StoreStagesByDigest(ctx, projectName, digest, stages)
+	cache.Metrics.observeError("store-stages-by-digest", err)
+	return err
+}
+
+func (cache *StagesStorageCacheWithMetrics) DeleteStagesByDigest(ctx context.Context, projectName, digest string) error {
+	err := cache.StagesStorageCache.DeleteStagesByDigest(ctx, projectName, digest)
+	cache.Metrics.observeError("delete-stages-by-digest", err)
+	return err
+}
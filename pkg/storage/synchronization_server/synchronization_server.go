@@ -17,9 +17,29 @@ import (
 	"github.com/werf/werf/pkg/storage"
 )
 
-func RunSynchronizationServer(_ context.Context, ip, port string, distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error)) error {
-	handler := NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc)
-	return http.ListenAndServe(fmt.Sprintf("%s:%s", ip, port), handler)
+func RunSynchronizationServer(_ context.Context, ip, port string, distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error), opts SynchronizationServerOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	var handler http.Handler = NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc)
+	if opts.Token != "" {
+		handler = NewBearerTokenAuthHandler(opts.Token, handler)
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf("%s:%s", ip, port), Handler: handler}
+
+	if !opts.IsTLSEnabled() {
+		return server.ListenAndServe()
+	}
+
+	if tlsConfig, err := opts.GetTLSConfig(); err != nil {
+		return err
+	} else {
+		server.TLSConfig = tlsConfig
+	}
+
+	return server.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
 }
 
 type SynchronizationServerHandler struct {
@@ -27,9 +47,11 @@ type SynchronizationServerHandler struct {
 
 	DistributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error)
 	StagesStorageCacheFactoryFunc       func(clientID string) (storage.StagesStorageCache, error)
+	Metrics                             *SynchronizationServerMetrics
 
 	mux                             sync.Mutex
 	SynchronizationServerByClientID map[string]*SynchronizationServerHandlerByClientID
+	issuedClientIDs                 map[string]bool
 }
 
 func NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(requestID string) (storage.StagesStorageCache, error)) *SynchronizationServerHandler {
@@ -37,9 +59,13 @@ func NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc func(cl
 		ServeMux:                            http.NewServeMux(),
 		DistributedLockerBackendFactoryFunc: distributedLockerBackendFactoryFunc,
 		StagesStorageCacheFactoryFunc:       stagesStorageCacheFactoryFunc,
+		Metrics:                             NewSynchronizationServerMetrics(),
 		SynchronizationServerByClientID:     make(map[string]*SynchronizationServerHandlerByClientID),
+		issuedClientIDs:                     make(map[string]bool),
 	}
+	srv.Metrics.RegisterActiveLocksGauge(srv.activeLocksCount)
 	srv.HandleFunc("/health", srv.handleHealth)
+	srv.Handle("/metrics", srv.Metrics.Handler())
 	srv.HandleFunc("/new-client-id", srv.handleNewClientID)
 	srv.HandleFunc("/", srv.handleRequestByClientID)
 	return srv
@@ -78,6 +104,11 @@ func (server *SynchronizationServerHandler) handleNewClientID(w http.ResponseWri
 	HandleRequest(w, r, &request, &response, func() {
 		logboek.Debug().LogF("SynchronizationServerHandler -- NewClientID request %#v\n", request)
 		response.ClientID = uuid.New().String()
+
+		server.mux.Lock()
+		server.issuedClientIDs[response.ClientID] = true
+		server.mux.Unlock()
+
 		logboek.Debug().LogF("SynchronizationServerHandler -- NewClientID response %#v\n", response)
 	})
 }
@@ -119,14 +150,31 @@ func (server *SynchronizationServerHandler) handleRequestByClientID(w http.Respo
 		return
 	}
 
+	clientIDLabel := server.clientIDMetricsLabel(clientID)
+
 	if clientServer, err := server.getOrCreateHandlerByClientID(clientID); err != nil {
 		http.Error(w, fmt.Sprintf("Internal error: %s", err), http.StatusInternalServerError)
 		return
 	} else {
-		http.StripPrefix(fmt.Sprintf("/%s", clientID), clientServer).ServeHTTP(w, r)
+		http.StripPrefix(fmt.Sprintf("/%s", clientID), server.Metrics.InstrumentHandler(clientIDLabel, clientServer)).ServeHTTP(w, r)
 	}
 }
 
+// clientIDMetricsLabel returns client id only when it has been issued by the server or is already known,
+// any other client id from the request URL path is reported as "unknown" to keep metrics cardinality bounded.
+func (server *SynchronizationServerHandler) clientIDMetricsLabel(clientID string) string {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+
+	if server.issuedClientIDs[clientID] {
+		return clientID
+	}
+	if _, hasKey := server.SynchronizationServerByClientID[clientID]; hasKey {
+		return clientID
+	}
+	return "unknown"
+}
+
 func (server *SynchronizationServerHandler) getOrCreateHandlerByClientID(clientID string) (*SynchronizationServerHandlerByClientID, error) {
 	server.mux.Lock()
 	defer server.mux.Unlock()
@@ -144,7 +192,7 @@ func (server *SynchronizationServerHandler) getOrCreateHandlerByClientID(clientI
 			return nil, fmt.Errorf("unable to create stages storage cache for clientID %q: %s", clientID, err)
 		}
 
-		handler := NewSynchronizationServerHandlerByClientID(clientID, distributedLockerBackend, stagesStorageCache)
+		handler := NewSynchronizationServerHandlerByClientID(clientID, NewDistributedLockerBackendWithMetrics(distributedLockerBackend, server.Metrics), NewStagesStorageCacheWithMetrics(stagesStorageCache, server.Metrics))
 		server.SynchronizationServerByClientID[clientID] = handler
 
 		logboek.Debug().LogF("SynchronizationServerHandler -- Created new synchronization server handler by clientID %q: %v\n", clientID, handler)
@@ -152,6 +200,20 @@ func (server *SynchronizationServerHandler) getOrCreateHandlerByClientID(clientI
 	}
 }
 
+// activeLocksCount sums active locks of all clients
+func (server *SynchronizationServerHandler) activeLocksCount() float64 {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+
+	var res float64
+	for _, handler := range server.SynchronizationServerByClientID {
+		if backend, ok := handler.DistributedLockerBackend.(*DistributedLockerBackendWithMetrics); ok {
+			res += backend.ActiveLocksCount()
+		}
+	}
+	return res
+}
+
 type SynchronizationServerHandlerByClientID struct {
 	*http.ServeMux
 	ClientID string
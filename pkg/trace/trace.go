@@ -0,0 +1,173 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+const (
+	ImageCategory    = "image"
+	StageCategory    = "stage"
+	DigestCategory   = "digest"
+	RegistryCategory = "registry"
+	LockCategory     = "lock"
+	FetchCategory    = "fetch"
+	BuildCategory    = "build"
+	PushCategory     = "push"
+)
+
+// Tracer collects spans of werf operations and writes them in the Chrome trace event format
+// (https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU), which can be opened with chrome://tracing or https://ui.perfetto.dev.
+// Spans of concurrent operations, for example images built in parallel, are placed on separate tracks.
+type Tracer struct {
+	mux         sync.Mutex
+	startedAt   time.Time
+	events      []*event
+	lastTrackID int64
+}
+
+type event struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat,omitempty"`
+	Phase     string            `json:"ph"`
+	Timestamp int64             `json:"ts"`
+	Duration  int64             `json:"dur"`
+	ProcessID int64             `json:"pid"`
+	ThreadID  int64             `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+type Span struct {
+	tracer    *Tracer
+	event     *event
+	startedAt time.Time
+}
+
+// processID is the same for all events, so all tracks are shown within a single process
+const processID = 1
+
+type tracerContextKey struct{}
+type trackContextKey struct{}
+
+func NewTracer() *Tracer {
+	return &Tracer{startedAt: time.Now()}
+}
+
+func NewContext(ctx context.Context, tracer *Tracer) context.Context {
+	ctx = context.WithValue(ctx, tracerContextKey{}, tracer)
+	return tracer.withNewTrack(ctx, "werf")
+}
+
+func getTracer(ctx context.Context) *Tracer {
+	if tracer, ok := ctx.Value(tracerContextKey{}).(*Tracer); ok {
+		return tracer
+	}
+	return nil
+}
+
+// WithNewTrack returns context which places spans on a separate named track
+func WithNewTrack(ctx context.Context, name string) context.Context {
+	if tracer := getTracer(ctx); tracer != nil {
+		return tracer.withNewTrack(ctx, name)
+	}
+	return ctx
+}
+
+func (tracer *Tracer) withNewTrack(ctx context.Context, name string) context.Context {
+	tracer.mux.Lock()
+	defer tracer.mux.Unlock()
+
+	tracer.lastTrackID++
+	tracer.events = append(tracer.events, &event{
+		Name:      "thread_name",
+		Phase:     "M",
+		ProcessID: processID,
+		ThreadID:  tracer.lastTrackID,
+		Args:      map[string]string{"name": name},
+	})
+
+	return context.WithValue(ctx, trackContextKey{}, tracer.lastTrackID)
+}
+
+// StartSpan starts the span on the track of the context, args are key-value pairs.
+// The span is a no-op when the context has no tracer.
+func StartSpan(ctx context.Context, category, name string, args ...string) *Span {
+	tracer := getTracer(ctx)
+	if tracer == nil {
+		return nil
+	}
+
+	trackID, _ := ctx.Value(trackContextKey{}).(int64)
+
+	e := &event{
+		Name:      name,
+		Category:  category,
+		Phase:     "X",
+		ProcessID: processID,
+		ThreadID:  trackID,
+	}
+
+	if len(args) > 0 {
+		e.Args = make(map[string]string)
+		for i := 0; i+1 < len(args); i += 2 {
+			e.Args[args[i]] = args[i+1]
+		}
+	}
+
+	return &Span{tracer: tracer, event: e, startedAt: time.Now()}
+}
+
+func (span *Span) SetArg(key, value string) {
+	if span == nil {
+		return
+	}
+
+	if span.event.Args == nil {
+		span.event.Args = make(map[string]string)
+	}
+	span.event.Args[key] = value
+}
+
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+
+	span.event.Timestamp = span.startedAt.Sub(span.tracer.startedAt).Microseconds()
+	span.event.Duration = time.Since(span.startedAt).Microseconds()
+
+	span.tracer.mux.Lock()
+	defer span.tracer.mux.Unlock()
+	span.tracer.events = append(span.tracer.events, span.event)
+}
+
+// EndWithError ends the span and records the error if any
+func (span *Span) EndWithError(err error) {
+	if err != nil {
+		span.SetArg("error", err.Error())
+	}
+	span.End()
+}
+
+func (tracer *Tracer) WriteChromeTraceFile(path string) error {
+	tracer.mux.Lock()
+	data, err := json.Marshal(map[string]interface{}{
+		"traceEvents":     tracer.events,
+		"displayTimeUnit": "ms",
+	})
+	tracer.mux.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	if span := StartSpan(context.Background(), BuildCategory, "no tracer"); span != nil {
+		t.Fatalf("expected no-op span without tracer")
+	}
+
+	tracer := NewTracer()
+	ctx := NewContext(context.Background(), tracer)
+
+	imageCtx := WithNewTrack(ctx, "image")
+	imageSpan := StartSpan(imageCtx, ImageCategory, "image", "key", "value")
+	StartSpan(imageCtx, BuildCategory, "build").EndWithError(errors.New("failed"))
+	imageSpan.End()
+
+	dir, err := ioutil.TempDir("", "werf-trace-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "trace.json")
+	if err := tracer.WriteChromeTraceFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		TraceEvents []event `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.TraceEvents) != 4 {
+		t.Fatalf("expected 2 track names and 2 spans, got %+v", res.TraceEvents)
+	}
+
+	build, image := res.TraceEvents[2], res.TraceEvents[3]
+	if build.Name != "build" || build.Phase != "X" || build.Args["error"] != "failed" {
+		t.Errorf("unexpected build span %+v", build)
+	}
+	if image.Name != "image" || image.Args["key"] != "value" || image.ThreadID != build.ThreadID || image.ThreadID != res.TraceEvents[1].ThreadID {
+		t.Errorf("unexpected image span %+v", image)
+	}
+	if image.Timestamp > build.Timestamp || image.Timestamp+image.Duration < build.Timestamp+build.Duration {
+		t.Errorf("expected image span %+v to contain build span %+v", image, build)
+	}
+}
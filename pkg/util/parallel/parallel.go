@@ -23,22 +23,37 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 		return nil
 	}
 
+	numberOfWorkers := getNumberOfWorkers(numberOfTasks, options)
+	return doTasks(ctx, numberOfTasks, numberOfWorkers, newStaticTaskQueue(numberOfTasks, numberOfWorkers), options, taskFunc)
+}
+
+// DoTasksWithDependencies runs each task as soon as all tasks it depends on are done.
+// The dependencies[taskId] list contains ids of tasks which should be done before the task taskId.
+// No new tasks are started after the first failed task.
+func DoTasksWithDependencies(ctx context.Context, numberOfTasks int, dependencies [][]int, options DoTasksOptions, taskFunc func(ctx context.Context, taskId int) error) error {
+	if numberOfTasks == 0 {
+		return nil
+	}
+
+	queue, err := newDependencyTaskQueue(numberOfTasks, dependencies)
+	if err != nil {
+		return err
+	}
+
+	numberOfWorkers := getNumberOfWorkers(numberOfTasks, options)
+	return doTasks(ctx, numberOfTasks, numberOfWorkers, queue, options, taskFunc)
+}
+
+func getNumberOfWorkers(numberOfTasks int, options DoTasksOptions) int {
 	numberOfWorkers := options.MaxNumberOfWorkers
 	if numberOfWorkers <= 0 || numberOfWorkers > numberOfTasks {
 		numberOfWorkers = numberOfTasks
 	}
 
-	var numberOfTasksPerWorker []int
-	for i := 0; i < numberOfWorkers; i++ {
-		workerNumberOfTasks := numberOfTasks / numberOfWorkers
-		rest := numberOfTasks % numberOfWorkers
-		if rest > i {
-			workerNumberOfTasks += 1
-		}
-
-		numberOfTasksPerWorker = append(numberOfTasksPerWorker, workerNumberOfTasks)
-	}
+	return numberOfWorkers
+}
 
+func doTasks(ctx context.Context, numberOfTasks, numberOfWorkers int, queue taskQueue, options DoTasksOptions, taskFunc func(ctx context.Context, taskId int) error) error {
 	errCh := make(chan interface{})
 	doneTaskCh := make(chan interface{})
 	doneWorkerCh := make(chan worker)
@@ -93,18 +108,22 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 		}
 
 		go func() {
-			workerNumberOfTasks := numberOfTasksPerWorker[workerId]
+			for {
+				taskId, ok := queue.Next(workerId)
+				if !ok {
+					break
+				}
 
-			for workerTaskId := 0; workerTaskId < workerNumberOfTasks; workerTaskId++ {
-				taskId := calculateTaskId(numberOfTasks, numberOfWorkers, workerId, workerTaskId)
 				if debug() {
-					logboek.Context(workerContext).LogF("Running worker %d task %d/%d (%d)\n", workerId, workerTaskId+1, workerNumberOfTasks, numberOfTasks)
+					logboek.Context(workerContext).LogF("Running worker %d task %d (%d)\n", workerId, taskId, numberOfTasks)
 				}
 				err := taskFunc(workerContext, taskId)
 
 				ch := doneTaskCh
 				if err != nil {
 					ch = errCh
+				} else {
+					queue.Done(taskId)
 				}
 
 				select {
@@ -117,7 +136,10 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 				}
 			}
 
-			doneWorkerCh <- worker
+			select {
+			case doneWorkerCh <- worker:
+			case <-quitCh:
+			}
 		}()
 	}
 
@@ -134,6 +156,7 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 			}
 		case res := <-errCh:
 			close(quitCh)
+			queue.Stop()
 
 			switch taskResult := res.(type) {
 			case *bufWorkerTaskResult:
@@ -188,21 +211,6 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 	}
 }
 
-func calculateTaskId(tasksNumber, workersNumber, workerInd, workerTaskId int) int {
-	taskId := workerInd*(tasksNumber/workersNumber) + workerTaskId
-
-	rest := tasksNumber % workersNumber
-	if rest != 0 {
-		if rest > workerInd {
-			taskId += workerInd
-		} else {
-			taskId += rest
-		}
-	}
-
-	return taskId
-}
-
 func processTaskResultData(ctx context.Context, data []byte) {
 	if len(data) == 0 { // TODO: fix in logboek
 		return
@@ -0,0 +1,210 @@
+package parallel
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type taskQueue interface {
+	// Next returns the next task for the worker or false when the worker should stop
+	Next(workerId int) (int, bool)
+	Done(taskId int)
+	Stop()
+}
+
+// staticTaskQueue distributes tasks between workers evenly in advance, each worker gets a continuous range of tasks
+type staticTaskQueue struct {
+	numberOfTasks          int
+	numberOfWorkers        int
+	numberOfTasksPerWorker []int
+	workersTaskCounters    []int
+}
+
+func newStaticTaskQueue(numberOfTasks, numberOfWorkers int) *staticTaskQueue {
+	queue := &staticTaskQueue{
+		numberOfTasks:       numberOfTasks,
+		numberOfWorkers:     numberOfWorkers,
+		workersTaskCounters: make([]int, numberOfWorkers),
+	}
+
+	for i := 0; i < numberOfWorkers; i++ {
+		workerNumberOfTasks := numberOfTasks / numberOfWorkers
+		rest := numberOfTasks % numberOfWorkers
+		if rest > i {
+			workerNumberOfTasks += 1
+		}
+
+		queue.numberOfTasksPerWorker = append(queue.numberOfTasksPerWorker, workerNumberOfTasks)
+	}
+
+	return queue
+}
+
+func (queue *staticTaskQueue) Next(workerId int) (int, bool) {
+	workerTaskId := queue.workersTaskCounters[workerId]
+	if workerTaskId >= queue.numberOfTasksPerWorker[workerId] {
+		return 0, false
+	}
+
+	queue.workersTaskCounters[workerId]++
+
+	return calculateTaskId(queue.numberOfTasks, queue.numberOfWorkers, workerId, workerTaskId), true
+}
+
+func (queue *staticTaskQueue) Done(_ int) {}
+
+func (queue *staticTaskQueue) Stop() {}
+
+func calculateTaskId(tasksNumber, workersNumber, workerInd, workerTaskId int) int {
+	taskId := workerInd*(tasksNumber/workersNumber) + workerTaskId
+
+	rest := tasksNumber % workersNumber
+	if rest != 0 {
+		if rest > workerInd {
+			taskId += workerInd
+		} else {
+			taskId += rest
+		}
+	}
+
+	return taskId
+}
+
+// dependencyTaskQueue gives a task to any free worker as soon as all dependencies of the task are done.
+// Ready tasks are given in the ascending order of ids.
+type dependencyTaskQueue struct {
+	mux  sync.Mutex
+	cond *sync.Cond
+
+	dependents                  [][]int
+	numberOfPendingDependencies []int
+	readyTasks                  []int
+	numberOfNotStartedTasks     int
+	isStopped                   bool
+}
+
+func newDependencyTaskQueue(numberOfTasks int, dependencies [][]int) (*dependencyTaskQueue, error) {
+	queue := &dependencyTaskQueue{
+		dependents:                  make([][]int, numberOfTasks),
+		numberOfPendingDependencies: make([]int, numberOfTasks),
+		numberOfNotStartedTasks:     numberOfTasks,
+	}
+	queue.cond = sync.NewCond(&queue.mux)
+
+	for taskId := 0; taskId < numberOfTasks; taskId++ {
+		if taskId >= len(dependencies) {
+			break
+		}
+
+		isDependencyAdded := map[int]bool{}
+		for _, dependencyTaskId := range dependencies[taskId] {
+			if dependencyTaskId < 0 || dependencyTaskId >= numberOfTasks {
+				return nil, fmt.Errorf("task %d depends on unknown task %d", taskId, dependencyTaskId)
+			}
+
+			if dependencyTaskId == taskId || isDependencyAdded[dependencyTaskId] {
+				continue
+			}
+			isDependencyAdded[dependencyTaskId] = true
+
+			queue.dependents[dependencyTaskId] = append(queue.dependents[dependencyTaskId], taskId)
+			queue.numberOfPendingDependencies[taskId]++
+		}
+	}
+
+	for taskId := 0; taskId < numberOfTasks; taskId++ {
+		if queue.numberOfPendingDependencies[taskId] == 0 {
+			queue.readyTasks = append(queue.readyTasks, taskId)
+		}
+	}
+
+	if err := queue.checkCycles(); err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+func (queue *dependencyTaskQueue) checkCycles() error {
+	numberOfPendingDependencies := append([]int{}, queue.numberOfPendingDependencies...)
+	readyTasks := append([]int{}, queue.readyTasks...)
+
+	numberOfVisitedTasks := 0
+	for len(readyTasks) != 0 {
+		taskId := readyTasks[0]
+		readyTasks = readyTasks[1:]
+		numberOfVisitedTasks++
+
+		for _, dependentTaskId := range queue.dependents[taskId] {
+			numberOfPendingDependencies[dependentTaskId]--
+			if numberOfPendingDependencies[dependentTaskId] == 0 {
+				readyTasks = append(readyTasks, dependentTaskId)
+			}
+		}
+	}
+
+	if numberOfVisitedTasks != len(queue.dependents) {
+		var taskIds []int
+		for taskId, n := range numberOfPendingDependencies {
+			if n != 0 {
+				taskIds = append(taskIds, taskId)
+			}
+		}
+
+		return fmt.Errorf("tasks %v have cyclic dependencies", taskIds)
+	}
+
+	return nil
+}
+
+func (queue *dependencyTaskQueue) Next(_ int) (int, bool) {
+	queue.mux.Lock()
+	defer queue.mux.Unlock()
+
+	for len(queue.readyTasks) == 0 && queue.numberOfNotStartedTasks != 0 && !queue.isStopped {
+		queue.cond.Wait()
+	}
+
+	if queue.isStopped || len(queue.readyTasks) == 0 {
+		return 0, false
+	}
+
+	taskId := queue.readyTasks[0]
+	queue.readyTasks = queue.readyTasks[1:]
+	queue.numberOfNotStartedTasks--
+
+	if queue.numberOfNotStartedTasks == 0 {
+		// wake up idle workers to let them finish
+		queue.cond.Broadcast()
+	}
+
+	return taskId, true
+}
+
+func (queue *dependencyTaskQueue) Done(taskId int) {
+	queue.mux.Lock()
+	defer queue.mux.Unlock()
+
+	isReadyTasksChanged := false
+	for _, dependentTaskId := range queue.dependents[taskId] {
+		queue.numberOfPendingDependencies[dependentTaskId]--
+		if queue.numberOfPendingDependencies[dependentTaskId] == 0 {
+			queue.readyTasks = append(queue.readyTasks, dependentTaskId)
+			isReadyTasksChanged = true
+		}
+	}
+
+	if isReadyTasksChanged {
+		sort.Ints(queue.readyTasks)
+		queue.cond.Broadcast()
+	}
+}
+
+func (queue *dependencyTaskQueue) Stop() {
+	queue.mux.Lock()
+	defer queue.mux.Unlock()
+
+	queue.isStopped = true
+	queue.cond.Broadcast()
+}
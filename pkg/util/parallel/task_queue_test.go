@@ -0,0 +1,75 @@
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDoTasksWithDependencies(t *testing.T) {
+	// 0 <- 2 <- 4, 1 <- 3, 2 and 3 <- 5
+	dependencies := [][]int{{}, {}, {0}, {1}, {2}, {2, 3}}
+
+	var mux sync.Mutex
+	isDone := map[int]bool{}
+
+	err := DoTasksWithDependencies(context.Background(), len(dependencies), dependencies, DoTasksOptions{MaxNumberOfWorkers: 3}, func(ctx context.Context, taskId int) error {
+		mux.Lock()
+		defer mux.Unlock()
+
+		for _, dependencyTaskId := range dependencies[taskId] {
+			if !isDone[dependencyTaskId] {
+				return fmt.Errorf("task %d started before dependency %d is done", taskId, dependencyTaskId)
+			}
+		}
+		isDone[taskId] = true
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(isDone) != len(dependencies) {
+		t.Fatalf("expected %d done tasks, got %d", len(dependencies), len(isDone))
+	}
+}
+
+func TestDoTasksWithDependencies_StopsOnError(t *testing.T) {
+	dependencies := [][]int{{}, {0}, {1}}
+
+	var mux sync.Mutex
+	var startedTasks []int
+
+	err := DoTasksWithDependencies(context.Background(), len(dependencies), dependencies, DoTasksOptions{MaxNumberOfWorkers: 2}, func(ctx context.Context, taskId int) error {
+		mux.Lock()
+		startedTasks = append(startedTasks, taskId)
+		mux.Unlock()
+
+		if taskId == 1 {
+			return fmt.Errorf("task failed")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, taskId := range startedTasks {
+		if taskId == 2 {
+			t.Fatal("task depending on the failed task should not be started")
+		}
+	}
+}
+
+func TestDoTasksWithDependencies_Cycle(t *testing.T) {
+	dependencies := [][]int{{2}, {0}, {1}}
+
+	err := DoTasksWithDependencies(context.Background(), len(dependencies), dependencies, DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected cyclic dependencies error")
+	}
+}